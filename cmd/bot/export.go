@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/db"
+	"github.com/skyzeper/telegram-bot/internal/services/accounting"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// exportMain runs the export subcommand and returns the process exit code once the database is closed
+func exportMain(args []string) int {
+	cfg := utils.LoadToolConfig()
+	utils.SetLogFile(cfg.ErrorLogFile)
+
+	dbConn, err := db.NewDB(dbConfig(cfg))
+	if err != nil {
+		utils.LogError(fmt.Errorf("failed to initialize database: %v", err))
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer dbConn.Close()
+
+	if err := runExport(args, cfg, dbConn); err != nil {
+		utils.LogError(fmt.Errorf("export failed: %v", err))
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// runExport writes the payments and expenses of a period for accounting software, e.g.
+//
+//	bot export -format 1c -from 01.03.2024 -to 31.03.2024 -out kl_to_1c.txt
+//
+// Without dates the previous month is exported; without -out the file goes to stdout.
+func runExport(args []string, cfg *utils.Config, dbConn *db.DB) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", accounting.FormatCSV, "export format: csv or 1c")
+	fromArg := fs.String("from", "", "first day of the period, DD.MM.YYYY")
+	toArg := fs.String("to", "", "last day of the period, DD.MM.YYYY")
+	out := fs.String("out", "", "output file, stdout by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	loc := businessLocation(cfg)
+	now := time.Now().In(loc)
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	from := to.AddDate(0, -1, 0)
+	if *fromArg != "" || *toArg != "" {
+		start, err1 := time.ParseInLocation("02.01.2006", *fromArg, loc)
+		end, err2 := time.ParseInLocation("02.01.2006", *toArg, loc)
+		if err1 != nil || err2 != nil || end.Before(start) {
+			return fmt.Errorf("both -from and -to are required as DD.MM.YYYY, -to not before -from")
+		}
+		from, to = start, end.AddDate(0, 0, 1)
+	}
+
+	accountingService := accounting.NewService(accounting.NewPostgresRepository(dbConn))
+	export, err := accountingService.GetExport(from, to)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	var file *os.File
+	if *out != "" {
+		file, err = os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create export file: %v", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	exportCfg := accounting.ExportConfig{
+		CompanyName: cfg.AccountingCompanyName,
+		INN:         cfg.AccountingCompanyINN,
+		BankAccount: cfg.AccountingBankAccount,
+	}
+	if err := accounting.WriteExport(w, *format, export, exportCfg); err != nil {
+		return err
+	}
+	// A failed close can lose buffered data, so the export only succeeds once the file is closed
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write export file: %v", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "exported %d records for %s — %s\n",
+		len(export.Records), from.Format("02.01.2006"), to.AddDate(0, 0, -1).Format("02.01.2006"))
+	return nil
+}
@@ -2,7 +2,11 @@ package main
 
 import (
 	"fmt"
+	"net/http"
+	"os"
+	"time"
 	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/config"
 	"github.com/skyzeper/telegram-bot/internal/db"
 	"github.com/skyzeper/telegram-bot/internal/handlers"
 	"github.com/skyzeper/telegram-bot/internal/handlers/callbacks"
@@ -10,12 +14,22 @@ import (
 	"github.com/skyzeper/telegram-bot/internal/security"
 	"github.com/skyzeper/telegram-bot/internal/services/accounting"
 	"github.com/skyzeper/telegram-bot/internal/services/chat"
+	"github.com/skyzeper/telegram-bot/internal/services/digest"
+	"github.com/skyzeper/telegram-bot/internal/services/escalation"
 	"github.com/skyzeper/telegram-bot/internal/services/executor"
+	"github.com/skyzeper/telegram-bot/internal/services/expense"
+	"github.com/skyzeper/telegram-bot/internal/services/fiscal"
+	"github.com/skyzeper/telegram-bot/internal/services/fleet"
+	"github.com/skyzeper/telegram-bot/internal/services/geo"
 	"github.com/skyzeper/telegram-bot/internal/services/notification"
 	"github.com/skyzeper/telegram-bot/internal/services/order"
 	"github.com/skyzeper/telegram-bot/internal/services/payment"
+	"github.com/skyzeper/telegram-bot/internal/services/payroll"
+	"github.com/skyzeper/telegram-bot/internal/services/report"
 	"github.com/skyzeper/telegram-bot/internal/services/referral"
 	"github.com/skyzeper/telegram-bot/internal/services/review"
+	"github.com/skyzeper/telegram-bot/internal/services/route"
+	"github.com/skyzeper/telegram-bot/internal/services/tracking"
 	"github.com/skyzeper/telegram-bot/internal/services/stats"
 	"github.com/skyzeper/telegram-bot/internal/services/user"
 	"github.com/skyzeper/telegram-bot/internal/state"
@@ -23,22 +37,21 @@ import (
 )
 
 func main() {
+	// Subcommands run without the bot and need only the database
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		os.Exit(exportMain(os.Args[2:]))
+	}
+
 	// Load configuration
 	cfg, err := utils.LoadConfig()
 	if err != nil {
 		utils.LogError(fmt.Errorf("failed to load config: %v", err))
 		return
 	}
+	utils.SetLogFile(cfg.ErrorLogFile)
 
 	// Initialize database
-	dbCfg := db.Config{
-		Host:     cfg.DBHost,
-		Port:     cfg.DBPort,
-		User:     cfg.DBUser,
-		Password: cfg.DBPassword,
-		DBName:   cfg.DBName,
-	}
-	dbConn, err := db.NewDB(dbCfg)
+	dbConn, err := db.NewDB(dbConfig(cfg))
 	if err != nil {
 		utils.LogError(fmt.Errorf("failed to initialize database: %v", err))
 		return
@@ -60,13 +73,96 @@ func main() {
 	userService := user.NewService(user.NewPostgresRepository(dbConn))
 	orderService := order.NewService(order.NewPostgresRepository(dbConn))
 	executorService := executor.NewService(executor.NewPostgresRepository(dbConn))
-	paymentService := payment.NewService(payment.NewPostgresRepository(dbConn))
-	reviewService := review.NewService(review.NewPostgresRepository(dbConn))
-	referralService := referral.NewService(referral.NewPostgresRepository(dbConn))
+	var fakeProvider *payment.FakeProvider
+	paymentCfg := payment.Config{
+		ProviderToken: cfg.PaymentProviderToken,
+		Currency:      cfg.PaymentCurrency,
+	}
+	switch cfg.PaymentLinkProvider {
+	case "":
+	case "fake":
+		fakeProvider = payment.NewFakeProvider(cfg.PaymentWebhookSecret, cfg.PaymentPublicURL)
+		paymentCfg.Provider = fakeProvider
+	default:
+		utils.LogError(fmt.Errorf("unknown payment link provider: %s", cfg.PaymentLinkProvider))
+	}
+	paymentService := payment.NewService(bot, payment.NewPostgresRepository(dbConn), paymentCfg)
+	fiscalCfg := fiscal.Config{VATMode: cfg.FiscalVATMode}
+	if cfg.FiscalVATMode != "" && !fiscal.ValidVATMode(cfg.FiscalVATMode) {
+		utils.LogError(fmt.Errorf("unknown fiscal VAT mode: %s", cfg.FiscalVATMode))
+		fiscalCfg.VATMode = ""
+	}
+	switch cfg.FiscalProvider {
+	case "":
+		// Receipts are not issued without a fiscal provider
+	case "stub":
+		fiscalCfg.Provider = fiscal.NewStubProvider()
+	default:
+		utils.LogError(fmt.Errorf("unknown fiscal provider: %s", cfg.FiscalProvider))
+		return
+	}
+	fiscalService := fiscal.NewService(bot, fiscal.NewPostgresRepository(dbConn), fiscalCfg)
+	reviewService := review.NewService(bot, review.NewPostgresRepository(dbConn), review.Config{
+		ChannelID:           cfg.ReviewChannelID,
+		ModerationMaxRating: cfg.ReviewModerationMaxRating,
+		RequestDelay:        cfg.ReviewRequestDelay,
+		ReminderDelay:       cfg.ReviewReminderDelay,
+	})
+	qrCfg := referral.DefaultQRConfig()
+	if cfg.QRSize > 0 {
+		qrCfg.Size = cfg.QRSize
+	}
+	if level, err := referral.ParseRecoveryLevel(cfg.QRRecoveryLevel); err != nil {
+		utils.LogError(err)
+	} else {
+		qrCfg.RecoveryLevel = level
+	}
+	qrCfg.LogoPath = cfg.QRLogoPath
+	if cfg.QRCaption != "" {
+		qrCfg.Caption = cfg.QRCaption
+	}
+	referralService := referral.NewService(referral.NewPostgresRepository(dbConn), qrCfg)
 	chatService := chat.NewService(bot, chat.NewPostgresRepository(dbConn))
 	notificationService := notification.NewService(bot, notification.NewPostgresRepository(dbConn))
 	accountingService := accounting.NewService(accounting.NewPostgresRepository(dbConn))
-	statsService := stats.NewService(stats.NewPostgresRepository(dbConn))
+	businessLoc := businessLocation(cfg)
+	statsService := stats.NewService(stats.NewPostgresRepository(dbConn), businessLoc)
+	escalationService := escalation.NewService(escalation.NewPostgresRepository(dbConn))
+	expenseService := expense.NewService(expense.NewPostgresRepository(dbConn), accountingService, userService)
+	payrollService := payroll.NewService(bot, payroll.NewPostgresRepository(dbConn))
+	reportService := report.NewService(report.NewPostgresRepository(dbConn))
+	var serviceArea *geo.ServiceArea
+	if cfg.ServiceAreasFile != "" {
+		if serviceArea, err = geo.LoadServiceArea(cfg.ServiceAreasFile); err != nil {
+			utils.LogError(err)
+		}
+	}
+
+	routeCfg := route.Config{
+		DayStart:    cfg.RouteDayStart,
+		ServiceTime: cfg.RouteServiceTime,
+		SlotWindow:  cfg.RouteSlotWindow,
+		Location:    businessLoc,
+	}
+	if cfg.RouteDepot != "" {
+		if depot, err := geo.ParsePoint(cfg.RouteDepot); err != nil {
+			utils.LogError(err)
+		} else {
+			routeCfg.Depot = &depot
+		}
+	}
+	routeMatrix := route.DefaultMatrix()
+	if cfg.RouteAverageSpeed > 0 {
+		routeMatrix = route.StraightLine{Speed: cfg.RouteAverageSpeed, Detour: 1.3}
+	}
+	routeService := route.NewService(route.NewPostgresRepository(dbConn), routeMatrix, routeCfg)
+	trackingService := tracking.NewService(tracking.NewPostgresRepository(dbConn), routeMatrix, tracking.Config{
+		StaleAfter: cfg.TrackingStaleAfter,
+	})
+	fleetService := fleet.NewService(bot, fleet.NewPostgresRepository(dbConn), fleet.Config{
+		ReminderLead:    cfg.FleetReminderLead,
+		ReminderMileage: cfg.FleetReminderMileage,
+	})
 
 	// Initialize security
 	securityChecker := security.NewSecurityChecker(userService)
@@ -77,24 +173,124 @@ func main() {
 	// Initialize callback handlers
 	ordersHandler := callbacks.NewOrdersHandler(
 		bot, securityChecker, menuGenerator, userService, orderService,
-		chatService, executorService, paymentService, reviewService, stateManager,
+		chatService, executorService, paymentService, reviewService, fiscalService, stateManager,
 	)
-	staffHandler := callbacks.NewStaffHandler(bot, securityChecker, menuGenerator, userService, stateManager)
+	staffHandler := callbacks.NewStaffHandler(bot, securityChecker, menuGenerator, userService, reviewService, stateManager)
 	contactHandler := callbacks.NewContactHandler(bot, securityChecker, menuGenerator, chatService, stateManager)
 	referralsHandler := callbacks.NewReferralsHandler(bot, securityChecker, menuGenerator, referralService, userService)
-	reviewsHandler := callbacks.NewReviewsHandler(bot, securityChecker, menuGenerator, reviewService, stateManager)
+	escalationsHandler := callbacks.NewEscalationsHandler(
+		bot, securityChecker, menuGenerator, escalationService, reviewService,
+		orderService, chatService, userService, stateManager,
+	)
+	reviewsHandler := callbacks.NewReviewsHandler(
+		bot, securityChecker, menuGenerator, reviewService, userService, escalationsHandler, stateManager,
+	)
+	debtsHandler := callbacks.NewDebtsHandler(bot, securityChecker, menuGenerator, accountingService)
+	expensesHandler := callbacks.NewExpensesHandler(bot, securityChecker, menuGenerator, expenseService, stateManager)
+	payrollHandler := callbacks.NewPayrollHandler(bot, securityChecker, menuGenerator, payrollService)
+	exportHandler := callbacks.NewExportHandler(bot, securityChecker, accountingService, accounting.ExportConfig{
+		CompanyName: cfg.AccountingCompanyName,
+		INN:         cfg.AccountingCompanyINN,
+		BankAccount: cfg.AccountingBankAccount,
+	})
 	statsHandler := callbacks.NewStatsHandler(bot, securityChecker, menuGenerator, statsService)
+	tripsHandler := callbacks.NewTripsHandler(bot, securityChecker, orderService, trackingService)
+	fleetHandler := callbacks.NewFleetHandler(bot, securityChecker, orderService, fleetService)
 	callbackHandler := callbacks.NewCallbackHandler(
 		bot, securityChecker, menuGenerator, userService, stateManager,
 		ordersHandler, staffHandler, contactHandler, referralsHandler, reviewsHandler, statsHandler,
+		escalationsHandler, debtsHandler, expensesHandler, payrollHandler, exportHandler, tripsHandler,
+		fleetHandler,
 	)
 
 	// Initialize main handler
+	orderSteps := order.NewStepHandler(bot, menuGenerator, orderService, stateManager, serviceArea)
 	mainHandler := handlers.NewHandler(
 		bot, securityChecker, menuGenerator, userService, orderService,
-		chatService, stateManager, callbackHandler, notificationService,
+		chatService, stateManager, callbackHandler, notificationService, referralService,
+		escalationService, paymentService, accountingService, fiscalService, expenseService,
+		payrollService, reportService, statsService, orderSteps, routeService, trackingService,
+		fleetService,
 	)
 
+	// Ask clients to rate completed orders in the background
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := reviewService.ProcessReviewRequests(); err != nil {
+				utils.LogError(err)
+			}
+		}
+	}()
+
+	// Remind about vehicle maintenance coming due
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := fleetService.ProcessReminders(); err != nil {
+				utils.LogError(err)
+			}
+		}
+	}()
+
+	// Send scheduled owner digests; the owner, accounting and group chats come from the shared chat settings
+	var digestCfg digest.Config
+	if chatCfg, err := config.Load(); err != nil {
+		utils.LogError(fmt.Errorf("failed to load chat settings, digests go to owners only: %v", err))
+	} else {
+		digestCfg.ChatIDs = []int64{chatCfg.OwnerChatID, chatCfg.AccountingChatID, chatCfg.GroupChatID}
+	}
+	if digestCfg.Kinds, err = digest.ParseKinds(cfg.Digests); err != nil {
+		utils.LogError(err)
+	}
+	for _, clock := range []struct {
+		value  string
+		target *time.Duration
+	}{
+		{cfg.DigestDailyAt, &digestCfg.DailyAt},
+		{cfg.DigestWeeklyAt, &digestCfg.WeeklyAt},
+		{cfg.DigestMonthlyAt, &digestCfg.MonthlyAt},
+	} {
+		if *clock.target, err = digest.ParseClock(clock.value); err != nil {
+			utils.LogError(err)
+		}
+	}
+	digestService := digest.NewService(bot, digest.NewPostgresRepository(dbConn), statsService, digestCfg)
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := digestService.ProcessDigests(); err != nil {
+				utils.LogError(err)
+			}
+		}
+	}()
+
+	// Confirm external payment links via webhooks and status polling
+	if cfg.PaymentWebhookAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/payments/webhook", payment.NewWebhookHandler(paymentService, mainHandler.OnPaymentLinkPaid))
+		if fakeProvider != nil {
+			mux.Handle("/pay/", fakeProvider.PayHandler())
+		}
+		go func() {
+			if err := http.ListenAndServe(cfg.PaymentWebhookAddr, mux); err != nil {
+				utils.LogError(fmt.Errorf("payment webhook server stopped: %v", err))
+			}
+		}()
+	}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := paymentService.PollPaymentLinks(mainHandler.OnPaymentLinkPaid); err != nil {
+				utils.LogError(err)
+			}
+		}
+	}()
+
 	// Set up Telegram updates
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
@@ -104,4 +300,28 @@ func main() {
 	for update := range updates {
 		mainHandler.HandleUpdate(&update)
 	}
+}
+
+// businessLocation loads the configured business time zone, falling back to the server's
+func businessLocation(cfg *utils.Config) *time.Location {
+	if cfg.BusinessTimezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(cfg.BusinessTimezone)
+	if err != nil {
+		utils.LogError(fmt.Errorf("failed to load business time zone %q: %v", cfg.BusinessTimezone, err))
+		return time.Local
+	}
+	return loc
+}
+
+// dbConfig picks the database connection settings from the configuration
+func dbConfig(cfg *utils.Config) db.Config {
+	return db.Config{
+		Host:     cfg.DBHost,
+		Port:     cfg.DBPort,
+		User:     cfg.DBUser,
+		Password: cfg.DBPassword,
+		DBName:   cfg.DBName,
+	}
 }
\ No newline at end of file
@@ -168,6 +168,322 @@ func (db *DB) initSchema() error {
 		FOREIGN KEY (order_id) REFERENCES orders(id),
 		FOREIGN KEY (user_id) REFERENCES users(chat_id)
 	);
+
+	CREATE TABLE IF NOT EXISTS referral_campaigns (
+		id SERIAL PRIMARY KEY,
+		code VARCHAR(32) UNIQUE NOT NULL,
+		name VARCHAR(100) NOT NULL,
+		created_by BIGINT NOT NULL,
+		is_active BOOLEAN DEFAULT TRUE,
+		created_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (created_by) REFERENCES users(chat_id)
+	);
+
+	ALTER TABLE referrals ALTER COLUMN inviter_id DROP NOT NULL;
+	ALTER TABLE referrals ADD COLUMN IF NOT EXISTS campaign_id INTEGER REFERENCES referral_campaigns(id);
+
+	CREATE TABLE IF NOT EXISTS referral_qr_codes (
+		user_id BIGINT PRIMARY KEY,
+		fingerprint VARCHAR(40) NOT NULL,
+		file_id TEXT NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(chat_id)
+	);
+
+	ALTER TABLE reviews ADD COLUMN IF NOT EXISTS photos TEXT[];
+	ALTER TABLE reviews ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'approved';
+	ALTER TABLE reviews ADD COLUMN IF NOT EXISTS moderated_by BIGINT REFERENCES users(chat_id);
+	ALTER TABLE reviews ADD COLUMN IF NOT EXISTS moderated_at TIMESTAMP;
+	ALTER TABLE reviews ADD COLUMN IF NOT EXISTS published_at TIMESTAMP;
+
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP;
+
+	CREATE TABLE IF NOT EXISTS review_requests (
+		order_id INTEGER PRIMARY KEY,
+		user_id BIGINT NOT NULL,
+		sent_at TIMESTAMP NOT NULL,
+		reminded_at TIMESTAMP,
+		FOREIGN KEY (order_id) REFERENCES orders(id),
+		FOREIGN KEY (user_id) REFERENCES users(chat_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS escalations (
+		id SERIAL PRIMARY KEY,
+		review_id INTEGER NOT NULL,
+		order_id INTEGER NOT NULL,
+		user_id BIGINT NOT NULL,
+		rating INTEGER NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		operator_id BIGINT,
+		resolution VARCHAR(20),
+		resolution_note TEXT,
+		amount FLOAT DEFAULT 0,
+		created_at TIMESTAMP NOT NULL,
+		resolved_at TIMESTAMP,
+		FOREIGN KEY (review_id) REFERENCES reviews(id),
+		FOREIGN KEY (order_id) REFERENCES orders(id),
+		FOREIGN KEY (user_id) REFERENCES users(chat_id),
+		FOREIGN KEY (operator_id) REFERENCES users(chat_id),
+		UNIQUE (review_id)
+	);
+
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS confirmed_by BIGINT REFERENCES users(chat_id);
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS confirmed_at TIMESTAMP;
+
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS provider_charge_id TEXT;
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS telegram_charge_id TEXT;
+	CREATE UNIQUE INDEX IF NOT EXISTS payments_provider_charge_id_key ON payments (provider_charge_id);
+
+	CREATE TABLE IF NOT EXISTS payment_links (
+		id SERIAL PRIMARY KEY,
+		order_id INTEGER NOT NULL,
+		user_id BIGINT NOT NULL,
+		provider VARCHAR(50) NOT NULL,
+		external_id VARCHAR(100) NOT NULL,
+		url TEXT NOT NULL,
+		amount FLOAT NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		payment_id INTEGER,
+		created_at TIMESTAMP NOT NULL,
+		paid_at TIMESTAMP,
+		FOREIGN KEY (order_id) REFERENCES orders(id),
+		FOREIGN KEY (user_id) REFERENCES users(chat_id),
+		FOREIGN KEY (payment_id) REFERENCES payments(id),
+		UNIQUE (provider, external_id)
+	);
+
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'payment';
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS reason TEXT;
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS requested_by BIGINT REFERENCES users(chat_id);
+
+	CREATE TABLE IF NOT EXISTS receipts (
+		id SERIAL PRIMARY KEY,
+		payment_id INTEGER NOT NULL,
+		order_id INTEGER NOT NULL,
+		user_id BIGINT NOT NULL,
+		provider VARCHAR(50) NOT NULL,
+		fiscal_id VARCHAR(100) NOT NULL,
+		operation VARCHAR(20) NOT NULL,
+		items JSONB NOT NULL,
+		total FLOAT NOT NULL,
+		vat_mode VARCHAR(20) NOT NULL,
+		vat_amount FLOAT DEFAULT 0,
+		payment_method VARCHAR(20) NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (payment_id) REFERENCES payments(id),
+		FOREIGN KEY (order_id) REFERENCES orders(id),
+		FOREIGN KEY (user_id) REFERENCES users(chat_id),
+		UNIQUE (payment_id)
+	);
+
+	ALTER TABLE receipts ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'registered';
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS receipt_id INTEGER REFERENCES receipts(id);
+
+	CREATE TABLE IF NOT EXISTS ledger_entries (
+		id SERIAL PRIMARY KEY,
+		kind VARCHAR(30) NOT NULL,
+		order_id INTEGER,
+		user_id BIGINT NOT NULL,
+		description TEXT,
+		legacy_record_id INTEGER UNIQUE,
+		created_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (order_id) REFERENCES orders(id),
+		FOREIGN KEY (user_id) REFERENCES users(chat_id),
+		FOREIGN KEY (legacy_record_id) REFERENCES accounting_records(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS ledger_postings (
+		id SERIAL PRIMARY KEY,
+		entry_id INTEGER NOT NULL,
+		account VARCHAR(30) NOT NULL,
+		driver_id BIGINT,
+		amount NUMERIC(14, 2) NOT NULL CHECK (amount <> 0),
+		FOREIGN KEY (entry_id) REFERENCES ledger_entries(id) ON DELETE CASCADE,
+		FOREIGN KEY (driver_id) REFERENCES users(chat_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS ledger_postings_account_driver_idx ON ledger_postings (account, driver_id);
+
+	-- Move every accounting record into the ledger; a record type without an account mapping aborts the migration
+	DO $$
+	BEGIN
+		IF EXISTS (
+			SELECT 1 FROM accounting_records
+			WHERE type NOT IN ('driver_cash', 'cash_handover', 'income', 'expense', 'salary')
+		) THEN
+			RAISE EXCEPTION 'accounting_records contains types without a ledger mapping';
+		END IF;
+	END $$;
+
+	INSERT INTO ledger_entries (kind, order_id, user_id, description, legacy_record_id, created_at)
+	SELECT type, order_id, user_id, description, id, created_at
+	FROM accounting_records
+	ON CONFLICT (legacy_record_id) DO NOTHING;
+
+	-- Collected cash is owed by the driver, hand-overs reach the cash desk, and legacy income,
+	-- expenses and salaries went through the cash desk
+	WITH legacy_accounts (type, debit, credit) AS (
+		VALUES
+			('driver_cash', 'driver_cash', 'revenue'),
+			('cash_handover', 'cash_desk', 'driver_cash'),
+			('income', 'cash_desk', 'revenue'),
+			('expense', 'other_expenses', 'cash_desk'),
+			('salary', 'salaries', 'cash_desk')
+	), legacy AS (
+		SELECT e.id AS entry_id, r.user_id, m.debit, m.credit, ABS(ROUND(r.amount::NUMERIC, 2)) AS amount
+		FROM ledger_entries e
+		JOIN accounting_records r ON r.id = e.legacy_record_id
+		JOIN legacy_accounts m ON m.type = r.type
+		WHERE ROUND(r.amount::NUMERIC, 2) <> 0
+		  AND NOT EXISTS (SELECT 1 FROM ledger_postings p WHERE p.entry_id = e.id)
+	)
+	INSERT INTO ledger_postings (entry_id, account, driver_id, amount)
+	SELECT entry_id, debit, CASE WHEN debit = 'driver_cash' THEN user_id END, amount FROM legacy
+	UNION ALL
+	SELECT entry_id, credit, CASE WHEN credit = 'driver_cash' THEN user_id END, -amount FROM legacy;
+
+	CREATE TABLE IF NOT EXISTS expenses (
+		id SERIAL PRIMARY KEY,
+		user_id BIGINT NOT NULL,
+		category VARCHAR(30) NOT NULL,
+		amount NUMERIC(14, 2) NOT NULL CHECK (amount > 0),
+		order_id INTEGER,
+		photo_file_id TEXT NOT NULL,
+		comment TEXT,
+		status VARCHAR(20) NOT NULL,
+		reviewed_by BIGINT,
+		reviewed_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(chat_id),
+		FOREIGN KEY (order_id) REFERENCES orders(id),
+		FOREIGN KEY (reviewed_by) REFERENCES users(chat_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS pay_rules (
+		user_id BIGINT PRIMARY KEY,
+		per_order NUMERIC(14, 2) NOT NULL DEFAULT 0,
+		per_hour NUMERIC(14, 2) NOT NULL DEFAULT 0,
+		percent NUMERIC(5, 2) NOT NULL DEFAULT 0,
+		category_bonuses JSONB NOT NULL DEFAULT '{}',
+		updated_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(chat_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS payroll_runs (
+		id SERIAL PRIMARY KEY,
+		period_start TIMESTAMP NOT NULL,
+		period_end TIMESTAMP NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		created_by BIGINT NOT NULL,
+		approved_by BIGINT,
+		approved_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (created_by) REFERENCES users(chat_id),
+		FOREIGN KEY (approved_by) REFERENCES users(chat_id)
+	);
+
+	-- Approved payrolls must not pay the same period twice
+	DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'payroll_runs_no_overlap') THEN
+			ALTER TABLE payroll_runs ADD CONSTRAINT payroll_runs_no_overlap
+				EXCLUDE USING gist (tsrange(period_start, period_end) WITH &&) WHERE (status = 'approved');
+		END IF;
+	END $$;
+
+	CREATE TABLE IF NOT EXISTS payroll_lines (
+		id SERIAL PRIMARY KEY,
+		run_id INTEGER NOT NULL,
+		user_id BIGINT NOT NULL,
+		name VARCHAR(100),
+		role VARCHAR(50) NOT NULL,
+		orders INTEGER NOT NULL,
+		minutes INTEGER NOT NULL,
+		per_order_pay NUMERIC(14, 2) NOT NULL,
+		hourly_pay NUMERIC(14, 2) NOT NULL,
+		percent_pay NUMERIC(14, 2) NOT NULL,
+		bonus_pay NUMERIC(14, 2) NOT NULL,
+		total NUMERIC(14, 2) NOT NULL,
+		no_rule BOOLEAN NOT NULL DEFAULT FALSE,
+		items JSONB NOT NULL,
+		FOREIGN KEY (run_id) REFERENCES payroll_runs(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(chat_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS digest_runs (
+		kind VARCHAR(20) NOT NULL,
+		period_start TIMESTAMP NOT NULL,
+		sent_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (kind, period_start)
+	);
+
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS zone VARCHAR(100);
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS zone_surcharge FLOAT NOT NULL DEFAULT 0;
+
+	CREATE TABLE IF NOT EXISTS trips (
+		order_id INTEGER PRIMARY KEY,
+		user_id BIGINT NOT NULL,
+		started_at TIMESTAMP NOT NULL,
+		ended_at TIMESTAMP,
+		latitude DOUBLE PRECISION,
+		longitude DOUBLE PRECISION,
+		located_at TIMESTAMP,
+		live_until TIMESTAMP,
+		FOREIGN KEY (order_id) REFERENCES orders(id),
+		FOREIGN KEY (user_id) REFERENCES users(chat_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS trip_locations (
+		id SERIAL PRIMARY KEY,
+		order_id INTEGER NOT NULL,
+		user_id BIGINT NOT NULL,
+		latitude DOUBLE PRECISION NOT NULL,
+		longitude DOUBLE PRECISION NOT NULL,
+		recorded_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (order_id) REFERENCES orders(id),
+		FOREIGN KEY (user_id) REFERENCES users(chat_id)
+	);
+	CREATE INDEX IF NOT EXISTS trip_locations_order_idx ON trip_locations (order_id, recorded_at);
+
+	CREATE TABLE IF NOT EXISTS vehicles (
+		id SERIAL PRIMARY KEY,
+		plate VARCHAR(20) NOT NULL UNIQUE,
+		type VARCHAR(20) NOT NULL,
+		capacity_m3 FLOAT NOT NULL,
+		capacity_tons FLOAT NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'active',
+		driver_id BIGINT,
+		mileage INTEGER NOT NULL DEFAULT 0,
+		service_due_date DATE,
+		service_due_mileage INTEGER,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (driver_id) REFERENCES users(chat_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS vehicle_mileage (
+		id SERIAL PRIMARY KEY,
+		vehicle_id INTEGER NOT NULL,
+		mileage INTEGER NOT NULL,
+		recorded_by BIGINT NOT NULL,
+		recorded_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (vehicle_id) REFERENCES vehicles(id) ON DELETE CASCADE,
+		FOREIGN KEY (recorded_by) REFERENCES users(chat_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS vehicle_reminders (
+		vehicle_id INTEGER NOT NULL,
+		due_key VARCHAR(50) NOT NULL,
+		sent_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (vehicle_id, due_key),
+		FOREIGN KEY (vehicle_id) REFERENCES vehicles(id) ON DELETE CASCADE
+	);
+
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS volume FLOAT NOT NULL DEFAULT 0;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS weight FLOAT NOT NULL DEFAULT 0;
+	ALTER TABLE orders ADD COLUMN IF NOT EXISTS vehicle_id INTEGER REFERENCES vehicles(id);
 	`
 
 	_, err := db.conn.Exec(schema)
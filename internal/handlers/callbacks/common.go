@@ -17,17 +17,24 @@ type CallbackHandlable interface {
 
 // CallbackHandler manages callback queries
 type CallbackHandler struct {
-	bot              *tgbotapi.BotAPI
-	security         *security.SecurityChecker
-	menus            *menus.MenuGenerator
-	userService      *user.Service
-	state            *state.Manager
-	ordersHandler    CallbackHandlable
-	staffHandler     CallbackHandlable
-	contactHandler   CallbackHandlable
-	referralsHandler CallbackHandlable
-	reviewsHandler   CallbackHandlable
-	statsHandler     CallbackHandlable
+	bot                *tgbotapi.BotAPI
+	security           *security.SecurityChecker
+	menus              *menus.MenuGenerator
+	userService        *user.Service
+	state              *state.Manager
+	ordersHandler      CallbackHandlable
+	staffHandler       CallbackHandlable
+	contactHandler     CallbackHandlable
+	referralsHandler   CallbackHandlable
+	reviewsHandler     CallbackHandlable
+	statsHandler       CallbackHandlable
+	escalationsHandler CallbackHandlable
+	debtsHandler       CallbackHandlable
+	expensesHandler    CallbackHandlable
+	payrollHandler     CallbackHandlable
+	exportHandler      CallbackHandlable
+	tripsHandler       CallbackHandlable
+	fleetHandler       CallbackHandlable
 }
 
 // NewCallbackHandler creates a new CallbackHandler
@@ -43,19 +50,33 @@ func NewCallbackHandler(
 	referralsHandler CallbackHandlable,
 	reviewsHandler CallbackHandlable,
 	statsHandler CallbackHandlable,
+	escalationsHandler CallbackHandlable,
+	debtsHandler CallbackHandlable,
+	expensesHandler CallbackHandlable,
+	payrollHandler CallbackHandlable,
+	exportHandler CallbackHandlable,
+	tripsHandler CallbackHandlable,
+	fleetHandler CallbackHandlable,
 ) *CallbackHandler {
 	return &CallbackHandler{
-		bot:              bot,
-		security:         security,
-		menus:            menus,
-		userService:      userService,
-		state:            state,
-		ordersHandler:    ordersHandler,
-		staffHandler:     staffHandler,
-		contactHandler:   contactHandler,
-		referralsHandler: referralsHandler,
-		reviewsHandler:   reviewsHandler,
-		statsHandler:     statsHandler,
+		bot:                bot,
+		security:           security,
+		menus:              menus,
+		userService:        userService,
+		state:              state,
+		ordersHandler:      ordersHandler,
+		staffHandler:       staffHandler,
+		contactHandler:     contactHandler,
+		referralsHandler:   referralsHandler,
+		reviewsHandler:     reviewsHandler,
+		statsHandler:       statsHandler,
+		escalationsHandler: escalationsHandler,
+		debtsHandler:       debtsHandler,
+		expensesHandler:    expensesHandler,
+		payrollHandler:     payrollHandler,
+		exportHandler:      exportHandler,
+		tripsHandler:       tripsHandler,
+		fleetHandler:       fleetHandler,
 	}
 }
 
@@ -76,7 +97,7 @@ func (h *CallbackHandler) HandleCallback(callback *tgbotapi.CallbackQuery) {
 
 	module := parts[0]
 	switch module {
-	case "order", "accept", "cancel", "block", "assign", "confirm", "cash":
+	case "order", "accept", "cancel", "block", "assign", "confirm", "cash", "cost", "volume", "payment", "prepay", "refund":
 		h.ordersHandler.Handle(callback)
 	case "staff", "edit":
 		h.staffHandler.Handle(callback)
@@ -84,10 +105,24 @@ func (h *CallbackHandler) HandleCallback(callback *tgbotapi.CallbackQuery) {
 		h.contactHandler.Handle(callback)
 	case "referral", "qr":
 		h.referralsHandler.Handle(callback)
-	case "review":
+	case "review", "rate":
 		h.reviewsHandler.Handle(callback)
 	case "stats":
 		h.statsHandler.Handle(callback)
+	case "escalation":
+		h.escalationsHandler.Handle(callback)
+	case "debt":
+		h.debtsHandler.Handle(callback)
+	case "expense":
+		h.expensesHandler.Handle(callback)
+	case "payroll":
+		h.payrollHandler.Handle(callback)
+	case "export":
+		h.exportHandler.Handle(callback)
+	case "trip":
+		h.tripsHandler.Handle(callback)
+	case "vehicle":
+		h.fleetHandler.Handle(callback)
 	case "date":
 		if len(parts) < 2 {
 			h.sendCallbackMessage(chatID, callback.Message.MessageID, "❌ Неверный формат даты.")
@@ -0,0 +1,131 @@
+package callbacks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/menus"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/security"
+	"github.com/skyzeper/telegram-bot/internal/services/accounting"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// DebtsHandler handles driver cash balance callbacks
+type DebtsHandler struct {
+	bot               *tgbotapi.BotAPI
+	security          *security.SecurityChecker
+	menus             *menus.MenuGenerator
+	accountingService *accounting.Service
+}
+
+// NewDebtsHandler creates a new DebtsHandler
+func NewDebtsHandler(
+	bot *tgbotapi.BotAPI,
+	security *security.SecurityChecker,
+	menus *menus.MenuGenerator,
+	accountingService *accounting.Service,
+) *DebtsHandler {
+	return &DebtsHandler{
+		bot:               bot,
+		security:          security,
+		menus:             menus,
+		accountingService: accountingService,
+	}
+}
+
+// Handle processes driver debt callbacks
+func (h *DebtsHandler) Handle(callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	data := callback.Data
+
+	switch {
+	case data == "debt_list":
+		if ok, err := h.security.HasAccess(chatID, "debts"); err != nil || !ok {
+			h.sendError(chatID, "🚫 Доступ запрещён.")
+			return
+		}
+		text, markup, err := DriverBalancesView(h.accountingService)
+		if err != nil {
+			h.sendError(chatID, "❌ Ошибка получения долгов водителей.")
+			return
+		}
+		h.edit(callback, text, markup)
+	case strings.HasPrefix(data, "debt_"):
+		driverID, err := strconv.ParseInt(strings.TrimPrefix(data, "debt_"), 10, 64)
+		if err != nil {
+			h.sendError(chatID, "❌ Неверный формат водителя.")
+			return
+		}
+		// Drivers may only see their own statement
+		if driverID != chatID {
+			if ok, err := h.security.HasAccess(chatID, "debts"); err != nil || !ok {
+				h.sendError(chatID, "🚫 Доступ запрещён.")
+				return
+			}
+		}
+		statement, err := h.accountingService.GetDriverStatement(driverID, time.Now().Add(-accounting.StatementPeriod))
+		if err != nil {
+			h.sendError(chatID, "❌ Ошибка получения выписки.")
+			return
+		}
+		markup := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "debt_list"),
+			),
+		)
+		h.edit(callback, accounting.FormatDriverStatement(statement), &markup)
+	default:
+		h.sendError(chatID, "❓ Неизвестная команда.")
+	}
+}
+
+// DriverBalancesView renders outstanding driver balances with a statement button per driver
+func DriverBalancesView(accountingService *accounting.Service) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	balances, err := accountingService.GetDriverBalances()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(balances) == 0 {
+		return "✅ Все наличные сданы в кассу.", nil, nil
+	}
+
+	var total models.Money
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, balance := range balances {
+		total += balance.Balance
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(driverBalanceLabel(balance), fmt.Sprintf("debt_%d", balance.UserID)),
+		))
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return fmt.Sprintf("💵 Наличные у водителей: %s руб.\nВыберите водителя для выписки:", total), &markup, nil
+}
+
+// driverBalanceLabel formats a driver balance for a button
+func driverBalanceLabel(balance models.DriverBalance) string {
+	name := balance.Name
+	if name == "" {
+		name = strconv.FormatInt(balance.UserID, 10)
+	}
+	return fmt.Sprintf("%s — %s руб.", name, balance.Balance)
+}
+
+// edit replaces the callback message with new text and markup
+func (h *DebtsHandler) edit(callback *tgbotapi.CallbackQuery, text string, markup *tgbotapi.InlineKeyboardMarkup) {
+	reply := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	reply.ReplyMarkup = markup
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// sendError sends an error message
+func (h *DebtsHandler) sendError(chatID int64, text string) {
+	reply := tgbotapi.NewMessage(chatID, text)
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
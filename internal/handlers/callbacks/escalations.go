@@ -0,0 +1,311 @@
+package callbacks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/menus"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/security"
+	"github.com/skyzeper/telegram-bot/internal/services/chat"
+	"github.com/skyzeper/telegram-bot/internal/services/escalation"
+	"github.com/skyzeper/telegram-bot/internal/services/order"
+	"github.com/skyzeper/telegram-bot/internal/services/review"
+	"github.com/skyzeper/telegram-bot/internal/services/user"
+	"github.com/skyzeper/telegram-bot/internal/state"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// escalationChatHistory is the number of latest chat messages shown on an escalation card
+const escalationChatHistory = 10
+
+// EscalationsHandler handles negative-review escalation callbacks
+type EscalationsHandler struct {
+	bot               *tgbotapi.BotAPI
+	security          *security.SecurityChecker
+	menus             *menus.MenuGenerator
+	escalationService *escalation.Service
+	reviewService     *review.Service
+	orderService      *order.Service
+	chatService       *chat.Service
+	userService       *user.Service
+	state             *state.Manager
+}
+
+// NewEscalationsHandler creates a new EscalationsHandler
+func NewEscalationsHandler(
+	bot *tgbotapi.BotAPI,
+	security *security.SecurityChecker,
+	menus *menus.MenuGenerator,
+	escalationService *escalation.Service,
+	reviewService *review.Service,
+	orderService *order.Service,
+	chatService *chat.Service,
+	userService *user.Service,
+	state *state.Manager,
+) *EscalationsHandler {
+	return &EscalationsHandler{
+		bot:               bot,
+		security:          security,
+		menus:             menus,
+		escalationService: escalationService,
+		reviewService:     reviewService,
+		orderService:      orderService,
+		chatService:       chatService,
+		userService:       userService,
+		state:             state,
+	}
+}
+
+// Handle processes escalation-related callbacks
+func (h *EscalationsHandler) Handle(callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	if !h.security.HasRole(chatID, "main_operator") {
+		h.sendUnauthorized(chatID)
+		return
+	}
+
+	data := callback.Data
+	switch {
+	case data == "escalation_list":
+		h.handleList(callback)
+	case strings.HasPrefix(data, "escalation_view_"):
+		h.handleView(callback, strings.TrimPrefix(data, "escalation_view_"))
+	case strings.HasPrefix(data, "escalation_take_"):
+		h.handleTake(callback, strings.TrimPrefix(data, "escalation_take_"))
+	case strings.HasPrefix(data, "escalation_resolve_"):
+		h.handleResolve(callback, strings.TrimPrefix(data, "escalation_resolve_"))
+	default:
+		h.sendError(chatID, "❓ Неизвестная команда.")
+	}
+}
+
+// OpenForReview opens an escalation for a negative review and alerts all main operators
+func (h *EscalationsHandler) OpenForReview(r *models.Review) {
+	opened, err := h.escalationService.OpenForReview(r)
+	if err != nil {
+		utils.LogError(err)
+		return
+	}
+	if opened == nil {
+		return
+	}
+
+	operators, err := h.userService.ListUsersByRole("main_operator")
+	if err != nil {
+		utils.LogError(err)
+		return
+	}
+	text := "🚨 Негативный отзыв — требуется реакция!\n\n" + h.buildCard(opened)
+	for _, operator := range operators {
+		msg := tgbotapi.NewMessage(operator.ChatID, text)
+		msg.ReplyMarkup = h.cardMarkup(opened, operator.ChatID)
+		if _, err := h.bot.Send(msg); err != nil {
+			utils.LogError(err)
+		}
+	}
+}
+
+// handleList shows unresolved escalations
+func (h *EscalationsHandler) handleList(callback *tgbotapi.CallbackQuery) {
+	escalations, err := h.escalationService.GetUnresolved()
+	if err != nil {
+		h.sendError(callback.Message.Chat.ID, "Ошибка получения жалоб.")
+		return
+	}
+	if len(escalations) == 0 {
+		h.editMessage(callback, "✅ Нерешённых жалоб нет.", nil)
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, e := range escalations {
+		label := fmt.Sprintf("#%d · заказ #%d · %s", e.ID, e.OrderID, strings.Repeat("⭐", e.Rating))
+		if e.Status == escalation.StatusInProgress {
+			label += " · в работе"
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("escalation_view_%d", e.ID)),
+		))
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.editMessage(callback, fmt.Sprintf("🚨 Нерешённые жалобы: %d", len(escalations)), &markup)
+}
+
+// handleView shows the escalation card
+func (h *EscalationsHandler) handleView(callback *tgbotapi.CallbackQuery, idStr string) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.sendError(callback.Message.Chat.ID, "Неверный формат жалобы.")
+		return
+	}
+	e, err := h.escalationService.GetEscalation(id)
+	if err != nil {
+		h.sendError(callback.Message.Chat.ID, "Жалоба не найдена.")
+		return
+	}
+	markup := h.cardMarkup(e, callback.Message.Chat.ID)
+	h.editMessage(callback, h.buildCard(e), &markup)
+}
+
+// handleTake assigns the escalation to the operator and shows client contacts
+func (h *EscalationsHandler) handleTake(callback *tgbotapi.CallbackQuery, idStr string) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.sendError(callback.Message.Chat.ID, "Неверный формат жалобы.")
+		return
+	}
+	e, err := h.escalationService.Take(id, callback.Message.Chat.ID)
+	if err == escalation.ErrAlreadyTaken {
+		h.sendError(callback.Message.Chat.ID, "Жалобу уже взял другой оператор.")
+		return
+	}
+	if err != nil {
+		h.sendError(callback.Message.Chat.ID, "Жалоба уже решена или не найдена.")
+		return
+	}
+	markup := h.cardMarkup(e, callback.Message.Chat.ID)
+	h.editMessage(callback, h.buildCard(e)+"\n\n📞 Свяжитесь с клиентом и выберите решение:", &markup)
+}
+
+// handleResolve asks the operator for the amount and comment of the chosen resolution
+func (h *EscalationsHandler) handleResolve(callback *tgbotapi.CallbackQuery, data string) {
+	parts := strings.SplitN(data, "_", 2)
+	if len(parts) != 2 || escalation.ResolutionLabel(parts[1]) == "" {
+		h.sendError(callback.Message.Chat.ID, "Неверный формат решения.")
+		return
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		h.sendError(callback.Message.Chat.ID, "Неверный формат жалобы.")
+		return
+	}
+	e, err := h.escalationService.GetEscalation(id)
+	if err != nil {
+		h.sendError(callback.Message.Chat.ID, "Жалоба не найдена.")
+		return
+	}
+	if e.Status != escalation.StatusInProgress || e.OperatorID != callback.Message.Chat.ID {
+		h.sendError(callback.Message.Chat.ID, "Закрыть жалобу может только оператор, который взял её в работу.")
+		return
+	}
+
+	h.state.Set(callback.Message.Chat.ID, state.State{
+		Module:     "escalation",
+		Step:       1,
+		TotalSteps: 1,
+		Data:       map[string]interface{}{"escalation_id": id, "resolution": parts[1]},
+	})
+
+	prompt := "✍️ Опишите, как решили вопрос с клиентом:"
+	if escalation.HasAmount(parts[1]) {
+		prompt = "✍️ Введите сумму и комментарий, например: 500 вернули за опоздание"
+	}
+	h.editMessage(callback, fmt.Sprintf("%s — жалоба #%d\n%s", escalation.ResolutionLabel(parts[1]), id, prompt), nil)
+}
+
+// buildCard renders order details, executors, the review and recent chat history
+func (h *EscalationsHandler) buildCard(e *models.Escalation) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Жалоба #%d · заказ #%d · оценка %s\n", e.ID, e.OrderID, strings.Repeat("⭐", e.Rating)))
+	if e.Status == escalation.StatusInProgress && e.OperatorID != 0 {
+		sb.WriteString(fmt.Sprintf("🧑‍💼 В работе у %s\n", h.userName(e.OperatorID)))
+	}
+
+	if r, err := h.reviewService.GetReviewByID(e.ReviewID); err == nil && r.Comment != "" {
+		sb.WriteString(fmt.Sprintf("💬 Отзыв: %s\n", r.Comment))
+	}
+
+	o, err := h.orderService.GetOrder(e.OrderID)
+	if err != nil {
+		utils.LogError(err)
+	} else {
+		sb.WriteString(fmt.Sprintf(
+			"\n📦 %s (%s)\n📍 %s\n📅 %s\n💰 %.2f руб.\n📞 %s\n",
+			o.Category, o.Subcategory, o.Address, o.Date.Format("02.01.2006"), o.Cost, o.Phone,
+		))
+		if len(o.Executors) > 0 {
+			sb.WriteString("\n👷 Исполнители:\n")
+			for _, ex := range o.Executors {
+				sb.WriteString(fmt.Sprintf("- %s (%s)\n", h.userName(ex.UserID), ex.Role))
+			}
+		}
+	}
+
+	history, err := h.chatService.GetHistory(e.UserID, escalationChatHistory)
+	if err != nil {
+		utils.LogError(err)
+	} else if len(history) > 0 {
+		sb.WriteString("\n🗨 Переписка:\n")
+		for _, m := range history {
+			author := "Оператор"
+			if m.IsFromUser {
+				author = "Клиент"
+			}
+			sb.WriteString(fmt.Sprintf("[%s] %s: %s\n", m.CreatedAt.Format("02.01 15:04"), author, m.Message))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// cardMarkup returns the actions available to a viewer of an escalation; only its operator may resolve it
+func (h *EscalationsHandler) cardMarkup(e *models.Escalation, viewerID int64) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if e.Status == escalation.StatusOpen {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📞 Взять в работу", fmt.Sprintf("escalation_take_%d", e.ID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonURL("💬 Написать клиенту", fmt.Sprintf("tg://user?id=%d", e.UserID)),
+	))
+	if e.Status != escalation.StatusInProgress || e.OperatorID != viewerID {
+		return tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💸 Возврат", fmt.Sprintf("escalation_resolve_%d_%s", e.ID, escalation.ResolutionRefund)),
+			tgbotapi.NewInlineKeyboardButtonData("🏷 Скидка", fmt.Sprintf("escalation_resolve_%d_%s", e.ID, escalation.ResolutionDiscount)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔁 Повторный выезд", fmt.Sprintf("escalation_resolve_%d_%s", e.ID, escalation.ResolutionRedo)),
+			tgbotapi.NewInlineKeyboardButtonData("✖️ Без компенсации", fmt.Sprintf("escalation_resolve_%d_%s", e.ID, escalation.ResolutionNone)),
+		),
+	)
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// userName returns a display name for a user
+func (h *EscalationsHandler) userName(chatID int64) string {
+	u, err := h.userService.GetUser(chatID)
+	if err != nil || u.FirstName == "" {
+		return fmt.Sprintf("ID %d", chatID)
+	}
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+// editMessage edits the callback message with optional inline keyboard
+func (h *EscalationsHandler) editMessage(callback *tgbotapi.CallbackQuery, text string, markup *tgbotapi.InlineKeyboardMarkup) {
+	reply := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	reply.ReplyMarkup = markup
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// sendError sends an error message
+func (h *EscalationsHandler) sendError(chatID int64, text string) {
+	reply := tgbotapi.NewMessage(chatID, text)
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// sendUnauthorized sends unauthorized access message
+func (h *EscalationsHandler) sendUnauthorized(chatID int64) {
+	reply := tgbotapi.NewMessage(chatID, "🚫 Доступ запрещён.")
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
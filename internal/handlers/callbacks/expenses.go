@@ -0,0 +1,152 @@
+package callbacks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/menus"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/security"
+	"github.com/skyzeper/telegram-bot/internal/services/expense"
+	"github.com/skyzeper/telegram-bot/internal/state"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// ExpensesHandler handles expense submission and review callbacks
+type ExpensesHandler struct {
+	bot            *tgbotapi.BotAPI
+	security       *security.SecurityChecker
+	menus          *menus.MenuGenerator
+	expenseService *expense.Service
+	state          *state.Manager
+}
+
+// NewExpensesHandler creates a new ExpensesHandler
+func NewExpensesHandler(
+	bot *tgbotapi.BotAPI,
+	security *security.SecurityChecker,
+	menus *menus.MenuGenerator,
+	expenseService *expense.Service,
+	state *state.Manager,
+) *ExpensesHandler {
+	return &ExpensesHandler{
+		bot:            bot,
+		security:       security,
+		menus:          menus,
+		expenseService: expenseService,
+		state:          state,
+	}
+}
+
+// Handle processes expense callbacks
+func (h *ExpensesHandler) Handle(callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	data := callback.Data
+
+	switch {
+	case strings.HasPrefix(data, "expense_cat_"):
+		h.handleCategory(callback, strings.TrimPrefix(data, "expense_cat_"))
+	case strings.HasPrefix(data, "expense_approve_"):
+		h.handleDecision(callback, strings.TrimPrefix(data, "expense_approve_"), true)
+	case strings.HasPrefix(data, "expense_reject_"):
+		h.handleDecision(callback, strings.TrimPrefix(data, "expense_reject_"), false)
+	default:
+		h.sendError(chatID, "❓ Неизвестная команда.")
+	}
+}
+
+// handleCategory starts the expense dialog for the chosen category
+func (h *ExpensesHandler) handleCategory(callback *tgbotapi.CallbackQuery, code string) {
+	chatID := callback.Message.Chat.ID
+	if !h.security.HasRole(chatID, "driver") && !h.security.HasRole(chatID, "loader") {
+		h.sendError(chatID, "🚫 Доступ запрещён.")
+		return
+	}
+	category, ok := expense.LookupCategory(code)
+	if !ok {
+		h.sendError(chatID, "❌ Неизвестная категория расхода.")
+		return
+	}
+
+	h.state.Set(chatID, state.State{
+		Module:     "expense",
+		Step:       1,
+		TotalSteps: 3,
+		Data:       map[string]interface{}{"category": category.Code},
+	})
+	reply := tgbotapi.NewEditMessageText(chatID, callback.Message.MessageID,
+		fmt.Sprintf("%s %s\n💰 Введите сумму расхода, руб.:", category.Icon, category.Name))
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// handleDecision approves or rejects a pending expense
+func (h *ExpensesHandler) handleDecision(callback *tgbotapi.CallbackQuery, expenseIDStr string, approve bool) {
+	chatID := callback.Message.Chat.ID
+	if ok, err := h.security.HasAccess(chatID, "expenses"); err != nil || !ok {
+		h.sendError(chatID, "🚫 Доступ запрещён.")
+		return
+	}
+	expenseID, err := strconv.Atoi(expenseIDStr)
+	if err != nil {
+		h.sendError(chatID, "❌ Неверный формат расхода.")
+		return
+	}
+
+	decide := h.expenseService.Reject
+	if approve {
+		decide = h.expenseService.Approve
+	}
+	e, err := decide(expenseID, chatID)
+	if err != nil {
+		utils.LogError(err)
+		h.sendError(chatID, "❌ Расход уже рассмотрен, не найден или внесён вами.")
+		return
+	}
+
+	result := fmt.Sprintf("🚫 Расход #%d на %s руб. отклонён.", e.ID, e.Amount)
+	if approve {
+		result = fmt.Sprintf("✅ Расход #%d на %s руб. одобрен и проведён.", e.ID, e.Amount)
+	}
+	edit := tgbotapi.NewEditMessageCaption(chatID, callback.Message.MessageID, expense.FormatExpense(e)+"\n\n"+result)
+	if _, err := h.bot.Send(edit); err != nil {
+		utils.LogError(err)
+	}
+	if _, err := h.bot.Send(tgbotapi.NewMessage(e.UserID, result)); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// ExpenseCategoryMarkup lists expense categories to choose from
+func ExpenseCategoryMarkup() tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, category := range expense.Categories() {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(category.Icon+" "+category.Name, "expense_cat_"+category.Code),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// ExpenseReviewPhoto builds the receipt photo sent to the main operator with approve and reject buttons
+func ExpenseReviewPhoto(chatID int64, e *models.Expense) tgbotapi.PhotoConfig {
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileID(e.PhotoFileID))
+	photo.Caption = expense.FormatExpense(e)
+	photo.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Одобрить", fmt.Sprintf("expense_approve_%d", e.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("🚫 Отклонить", fmt.Sprintf("expense_reject_%d", e.ID)),
+		),
+	)
+	return photo
+}
+
+// sendError sends an error message
+func (h *ExpensesHandler) sendError(chatID int64, text string) {
+	reply := tgbotapi.NewMessage(chatID, text)
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
@@ -0,0 +1,106 @@
+package callbacks
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/security"
+	"github.com/skyzeper/telegram-bot/internal/services/accounting"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// ExportHandler handles accounting export callbacks
+type ExportHandler struct {
+	bot               *tgbotapi.BotAPI
+	security          *security.SecurityChecker
+	accountingService *accounting.Service
+	cfg               accounting.ExportConfig
+}
+
+// NewExportHandler creates a new ExportHandler
+func NewExportHandler(
+	bot *tgbotapi.BotAPI,
+	security *security.SecurityChecker,
+	accountingService *accounting.Service,
+	cfg accounting.ExportConfig,
+) *ExportHandler {
+	return &ExportHandler{
+		bot:               bot,
+		security:          security,
+		accountingService: accountingService,
+		cfg:               cfg,
+	}
+}
+
+// Handle processes export callbacks like export_1c_prev
+func (h *ExportHandler) Handle(callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	if ok, err := h.security.HasAccess(chatID, "ledger"); err != nil || !ok {
+		h.sendError(chatID, "🚫 Доступ запрещён.")
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(callback.Data, "export_"), "_")
+	if len(parts) != 2 {
+		h.sendError(chatID, "❓ Неизвестная команда.")
+		return
+	}
+	format := parts[0]
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	switch parts[1] {
+	case "current":
+	case "prev":
+		from = from.AddDate(0, -1, 0)
+	default:
+		h.sendError(chatID, "❌ Неверный период выгрузки.")
+		return
+	}
+	to := from.AddDate(0, 1, 0)
+
+	export, err := h.accountingService.GetExport(from, to)
+	if err != nil {
+		h.sendError(chatID, "❌ Ошибка получения проводок. Попробуйте позже.")
+		return
+	}
+	var buf bytes.Buffer
+	if err := accounting.WriteExport(&buf, format, export, h.cfg); err != nil {
+		utils.LogError(err)
+		h.sendError(chatID, "❌ Ошибка формирования выгрузки.")
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileReader{Name: accounting.ExportFileName(format, from, to), Reader: &buf})
+	doc.Caption = fmt.Sprintf(
+		"📤 Платежи и расходы за %s — %s: %d операций",
+		from.Format("02.01.2006"), to.AddDate(0, 0, -1).Format("02.01.2006"), len(export.Records),
+	)
+	if _, err := h.bot.Send(doc); err != nil {
+		utils.LogError(err)
+		h.sendError(chatID, "❌ Не удалось отправить выгрузку.")
+	}
+}
+
+// ExportMenuMarkup lets the accountant or owner pick the export format and month
+func ExportMenuMarkup() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("1С · текущий месяц", "export_1c_current"),
+			tgbotapi.NewInlineKeyboardButtonData("1С · прошлый месяц", "export_1c_prev"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("CSV · текущий месяц", "export_csv_current"),
+			tgbotapi.NewInlineKeyboardButtonData("CSV · прошлый месяц", "export_csv_prev"),
+		),
+	)
+}
+
+// sendError sends an error message
+func (h *ExportHandler) sendError(chatID int64, text string) {
+	reply := tgbotapi.NewMessage(chatID, text)
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
@@ -0,0 +1,177 @@
+package callbacks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/security"
+	"github.com/skyzeper/telegram-bot/internal/services/fleet"
+	"github.com/skyzeper/telegram-bot/internal/services/order"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// FleetHandler handles linking vehicles to orders and drivers
+type FleetHandler struct {
+	bot          *tgbotapi.BotAPI
+	security     *security.SecurityChecker
+	orderService *order.Service
+	fleetService *fleet.Service
+}
+
+// NewFleetHandler creates a new FleetHandler
+func NewFleetHandler(
+	bot *tgbotapi.BotAPI,
+	security *security.SecurityChecker,
+	orderService *order.Service,
+	fleetService *fleet.Service,
+) *FleetHandler {
+	return &FleetHandler{
+		bot:          bot,
+		security:     security,
+		orderService: orderService,
+		fleetService: fleetService,
+	}
+}
+
+// Handle processes vehicle callbacks
+func (h *FleetHandler) Handle(callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	if !h.security.HasRole(chatID, "operator") && !h.security.HasRole(chatID, "main_operator") {
+		h.edit(callback, "🚫 Доступ запрещён.", nil)
+		return
+	}
+
+	data := callback.Data
+	switch {
+	case strings.HasPrefix(data, "vehicle_menu_"):
+		ids, ok := parseIDs(strings.TrimPrefix(data, "vehicle_menu_"), 2)
+		if !ok {
+			h.edit(callback, "❌ Неверный формат заказа.", nil)
+			return
+		}
+		h.handleVehicleMenu(callback, int(ids[0]), ids[1])
+	case strings.HasPrefix(data, "vehicle_pick_"):
+		ids, ok := parseIDs(strings.TrimPrefix(data, "vehicle_pick_"), 3)
+		if !ok {
+			h.edit(callback, "❌ Неверный формат назначения.", nil)
+			return
+		}
+		h.handleVehiclePick(callback, int(ids[0]), int(ids[1]), ids[2])
+	default:
+		h.edit(callback, "❓ Неизвестная команда.", nil)
+	}
+}
+
+// handleVehicleMenu lists the vehicles on the line with warnings about the order's load
+func (h *FleetHandler) handleVehicleMenu(callback *tgbotapi.CallbackQuery, orderID int, driverID int64) {
+	o, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		h.edit(callback, "❌ Заказ не найден.", nil)
+		return
+	}
+	vehicles, err := h.fleetService.AvailableVehicles(driverID)
+	if err != nil {
+		h.edit(callback, "❌ Ошибка получения автопарка.", nil)
+		return
+	}
+
+	now := time.Now()
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := range vehicles {
+		v := &vehicles[i]
+		label := fmt.Sprintf("%s · %g м³, %g т", fleet.VehicleName(v), v.CapacityM3, v.CapacityTons)
+		if len(fleet.CheckLoad(o, v, now)) > 0 {
+			label = "⚠️ " + label
+		}
+		if v.ID == o.VehicleID {
+			label = "✅ " + label
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("vehicle_pick_%d_%d_%d", orderID, v.ID, driverID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", fmt.Sprintf("assign_menu_%d", orderID)),
+	))
+
+	text := fmt.Sprintf("🚛 Выберите машину для заказа #%d%s:", orderID, loadText(o))
+	if len(vehicles) == 0 {
+		text = "🚛 Нет машин на линии. Добавьте машину командой /fleet add."
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.edit(callback, text, &markup)
+}
+
+// handleVehiclePick links the chosen vehicle to the order and its driver
+func (h *FleetHandler) handleVehiclePick(callback *tgbotapi.CallbackQuery, orderID, vehicleID int, driverID int64) {
+	o, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		h.edit(callback, "❌ Заказ не найден.", nil)
+		return
+	}
+	vehicle, warnings, err := h.fleetService.AssignVehicle(o, vehicleID, driverID)
+	switch err {
+	case nil:
+	case fleet.ErrVehicleNotFound, fleet.ErrVehicleUnavailable:
+		h.edit(callback, "❌ Машина не найдена или не на линии.", nil)
+		return
+	default:
+		utils.LogError(err)
+		h.edit(callback, "❌ Ошибка назначения машины.", nil)
+		return
+	}
+
+	text := fmt.Sprintf("✅ Машина %s назначена на заказ #%d.", fleet.VehicleName(vehicle), orderID)
+	if len(warnings) > 0 {
+		text += "\n\n" + strings.Join(warnings, "\n")
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 Назначение исполнителей", fmt.Sprintf("assign_menu_%d", orderID)),
+	))
+	h.edit(callback, text, &markup)
+	if driverID != 0 {
+		h.notify(driverID, fmt.Sprintf("🚛 На заказ #%d назначена машина %s.", orderID, fleet.VehicleName(vehicle)))
+	}
+}
+
+// VehicleMenuRow builds the button choosing a vehicle for an order and, when known, its driver
+func VehicleMenuRow(orderID int, driverID int64) []tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🚛 Выбрать машину", fmt.Sprintf("vehicle_menu_%d_%d", orderID, driverID)),
+	)
+}
+
+// parseIDs splits n underscore-separated IDs
+func parseIDs(data string, n int) ([]int64, bool) {
+	parts := strings.Split(data, "_")
+	if len(parts) != n {
+		return nil, false
+	}
+	ids := make([]int64, n)
+	for i, part := range parts {
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		ids[i] = id
+	}
+	return ids, true
+}
+
+// notify sends a new message to a user
+func (h *FleetHandler) notify(chatID int64, text string) {
+	if _, err := h.bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// edit replaces the callback message
+func (h *FleetHandler) edit(callback *tgbotapi.CallbackQuery, text string, markup *tgbotapi.InlineKeyboardMarkup) {
+	msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	msg.ReplyMarkup = markup
+	if _, err := h.bot.Send(msg); err != nil {
+		utils.LogError(err)
+	}
+}
@@ -2,15 +2,22 @@ package callbacks
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/skyzeper/telegram-bot/internal/menus"
 	"github.com/skyzeper/telegram-bot/internal/models"
 	"github.com/skyzeper/telegram-bot/internal/security"
 	"github.com/skyzeper/telegram-bot/internal/services/chat"
 	"github.com/skyzeper/telegram-bot/internal/services/executor"
+	"github.com/skyzeper/telegram-bot/internal/services/fiscal"
+	"github.com/skyzeper/telegram-bot/internal/services/geo"
 	"github.com/skyzeper/telegram-bot/internal/services/order"
 	"github.com/skyzeper/telegram-bot/internal/services/payment"
 	"github.com/skyzeper/telegram-bot/internal/services/review"
+	"github.com/skyzeper/telegram-bot/internal/services/route"
+	"github.com/skyzeper/telegram-bot/internal/services/tracking"
 	"github.com/skyzeper/telegram-bot/internal/services/user"
 	"github.com/skyzeper/telegram-bot/internal/state"
 	"github.com/skyzeper/telegram-bot/internal/utils"
@@ -27,6 +34,7 @@ type OrdersHandler struct {
 	executorService *executor.Service
 	paymentService *payment.Service
 	reviewService *review.Service
+	fiscalService *fiscal.Service
 	state         *state.Manager
 }
 
@@ -41,6 +49,7 @@ func NewOrdersHandler(
 	executorService *executor.Service,
 	paymentService *payment.Service,
 	reviewService *review.Service,
+	fiscalService *fiscal.Service,
 	state *state.Manager,
 ) *OrdersHandler {
 	return &OrdersHandler{
@@ -53,6 +62,7 @@ func NewOrdersHandler(
 		executorService: executorService,
 		paymentService: paymentService,
 		reviewService: reviewService,
+		fiscalService: fiscalService,
 		state:         state,
 	}
 }
@@ -63,16 +73,510 @@ func (h *OrdersHandler) Handle(callback *tgbotapi.CallbackQuery) {
 	data := callback.Data
 
 	// Placeholder for order handling logic
-	switch data {
-	case "accept_order_1":
+	switch {
+	case data == "accept_order_1":
 		h.sendMessage(chatID, callback.Message.MessageID, "✅ Заказ принят!")
-	case "cancel_order_1":
+	case data == "cancel_order_1":
 		h.sendMessage(chatID, callback.Message.MessageID, "❌ Заказ отменён.")
+	case strings.HasPrefix(data, "assign_drivers_"):
+		h.handleAssignPicker(callback, "driver", strings.TrimPrefix(data, "assign_drivers_"))
+	case strings.HasPrefix(data, "assign_loaders_"):
+		h.handleAssignPicker(callback, "loader", strings.TrimPrefix(data, "assign_loaders_"))
+	case strings.HasPrefix(data, "assign_menu_"):
+		orderID, err := strconv.Atoi(strings.TrimPrefix(data, "assign_menu_"))
+		if err != nil {
+			h.sendMessage(chatID, callback.Message.MessageID, "❌ Неверный формат заказа.")
+			return
+		}
+		h.sendMessage(chatID, callback.Message.MessageID, fmt.Sprintf("👷 Назначение исполнителей на заказ #%d:", orderID), h.menus.AssignExecutorMenu(orderID))
+	case strings.HasPrefix(data, "assign_pick_"):
+		h.handleAssignPick(callback, strings.TrimPrefix(data, "assign_pick_"))
+	case strings.HasPrefix(data, "cost_order_"):
+		h.handleCostOrder(callback, strings.TrimPrefix(data, "cost_order_"))
+	case strings.HasPrefix(data, "volume_order_"):
+		h.handleVolumeOrder(callback, strings.TrimPrefix(data, "volume_order_"))
+	case strings.HasPrefix(data, "cash_order_"):
+		h.handleCashOrder(callback, strings.TrimPrefix(data, "cash_order_"))
+	case strings.HasPrefix(data, "payment_confirm_"):
+		h.handlePaymentConfirm(callback, strings.TrimPrefix(data, "payment_confirm_"))
+	case strings.HasPrefix(data, "prepay_order_"):
+		h.handleAmountRequest(callback, "prepay", strings.TrimPrefix(data, "prepay_order_"))
+	case strings.HasPrefix(data, "refund_order_"):
+		h.handleAmountRequest(callback, "refund", strings.TrimPrefix(data, "refund_order_"))
+	case strings.HasPrefix(data, "refund_approve_"):
+		h.handleRefundDecision(callback, strings.TrimPrefix(data, "refund_approve_"), true)
+	case strings.HasPrefix(data, "refund_reject_"):
+		h.handleRefundDecision(callback, strings.TrimPrefix(data, "refund_reject_"), false)
+	case strings.HasPrefix(data, "order_"):
+		h.handleOrderCard(callback, strings.TrimPrefix(data, "order_"))
 	default:
 		h.sendMessage(chatID, callback.Message.MessageID, "❓ Неизвестная команда.")
 	}
 }
 
+// handleAssignPicker lists drivers or loaders with their client rating, best rated first
+func (h *OrdersHandler) handleAssignPicker(callback *tgbotapi.CallbackQuery, role, orderIDStr string) {
+	chatID := callback.Message.Chat.ID
+	if !h.security.HasRole(chatID, "operator") && !h.security.HasRole(chatID, "main_operator") {
+		h.sendMessage(chatID, callback.Message.MessageID, "🚫 Доступ запрещён.")
+		return
+	}
+	orderID, err := strconv.Atoi(orderIDStr)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Неверный формат заказа.")
+		return
+	}
+
+	staff, err := h.userService.ListUsersByRole(role)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Ошибка получения сотрудников.")
+		return
+	}
+	ratings, err := h.reviewService.GetExecutorRatings()
+	if err != nil {
+		utils.LogError(err)
+		ratings = map[int64]models.ExecutorRating{}
+	}
+	sort.SliceStable(staff, func(i, j int) bool {
+		return ratings[staff[i].ChatID].AvgRating > ratings[staff[j].ChatID].AvgRating
+	})
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, u := range staff {
+		if u.IsBlocked {
+			continue
+		}
+		label := fmt.Sprintf("%s %s · %s", u.FirstName, u.LastName, review.FormatExecutorRating(ratings[u.ChatID]))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("assign_pick_%d_%s_%d", orderID, role, u.ChatID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", fmt.Sprintf("assign_menu_%d", orderID)),
+	))
+
+	title := "🛻 Выберите водителя для заказа #%d:"
+	if role == "loader" {
+		title = "💪 Выберите грузчика для заказа #%d:"
+	}
+	h.sendMessage(chatID, callback.Message.MessageID, fmt.Sprintf(title, orderID), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// handleAssignPick assigns the chosen executor to the order
+func (h *OrdersHandler) handleAssignPick(callback *tgbotapi.CallbackQuery, data string) {
+	chatID := callback.Message.Chat.ID
+	if !h.security.HasRole(chatID, "operator") && !h.security.HasRole(chatID, "main_operator") {
+		h.sendMessage(chatID, callback.Message.MessageID, "🚫 Доступ запрещён.")
+		return
+	}
+	parts := strings.Split(data, "_")
+	if len(parts) != 3 {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Неверный формат назначения.")
+		return
+	}
+	orderID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Неверный формат заказа.")
+		return
+	}
+	userID, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Неверный формат сотрудника.")
+		return
+	}
+
+	if err := h.executorService.AssignExecutor(orderID, userID, parts[1]); err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Ошибка назначения исполнителя.")
+		return
+	}
+	markup := h.menus.AssignExecutorMenu(orderID)
+	text := fmt.Sprintf("✅ Исполнитель назначен на заказ #%d.", orderID)
+	if parts[1] == "driver" {
+		markup.InlineKeyboard = append([][]tgbotapi.InlineKeyboardButton{VehicleMenuRow(orderID, userID)}, markup.InlineKeyboard...)
+		text += " Выберите машину для водителя."
+	}
+	h.sendMessage(chatID, callback.Message.MessageID, text, markup)
+
+	o, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		utils.LogError(err)
+		return
+	}
+	msg := tgbotapi.NewMessage(userID, fmt.Sprintf(
+		"👷 Вы назначены на заказ #%d\n%s / %s\nАдрес: %s\nДата: %s\nТелефон: %s\n\nНажмите «🚚 Выехал», когда отправитесь к клиенту.",
+		o.ID, o.Category, o.Subcategory, o.Address, o.Date.Format("02.01.2006"), o.Phone,
+	))
+	msg.ReplyMarkup = ExecutorTripMarkup(o)
+	if _, err := h.bot.Send(msg); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// handleOrderCard shows order details with its payment balance and actions
+func (h *OrdersHandler) handleOrderCard(callback *tgbotapi.CallbackQuery, orderIDStr string) {
+	chatID := callback.Message.Chat.ID
+	if ok, err := h.security.HasAccess(chatID, "orders"); err != nil || !ok || h.security.HasRole(chatID, "client") {
+		h.sendMessage(chatID, callback.Message.MessageID, "🚫 Доступ запрещён.")
+		return
+	}
+	orderID, err := strconv.Atoi(orderIDStr)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Неверный формат заказа.")
+		return
+	}
+	o, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Заказ не найден.")
+		return
+	}
+	balance, err := h.paymentService.GetOrderBalance(o)
+	if err != nil {
+		utils.LogError(err)
+	}
+
+	text := fmt.Sprintf(
+		"📋 Заказ #%d (%s)\n%s / %s\nАдрес: %s%s\nДата: %s\nТелефон: %s\nОплата: %s%s\n\n%s",
+		o.ID, o.Status, o.Category, o.Subcategory, o.Address, zoneText(o), o.Date.Format("02.01.2006"), o.Phone, o.PaymentMethod,
+		loadText(o), BalanceText(balance),
+	)
+
+	var markup tgbotapi.InlineKeyboardMarkup
+	switch o.Status {
+	case "new":
+		markup = h.menus.OrderActionsMenu(o.ID)
+	case "completed":
+		markup = tgbotapi.NewInlineKeyboardMarkup()
+	default:
+		markup = h.menus.InProgressOrderActionsMenu(o.ID)
+	}
+	if balance.Paid-balance.Refunded > 0.005 {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("↩️ Возврат", fmt.Sprintf("refund_order_%d", o.ID)),
+		))
+	}
+	if o.Status != "completed" && o.Status != "canceled" {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📦 Объём груза", fmt.Sprintf("volume_order_%d", o.ID)),
+		), VehicleMenuRow(o.ID, 0))
+	}
+	if o.Status == tracking.StatusOnTheWay {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, ClientTrackingMarkup(o.ID).InlineKeyboard...)
+	}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonURL("🗺 Открыть на карте", OrderMapLink(o)),
+	))
+	h.sendMessage(chatID, callback.Message.MessageID, text, markup)
+}
+
+// OrderMapLink returns a map link to the order's shared location or a search for its address
+func OrderMapLink(o *models.Order) string {
+	return geo.AddressLink(geo.Point{Lat: o.Latitude, Lon: o.Longitude}, o.Address)
+}
+
+// handleAmountRequest asks staff for a prepayment or refund amount
+func (h *OrdersHandler) handleAmountRequest(callback *tgbotapi.CallbackQuery, module, orderIDStr string) {
+	chatID := callback.Message.Chat.ID
+	if !h.security.HasRole(chatID, "operator") && !h.security.HasRole(chatID, "main_operator") && !h.security.HasRole(chatID, "owner") {
+		h.sendMessage(chatID, callback.Message.MessageID, "🚫 Доступ запрещён.")
+		return
+	}
+	orderID, err := strconv.Atoi(orderIDStr)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Неверный формат заказа.")
+		return
+	}
+	o, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Заказ не найден.")
+		return
+	}
+	balance, err := h.paymentService.GetOrderBalance(o)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Ошибка расчёта баланса заказа.")
+		return
+	}
+
+	prompt := fmt.Sprintf("💳 Заказ #%d, к оплате %.2f руб.\nВведите сумму предоплаты:", o.ID, balance.Due)
+	totalSteps := 1
+	if module == "refund" {
+		prompt = fmt.Sprintf("↩️ Заказ #%d, оплачено %.2f руб.\nВведите сумму возврата:", o.ID, balance.Paid-balance.Refunded)
+		totalSteps = 2
+	} else if balance.FullyPaid() {
+		h.sendMessage(chatID, callback.Message.MessageID, fmt.Sprintf("💰 Заказ #%d уже оплачен.", o.ID))
+		return
+	}
+
+	h.state.Set(chatID, state.State{
+		Module:     module,
+		Step:       1,
+		TotalSteps: totalSteps,
+		Data:       map[string]interface{}{"order_id": orderID},
+	})
+	h.sendMessage(chatID, callback.Message.MessageID, prompt)
+}
+
+// handleRefundDecision approves or rejects a refund request
+func (h *OrdersHandler) handleRefundDecision(callback *tgbotapi.CallbackQuery, paymentIDStr string, approve bool) {
+	chatID := callback.Message.Chat.ID
+	if ok, err := h.security.HasAccess(chatID, "payments"); err != nil || !ok {
+		h.sendMessage(chatID, callback.Message.MessageID, "🚫 Доступ запрещён.")
+		return
+	}
+	paymentID, err := strconv.Atoi(paymentIDStr)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Неверный формат платежа.")
+		return
+	}
+
+	decide := h.paymentService.RejectRefund
+	if approve {
+		decide = h.paymentService.ApproveRefund
+	}
+	refund, err := decide(paymentID, chatID)
+	if err == payment.ErrRefundExceedsPaid {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Возврат больше суммы, оплаченной по заказу.")
+		return
+	}
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Возврат уже обработан, не найден или запрошен вами.")
+		return
+	}
+
+	if !approve {
+		h.sendMessage(chatID, callback.Message.MessageID, fmt.Sprintf("🚫 Возврат %.2f руб. по заказу #%d отклонён.", refund.Amount, refund.OrderID))
+		h.notify(refund.RequestedBy, fmt.Sprintf("🚫 Возврат %.2f руб. по заказу #%d отклонён.", refund.Amount, refund.OrderID))
+		return
+	}
+
+	text := fmt.Sprintf("✅ Возврат %.2f руб. по заказу #%d одобрен.\nПричина: %s", refund.Amount, refund.OrderID, refund.Reason)
+	o, err := h.orderService.GetOrder(refund.OrderID)
+	if err != nil {
+		utils.LogError(err)
+	} else if rec, err := h.paymentService.ReconcileOrder(o); err != nil {
+		utils.LogError(err)
+	} else if !rec.Covered() && o.PaymentConfirmed {
+		o.PaymentConfirmed = false
+		if err := h.orderService.UpdateOrder(o); err != nil {
+			utils.LogError(err)
+		}
+	}
+	h.sendMessage(chatID, callback.Message.MessageID, text)
+	h.notify(refund.RequestedBy, text)
+	h.notify(refund.UserID, fmt.Sprintf("↩️ По заказу #%d оформлен возврат %.2f руб.", refund.OrderID, refund.Amount))
+	if o != nil {
+		if _, err := h.fiscalService.IssueReceipt(o, refund); err != nil {
+			utils.LogError(err)
+		}
+	}
+}
+
+// handleCostOrder asks the operator for the agreed order cost
+func (h *OrdersHandler) handleCostOrder(callback *tgbotapi.CallbackQuery, orderIDStr string) {
+	chatID := callback.Message.Chat.ID
+	if !h.security.HasRole(chatID, "operator") && !h.security.HasRole(chatID, "main_operator") {
+		h.sendMessage(chatID, callback.Message.MessageID, "🚫 Доступ запрещён.")
+		return
+	}
+	orderID, err := strconv.Atoi(orderIDStr)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Неверный формат заказа.")
+		return
+	}
+	o, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Заказ не найден.")
+		return
+	}
+	if o.PaymentConfirmed {
+		h.sendMessage(chatID, callback.Message.MessageID, fmt.Sprintf("💰 Заказ #%d уже оплачен.", orderID))
+		return
+	}
+
+	h.state.Set(chatID, state.State{
+		Module:     "cost",
+		Step:       1,
+		TotalSteps: 1,
+		Data:       map[string]interface{}{"order_id": orderID},
+	})
+	h.sendMessage(chatID, callback.Message.MessageID, fmt.Sprintf(
+		"💰 Заказ #%d (оплата: %s)\nВведите согласованную с клиентом стоимость:",
+		orderID, o.PaymentMethod,
+	))
+}
+
+// handleVolumeOrder asks the operator for the volume and weight of the order's load
+func (h *OrdersHandler) handleVolumeOrder(callback *tgbotapi.CallbackQuery, orderIDStr string) {
+	chatID := callback.Message.Chat.ID
+	if !h.security.HasRole(chatID, "operator") && !h.security.HasRole(chatID, "main_operator") {
+		h.sendMessage(chatID, callback.Message.MessageID, "🚫 Доступ запрещён.")
+		return
+	}
+	orderID, err := strconv.Atoi(orderIDStr)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Неверный формат заказа.")
+		return
+	}
+
+	h.state.Set(chatID, state.State{
+		Module:     "volume",
+		Step:       1,
+		TotalSteps: 1,
+		Data:       map[string]interface{}{"order_id": orderID},
+	})
+	h.sendMessage(chatID, callback.Message.MessageID, fmt.Sprintf(
+		"📦 Заказ #%d\nВведите объём груза в м³ и, если известен, вес в тоннах, например: 12 1.5",
+		orderID,
+	))
+}
+
+// handleCashOrder asks the driver for the amount of cash collected at the site
+func (h *OrdersHandler) handleCashOrder(callback *tgbotapi.CallbackQuery, orderIDStr string) {
+	chatID := callback.Message.Chat.ID
+	orderID, err := strconv.Atoi(orderIDStr)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Неверный формат заказа.")
+		return
+	}
+	o, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Заказ не найден.")
+		return
+	}
+	if !isOrderDriver(o, chatID) && !h.security.HasRole(chatID, "main_operator") {
+		h.sendMessage(chatID, callback.Message.MessageID, "🚫 Наличные может учесть только водитель заказа.")
+		return
+	}
+	balance, err := h.paymentService.GetOrderBalance(o)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Ошибка расчёта баланса заказа.")
+		return
+	}
+
+	h.state.Set(chatID, state.State{
+		Module:     "cash",
+		Step:       1,
+		TotalSteps: 1,
+		Data:       map[string]interface{}{"order_id": orderID},
+	})
+	h.sendMessage(chatID, callback.Message.MessageID, fmt.Sprintf(
+		"💸 Заказ #%d, к оплате: %.2f руб.\nВведите сумму наличных, полученную от клиента:",
+		orderID, balance.Due,
+	))
+}
+
+// handlePaymentConfirm confirms cash hand-over and marks the order paid once payments cover the cost
+func (h *OrdersHandler) handlePaymentConfirm(callback *tgbotapi.CallbackQuery, paymentIDStr string) {
+	chatID := callback.Message.Chat.ID
+	if ok, err := h.security.HasAccess(chatID, "payments"); err != nil || !ok {
+		h.sendMessage(chatID, callback.Message.MessageID, "🚫 Доступ запрещён.")
+		return
+	}
+	paymentID, err := strconv.Atoi(paymentIDStr)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Неверный формат платежа.")
+		return
+	}
+
+	p, err := h.paymentService.ConfirmHandOver(paymentID, chatID)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Платёж уже подтверждён или не найден.")
+		return
+	}
+	o, err := h.orderService.GetOrder(p.OrderID)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Заказ не найден.")
+		return
+	}
+	rec, err := h.paymentService.ReconcileOrder(o)
+	if err != nil {
+		h.sendMessage(chatID, callback.Message.MessageID, "❌ Ошибка сверки оплаты.")
+		return
+	}
+
+	text := fmt.Sprintf("✅ Сдача %.2f руб. по заказу #%d подтверждена.\n%s", p.Amount, o.ID, ReconciliationText(rec))
+	if rec.Covered() && !o.PaymentConfirmed {
+		o.PaymentConfirmed = true
+		if err := h.orderService.UpdateOrder(o); err != nil {
+			utils.LogError(err)
+		} else {
+			text += "\n💰 Заказ полностью оплачен."
+		}
+	}
+	if _, err := h.fiscalService.IssueReceipt(o, p); err != nil {
+		utils.LogError(err)
+		text += "\n⚠️ Чек не сформирован."
+	}
+	h.sendMessage(chatID, callback.Message.MessageID, text)
+}
+
+// isOrderDriver reports whether the user is assigned to the order as a driver
+func isOrderDriver(o *models.Order, chatID int64) bool {
+	for _, ex := range o.Executors {
+		if ex.UserID == chatID && ex.Role == "driver" {
+			return true
+		}
+	}
+	return false
+}
+
+// BalanceText renders the order balance for the order card
+func BalanceText(b payment.OrderBalance) string {
+	text := fmt.Sprintf("💰 Стоимость: %.2f руб.\nОплачено: %.2f руб.", b.Cost, b.Paid)
+	if b.Refunded > 0 {
+		text += fmt.Sprintf("\nВозвращено: %.2f руб.", b.Refunded)
+	}
+	switch {
+	case b.Due > 0.005:
+		text += fmt.Sprintf("\nК оплате: %.2f руб.", b.Due)
+	case b.Due < -0.005:
+		text += fmt.Sprintf("\nПереплата: %.2f руб.", -b.Due)
+	default:
+		text += "\n✅ Оплачен полностью"
+	}
+	return text
+}
+
+// RefundDecisionMarkup builds approve/reject buttons for a refund request
+func RefundDecisionMarkup(paymentID int) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Одобрить возврат", fmt.Sprintf("refund_approve_%d", paymentID)),
+			tgbotapi.NewInlineKeyboardButtonData("🚫 Отклонить", fmt.Sprintf("refund_reject_%d", paymentID)),
+		),
+	)
+}
+
+// ReconciliationText describes how received money compares with the order cost
+func ReconciliationText(rec payment.Reconciliation) string {
+	switch {
+	case rec.Difference > 0.005:
+		return fmt.Sprintf("⚠️ Получено %.2f из %.2f руб., излишек %.2f руб.", rec.Paid, rec.Cost, rec.Difference)
+	case rec.Difference < -0.005:
+		return fmt.Sprintf("⚠️ Получено %.2f из %.2f руб., недостача %.2f руб.", rec.Paid, rec.Cost, -rec.Difference)
+	default:
+		return fmt.Sprintf("✅ Получено %.2f руб. — совпадает со стоимостью.", rec.Paid)
+	}
+}
+
+// PaymentConfirmMarkup returns the keyboard confirming hand-over of a payment
+func PaymentConfirmMarkup(paymentID int) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Подтвердить сдачу", fmt.Sprintf("payment_confirm_%d", paymentID)),
+		),
+	)
+}
+
+// notify sends a new message to a user
+func (h *OrdersHandler) notify(chatID int64, text string) {
+	if chatID == 0 {
+		return
+	}
+	if _, err := h.bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		utils.LogError(err)
+	}
+}
+
 // sendMessage sends a message in response to a callback
 func (h *OrdersHandler) sendMessage(chatID int64, messageID int, text string, replyMarkup ...interface{}) {
 	msg := tgbotapi.NewEditMessageText(chatID, messageID, text)
@@ -84,4 +588,55 @@ func (h *OrdersHandler) sendMessage(chatID int64, messageID int, text string, re
 	if _, err := h.bot.Send(msg); err != nil {
 		utils.LogError(err)
 	}
+}
+
+// RouteMarkup links every stop of a route and the whole route to the map
+func RouteMarkup(r *route.Route) *tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, stop := range r.Stops {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL(
+			fmt.Sprintf("%d. ~%s — заказ #%d", i+1, stop.Arrival.Format("15:04"), stop.OrderID),
+			geo.MapLink(stop.Point),
+		)))
+	}
+	for _, stop := range r.Unrouted {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL(
+			fmt.Sprintf("📍 Заказ #%d", stop.OrderID),
+			geo.AddressLink(stop.Point, stop.Address),
+		)))
+	}
+	if points := r.Points(); len(points) > 1 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("🧭 Весь маршрут", geo.RouteLink(points)),
+		))
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &markup
+}
+
+// zoneText describes the service zone of an order with its surcharge
+func zoneText(o *models.Order) string {
+	if o.Zone == "" {
+		return ""
+	}
+	if o.ZoneSurcharge > 0 {
+		return fmt.Sprintf("\nЗона: %s (доплата %.2f руб.)", o.Zone, o.ZoneSurcharge)
+	}
+	return "\nЗона: " + o.Zone
+}
+
+// loadText describes the volume and weight of an order's load
+func loadText(o *models.Order) string {
+	switch {
+	case o.Volume > 0 && o.Weight > 0:
+		return fmt.Sprintf("\nГруз: %g м³, %g т", o.Volume, o.Weight)
+	case o.Volume > 0:
+		return fmt.Sprintf("\nГруз: %g м³", o.Volume)
+	case o.Weight > 0:
+		return fmt.Sprintf("\nГруз: %g т", o.Weight)
+	}
+	return ""
 }
\ No newline at end of file
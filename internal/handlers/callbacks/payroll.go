@@ -0,0 +1,103 @@
+package callbacks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/menus"
+	"github.com/skyzeper/telegram-bot/internal/security"
+	"github.com/skyzeper/telegram-bot/internal/services/payroll"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// PayrollHandler handles payroll approval callbacks
+type PayrollHandler struct {
+	bot            *tgbotapi.BotAPI
+	security       *security.SecurityChecker
+	menus          *menus.MenuGenerator
+	payrollService *payroll.Service
+}
+
+// NewPayrollHandler creates a new PayrollHandler
+func NewPayrollHandler(
+	bot *tgbotapi.BotAPI,
+	security *security.SecurityChecker,
+	menus *menus.MenuGenerator,
+	payrollService *payroll.Service,
+) *PayrollHandler {
+	return &PayrollHandler{
+		bot:            bot,
+		security:       security,
+		menus:          menus,
+		payrollService: payrollService,
+	}
+}
+
+// Handle processes payroll callbacks
+func (h *PayrollHandler) Handle(callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	data := callback.Data
+
+	if ok, err := h.security.HasAccess(chatID, "payroll"); err != nil || !ok {
+		h.sendError(chatID, "🚫 Доступ запрещён.")
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(data, "payroll_approve_"):
+		h.handleDecision(callback, strings.TrimPrefix(data, "payroll_approve_"), true)
+	case strings.HasPrefix(data, "payroll_reject_"):
+		h.handleDecision(callback, strings.TrimPrefix(data, "payroll_reject_"), false)
+	default:
+		h.sendError(chatID, "❓ Неизвестная команда.")
+	}
+}
+
+// handleDecision approves or rejects a draft payroll run
+func (h *PayrollHandler) handleDecision(callback *tgbotapi.CallbackQuery, runIDStr string, approve bool) {
+	chatID := callback.Message.Chat.ID
+	runID, err := strconv.Atoi(runIDStr)
+	if err != nil {
+		h.sendError(chatID, "❌ Неверный формат ведомости.")
+		return
+	}
+
+	decide := h.payrollService.Reject
+	if approve {
+		decide = h.payrollService.Approve
+	}
+	run, err := decide(runID, chatID)
+	if err != nil {
+		utils.LogError(err)
+		h.sendError(chatID, fmt.Sprintf("❌ Ведомость не обработана: %v", err))
+		return
+	}
+
+	result := "🚫 Ведомость отклонена."
+	if approve {
+		result = "✅ Ведомость утверждена, зарплата проведена, расчётные листки отправлены."
+	}
+	reply := tgbotapi.NewEditMessageText(chatID, callback.Message.MessageID, payroll.FormatRun(run)+"\n\n"+result)
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// PayrollDecisionMarkup builds approve and reject buttons for a draft payroll run
+func PayrollDecisionMarkup(runID int) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Утвердить", fmt.Sprintf("payroll_approve_%d", runID)),
+			tgbotapi.NewInlineKeyboardButtonData("🚫 Отклонить", fmt.Sprintf("payroll_reject_%d", runID)),
+		),
+	)
+}
+
+// sendError sends an error message
+func (h *PayrollHandler) sendError(chatID int64, text string) {
+	reply := tgbotapi.NewMessage(chatID, text)
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
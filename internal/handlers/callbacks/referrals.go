@@ -58,7 +58,7 @@ func (h *ReferralsHandler) handleReferralLink(callback *tgbotapi.CallbackQuery,
 		return
 	}
 
-	link := fmt.Sprintf("@vseVsimferopole?start=ref_%d", callback.Message.Chat.ID)
+	link := h.referralService.ReferralLink(callback.Message.Chat.ID)
 	reply := tgbotapi.NewEditMessageText(
 		callback.Message.Chat.ID,
 		callback.Message.MessageID,
@@ -77,18 +77,41 @@ func (h *ReferralsHandler) handleReferralQR(callback *tgbotapi.CallbackQuery, us
 		return
 	}
 
-	link := fmt.Sprintf("@vseVsimferopole?start=ref_%d", callback.Message.Chat.ID)
-	qrPath, err := h.referralService.GenerateQRCode(link, callback.Message.Chat.ID)
+	chatID := callback.Message.Chat.ID
+	link := h.referralService.ReferralLink(chatID)
+	caption := "📷 Ваш реферальный QR-код!\nПриглашайте друзей и получайте 500 рублей за заказ от 10,000 рублей! 🎉"
+
+	fileID, err := h.referralService.GetCachedQRCode(chatID, link)
 	if err != nil {
-		h.sendError(callback.Message.Chat.ID, "Ошибка создания QR-кода.")
-		return
+		utils.LogError(err)
+	}
+
+	var file tgbotapi.RequestFileData
+	if fileID != "" {
+		file = tgbotapi.FileID(fileID)
+	} else {
+		png, err := h.referralService.GenerateQRCode(link)
+		if err != nil {
+			h.sendError(chatID, "Ошибка создания QR-кода.")
+			return
+		}
+		file = tgbotapi.FileBytes{Name: "referral_qr.png", Bytes: png}
 	}
 
-	photo := tgbotapi.NewPhoto(callback.Message.Chat.ID, tgbotapi.FilePath(qrPath))
-	photo.Caption = "📷 Ваш реферальный QR-код!\nПриглашайте друзей и получайте 500 рублей за заказ от 10,000 рублей! 🎉"
+	photo := tgbotapi.NewPhoto(chatID, file)
+	photo.Caption = caption
 	photo.ReplyMarkup = h.menus.ReferralMenu()
-	if _, err := h.bot.Send(photo); err != nil {
+	sent, err := h.bot.Send(photo)
+	if err != nil {
 		utils.LogError(err)
+		return
+	}
+
+	if fileID == "" && len(sent.Photo) > 0 {
+		uploaded := sent.Photo[len(sent.Photo)-1].FileID
+		if err := h.referralService.CacheQRCode(chatID, link, uploaded); err != nil {
+			utils.LogError(err)
+		}
 	}
 }
 
@@ -8,6 +8,7 @@ import (
 	"github.com/skyzeper/telegram-bot/internal/models"
 	"github.com/skyzeper/telegram-bot/internal/menus"
 	"github.com/skyzeper/telegram-bot/internal/services/review"
+	"github.com/skyzeper/telegram-bot/internal/services/user"
 	"github.com/skyzeper/telegram-bot/internal/state"
 	"github.com/skyzeper/telegram-bot/internal/security"
 	"github.com/skyzeper/telegram-bot/internal/utils"
@@ -16,35 +17,60 @@ import (
 // ReviewsHandler handles review-related callbacks
 type ReviewsHandler struct {
 	bot           *tgbotapi.BotAPI
-	security      security.SecurityChecker
+	security      *security.SecurityChecker
 	menus         *menus.MenuGenerator
 	reviewService *review.Service
-	state         *state.State
+	userService   *user.Service
+	escalations   *EscalationsHandler
+	state         *state.Manager
 }
 
 // NewReviewsHandler creates a new ReviewsHandler
 func NewReviewsHandler(
 	bot *tgbotapi.BotAPI,
-	security security.SecurityChecker,
+	security *security.SecurityChecker,
 	menus *menus.MenuGenerator,
 	reviewService *review.Service,
-	state *state.State,
+	userService *user.Service,
+	escalations *EscalationsHandler,
+	state *state.Manager,
 ) *ReviewsHandler {
 	return &ReviewsHandler{
 		bot:           bot,
 		security:      security,
 		menus:         menus,
 		reviewService: reviewService,
+		userService:   userService,
+		escalations:   escalations,
 		state:         state,
 	}
 }
 
+// Handle processes review-related callbacks
+func (h *ReviewsHandler) Handle(callback *tgbotapi.CallbackQuery) {
+	user, err := h.userService.GetUser(callback.Message.Chat.ID)
+	if err != nil {
+		h.sendError(callback.Message.Chat.ID, "Пользователь не найден.")
+		return
+	}
+	h.HandleReviewsCallback(callback, user, callback.Data)
+}
+
 // HandleReviewsCallback processes review-related callback queries
 func (h *ReviewsHandler) HandleReviewsCallback(callback *tgbotapi.CallbackQuery, user *models.User, data string) {
-	if strings.HasPrefix(data, "review_rate_") {
+	switch {
+	case strings.HasPrefix(data, "review_rate_"):
 		h.handleReviewRate(callback, user, data)
-	} else if strings.HasPrefix(data, "rate_") {
+	case strings.HasPrefix(data, "rate_"):
 		h.handleRatingSubmit(callback, user, data)
+	case strings.HasPrefix(data, "review_skip_"):
+		h.handleCommentSkip(callback, user)
+	case strings.HasPrefix(data, "review_done_"):
+		h.handleReviewDone(callback, user)
+	case strings.HasPrefix(data, "review_approve_"):
+		h.handleModeration(callback, user, strings.TrimPrefix(data, "review_approve_"), true)
+	case strings.HasPrefix(data, "review_reject_"):
+		h.handleModeration(callback, user, strings.TrimPrefix(data, "review_reject_"), false)
 	}
 }
 
@@ -62,15 +88,23 @@ func (h *ReviewsHandler) handleReviewRate(callback *tgbotapi.CallbackQuery, user
 		return
 	}
 
-	h.state.SetState(callback.Message.Chat.ID, state.State{
-		Module:      "review",
-		Step:        1,
-		TotalSteps:  2,
-		Data:        map[string]interface{}{"order_id": orderID},
+	if _, err := h.reviewService.GetReview(orderID); err == nil {
+		reply := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, "🌟 Вы уже оценили этот заказ. Спасибо!")
+		if _, err := h.bot.Send(reply); err != nil {
+			utils.LogError(err)
+		}
+		return
+	}
+
+	h.state.Set(callback.Message.Chat.ID, state.State{
+		Module:     "review",
+		Step:       1,
+		TotalSteps: 3,
+		Data:       map[string]interface{}{"order_id": orderID},
 	})
 
 	reply := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, "🌟 Оцените заказ (1-5):")
-	reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+	markup := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("1", fmt.Sprintf("rate_%d_1", orderID)),
 			tgbotapi.NewInlineKeyboardButtonData("2", fmt.Sprintf("rate_%d_2", orderID)),
@@ -81,12 +115,13 @@ func (h *ReviewsHandler) handleReviewRate(callback *tgbotapi.CallbackQuery, user
 			tgbotapi.NewInlineKeyboardButtonData("5", fmt.Sprintf("rate_%d_5", orderID)),
 		),
 	)
+	reply.ReplyMarkup = &markup
 	if _, err := h.bot.Send(reply); err != nil {
 		utils.LogError(err)
 	}
 }
 
-// handleRatingSubmit submits the review rating
+// handleRatingSubmit stores the rating and asks for an optional comment
 func (h *ReviewsHandler) handleRatingSubmit(callback *tgbotapi.CallbackQuery, user *models.User, data string) {
 	if !h.security.HasRole(callback.Message.Chat.ID, "user") {
 		h.sendUnauthorized(callback.Message.Chat.ID)
@@ -111,22 +146,167 @@ func (h *ReviewsHandler) handleRatingSubmit(callback *tgbotapi.CallbackQuery, us
 		return
 	}
 
-	if err := h.reviewService.SubmitReview(orderID, user.ChatID, rating, ""); err != nil {
+	h.state.Set(callback.Message.Chat.ID, state.State{
+		Module:     "review",
+		Step:       2,
+		TotalSteps: 3,
+		Data: map[string]interface{}{
+			"order_id": orderID,
+			"rating":   rating,
+			"comment":  "",
+			"photos":   []string{},
+		},
+	})
+
+	reply := tgbotapi.NewEditMessageText(
+		callback.Message.Chat.ID,
+		callback.Message.MessageID,
+		fmt.Sprintf("%s Спасибо за оценку!\n✍️ Напишите комментарий к отзыву или нажмите «Пропустить».", strings.Repeat("⭐", rating)),
+	)
+	markup := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⏭ Пропустить", fmt.Sprintf("review_skip_%d", orderID)),
+		),
+	)
+	reply.ReplyMarkup = &markup
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// handleCommentSkip moves the review to the photo step without a comment
+func (h *ReviewsHandler) handleCommentSkip(callback *tgbotapi.CallbackQuery, user *models.User) {
+	currentState := h.state.Get(callback.Message.Chat.ID)
+	if currentState.Module != "review" || currentState.Step != 2 {
+		h.sendError(callback.Message.Chat.ID, "Отзыв уже отправлен или устарел.")
+		return
+	}
+	currentState.Step = 3
+	h.state.Set(callback.Message.Chat.ID, currentState)
+
+	orderID, _ := currentState.Data["order_id"].(int)
+	reply := tgbotapi.NewEditMessageText(
+		callback.Message.Chat.ID,
+		callback.Message.MessageID,
+		fmt.Sprintf("📸 Прикрепите до %d фото или нажмите «Готово».", review.MaxPhotos),
+	)
+	markup := ReviewDoneMarkup(orderID)
+	reply.ReplyMarkup = &markup
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// handleReviewDone saves the review and sends it to moderation or the channel
+func (h *ReviewsHandler) handleReviewDone(callback *tgbotapi.CallbackQuery, user *models.User) {
+	currentState := h.state.Get(callback.Message.Chat.ID)
+	if currentState.Module != "review" || currentState.Step < 2 {
+		h.sendError(callback.Message.Chat.ID, "Отзыв уже отправлен или устарел.")
+		return
+	}
+
+	orderID, _ := currentState.Data["order_id"].(int)
+	rating, _ := currentState.Data["rating"].(int)
+	comment, _ := currentState.Data["comment"].(string)
+	photos, _ := currentState.Data["photos"].([]string)
+
+	submitted, err := h.reviewService.SubmitReview(orderID, user.ChatID, rating, comment, photos)
+	if err != nil {
 		h.sendError(callback.Message.Chat.ID, "Ошибка отправки отзыва.")
 		return
 	}
+	h.state.Clear(callback.Message.Chat.ID)
 
-	h.state.ClearState(callback.Message.Chat.ID)
+	if h.escalations != nil {
+		h.escalations.OpenForReview(submitted)
+	}
+	if submitted.Status == review.StatusPending {
+		h.notifyModerators(submitted)
+	} else if err := h.reviewService.PublishReview(submitted); err != nil {
+		utils.LogError(err)
+	}
 
 	reply := tgbotapi.NewEditMessageText(
 		callback.Message.Chat.ID,
 		callback.Message.MessageID,
 		"🌟 Спасибо за ваш отзыв! 🙌\nВаш голос помогает нам стать лучше!",
 	)
-	reply.ReplyMarkup = h.menus.MainMenu(user)
 	if _, err := h.bot.Send(reply); err != nil {
 		utils.LogError(err)
 	}
+	h.sendMainMenu(callback.Message.Chat.ID, user)
+}
+
+// handleModeration approves or rejects a pending review
+func (h *ReviewsHandler) handleModeration(callback *tgbotapi.CallbackQuery, user *models.User, reviewIDStr string, approve bool) {
+	if !h.security.HasRole(callback.Message.Chat.ID, "main_operator") {
+		h.sendUnauthorized(callback.Message.Chat.ID)
+		return
+	}
+
+	reviewID, err := strconv.Atoi(reviewIDStr)
+	if err != nil {
+		h.sendError(callback.Message.Chat.ID, "Неверный формат отзыва.")
+		return
+	}
+
+	moderated, err := h.reviewService.ModerateReview(reviewID, user.ChatID, approve)
+	if err != nil {
+		h.sendError(callback.Message.Chat.ID, "Отзыв уже обработан или не найден.")
+		return
+	}
+
+	text := fmt.Sprintf("❌ Отзыв #%d отклонён.", moderated.ID)
+	if approve {
+		text = fmt.Sprintf("✅ Отзыв #%d одобрен.", moderated.ID)
+		if err := h.reviewService.PublishReview(moderated); err != nil {
+			utils.LogError(err)
+			text += "\n⚠️ Не удалось опубликовать в канале."
+		}
+	}
+
+	reply := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// notifyModerators sends a pending review to all main operators
+func (h *ReviewsHandler) notifyModerators(pending *models.Review) {
+	operators, err := h.userService.ListUsersByRole("main_operator")
+	if err != nil {
+		utils.LogError(err)
+		return
+	}
+
+	text := "🛡 Отзыв на модерации\n\n" + review.FormatReview(pending)
+	markup := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Опубликовать", fmt.Sprintf("review_approve_%d", pending.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отклонить", fmt.Sprintf("review_reject_%d", pending.ID)),
+		),
+	)
+	for _, operator := range operators {
+		for _, fileID := range pending.Photos {
+			if _, err := h.bot.Send(tgbotapi.NewPhoto(operator.ChatID, tgbotapi.FileID(fileID))); err != nil {
+				utils.LogError(err)
+			}
+		}
+		msg := tgbotapi.NewMessage(operator.ChatID, text)
+		msg.ReplyMarkup = markup
+		if _, err := h.bot.Send(msg); err != nil {
+			utils.LogError(err)
+		}
+	}
+}
+
+// ReviewDoneMarkup returns the keyboard finishing the photo step of a review
+func ReviewDoneMarkup(orderID int) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Готово", fmt.Sprintf("review_done_%d", orderID)),
+		),
+	)
 }
 
 // sendMainMenu sends the main menu
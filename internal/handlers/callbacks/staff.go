@@ -7,6 +7,7 @@ import (
 	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/skyzeper/telegram-bot/internal/models"
 	"github.com/skyzeper/telegram-bot/internal/menus"
+	"github.com/skyzeper/telegram-bot/internal/services/review"
 	"github.com/skyzeper/telegram-bot/internal/services/user"
 	"github.com/skyzeper/telegram-bot/internal/state"
 	"github.com/skyzeper/telegram-bot/internal/security"
@@ -15,11 +16,12 @@ import (
 
 // StaffHandler handles staff-related callbacks
 type StaffHandler struct {
-	bot         *tgbotapi.BotAPI
-	security    security.SecurityChecker
-	menus       *menus.MenuGenerator
-	userService *user.Service
-	state       *state.State
+	bot           *tgbotapi.BotAPI
+	security      security.SecurityChecker
+	menus         *menus.MenuGenerator
+	userService   *user.Service
+	reviewService *review.Service
+	state         *state.State
 }
 
 // NewStaffHandler creates a new StaffHandler
@@ -28,14 +30,16 @@ func NewStaffHandler(
 	security security.SecurityChecker,
 	menus *menus.MenuGenerator,
 	userService *user.Service,
+	reviewService *review.Service,
 	state *state.State,
 ) *StaffHandler {
 	return &StaffHandler{
-		bot:         bot,
-		security:    security,
-		menus:       menus,
-		userService: userService,
-		state:       state,
+		bot:           bot,
+		security:      security,
+		menus:         menus,
+		userService:   userService,
+		reviewService: reviewService,
+		state:         state,
 	}
 }
 
@@ -203,7 +207,7 @@ func (h *StaffHandler) handleStaffSelect(callback *tgbotapi.CallbackQuery, user
 	reply := tgbotapi.NewEditMessageText(
 		callback.Message.Chat.ID,
 		callback.Message.MessageID,
-		fmt.Sprintf("🧑‍💼 Сотрудник: %s %s\nРоль: %s", staff.FirstName, staff.LastName, staff.Role),
+		h.staffCard(staff),
 	)
 	reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
@@ -220,6 +224,20 @@ func (h *StaffHandler) handleStaffSelect(callback *tgbotapi.CallbackQuery, user
 	}
 }
 
+// staffCard renders staff details, with the client rating for drivers and loaders
+func (h *StaffHandler) staffCard(staff *models.User) string {
+	text := fmt.Sprintf("🧑‍💼 Сотрудник: %s %s\nРоль: %s", staff.FirstName, staff.LastName, staff.Role)
+	if staff.Role != "driver" && staff.Role != "loader" {
+		return text
+	}
+	rating, err := h.reviewService.GetExecutorRating(staff.ChatID)
+	if err != nil {
+		utils.LogError(err)
+		return text
+	}
+	return text + "\nРейтинг: " + review.FormatExecutorRating(*rating)
+}
+
 // handleStaffAction performs staff actions
 func (h *StaffHandler) handleStaffAction(callback *tgbotapi.CallbackQuery, user *models.User, data string) {
 	parts := strings.Split(strings.TrimPrefix(data, "staff_action_"), "_")
@@ -2,10 +2,13 @@ package callbacks
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/skyzeper/telegram-bot/internal/models"
 	"github.com/skyzeper/telegram-bot/internal/menus"
+	"github.com/skyzeper/telegram-bot/internal/services/review"
 	"github.com/skyzeper/telegram-bot/internal/services/stats"
 	"github.com/skyzeper/telegram-bot/internal/security"
 	"github.com/skyzeper/telegram-bot/internal/utils"
@@ -41,9 +44,21 @@ func (h *StatsHandler) HandleStatsCallback(callback *tgbotapi.CallbackQuery, use
 		return
 	}
 
-	if data == "stats_date" {
-		h.handleMonthSelection(callback)
+	if data == "stats_range" {
+		h.handleRangeHint(callback)
+	} else if data == "stats_date" || strings.HasPrefix(data, "stats_date_") {
+		h.handleMonthSelection(callback, data)
+	} else if data == "stats_referrals" {
+		h.handleReferralPeriodSelection(callback)
+	} else if strings.HasPrefix(data, "stats_referrals_") {
+		h.handleReferralReport(callback, data)
+	} else if data == "stats_executors" {
+		h.handleExecutorPeriodSelection(callback)
+	} else if strings.HasPrefix(data, "stats_executors_") {
+		h.handleExecutorRanking(callback, data)
 	} else if strings.HasPrefix(data, "stats_month_") {
+		h.handleMonthStats(callback, data)
+	} else if strings.HasPrefix(data, "stats_weeks_") {
 		h.handleWeekSelection(callback, data)
 	} else if strings.HasPrefix(data, "stats_week_") {
 		h.handleWeekStats(callback, data)
@@ -52,141 +67,337 @@ func (h *StatsHandler) HandleStatsCallback(callback *tgbotapi.CallbackQuery, use
 	}
 }
 
-// handleStats shows statistics
+// handleStats shows statistics for the current day, week, month, year or all time
 func (h *StatsHandler) handleStats(callback *tgbotapi.CallbackQuery, user *models.User, data string) {
-	var stats models.Stats
-	var err error
-	period := strings.TrimPrefix(data, "stats_")
-	switch period {
-	case "day":
-		stats, err = h.statsService.GetStatsForDay()
-	case "week":
-		stats, err = h.statsService.GetStatsForWeek()
-	case "month":
-		stats, err = h.statsService.GetStatsForMonth()
-	case "year":
-		stats, err = h.statsService.GetStatsForYear()
-	case "all":
-		stats, err = h.statsService.GetStatsForAllTime()
-	default:
+	period, err := h.statsService.CurrentPeriod(strings.TrimPrefix(data, "stats_"))
+	if err != nil {
 		h.sendError(callback.Message.Chat.ID, "Неверный период.")
 		return
 	}
+	h.showReport(callback, period, tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "back_to_stats"),
+		),
+	))
+}
 
+// showReport edits the message into the statistics of a period compared with the previous one
+func (h *StatsHandler) showReport(callback *tgbotapi.CallbackQuery, period stats.Period, markup tgbotapi.InlineKeyboardMarkup) {
+	report, err := h.statsService.GetStatsReport(period)
 	if err != nil {
 		h.sendError(callback.Message.Chat.ID, "Ошибка получения статистики.")
 		return
 	}
+	reply := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, stats.FormatStatsReport(report))
+	reply.ReplyMarkup = &markup
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+	SendStatsCharts(h.bot, h.statsService, callback.Message.Chat.ID, period, report.Current)
+}
+
+// SendStatsCharts sends the charts of a period as an album, or as a single photo when only one has data
+func SendStatsCharts(bot *tgbotapi.BotAPI, statsService *stats.Service, chatID int64, period stats.Period, current models.Stats) {
+	charts, err := statsService.RenderCharts(period, current)
+	if err != nil {
+		utils.LogError(err)
+		return
+	}
+	caption := "📈 Графики за " + period.Label()
 
+	switch len(charts) {
+	case 0:
+		return
+	case 1:
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: charts[0].Name, Bytes: charts[0].PNG})
+		photo.Caption = caption
+		if _, err := bot.Send(photo); err != nil {
+			utils.LogError(err)
+		}
+	default:
+		media := make([]interface{}, 0, len(charts))
+		for i, chart := range charts {
+			photo := tgbotapi.NewInputMediaPhoto(tgbotapi.FileBytes{Name: chart.Name, Bytes: chart.PNG})
+			if i == 0 {
+				photo.Caption = caption
+			}
+			media = append(media, photo)
+		}
+		if _, err := bot.SendMediaGroup(tgbotapi.NewMediaGroup(chatID, media)); err != nil {
+			utils.LogError(err)
+		}
+	}
+}
+
+// handleRangeHint explains how to request statistics for a custom period
+func (h *StatsHandler) handleRangeHint(callback *tgbotapi.CallbackQuery) {
 	reply := tgbotapi.NewEditMessageText(
 		callback.Message.Chat.ID,
 		callback.Message.MessageID,
-		fmt.Sprintf(
-			"📊 Статистика за %s:\n"+
-				"- Всего заказов: %d\n"+
-				"- Вывоз мусора: %d\n"+
-				"- Демонтаж: %d\n"+
-				"- Стройматериалы: %d\n"+
-				"- Сумма: %.2f руб.\n"+
-				"- Долги водителей: %.2f руб.\n\n📈 Держите руку на пульсе бизнеса!",
-			period,
-			stats.TotalOrders,
-			stats.WasteRemovalOrders,
-			stats.DemolitionOrders,
-			stats.ConstructionOrders,
-			stats.TotalAmount,
-			stats.DriverDebts,
-		),
+		"🗓 Отправьте команду с первым и последним днём периода:\n/stats 01.03.2024 15.03.2024",
 	)
-	reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+	markup := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "back_to_stats"),
 		),
 	)
+	reply.ReplyMarkup = &markup
 	if _, err := h.bot.Send(reply); err != nil {
 		utils.LogError(err)
 	}
 }
 
-// handleMonthSelection shows month selection for stats
-func (h *StatsHandler) handleMonthSelection(callback *tgbotapi.CallbackQuery) {
-	reply := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, "📅 Выберите месяц:")
-	reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Январь", "stats_month_01"),
-			tgbotapi.NewInlineKeyboardButtonData("Февраль", "stats_month_02"),
-			tgbotapi.NewInlineKeyboardButtonData("Март", "stats_month_03"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Апрель", "stats_month_04"),
-			tgbotapi.NewInlineKeyboardButtonData("Май", "stats_month_05"),
-			tgbotapi.NewInlineKeyboardButtonData("Июнь", "stats_month_06"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Июль", "stats_month_07"),
-			tgbotapi.NewInlineKeyboardButtonData("Август", "stats_month_08"),
-			tgbotapi.NewInlineKeyboardButtonData("Сентябрь", "stats_month_09"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Октябрь", "stats_month_10"),
-			tgbotapi.NewInlineKeyboardButtonData("Ноябрь", "stats_month_11"),
-			tgbotapi.NewInlineKeyboardButtonData("Декабрь", "stats_month_12"),
-		),
+// handleReferralPeriodSelection shows period selection for the referral report
+func (h *StatsHandler) handleReferralPeriodSelection(callback *tgbotapi.CallbackQuery) {
+	reply := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, "🏆 Выберите период отчёта по рефералам:")
+	markup := h.menus.ReferralStatsMenu()
+	reply.ReplyMarkup = &markup
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// handleReferralReport shows top referrers and campaign attribution for a period
+func (h *StatsHandler) handleReferralReport(callback *tgbotapi.CallbackQuery, data string) {
+	kind := strings.TrimPrefix(data, "stats_referrals_")
+	period, err := h.statsService.CurrentPeriod(kind)
+	if err != nil {
+		h.sendError(callback.Message.Chat.ID, "Неверный период.")
+		return
+	}
+	report, err := h.statsService.GetReferralReport(kind, 10)
+	if err != nil {
+		h.sendError(callback.Message.Chat.ID, "Ошибка получения отчёта по рефералам.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🏆 Топ рефереров за %s:\n", period.Label()))
+	if len(report.TopReferrers) == 0 {
+		sb.WriteString("- нет данных\n")
+	}
+	for i, r := range report.TopReferrers {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("ID %d", r.InviterID)
+		}
+		sb.WriteString(fmt.Sprintf(
+			"%d. %s — новых: %d, заказов: %d, выручка: %.2f руб.\n",
+			i+1, name, r.NewUsers, r.Orders, r.Revenue,
+		))
+	}
+
+	sb.WriteString("\n📣 Кампании:\n")
+	if len(report.Campaigns) == 0 {
+		sb.WriteString("- нет кампаний (создайте через /campaign)\n")
+	}
+	for _, c := range report.Campaigns {
+		sb.WriteString(fmt.Sprintf(
+			"- %s (%s) — новых: %d, заказов: %d, выручка: %.2f руб.\n",
+			c.Name, c.Code, c.NewUsers, c.Orders, c.Revenue,
+		))
+	}
+
+	reply := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, sb.String())
+	markup := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "back_to_stats"),
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "stats_referrals"),
 		),
 	)
+	reply.ReplyMarkup = &markup
 	if _, err := h.bot.Send(reply); err != nil {
 		utils.LogError(err)
 	}
 }
 
-// handleWeekSelection shows week selection for stats
-func (h *StatsHandler) handleWeekSelection(callback *tgbotapi.CallbackQuery, data string) {
-	month := strings.TrimPrefix(data, "stats_month_")
-	reply := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, fmt.Sprintf("📅 Выберите неделю для месяца %s:", month))
-	reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+// handleExecutorPeriodSelection shows period selection for the executor ranking
+func (h *StatsHandler) handleExecutorPeriodSelection(callback *tgbotapi.CallbackQuery) {
+	reply := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, "👷 Выберите период рейтинга исполнителей:")
+	markup := h.menus.ExecutorStatsMenu()
+	reply.ReplyMarkup = &markup
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// handleExecutorRanking shows executors ranked by client rating for a period
+func (h *StatsHandler) handleExecutorRanking(callback *tgbotapi.CallbackQuery, data string) {
+	kind := strings.TrimPrefix(data, "stats_executors_")
+	period, err := h.statsService.CurrentPeriod(kind)
+	if err != nil {
+		h.sendError(callback.Message.Chat.ID, "Неверный период.")
+		return
+	}
+	ranking, err := h.statsService.GetExecutorRanking(kind, 10)
+	if err != nil {
+		h.sendError(callback.Message.Chat.ID, "Ошибка получения рейтинга исполнителей.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("👷 Рейтинг исполнителей за %s:\n", period.Label()))
+	if len(ranking) == 0 {
+		sb.WriteString("- нет отзывов за период\n")
+	}
+	for i, r := range ranking {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("ID %d", r.UserID)
+		}
+		sb.WriteString(fmt.Sprintf(
+			"%d. %s (%s) — %s, заказов: %d\n",
+			i+1, name, r.Role, review.FormatExecutorRating(r), r.Orders,
+		))
+	}
+
+	reply := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, sb.String())
+	markup := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("1 неделя", fmt.Sprintf("stats_week_%s_1", month)),
-			tgbotapi.NewInlineKeyboardButtonData("2 неделя", fmt.Sprintf("stats_week_%s_2", month)),
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "stats_executors"),
 		),
+	)
+	reply.ReplyMarkup = &markup
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// handleMonthSelection shows the months of a year (stats_date_2024), the current year by default
+func (h *StatsHandler) handleMonthSelection(callback *tgbotapi.CallbackQuery, data string) {
+	currentYear := time.Now().In(h.statsService.Location()).Year()
+	year := currentYear
+	if suffix := strings.TrimPrefix(data, "stats_date_"); suffix != data {
+		parsed, err := strconv.Atoi(suffix)
+		if err != nil || parsed < 2000 || parsed > currentYear {
+			h.sendError(callback.Message.Chat.ID, "Неверный год.")
+			return
+		}
+		year = parsed
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for month := time.January; month <= time.December; month += 3 {
+		var row []tgbotapi.InlineKeyboardButton
+		for m := month; m < month+3; m++ {
+			name := stats.MonthName(m)
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+				strings.ToUpper(name[:2])+name[2:], fmt.Sprintf("stats_month_%d_%02d", year, int(m)),
+			))
+		}
+		rows = append(rows, row)
+	}
+	yearRow := []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("◀️ %d", year-1), fmt.Sprintf("stats_date_%d", year-1)),
+	}
+	if year < currentYear {
+		yearRow = append(yearRow, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d ▶️", year+1), fmt.Sprintf("stats_date_%d", year+1)))
+	}
+	rows = append(rows, yearRow, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "back_to_stats"),
+	))
+
+	reply := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, fmt.Sprintf("📅 Выберите месяц %d года:", year))
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	reply.ReplyMarkup = &markup
+	if _, err := h.bot.Send(reply); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// parseYearMonth reads "2024_03" or a bare month "03" of the current year
+func (h *StatsHandler) parseYearMonth(value string) (int, time.Month, bool) {
+	parts := strings.Split(value, "_")
+	year := time.Now().In(h.statsService.Location()).Year()
+	if len(parts) == 2 {
+		parsed, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return 0, 0, false
+		}
+		year = parsed
+		parts = parts[1:]
+	}
+	month, err := strconv.Atoi(parts[0])
+	if len(parts) != 1 || err != nil || month < 1 || month > 12 {
+		return 0, 0, false
+	}
+	return year, time.Month(month), true
+}
+
+// handleMonthStats shows statistics for a month (stats_month_2024_03)
+func (h *StatsHandler) handleMonthStats(callback *tgbotapi.CallbackQuery, data string) {
+	year, month, ok := h.parseYearMonth(strings.TrimPrefix(data, "stats_month_"))
+	if !ok {
+		h.sendError(callback.Message.Chat.ID, "Неверный формат месяца.")
+		return
+	}
+	h.showReport(callback, stats.MonthPeriod(year, month, h.statsService.Location()), tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("3 неделя", fmt.Sprintf("stats_week_%s_3", month)),
-			tgbotapi.NewInlineKeyboardButtonData("4 неделя", fmt.Sprintf("stats_week_%s_4", month)),
+			tgbotapi.NewInlineKeyboardButtonData("📆 По неделям", fmt.Sprintf("stats_weeks_%d_%02d", year, int(month))),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "stats_date"),
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", fmt.Sprintf("stats_date_%d", year)),
 		),
+	))
+}
+
+// handleWeekSelection shows the ISO weeks of a month (stats_weeks_2024_03)
+func (h *StatsHandler) handleWeekSelection(callback *tgbotapi.CallbackQuery, data string) {
+	year, month, ok := h.parseYearMonth(strings.TrimPrefix(data, "stats_weeks_"))
+	if !ok {
+		h.sendError(callback.Message.Chat.ID, "Неверный формат месяца.")
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, week := range stats.WeeksOfMonth(year, month, h.statsService.Location()) {
+		isoYear, isoWeek := week.Start.ISOWeek()
+		text := fmt.Sprintf("Неделя %d: %s — %s", isoWeek, week.Start.Format("02.01"), week.End.AddDate(0, 0, -1).Format("02.01"))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(text, fmt.Sprintf("stats_week_%d_%02d", isoYear, isoWeek)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", fmt.Sprintf("stats_month_%d_%02d", year, int(month))),
+	))
+
+	reply := tgbotapi.NewEditMessageText(
+		callback.Message.Chat.ID,
+		callback.Message.MessageID,
+		fmt.Sprintf("📅 Выберите неделю (%s %d):", stats.MonthName(month), year),
 	)
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	reply.ReplyMarkup = &markup
 	if _, err := h.bot.Send(reply); err != nil {
 		utils.LogError(err)
 	}
 }
 
-// handleWeekStats shows stats for selected week
+// handleWeekStats shows statistics for an ISO week (stats_week_2024_12)
 func (h *StatsHandler) handleWeekStats(callback *tgbotapi.CallbackQuery, data string) {
 	parts := strings.Split(strings.TrimPrefix(data, "stats_week_"), "_")
 	if len(parts) != 2 {
 		h.sendError(callback.Message.Chat.ID, "Неверный формат недели.")
 		return
 	}
+	year, err1 := strconv.Atoi(parts[0])
+	week, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		h.sendError(callback.Message.Chat.ID, "Неверный формат недели.")
+		return
+	}
+	period, err := stats.WeekPeriod(year, week, h.statsService.Location())
+	if err != nil {
+		h.sendError(callback.Message.Chat.ID, "Неверный формат недели.")
+		return
+	}
 
-	month := parts[0]
-	// Placeholder: Fetch stats for specific week
-	// In real implementation, query statsService with month and week
-	reply := tgbotapi.NewEditMessageText(
-		callback.Message.Chat.ID,
-		callback.Message.MessageID,
-		fmt.Sprintf("📊 Статистика за неделю %s/%s:\n(данные временно недоступны)", month, parts[1]),
-	)
-	reply.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+	// An ISO week belongs to the month of its Thursday
+	thursday := period.Start.AddDate(0, 0, 3)
+	h.showReport(callback, period, tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", fmt.Sprintf("stats_month_%s", month)),
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", fmt.Sprintf("stats_weeks_%d_%02d", thursday.Year(), int(thursday.Month()))),
 		),
-	)
-	if _, err := h.bot.Send(reply); err != nil {
-		utils.LogError(err)
-	}
+	))
 }
 
 // sendMainMenu sends the main menu
@@ -0,0 +1,199 @@
+package callbacks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/security"
+	"github.com/skyzeper/telegram-bot/internal/services/order"
+	"github.com/skyzeper/telegram-bot/internal/services/tracking"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// TripsHandler handles executors' trip updates and clients' location requests
+type TripsHandler struct {
+	bot             *tgbotapi.BotAPI
+	security        *security.SecurityChecker
+	orderService    *order.Service
+	trackingService *tracking.Service
+}
+
+// NewTripsHandler creates a new TripsHandler
+func NewTripsHandler(
+	bot *tgbotapi.BotAPI,
+	security *security.SecurityChecker,
+	orderService *order.Service,
+	trackingService *tracking.Service,
+) *TripsHandler {
+	return &TripsHandler{
+		bot:             bot,
+		security:        security,
+		orderService:    orderService,
+		trackingService: trackingService,
+	}
+}
+
+// Handle processes trip callbacks
+func (h *TripsHandler) Handle(callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	data := callback.Data
+
+	var action, orderIDStr string
+	switch {
+	case strings.HasPrefix(data, "trip_go_"):
+		action, orderIDStr = "go", strings.TrimPrefix(data, "trip_go_")
+	case strings.HasPrefix(data, "trip_work_"):
+		action, orderIDStr = "work", strings.TrimPrefix(data, "trip_work_")
+	case strings.HasPrefix(data, "trip_where_"):
+		action, orderIDStr = "where", strings.TrimPrefix(data, "trip_where_")
+	default:
+		h.send(chatID, "❓ Неизвестная команда.")
+		return
+	}
+	orderID, err := strconv.Atoi(orderIDStr)
+	if err != nil {
+		h.send(chatID, "❌ Неверный формат заказа.")
+		return
+	}
+	o, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		h.send(chatID, "❌ Заказ не найден.")
+		return
+	}
+
+	switch action {
+	case "go":
+		h.handleOnTheWay(callback, o)
+	case "work":
+		h.handleStartWork(callback, o)
+	case "where":
+		h.handleWhere(chatID, o)
+	}
+}
+
+// handleOnTheWay marks the order as on the way, asks for live location and tells the client
+func (h *TripsHandler) handleOnTheWay(callback *tgbotapi.CallbackQuery, o *models.Order) {
+	chatID := callback.Message.Chat.ID
+	if err := h.trackingService.StartTrip(o, chatID); err != nil {
+		h.send(chatID, tripError(err))
+		return
+	}
+
+	text := fmt.Sprintf("🚚 Вы в пути к клиенту по заказу #%d.\n"+
+		"📡 Включите трансляцию геопозиции, чтобы клиент видел, где вы: 📎 → Геопозиция → Транслировать геопозицию.\n"+
+		"Когда приступите к работе, нажмите «🛠 Начать работу».", o.ID)
+	h.edit(callback, text, ExecutorTripMarkup(o))
+	h.send(o.UserID, fmt.Sprintf("🚚 Исполнитель выехал к вам по заказу #%d. Нажмите кнопку, чтобы узнать, где он сейчас.", o.ID), ClientTrackingMarkup(o.ID))
+}
+
+// handleStartWork marks the order as in progress, which stops tracking
+func (h *TripsHandler) handleStartWork(callback *tgbotapi.CallbackQuery, o *models.Order) {
+	chatID := callback.Message.Chat.ID
+	if err := h.trackingService.FinishTrip(o, chatID); err != nil {
+		h.send(chatID, tripError(err))
+		return
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonURL("🗺 Открыть на карте", OrderMapLink(o)),
+	))
+	h.edit(callback, fmt.Sprintf("🛠 Работа по заказу #%d начата. Трансляцию геопозиции можно остановить.", o.ID), markup)
+	h.send(o.UserID, fmt.Sprintf("🛠 Исполнитель на месте и приступил к работе по заказу #%d.", o.ID))
+}
+
+// handleWhere sends the executor's last location and a rough ETA to the client or staff
+func (h *TripsHandler) handleWhere(chatID int64, o *models.Order) {
+	if chatID != o.UserID {
+		if ok, err := h.security.HasAccess(chatID, "orders"); err != nil || !ok || h.security.HasRole(chatID, "client") {
+			h.send(chatID, "🚫 Доступ запрещён.")
+			return
+		}
+	}
+
+	now := time.Now()
+	pos, err := h.trackingService.Locate(o, now)
+	if err == tracking.ErrNoActiveTrip {
+		switch o.Status {
+		case tracking.StatusInProgress, "completed":
+			h.send(chatID, fmt.Sprintf("✅ Исполнитель уже на месте по заказу #%d.", o.ID))
+		default:
+			h.send(chatID, fmt.Sprintf("🕒 Исполнитель ещё не выехал по заказу #%d. Мы сообщим, когда он будет в пути.", o.ID))
+		}
+		return
+	}
+	if err != nil {
+		utils.LogError(err)
+		h.send(chatID, "❌ Не удалось определить местоположение исполнителя. Попробуйте позже.")
+		return
+	}
+
+	if !pos.At.IsZero() {
+		if _, err := h.bot.Send(tgbotapi.NewLocation(chatID, pos.Point.Lat, pos.Point.Lon)); err != nil {
+			utils.LogError(err)
+		}
+	}
+	h.send(chatID, tracking.FormatPosition(o, pos, now), ClientTrackingMarkup(o.ID))
+}
+
+// ExecutorTripMarkup builds the executor's on-the-way and start-work buttons with a map link to the order
+func ExecutorTripMarkup(o *models.Order) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🚚 Выехал", fmt.Sprintf("trip_go_%d", o.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("🛠 Начать работу", fmt.Sprintf("trip_work_%d", o.ID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("🗺 Открыть на карте", OrderMapLink(o)),
+		),
+	)
+}
+
+// ClientTrackingMarkup builds the button asking where the executor is
+func ClientTrackingMarkup(orderID int) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📍 Где исполнитель?", fmt.Sprintf("trip_where_%d", orderID)),
+		),
+	)
+}
+
+// tripError explains a failed trip update to the executor
+func tripError(err error) string {
+	switch err {
+	case tracking.ErrNotAssigned:
+		return "🚫 Вы не назначены на этот заказ."
+	case tracking.ErrOrderClosed:
+		return "❌ Заказ уже закрыт."
+	case tracking.ErrWorkStarted:
+		return "❌ Работа по заказу уже начата или заказ закрыт."
+	default:
+		utils.LogError(err)
+		return "❌ Ошибка обновления заказа. Попробуйте позже."
+	}
+}
+
+// edit replaces the callback message
+func (h *TripsHandler) edit(callback *tgbotapi.CallbackQuery, text string, markup tgbotapi.InlineKeyboardMarkup) {
+	msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	msg.ReplyMarkup = &markup
+	if _, err := h.bot.Send(msg); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// send sends a new message with an optional inline keyboard
+func (h *TripsHandler) send(chatID int64, text string, markup ...tgbotapi.InlineKeyboardMarkup) {
+	if chatID == 0 {
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	if len(markup) > 0 {
+		msg.ReplyMarkup = markup[0]
+	}
+	if _, err := h.bot.Send(msg); err != nil {
+		utils.LogError(err)
+	}
+}
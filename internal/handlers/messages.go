@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -9,24 +10,52 @@ import (
 	"github.com/skyzeper/telegram-bot/internal/menus"
 	"github.com/skyzeper/telegram-bot/internal/models"
 	"github.com/skyzeper/telegram-bot/internal/security"
+	"github.com/skyzeper/telegram-bot/internal/services/accounting"
 	"github.com/skyzeper/telegram-bot/internal/services/chat"
+	"github.com/skyzeper/telegram-bot/internal/services/escalation"
+	"github.com/skyzeper/telegram-bot/internal/services/expense"
+	"github.com/skyzeper/telegram-bot/internal/services/fiscal"
+	"github.com/skyzeper/telegram-bot/internal/services/fleet"
+	"github.com/skyzeper/telegram-bot/internal/services/geo"
 	"github.com/skyzeper/telegram-bot/internal/services/notification"
 	"github.com/skyzeper/telegram-bot/internal/services/order"
+	"github.com/skyzeper/telegram-bot/internal/services/payment"
+	"github.com/skyzeper/telegram-bot/internal/services/payroll"
+	"github.com/skyzeper/telegram-bot/internal/services/report"
+	"github.com/skyzeper/telegram-bot/internal/services/referral"
+	"github.com/skyzeper/telegram-bot/internal/services/review"
+	"github.com/skyzeper/telegram-bot/internal/services/route"
+	"github.com/skyzeper/telegram-bot/internal/services/tracking"
+	"github.com/skyzeper/telegram-bot/internal/services/stats"
 	"github.com/skyzeper/telegram-bot/internal/services/user"
 	"github.com/skyzeper/telegram-bot/internal/state"
+	"github.com/skyzeper/telegram-bot/internal/utils"
 )
 
 // Handler manages incoming Telegram updates
 type Handler struct {
-	bot                *tgbotapi.BotAPI
-	security           *security.SecurityChecker
-	menus              *menus.MenuGenerator
-	userService        *user.Service
-	orderService       *order.Service
-	chatService        *chat.Service
-	state              *state.Manager
-	callbackHandler    *callbacks.CallbackHandler
+	bot                 *tgbotapi.BotAPI
+	security            *security.SecurityChecker
+	menus               *menus.MenuGenerator
+	userService         *user.Service
+	orderService        *order.Service
+	chatService         *chat.Service
+	state               *state.Manager
+	callbackHandler     *callbacks.CallbackHandler
 	notificationService *notification.Service
+	referralService     *referral.Service
+	escalationService   *escalation.Service
+	paymentService      *payment.Service
+	accountingService   *accounting.Service
+	fiscalService       *fiscal.Service
+	expenseService      *expense.Service
+	payrollService      *payroll.Service
+	reportService       *report.Service
+	statsService        *stats.Service
+	orderSteps          *order.StepHandler
+	routeService        *route.Service
+	trackingService     *tracking.Service
+	fleetService        *fleet.Service
 }
 
 // NewHandler creates a new Handler
@@ -40,26 +69,63 @@ func NewHandler(
 	state *state.Manager,
 	callbackHandler *callbacks.CallbackHandler,
 	notificationService *notification.Service,
+	referralService *referral.Service,
+	escalationService *escalation.Service,
+	paymentService *payment.Service,
+	accountingService *accounting.Service,
+	fiscalService *fiscal.Service,
+	expenseService *expense.Service,
+	payrollService *payroll.Service,
+	reportService *report.Service,
+	statsService *stats.Service,
+	orderSteps *order.StepHandler,
+	routeService *route.Service,
+	trackingService *tracking.Service,
+	fleetService *fleet.Service,
 ) *Handler {
 	return &Handler{
-		bot:                bot,
-		security:           security,
-		menus:              menus,
-		userService:        userService,
-		orderService:       orderService,
-		chatService:        chatService,
-		state:              state,
-		callbackHandler:    callbackHandler,
+		bot:                 bot,
+		security:            security,
+		menus:               menus,
+		userService:         userService,
+		orderService:        orderService,
+		chatService:         chatService,
+		state:               state,
+		callbackHandler:     callbackHandler,
 		notificationService: notificationService,
+		referralService:     referralService,
+		escalationService:   escalationService,
+		paymentService:      paymentService,
+		accountingService:   accountingService,
+		fiscalService:       fiscalService,
+		expenseService:      expenseService,
+		payrollService:      payrollService,
+		reportService:       reportService,
+		statsService:        statsService,
+		orderSteps:          orderSteps,
+		routeService:        routeService,
+		trackingService:     trackingService,
+		fleetService:        fleetService,
 	}
 }
 
 // HandleUpdate processes incoming Telegram updates
 func (h *Handler) HandleUpdate(update *tgbotapi.Update) {
 	if update.Message == nil {
+		// Live locations arrive as edits of the message that started sharing
+		if update.EditedMessage != nil && update.EditedMessage.Location != nil {
+			h.handleExecutorLocation(update.EditedMessage, true)
+		}
 		if update.CallbackQuery != nil {
 			h.callbackHandler.HandleCallback(update.CallbackQuery)
 		}
+		if update.PreCheckoutQuery != nil {
+			h.handlePreCheckoutQuery(update.PreCheckoutQuery)
+		}
+		return
+	}
+	if update.Message.SuccessfulPayment != nil {
+		h.handleSuccessfulPayment(update.Message)
 		return
 	}
 
@@ -82,6 +148,15 @@ func (h *Handler) HandleUpdate(update *tgbotapi.Update) {
 			return
 		}
 		user = &models.User{ChatID: chatID, Role: "client"}
+		if update.Message.IsCommand() && update.Message.Command() == "start" {
+			h.handleStartPayload(chatID, update.Message.CommandArguments())
+		}
+	}
+
+	// Executors share their location while on the way to an order
+	if update.Message.Location != nil && currentState.Module != "order" && (user.Role == "driver" || user.Role == "loader") {
+		h.handleExecutorLocation(update.Message, false)
+		return
 	}
 
 	// Handle commands
@@ -94,12 +169,35 @@ func (h *Handler) HandleUpdate(update *tgbotapi.Update) {
 	if currentState.Module != "" {
 		switch currentState.Module {
 		case "order":
-			// Delegate to order steps handler
-			// Note: This is handled in order/steps.go
+			h.orderSteps.HandleStep(update)
 			return
 		case "chat":
 			h.handleChatMessage(update)
 			return
+		case "review":
+			h.handleReviewMessage(update, currentState)
+			return
+		case "escalation":
+			h.handleEscalationMessage(update, currentState)
+			return
+		case "cash":
+			h.handleCashMessage(update, currentState)
+			return
+		case "cost":
+			h.handleCostMessage(update, currentState)
+			return
+		case "volume":
+			h.handleVolumeMessage(update, currentState)
+			return
+		case "prepay":
+			h.handlePrepayMessage(update, currentState)
+			return
+		case "refund":
+			h.handleRefundMessage(update, currentState)
+			return
+		case "expense":
+			h.handleExpenseMessage(update, currentState)
+			return
 		}
 	}
 
@@ -115,6 +213,30 @@ func (h *Handler) handleCommand(update *tgbotapi.Update, user *models.User) {
 	switch command {
 	case "start":
 		h.sendMessage(chatID, "Добро пожаловать! 🚛 Выберите действие:", h.menus.MainMenu(user))
+	case "campaign":
+		h.handleCampaignCommand(chatID, update.Message.CommandArguments())
+	case "escalations":
+		h.handleEscalationsCommand(chatID)
+	case "payments":
+		h.handlePaymentsCommand(chatID)
+	case "balance":
+		h.handleBalanceCommand(chatID, update.Message.CommandArguments())
+	case "expenses":
+		h.handleExpensesCommand(chatID)
+	case "payrule":
+		h.handlePayRuleCommand(chatID, update.Message.CommandArguments())
+	case "payroll":
+		h.handlePayrollCommand(chatID, update.Message.CommandArguments())
+	case "report":
+		h.handleReportCommand(chatID, update.Message.CommandArguments())
+	case "stats":
+		h.handleStatsCommand(chatID, update.Message.CommandArguments())
+	case "route":
+		h.handleRouteCommand(chatID, update.Message.CommandArguments(), user)
+	case "fleet":
+		h.handleFleetCommand(chatID, update.Message.CommandArguments())
+	case "mileage":
+		h.handleMileageCommand(chatID, update.Message.CommandArguments(), user)
 	case "help":
 		h.sendMessage(chatID, "📚 Помощь: Используйте меню для заказа услуг, связи с оператором или приглашения друзей.", h.menus.MainMenu(user))
 	default:
@@ -122,6 +244,514 @@ func (h *Handler) handleCommand(update *tgbotapi.Update, user *models.User) {
 	}
 }
 
+// handleStartPayload attributes a newly registered user to an inviter or campaign
+func (h *Handler) handleStartPayload(chatID int64, payload string) {
+	ref, err := h.referralService.RegisterStart(chatID, payload)
+	if err != nil {
+		utils.LogError(fmt.Errorf("failed to register start payload %q: %v", payload, err))
+		return
+	}
+	if ref != nil && ref.InviterID != 0 {
+		if err := h.notificationService.SendReferralNotification(ref.InviterID, chatID, "joined"); err != nil {
+			utils.LogError(err)
+		}
+	}
+}
+
+// handleCampaignCommand lists or creates campaign codes (/campaign <code> <name>)
+func (h *Handler) handleCampaignCommand(chatID int64, args string) {
+	role, err := h.security.GetUserRole(chatID)
+	if err != nil || role != "owner" {
+		h.sendMessage(chatID, "❌ У вас нет доступа к рекламным кампаниям.", nil)
+		return
+	}
+
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		campaigns, err := h.referralService.ListCampaigns()
+		if err != nil {
+			h.sendMessage(chatID, "❌ Ошибка получения кампаний. Попробуйте позже.", nil)
+			return
+		}
+		if len(campaigns) == 0 {
+			h.sendMessage(chatID, "📣 Кампаний пока нет.\nСоздайте: /campaign <код> <название>", nil)
+			return
+		}
+		var sb strings.Builder
+		sb.WriteString("📣 Рекламные кампании:\n")
+		for _, c := range campaigns {
+			sb.WriteString(fmt.Sprintf("\n• %s (%s)\n%s\n", c.Name, c.Code, h.referralService.CampaignLink(c.Code)))
+		}
+		h.sendMessage(chatID, sb.String(), nil)
+		return
+	}
+
+	campaign, err := h.referralService.CreateCampaign(fields[0], strings.Join(fields[1:], " "), chatID)
+	if err != nil {
+		h.sendMessage(chatID, fmt.Sprintf("❌ Не удалось создать кампанию: %v", err), nil)
+		return
+	}
+	h.sendMessage(chatID, fmt.Sprintf(
+		"✅ Кампания «%s» создана!\nСсылка для рекламы:\n%s",
+		campaign.Name, h.referralService.CampaignLink(campaign.Code),
+	), nil)
+}
+
+// handleEscalationsCommand shows the number of unresolved negative-review escalations
+func (h *Handler) handleEscalationsCommand(chatID int64) {
+	role, err := h.security.GetUserRole(chatID)
+	if err != nil || role != "main_operator" {
+		h.sendMessage(chatID, "❌ У вас нет доступа к жалобам.", nil)
+		return
+	}
+
+	escalations, err := h.escalationService.GetUnresolved()
+	if err != nil {
+		h.sendMessage(chatID, "❌ Ошибка получения жалоб. Попробуйте позже.", nil)
+		return
+	}
+	if len(escalations) == 0 {
+		h.sendMessage(chatID, "✅ Нерешённых жалоб нет.", nil)
+		return
+	}
+	h.sendMessage(chatID, fmt.Sprintf("🚨 Нерешённых жалоб: %d", len(escalations)), tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📋 Открыть список", "escalation_list"),
+		),
+	))
+}
+
+// handlePaymentsCommand lists payments waiting for hand-over confirmation
+func (h *Handler) handlePaymentsCommand(chatID int64) {
+	if ok, err := h.security.HasAccess(chatID, "payments"); err != nil || !ok {
+		h.sendMessage(chatID, "❌ У вас нет доступа к платежам.", nil)
+		return
+	}
+
+	payments, err := h.paymentService.GetUnconfirmedPayments()
+	if err != nil {
+		h.sendMessage(chatID, "❌ Ошибка получения платежей. Попробуйте позже.", nil)
+		return
+	}
+	refunds, err := h.paymentService.GetPendingRefunds()
+	if err != nil {
+		h.sendMessage(chatID, "❌ Ошибка получения возвратов. Попробуйте позже.", nil)
+		return
+	}
+	if len(payments) == 0 && len(refunds) == 0 {
+		h.sendMessage(chatID, "✅ Все платежи подтверждены.", nil)
+		return
+	}
+	for _, p := range refunds {
+		h.sendMessage(chatID, fmt.Sprintf(
+			"↩️ Возврат по заказу #%d: %.2f руб.\nПричина: %s\nЗапросил: %d, %s",
+			p.OrderID, p.Amount, p.Reason, p.RequestedBy, p.CreatedAt.Format("02.01.2006 15:04"),
+		), callbacks.RefundDecisionMarkup(p.ID))
+	}
+	for _, p := range payments {
+		h.sendMessage(chatID, fmt.Sprintf(
+			"💸 Заказ #%d: %.2f руб. (%s)\nВодитель: %d, получено %s",
+			p.OrderID, p.Amount, p.Method, p.DriverID, p.CreatedAt.Format("02.01.2006 15:04"),
+		), callbacks.PaymentConfirmMarkup(p.ID))
+	}
+}
+
+// handleBalanceCommand shows ledger account balances (/balance [ДД.ММ.ГГГГ ДД.ММ.ГГГГ]), the current month by default
+func (h *Handler) handleBalanceCommand(chatID int64, args string) {
+	if ok, err := h.security.HasAccess(chatID, "ledger"); err != nil || !ok {
+		h.sendMessage(chatID, "❌ У вас нет доступа к балансу счетов.", nil)
+		return
+	}
+
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	from, to, ok := parsePeriodArgs(args, monthStart, monthStart.AddDate(0, 1, 0))
+	if !ok {
+		h.sendMessage(chatID, "❌ Формат: /balance 01.03.2024 31.03.2024", nil)
+		return
+	}
+
+	report, err := h.accountingService.GetBalanceReport(from, to)
+	if err != nil {
+		h.sendMessage(chatID, "❌ Ошибка получения баланса. Попробуйте позже.", nil)
+		return
+	}
+	h.sendMessage(chatID, accounting.FormatBalanceReport(report), nil)
+}
+
+// handleExecutorLocation records an executor's location for the order they are driving to;
+// live location updates are recorded silently
+func (h *Handler) handleExecutorLocation(msg *tgbotapi.Message, update bool) {
+	chatID := msg.Chat.ID
+	point := geo.Point{Lat: msg.Location.Latitude, Lon: msg.Location.Longitude}
+	livePeriod := msg.Location.LivePeriod
+	if update {
+		livePeriod = 0 // Updates repeat the original period, which must not be extended
+	}
+	orderID, err := h.trackingService.RecordLocation(chatID, point, livePeriod, time.Now())
+	if update {
+		if err != nil && err != tracking.ErrNoActiveTrip {
+			utils.LogError(err)
+		}
+		return
+	}
+
+	switch {
+	case err == tracking.ErrNoActiveTrip:
+		h.sendMessage(chatID, "📍 Нет заказа в пути. Нажмите «🚚 Выехал» в сообщении о заказе, чтобы клиент видел, где вы.", nil)
+	case err != nil:
+		utils.LogError(err)
+		h.sendMessage(chatID, "❌ Не удалось сохранить геопозицию. Попробуйте позже.", nil)
+	case livePeriod > 0:
+		h.sendMessage(chatID, fmt.Sprintf("📡 Трансляция геопозиции по заказу #%d включена, клиент видит, где вы.", orderID), nil)
+	default:
+		h.sendMessage(chatID, fmt.Sprintf("📍 Геопозиция по заказу #%d сохранена. Включите трансляцию, чтобы клиент видел вас в пути.", orderID), nil)
+	}
+}
+
+// handleRouteCommand shows an executor's planned route for a day (/route [ДД.ММ.ГГГГ]), today by default
+func (h *Handler) handleRouteCommand(chatID int64, args string, user *models.User) {
+	if user.Role != "driver" && user.Role != "loader" {
+		h.sendMessage(chatID, "❌ Маршрут доступен только водителям и грузчикам.", nil)
+		return
+	}
+
+	loc := h.routeService.Location()
+	now := time.Now().In(loc)
+	day := now
+	if args = strings.TrimSpace(args); args != "" {
+		parsed, err := time.ParseInLocation("02.01.2006", args, loc)
+		if err != nil {
+			h.sendMessage(chatID, "❌ Формат: /route 13.03.2024", nil)
+			return
+		}
+		day = parsed
+	}
+
+	r, err := h.routeService.PlanDay(chatID, day, now)
+	if err != nil {
+		utils.LogError(err)
+		h.sendMessage(chatID, "❌ Ошибка построения маршрута. Попробуйте позже.", nil)
+		return
+	}
+	if markup := callbacks.RouteMarkup(r); markup != nil {
+		h.sendMessage(chatID, route.FormatRoute(day, r), *markup)
+	} else {
+		h.sendMessage(chatID, route.FormatRoute(day, r), nil)
+	}
+}
+
+// handleFleetCommand lists or manages the vehicle fleet
+// (/fleet, /fleet add <номер> <тип> <м³> <т>, /fleet status <номер> <статус>, /fleet service <номер> <ДД.ММ.ГГГГ|км> [км])
+func (h *Handler) handleFleetCommand(chatID int64, args string) {
+	if ok, err := h.security.HasAccess(chatID, "fleet"); err != nil || !ok {
+		h.sendMessage(chatID, "❌ У вас нет доступа к автопарку.", nil)
+		return
+	}
+
+	usage := "ℹ️ Формат:\n/fleet add А123ВС77 газель 16 1.5 — добавить машину (номер, тип: газель, самосвал, манипулятор, объём м³, грузоподъёмность т)\n" +
+		"/fleet status А123ВС77 ремонт — статус: на линии, ремонт, списана\n" +
+		"/fleet service А123ВС77 01.06.2024 150000 — дата и пробег следующего ТО (можно указать что-то одно)"
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		vehicles, err := h.fleetService.GetVehicles()
+		if err != nil {
+			h.sendMessage(chatID, "❌ Ошибка получения автопарка. Попробуйте позже.", nil)
+			return
+		}
+		h.sendMessage(chatID, fleet.FormatFleet(vehicles, time.Now())+"\n\n"+usage, nil)
+		return
+	}
+
+	var vehicle *models.Vehicle
+	var err error
+	switch {
+	case fields[0] == "add":
+		if vehicle, err = fleet.ParseVehicle(fields[1:]); err == nil {
+			err = h.fleetService.AddVehicle(vehicle)
+		}
+	case fields[0] == "status" && len(fields) >= 3:
+		var status string
+		if status, err = fleet.ParseStatus(strings.Join(fields[2:], " ")); err == nil {
+			vehicle, err = h.fleetService.SetStatus(fields[1], status)
+		}
+	case fields[0] == "service" && (len(fields) == 3 || len(fields) == 4):
+		var due *time.Time
+		dueMileage := 0
+		if date, dateErr := time.ParseInLocation("02.01.2006", fields[2], time.Local); dateErr == nil {
+			due = &date
+			if len(fields) == 4 {
+				dueMileage, err = strconv.Atoi(fields[3])
+			}
+		} else if len(fields) == 3 {
+			dueMileage, err = strconv.Atoi(fields[2])
+		} else {
+			err = dateErr
+		}
+		if err == nil {
+			vehicle, err = h.fleetService.SetService(fields[1], due, dueMileage)
+		}
+	default:
+		h.sendMessage(chatID, usage, nil)
+		return
+	}
+	switch err {
+	case nil:
+		h.sendMessage(chatID, "✅ Автопарк обновлён.\n"+fleet.FormatVehicle(vehicle, time.Now()), nil)
+	case fleet.ErrVehicleNotFound:
+		h.sendMessage(chatID, "❌ Машина с таким номером не найдена.", nil)
+	case fleet.ErrPlateExists:
+		h.sendMessage(chatID, "❌ Машина с таким номером уже есть в автопарке.", nil)
+	default:
+		h.sendMessage(chatID, fmt.Sprintf("❌ Автопарк не обновлён: %v\n%s", err, usage), nil)
+	}
+}
+
+// handleMileageCommand records a vehicle's odometer reading (/mileage <номер> <км>)
+func (h *Handler) handleMileageCommand(chatID int64, args string, user *models.User) {
+	manager, err := h.security.HasAccess(chatID, "fleet")
+	if err != nil || (!manager && user.Role != "driver") {
+		h.sendMessage(chatID, "❌ Пробег вносят водители и руководители автопарка.", nil)
+		return
+	}
+
+	fields := strings.Fields(args)
+	if len(fields) != 2 {
+		h.sendMessage(chatID, "ℹ️ Формат: /mileage А123ВС77 150230", nil)
+		return
+	}
+	mileage, err := strconv.Atoi(fields[1])
+	if err != nil {
+		h.sendMessage(chatID, "ℹ️ Формат: /mileage А123ВС77 150230", nil)
+		return
+	}
+	if !manager {
+		// Drivers record mileage only for the vehicles assigned to them
+		vehicles, err := h.fleetService.DriverVehicles(chatID)
+		if err != nil {
+			h.sendMessage(chatID, "❌ Ошибка получения машины. Попробуйте позже.", nil)
+			return
+		}
+		plate := fleet.NormalizePlate(fields[0])
+		own := false
+		for _, v := range vehicles {
+			if v.Plate == plate {
+				own = true
+				break
+			}
+		}
+		if !own {
+			h.sendMessage(chatID, "❌ Эта машина не закреплена за вами.", nil)
+			return
+		}
+	}
+	vehicle, err := h.fleetService.RecordMileage(fields[0], mileage, chatID)
+	switch err {
+	case nil:
+		h.sendMessage(chatID, "✅ Пробег сохранён.\n"+fleet.FormatVehicle(vehicle, time.Now()), nil)
+	case fleet.ErrVehicleNotFound:
+		h.sendMessage(chatID, "❌ Машина с таким номером не найдена.", nil)
+	case fleet.ErrMileageDecreased:
+		h.sendMessage(chatID, "❌ Пробег не может быть меньше последнего внесённого.", nil)
+	default:
+		h.sendMessage(chatID, "❌ Ошибка сохранения пробега. Попробуйте позже.", nil)
+	}
+}
+
+// handleMyVehicle shows a driver the vehicles they were assigned to
+func (h *Handler) handleMyVehicle(chatID int64, user *models.User) {
+	if user.Role != "driver" {
+		h.sendMessage(chatID, "❌ Раздел доступен только водителям.", nil)
+		return
+	}
+	vehicles, err := h.fleetService.DriverVehicles(chatID)
+	if err != nil {
+		h.sendMessage(chatID, "❌ Ошибка получения машины. Попробуйте позже.", nil)
+		return
+	}
+	if len(vehicles) == 0 {
+		h.sendMessage(chatID, "🚛 За вами пока не закреплена машина.", nil)
+		return
+	}
+	var parts []string
+	for i := range vehicles {
+		parts = append(parts, fleet.FormatVehicle(&vehicles[i], time.Now()))
+	}
+	h.sendMessage(chatID, strings.Join(parts, "\n\n")+"\n\nВнесите пробег: /mileage <номер> <км>", nil)
+}
+
+// parsePeriodArgs reads an inclusive "ДД.ММ.ГГГГ ДД.ММ.ГГГГ" range as [from, to) in the time zone of the defaults,
+// falling back to the defaults without arguments
+func parsePeriodArgs(args string, defaultFrom, defaultTo time.Time) (time.Time, time.Time, bool) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return defaultFrom, defaultTo, true
+	}
+	if len(fields) != 2 {
+		return time.Time{}, time.Time{}, false
+	}
+	start, err1 := time.ParseInLocation("02.01.2006", fields[0], defaultFrom.Location())
+	end, err2 := time.ParseInLocation("02.01.2006", fields[1], defaultFrom.Location())
+	if err1 != nil || err2 != nil || end.Before(start) {
+		return time.Time{}, time.Time{}, false
+	}
+	return start, end.AddDate(0, 0, 1), true
+}
+
+// handlePayRuleCommand shows or sets an executor's pay rule (/payrule <id> order=1500 hour=300 percent=5 bonus:demolition=500)
+func (h *Handler) handlePayRuleCommand(chatID int64, args string) {
+	if ok, err := h.security.HasAccess(chatID, "payroll"); err != nil || !ok {
+		h.sendMessage(chatID, "❌ У вас нет доступа к зарплате.", nil)
+		return
+	}
+
+	usage := "ℹ️ Формат: /payrule <ID сотрудника> order=1500 hour=300 percent=5 bonus:demolition=500"
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		h.sendMessage(chatID, usage, nil)
+		return
+	}
+	userID, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		h.sendMessage(chatID, usage, nil)
+		return
+	}
+	staff, err := h.userService.GetUser(userID)
+	if err != nil || (staff.Role != "driver" && staff.Role != "loader") {
+		h.sendMessage(chatID, "❌ Водитель или грузчик с таким ID не найден.", nil)
+		return
+	}
+
+	if len(fields) == 1 {
+		rule, err := h.payrollService.GetPayRule(userID)
+		if err != nil {
+			h.sendMessage(chatID, "❌ Ошибка получения ставки. Попробуйте позже.", nil)
+			return
+		}
+		if rule == nil {
+			h.sendMessage(chatID, "⚠️ Ставка не задана.\n"+usage, nil)
+			return
+		}
+		h.sendMessage(chatID, payroll.FormatPayRule(rule), nil)
+		return
+	}
+
+	rule, err := payroll.ParsePayRule(userID, fields[1:])
+	if err == nil {
+		err = h.payrollService.SetPayRule(rule)
+	}
+	if err != nil {
+		h.sendMessage(chatID, fmt.Sprintf("❌ Ставка не сохранена: %v\n%s", err, usage), nil)
+		return
+	}
+	h.sendMessage(chatID, "✅ Ставка сохранена.\n"+payroll.FormatPayRule(rule), nil)
+}
+
+// handlePayrollCommand computes a draft payroll (/payroll [ДД.ММ.ГГГГ ДД.ММ.ГГГГ]), the previous month by default
+func (h *Handler) handlePayrollCommand(chatID int64, args string) {
+	if ok, err := h.security.HasAccess(chatID, "payroll"); err != nil || !ok {
+		h.sendMessage(chatID, "❌ У вас нет доступа к зарплате.", nil)
+		return
+	}
+
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	from, to, ok := parsePeriodArgs(args, monthStart.AddDate(0, -1, 0), monthStart)
+	if !ok {
+		h.sendMessage(chatID, "❌ Формат: /payroll 01.03.2024 31.03.2024", nil)
+		return
+	}
+
+	run, err := h.payrollService.PrepareRun(from, to, chatID)
+	if err != nil {
+		h.sendMessage(chatID, "❌ Ошибка расчёта зарплаты. Попробуйте позже.", nil)
+		return
+	}
+	if len(run.Lines) == 0 {
+		h.sendMessage(chatID, payroll.FormatRun(run), nil)
+		return
+	}
+	h.sendMessage(chatID, payroll.FormatRun(run), callbacks.PayrollDecisionMarkup(run.ID))
+}
+
+// handleReportCommand sends the financial workbook (/report [ДД.ММ.ГГГГ ДД.ММ.ГГГГ]), the current month by default
+func (h *Handler) handleReportCommand(chatID int64, args string) {
+	if ok, err := h.security.HasAccess(chatID, "stats"); err != nil || !ok {
+		h.sendMessage(chatID, "❌ У вас нет доступа к отчётам.", nil)
+		return
+	}
+
+	now := time.Now().In(h.statsService.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	from, to, ok := parsePeriodArgs(args, monthStart, monthStart.AddDate(0, 1, 0))
+	if !ok {
+		h.sendMessage(chatID, "❌ Формат: /report 01.03.2024 31.03.2024", nil)
+		return
+	}
+
+	financial, err := h.reportService.GetFinancialReport(from, to)
+	if err != nil {
+		h.sendMessage(chatID, "❌ Ошибка формирования отчёта. Попробуйте позже.", nil)
+		return
+	}
+	buf, err := report.WriteExcel(financial)
+	if err != nil {
+		utils.LogError(err)
+		h.sendMessage(chatID, "❌ Ошибка формирования отчёта. Попробуйте позже.", nil)
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileReader{Name: report.FileName(financial, "xlsx"), Reader: buf})
+	doc.Caption = fmt.Sprintf(
+		"📊 Финансовый отчёт за %s — %s",
+		from.Format("02.01.2006"), to.AddDate(0, 0, -1).Format("02.01.2006"),
+	)
+	if _, err := h.bot.Send(doc); err != nil {
+		utils.LogError(err)
+		h.sendMessage(chatID, "❌ Не удалось отправить отчёт. Попробуйте позже.", nil)
+	}
+}
+
+// handleStatsCommand shows statistics for an arbitrary range (/stats ДД.ММ.ГГГГ ДД.ММ.ГГГГ) or the period menu
+func (h *Handler) handleStatsCommand(chatID int64, args string) {
+	if ok, err := h.security.HasAccess(chatID, "stats"); err != nil || !ok {
+		h.sendMessage(chatID, "❌ У вас нет доступа к статистике.", nil)
+		return
+	}
+
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		h.sendMessage(chatID, "📊 Выберите период статистики:", h.menus.StatsMenu())
+		return
+	}
+
+	usage := "❌ Формат: /stats 01.03.2024 15.03.2024"
+	if len(fields) != 2 {
+		h.sendMessage(chatID, usage, nil)
+		return
+	}
+	loc := h.statsService.Location()
+	first, err1 := time.ParseInLocation("02.01.2006", fields[0], loc)
+	last, err2 := time.ParseInLocation("02.01.2006", fields[1], loc)
+	if err1 != nil || err2 != nil {
+		h.sendMessage(chatID, usage, nil)
+		return
+	}
+	period, err := stats.RangePeriod(first, last, loc)
+	if err != nil {
+		h.sendMessage(chatID, usage, nil)
+		return
+	}
+
+	report, err := h.statsService.GetStatsReport(period)
+	if err != nil {
+		h.sendMessage(chatID, "❌ Ошибка получения статистики. Попробуйте позже.", nil)
+		return
+	}
+	h.sendMessage(chatID, stats.FormatStatsReport(report), nil)
+	callbacks.SendStatsCharts(h.bot, h.statsService, chatID, period, report.Current)
+}
+
 // handleTextMessage processes text messages
 func (h *Handler) handleTextMessage(update *tgbotapi.Update, user *models.User) {
 	chatID := update.Message.Chat.ID
@@ -207,11 +837,68 @@ func (h *Handler) handleTextMessage(update *tgbotapi.Update, user *models.User)
 			return
 		}
 		if role == "owner" {
-			h.sendMessage(chatID, "📊 Выберите период статистики:", nil)
+			h.sendMessage(chatID, "📊 Выберите период статистики:", h.menus.StatsMenu())
 		} else {
 			h.sendMessage(chatID, "❌ У вас нет доступа к статистике.", nil)
 		}
 
+	case "💵 мои наличные":
+		if user.Role != "driver" {
+			h.sendMessage(chatID, "❌ Раздел доступен только водителям.", nil)
+			return
+		}
+		statement, err := h.accountingService.GetDriverStatement(chatID, time.Now().Add(-accounting.StatementPeriod))
+		if err != nil {
+			h.sendMessage(chatID, "❌ Ошибка получения выписки. Попробуйте позже.", nil)
+			return
+		}
+		h.sendMessage(chatID, accounting.FormatDriverStatement(statement), nil)
+
+	case "🧭 мой маршрут":
+		h.handleRouteCommand(chatID, "", user)
+
+	case "🚛 автопарк":
+		h.handleFleetCommand(chatID, "")
+
+	case "🚛 моя машина":
+		h.handleMyVehicle(chatID, user)
+
+	case "🧾 внести расход":
+		if user.Role != "driver" && user.Role != "loader" {
+			h.sendMessage(chatID, "❌ Раздел доступен только водителям и грузчикам.", nil)
+			return
+		}
+		h.sendMessage(chatID, "🧾 Выберите категорию расхода:", callbacks.ExpenseCategoryMarkup())
+
+	case "💼 зарплата":
+		h.handlePayrollCommand(chatID, "")
+
+	case "📒 баланс счетов":
+		h.handleBalanceCommand(chatID, "")
+
+	case "📤 выгрузка в 1с":
+		if ok, err := h.security.HasAccess(chatID, "ledger"); err != nil || !ok {
+			h.sendMessage(chatID, "❌ У вас нет доступа к выгрузке.", nil)
+			return
+		}
+		h.sendMessage(chatID, "📤 Выберите формат и период выгрузки платежей и расходов:", callbacks.ExportMenuMarkup())
+
+	case "💵 долги водителей":
+		if ok, err := h.security.HasAccess(chatID, "debts"); err != nil || !ok {
+			h.sendMessage(chatID, "❌ У вас нет доступа к долгам водителей.", nil)
+			return
+		}
+		text, markup, err := callbacks.DriverBalancesView(h.accountingService)
+		if err != nil {
+			h.sendMessage(chatID, "❌ Ошибка получения долгов водителей. Попробуйте позже.", nil)
+			return
+		}
+		if markup != nil {
+			h.sendMessage(chatID, text, *markup)
+		} else {
+			h.sendMessage(chatID, text, nil)
+		}
+
 	default:
 		h.sendMessage(chatID, "❓ Пожалуйста, выберите действие из меню:", h.menus.MainMenu(user))
 	}
@@ -251,6 +938,514 @@ func (h *Handler) handleChatMessage(update *tgbotapi.Update) {
 	h.sendMessage(chatID, "✅ Сообщение отправлено! Оператор скоро ответит.", nil)
 }
 
+// handleReviewMessage collects the comment and photos of a review in progress
+func (h *Handler) handleReviewMessage(update *tgbotapi.Update, currentState state.State) {
+	chatID := update.Message.Chat.ID
+	orderID, _ := currentState.Data["order_id"].(int)
+
+	switch currentState.Step {
+	case 2:
+		if update.Message.Text == "" {
+			h.sendMessage(chatID, "✍️ Напишите комментарий текстом или нажмите «Пропустить».", nil)
+			return
+		}
+		currentState.Data["comment"] = update.Message.Text
+		currentState.Step = 3
+		h.state.Set(chatID, currentState)
+		h.sendMessage(chatID, fmt.Sprintf("📸 Прикрепите до %d фото или нажмите «Готово».", review.MaxPhotos), callbacks.ReviewDoneMarkup(orderID))
+	case 3:
+		if len(update.Message.Photo) == 0 {
+			h.sendMessage(chatID, "📸 Отправьте фото или нажмите «Готово».", callbacks.ReviewDoneMarkup(orderID))
+			return
+		}
+		photos, _ := currentState.Data["photos"].([]string)
+		if len(photos) >= review.MaxPhotos {
+			h.sendMessage(chatID, fmt.Sprintf("⚠️ Можно прикрепить не больше %d фото.", review.MaxPhotos), callbacks.ReviewDoneMarkup(orderID))
+			return
+		}
+		largest := update.Message.Photo[len(update.Message.Photo)-1]
+		photos = append(photos, largest.FileID)
+		currentState.Data["photos"] = photos
+		h.state.Set(chatID, currentState)
+		h.sendMessage(chatID, fmt.Sprintf("✅ Фото добавлено (%d/%d).", len(photos), review.MaxPhotos), callbacks.ReviewDoneMarkup(orderID))
+	}
+}
+
+// handleEscalationMessage records the resolution of an escalation entered by the operator
+func (h *Handler) handleEscalationMessage(update *tgbotapi.Update, currentState state.State) {
+	chatID := update.Message.Chat.ID
+	escalationID, _ := currentState.Data["escalation_id"].(int)
+	resolution, _ := currentState.Data["resolution"].(string)
+
+	amount, note := escalation.ParseResolutionInput(resolution, update.Message.Text)
+	if resolution == escalation.ResolutionRefund && amount <= 0 {
+		h.sendMessage(chatID, "❌ Введите сумму возврата, например: 500 вернули за опоздание", nil)
+		return
+	}
+
+	resolved, err := h.escalationService.Resolve(escalationID, chatID, resolution, note, amount)
+	h.state.Clear(chatID)
+	if err == escalation.ErrNotTaken {
+		h.sendMessage(chatID, "❌ Закрыть жалобу может только оператор, который взял её в работу.", nil)
+		return
+	}
+	if err != nil {
+		h.sendMessage(chatID, fmt.Sprintf("❌ Не удалось закрыть жалобу: %v", err), nil)
+		return
+	}
+
+	summary := escalation.ResolutionLabel(resolved.Resolution)
+	if resolved.Amount > 0 {
+		summary += fmt.Sprintf(" — %.2f руб.", resolved.Amount)
+	}
+	h.sendMessage(chatID, fmt.Sprintf(
+		"✅ Жалоба #%d закрыта за %s.\nРешение: %s",
+		resolved.ID, resolved.ResolvedAt.Sub(resolved.CreatedAt).Round(time.Minute), summary,
+	), nil)
+
+	clientText := fmt.Sprintf("🙏 Нам очень жаль, что заказ #%d вас расстроил.\nМы приняли меры: %s", resolved.OrderID, summary)
+	if resolved.ResolutionNote != "" {
+		clientText += "\n" + resolved.ResolutionNote
+	}
+	h.sendMessage(resolved.UserID, clientText, nil)
+
+	if resolved.Resolution == escalation.ResolutionRefund {
+		// Refunds go through the usual approval so they are capped by what the client paid and reach the ledger
+		h.requestEscalationRefund(chatID, resolved)
+	}
+}
+
+// requestEscalationRefund requests the refund promised when a complaint was resolved
+func (h *Handler) requestEscalationRefund(chatID int64, e *models.Escalation) {
+	failed := fmt.Sprintf("⚠️ Возврат по жалобе #%d не оформлен", e.ID)
+	order, err := h.orderService.GetOrder(e.OrderID)
+	if err != nil {
+		h.sendMessage(chatID, failed+": заказ не найден.", nil)
+		return
+	}
+	reason := fmt.Sprintf("Жалоба #%d", e.ID)
+	if e.ResolutionNote != "" {
+		reason += ": " + e.ResolutionNote
+	}
+	refund, err := h.paymentService.RequestRefund(order, e.Amount, reason, chatID)
+	if err != nil {
+		h.sendMessage(chatID, fmt.Sprintf("%s: %v\nОформите возврат по заказу #%d вручную.", failed, err, order.ID), nil)
+		return
+	}
+	h.sendMessage(chatID, fmt.Sprintf("⏳ Возврат %.2f руб. по заказу #%d отправлен на одобрение.", refund.Amount, order.ID), nil)
+	h.notifyRefundApprovers(refund, chatID)
+}
+
+// handleCashMessage records cash collected by the driver and asks for hand-over confirmation
+func (h *Handler) handleCashMessage(update *tgbotapi.Update, currentState state.State) {
+	chatID := update.Message.Chat.ID
+	orderID, _ := currentState.Data["order_id"].(int)
+
+	parsed, err := models.ParseMoney(update.Message.Text)
+	if err != nil || parsed <= 0 {
+		h.sendMessage(chatID, "❌ Введите сумму числом, например: 3500", nil)
+		return
+	}
+	amount := parsed.Float64()
+
+	order, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		h.state.Clear(chatID)
+		h.sendMessage(chatID, "❌ Заказ не найден.", nil)
+		return
+	}
+	p, rec, err := h.paymentService.RecordCash(order, chatID, amount)
+	if err != nil {
+		h.sendMessage(chatID, "❌ Ошибка записи оплаты. Попробуйте позже.", nil)
+		return
+	}
+	h.state.Clear(chatID)
+
+	h.sendMessage(chatID, fmt.Sprintf(
+		"💸 Наличные по заказу #%d записаны.\n%s\nСдайте деньги старшему оператору или бухгалтеру.",
+		order.ID, callbacks.ReconciliationText(rec),
+	), nil)
+
+	text := fmt.Sprintf("💸 Водитель %d получил наличные по заказу #%d.\n%s", chatID, order.ID, callbacks.ReconciliationText(rec))
+	for _, role := range []string{"main_operator", "accountant"} {
+		staff, err := h.userService.ListUsersByRole(role)
+		if err != nil {
+			utils.LogError(err)
+			continue
+		}
+		for _, u := range staff {
+			h.sendMessage(u.ChatID, text, callbacks.PaymentConfirmMarkup(p.ID))
+		}
+	}
+}
+
+// handleCostMessage stores the agreed order cost and bills the client
+func (h *Handler) handleCostMessage(update *tgbotapi.Update, currentState state.State) {
+	chatID := update.Message.Chat.ID
+	orderID, _ := currentState.Data["order_id"].(int)
+
+	parsed, err := models.ParseMoney(update.Message.Text)
+	if err != nil || parsed <= 0 {
+		h.sendMessage(chatID, "❌ Введите стоимость числом, например: 3500", nil)
+		return
+	}
+	cost := parsed.Float64()
+
+	order, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		h.state.Clear(chatID)
+		h.sendMessage(chatID, "❌ Заказ не найден.", nil)
+		return
+	}
+	// The zone surcharge for delivery outside the base area is added on top of the agreed cost
+	if order.ZoneSurcharge > 0 {
+		cost += order.ZoneSurcharge
+		h.sendMessage(chatID, fmt.Sprintf("🚚 Добавлена доплата за зону «%s»: %.2f руб.", order.Zone, order.ZoneSurcharge), nil)
+	}
+	order.Cost = cost
+	if err := h.orderService.UpdateOrder(order); err != nil {
+		h.sendMessage(chatID, "❌ Ошибка сохранения стоимости. Попробуйте позже.", nil)
+		return
+	}
+	h.state.Clear(chatID)
+
+	if order.PaymentMethod == "карта" {
+		balance, err := h.paymentService.GetOrderBalance(order)
+		if err == nil && balance.FullyPaid() {
+			h.sendMessage(chatID, fmt.Sprintf("✅ Стоимость заказа #%d: %.2f руб. Заказ уже оплачен.", order.ID, cost), nil)
+			return
+		}
+		if err == nil {
+			err = h.billOnline(order, balance.Due)
+		}
+		if err != nil {
+			utils.LogError(err)
+			h.sendMessage(chatID, fmt.Sprintf("⚠️ Стоимость заказа #%d сохранена, но счёт отправить не удалось.", order.ID), nil)
+			return
+		}
+		h.sendMessage(chatID, fmt.Sprintf("✅ Стоимость заказа #%d: %.2f руб. Клиенту отправлен счёт на %.2f руб.", order.ID, cost, balance.Due), nil)
+		return
+	}
+
+	h.sendMessage(order.UserID, fmt.Sprintf("💰 Стоимость заказа #%d: %.2f руб.", order.ID, cost), nil)
+	h.sendMessage(chatID, fmt.Sprintf("✅ Стоимость заказа #%d: %.2f руб. сохранена.", order.ID, cost), nil)
+}
+
+// handleVolumeMessage stores the volume and weight of an order's load and checks them against its vehicle
+func (h *Handler) handleVolumeMessage(update *tgbotapi.Update, currentState state.State) {
+	chatID := update.Message.Chat.ID
+	orderID, _ := currentState.Data["order_id"].(int)
+
+	volume, weight, err := fleet.ParseLoad(update.Message.Text)
+	if err != nil || volume <= 0 {
+		h.sendMessage(chatID, "❌ Введите объём в м³ и, если известен, вес в тоннах, например: 12 1.5", nil)
+		return
+	}
+
+	order, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		h.state.Clear(chatID)
+		h.sendMessage(chatID, "❌ Заказ не найден.", nil)
+		return
+	}
+	order.Volume = volume
+	order.Weight = weight
+	if err := h.orderService.UpdateOrder(order); err != nil {
+		h.sendMessage(chatID, "❌ Ошибка сохранения объёма. Попробуйте позже.", nil)
+		return
+	}
+	h.state.Clear(chatID)
+
+	text := fmt.Sprintf("✅ Груз по заказу #%d сохранён: %g м³", order.ID, volume)
+	if weight > 0 {
+		text += fmt.Sprintf(", %g т", weight)
+	}
+	if order.VehicleID != 0 {
+		vehicle, err := h.fleetService.GetVehicle(order.VehicleID)
+		if err != nil {
+			utils.LogError(err)
+		} else if warnings := fleet.CheckLoad(order, vehicle, time.Now()); len(warnings) > 0 {
+			text += fmt.Sprintf("\n\nМашина %s:\n%s", fleet.VehicleName(vehicle), strings.Join(warnings, "\n"))
+		}
+	}
+	h.sendMessage(chatID, text, nil)
+}
+
+// handlePrepayMessage bills the client for a prepayment
+func (h *Handler) handlePrepayMessage(update *tgbotapi.Update, currentState state.State) {
+	chatID := update.Message.Chat.ID
+	orderID, _ := currentState.Data["order_id"].(int)
+
+	parsed, err := models.ParseMoney(update.Message.Text)
+	if err != nil || parsed <= 0 {
+		h.sendMessage(chatID, "❌ Введите сумму числом, например: 1500", nil)
+		return
+	}
+	amount := parsed.Float64()
+
+	order, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		h.state.Clear(chatID)
+		h.sendMessage(chatID, "❌ Заказ не найден.", nil)
+		return
+	}
+	balance, err := h.paymentService.GetOrderBalance(order)
+	if err != nil {
+		h.sendMessage(chatID, "❌ Ошибка расчёта баланса заказа. Попробуйте позже.", nil)
+		return
+	}
+	if amount > balance.Due {
+		h.sendMessage(chatID, fmt.Sprintf("❌ Предоплата не может превышать сумму к оплате: %.2f руб.", balance.Due), nil)
+		return
+	}
+	h.state.Clear(chatID)
+
+	if err := h.billOnline(order, amount); err != nil {
+		utils.LogError(err)
+		h.sendMessage(order.UserID, fmt.Sprintf("💳 По заказу #%d требуется предоплата %.2f руб. Оператор подскажет, как её внести.", order.ID, amount), nil)
+		h.sendMessage(chatID, fmt.Sprintf("⚠️ Онлайн-оплата недоступна, клиент уведомлён о предоплате %.2f руб.", amount), nil)
+		return
+	}
+	h.sendMessage(chatID, fmt.Sprintf("✅ Клиенту отправлен счёт на предоплату %.2f руб. по заказу #%d.", amount, order.ID), nil)
+}
+
+// handleRefundMessage collects the refund amount and reason, then asks approvers to decide
+func (h *Handler) handleRefundMessage(update *tgbotapi.Update, currentState state.State) {
+	chatID := update.Message.Chat.ID
+	orderID, _ := currentState.Data["order_id"].(int)
+
+	if currentState.Step == 1 {
+		parsed, err := models.ParseMoney(update.Message.Text)
+		if err != nil || parsed <= 0 {
+			h.sendMessage(chatID, "❌ Введите сумму числом, например: 500", nil)
+			return
+		}
+		amount := parsed.Float64()
+		currentState.Data["amount"] = amount
+		currentState.Step = 2
+		h.state.Set(chatID, currentState)
+		h.sendMessage(chatID, "✍️ Укажите причину возврата:", nil)
+		return
+	}
+
+	amount, _ := currentState.Data["amount"].(float64)
+	order, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		h.state.Clear(chatID)
+		h.sendMessage(chatID, "❌ Заказ не найден.", nil)
+		return
+	}
+	refund, err := h.paymentService.RequestRefund(order, amount, update.Message.Text, chatID)
+	h.state.Clear(chatID)
+	if err != nil {
+		h.sendMessage(chatID, fmt.Sprintf("❌ Возврат не оформлен: %v", err), nil)
+		return
+	}
+	h.sendMessage(chatID, fmt.Sprintf("⏳ Возврат %.2f руб. по заказу #%d отправлен на одобрение.", refund.Amount, order.ID), nil)
+	h.notifyRefundApprovers(refund, chatID)
+}
+
+// notifyRefundApprovers asks every staff member who may approve refunds, except the requester, to decide
+func (h *Handler) notifyRefundApprovers(refund *models.Payment, requestedBy int64) {
+	text := fmt.Sprintf("↩️ Запрос возврата по заказу #%d: %.2f руб.\nПричина: %s\nЗапросил: %d", refund.OrderID, refund.Amount, refund.Reason, requestedBy)
+	for _, role := range []string{"main_operator", "accountant", "owner"} {
+		staff, err := h.userService.ListUsersByRole(role)
+		if err != nil {
+			utils.LogError(err)
+			continue
+		}
+		for _, u := range staff {
+			if u.ChatID != requestedBy {
+				h.sendMessage(u.ChatID, text, callbacks.RefundDecisionMarkup(refund.ID))
+			}
+		}
+	}
+}
+
+// handleExpenseMessage collects the amount, order and receipt photo of an expense and sends it for approval
+func (h *Handler) handleExpenseMessage(update *tgbotapi.Update, currentState state.State) {
+	chatID := update.Message.Chat.ID
+
+	switch currentState.Step {
+	case 1:
+		amount, err := models.ParseMoney(update.Message.Text)
+		if err != nil || amount <= 0 {
+			h.sendMessage(chatID, "❌ Введите сумму числом, например: 1500 или 1500,50", nil)
+			return
+		}
+		currentState.Data["amount"] = int64(amount)
+		currentState.Step = 2
+		h.state.Set(chatID, currentState)
+		h.sendMessage(chatID, "🔗 Укажите номер заказа или отправьте «-», если расход не связан с заказом:", nil)
+	case 2:
+		text := strings.TrimPrefix(strings.TrimSpace(update.Message.Text), "#")
+		orderID := 0
+		if text != "-" {
+			id, err := strconv.Atoi(text)
+			if err != nil || id <= 0 {
+				h.sendMessage(chatID, "❌ Введите номер заказа числом или «-».", nil)
+				return
+			}
+			if _, err := h.orderService.GetOrder(id); err != nil {
+				h.sendMessage(chatID, "❌ Заказ не найден. Проверьте номер или отправьте «-».", nil)
+				return
+			}
+			orderID = id
+		}
+		currentState.Data["order_id"] = orderID
+		currentState.Step = 3
+		h.state.Set(chatID, currentState)
+		h.sendMessage(chatID, "📸 Пришлите фото чека. В подписи к фото можно добавить комментарий.", nil)
+	default:
+		if len(update.Message.Photo) == 0 {
+			h.sendMessage(chatID, "📸 Пришлите фото чека.", nil)
+			return
+		}
+		category, _ := currentState.Data["category"].(string)
+		amount, _ := currentState.Data["amount"].(int64)
+		orderID, _ := currentState.Data["order_id"].(int)
+		largest := update.Message.Photo[len(update.Message.Photo)-1]
+
+		e, err := h.expenseService.Submit(chatID, category, models.Money(amount), orderID, largest.FileID, update.Message.Caption)
+		h.state.Clear(chatID)
+		if err != nil {
+			h.sendMessage(chatID, fmt.Sprintf("❌ Расход не сохранён: %v", err), nil)
+			return
+		}
+		h.sendMessage(chatID, fmt.Sprintf("⏳ Расход #%d на %s руб. отправлен на одобрение.", e.ID, e.Amount), nil)
+
+		operators, err := h.userService.ListUsersByRole("main_operator")
+		if err != nil {
+			utils.LogError(err)
+			return
+		}
+		for _, operator := range operators {
+			if _, err := h.bot.Send(callbacks.ExpenseReviewPhoto(operator.ChatID, e)); err != nil {
+				utils.LogError(err)
+			}
+		}
+	}
+}
+
+// handleExpensesCommand lists expenses waiting for approval by the main operator
+func (h *Handler) handleExpensesCommand(chatID int64) {
+	if ok, err := h.security.HasAccess(chatID, "expenses"); err != nil || !ok {
+		h.sendMessage(chatID, "❌ У вас нет доступа к расходам.", nil)
+		return
+	}
+
+	expenses, err := h.expenseService.GetPending()
+	if err != nil {
+		h.sendMessage(chatID, "❌ Ошибка получения расходов. Попробуйте позже.", nil)
+		return
+	}
+	if len(expenses) == 0 {
+		h.sendMessage(chatID, "✅ Все расходы рассмотрены.", nil)
+		return
+	}
+	for i := range expenses {
+		if _, err := h.bot.Send(callbacks.ExpenseReviewPhoto(chatID, &expenses[i])); err != nil {
+			utils.LogError(err)
+		}
+	}
+}
+
+// billOnline sends the client a Telegram invoice or, failing that, a provider payment link
+func (h *Handler) billOnline(order *models.Order, amount float64) error {
+	switch {
+	case h.paymentService.InvoicesEnabled():
+		return h.paymentService.SendInvoice(order, amount)
+	case h.paymentService.LinksEnabled():
+		_, err := h.paymentService.SendPaymentLink(order, amount)
+		return err
+	default:
+		return fmt.Errorf("no online payment method configured")
+	}
+}
+
+// handlePreCheckoutQuery confirms that an invoice can still be paid
+func (h *Handler) handlePreCheckoutQuery(query *tgbotapi.PreCheckoutQuery) {
+	var order *models.Order
+	if orderID, err := payment.ParseInvoicePayload(query.InvoicePayload); err == nil {
+		order, err = h.orderService.GetOrder(orderID)
+		if err != nil {
+			utils.LogError(err)
+			order = nil
+		}
+	}
+	if err := h.paymentService.AnswerPreCheckout(query, order); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// handleSuccessfulPayment records a card payment and marks the order paid once it covers the cost
+func (h *Handler) handleSuccessfulPayment(message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	sp := message.SuccessfulPayment
+
+	orderID, err := payment.ParseInvoicePayload(sp.InvoicePayload)
+	if err != nil {
+		utils.LogError(err)
+		return
+	}
+	order, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		utils.LogError(err)
+		return
+	}
+	p, err := h.paymentService.RecordCardPayment(order, sp)
+	if err != nil {
+		utils.LogError(err)
+		h.sendMessage(chatID, "⚠️ Оплата получена, но не записана. Оператор свяжется с вами.", nil)
+		return
+	}
+
+	h.markOrderPaid(order)
+	h.recordBankIncome(p)
+	h.sendMessage(chatID, fmt.Sprintf("✅ Оплата заказа #%d получена: %.2f руб. Спасибо!", order.ID, float64(sp.TotalAmount)/100), nil)
+	if _, err := h.fiscalService.IssueReceipt(order, p); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// OnPaymentLinkPaid marks the order paid and thanks the client once a payment link is confirmed
+func (h *Handler) OnPaymentLinkPaid(link *models.PaymentLink, p *models.Payment) {
+	order, err := h.orderService.GetOrder(link.OrderID)
+	if err != nil {
+		utils.LogError(err)
+		return
+	}
+	h.markOrderPaid(order)
+	h.recordBankIncome(p)
+	h.sendMessage(link.UserID, fmt.Sprintf("✅ Оплата заказа #%d получена: %.2f руб. Спасибо!", order.ID, p.Amount), nil)
+	if _, err := h.fiscalService.IssueReceipt(order, p); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// recordBankIncome posts an online payment to the bank account in the ledger
+func (h *Handler) recordBankIncome(p *models.Payment) {
+	description := fmt.Sprintf("Оплата заказа #%d (%s)", p.OrderID, p.Method)
+	if err := h.accountingService.RecordIncome(p.OrderID, p.UserID, models.NewMoney(p.Amount), accounting.AccountBank, description); err != nil {
+		utils.LogError(err)
+	}
+}
+
+// markOrderPaid sets the order's payment flag once confirmed payments cover its cost
+func (h *Handler) markOrderPaid(order *models.Order) {
+	rec, err := h.paymentService.ReconcileOrder(order)
+	if err != nil {
+		utils.LogError(err)
+		return
+	}
+	if rec.Covered() && !order.PaymentConfirmed {
+		order.PaymentConfirmed = true
+		if err := h.orderService.UpdateOrder(order); err != nil {
+			utils.LogError(err)
+		}
+	}
+}
+
 // sendMessage sends a message to a chat
 func (h *Handler) sendMessage(chatID int64, text string, replyMarkup interface{}) {
 	msg := tgbotapi.NewMessage(chatID, text)
@@ -30,6 +30,23 @@ func (m *MenuGenerator) MainMenu(user *models.User) tgbotapi.ReplyKeyboardMarkup
 	}
 	if user.Role == "owner" {
 		buttons = append(buttons, []tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButton("📊 Статистика")})
+		buttons = append(buttons, []tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButton("💵 Долги водителей")})
+		buttons = append(buttons, []tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButton("💼 Зарплата")})
+	}
+	if user.Role == "owner" || user.Role == "main_operator" {
+		buttons = append(buttons, []tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButton("🚛 Автопарк")})
+	}
+	if user.Role == "accountant" || user.Role == "owner" {
+		buttons = append(buttons, []tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButton("📒 Баланс счетов")})
+		buttons = append(buttons, []tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButton("📤 Выгрузка в 1С")})
+	}
+	if user.Role == "driver" {
+		buttons = append(buttons, []tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButton("💵 Мои наличные")})
+		buttons = append(buttons, []tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButton("🚛 Моя машина")})
+	}
+	if user.Role == "driver" || user.Role == "loader" {
+		buttons = append(buttons, []tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButton("🧭 Мой маршрут")})
+		buttons = append(buttons, []tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButton("🧾 Внести расход")})
 	}
 	return tgbotapi.NewReplyKeyboard(buttons...)
 }
@@ -145,6 +162,18 @@ func (m *MenuGenerator) PhoneMenu() tgbotapi.ReplyKeyboardMarkup {
 	)
 }
 
+// LocationMenu generates the address input menu with a location button
+func (m *MenuGenerator) LocationMenu() tgbotapi.ReplyKeyboardMarkup {
+	return tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButtonLocation("📍 Отправить геолокацию"),
+		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("🔙 Главное меню"),
+		),
+	)
+}
+
 // SkipMenu generates the skip option menu
 func (m *MenuGenerator) SkipMenu() tgbotapi.ReplyKeyboardMarkup {
 	return tgbotapi.NewReplyKeyboard(
@@ -237,8 +266,12 @@ func (m *MenuGenerator) OrderActionsMenu(orderID int) tgbotapi.InlineKeyboardMar
 			tgbotapi.NewInlineKeyboardButtonData("🚫 Заблокировать клиента", fmt.Sprintf("block_client_%d", orderID)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💰 Указать стоимость", fmt.Sprintf("cost_order_%d", orderID)),
 			tgbotapi.NewInlineKeyboardButtonData("🚫 Заблокировать и отменить", fmt.Sprintf("block_cancel_%d", orderID)),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💳 Запросить предоплату", fmt.Sprintf("prepay_order_%d", orderID)),
+		),
 	)
 }
 
@@ -253,6 +286,10 @@ func (m *MenuGenerator) InProgressOrderActionsMenu(orderID int) tgbotapi.InlineK
 			tgbotapi.NewInlineKeyboardButtonData("💸 Учесть наличные", fmt.Sprintf("cash_order_%d", orderID)),
 			tgbotapi.NewInlineKeyboardButtonData("📞 Связаться с клиентом", fmt.Sprintf("contact_client_%d", orderID)),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💰 Указать стоимость", fmt.Sprintf("cost_order_%d", orderID)),
+			tgbotapi.NewInlineKeyboardButtonData("💳 Запросить предоплату", fmt.Sprintf("prepay_order_%d", orderID)),
+		),
 	)
 }
 
@@ -306,3 +343,62 @@ func (m *MenuGenerator) ReferralMenu() tgbotapi.InlineKeyboardMarkup {
 		),
 	)
 }
+
+// StatsMenu generates the statistics period selection menu
+func (m *MenuGenerator) StatsMenu() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("День", "stats_day"),
+			tgbotapi.NewInlineKeyboardButtonData("Неделя", "stats_week"),
+			tgbotapi.NewInlineKeyboardButtonData("Месяц", "stats_month"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Год", "stats_year"),
+			tgbotapi.NewInlineKeyboardButtonData("Всё время", "stats_all"),
+			tgbotapi.NewInlineKeyboardButtonData("📅 Выбрать месяц", "stats_date"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗓 Произвольный период", "stats_range"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🏆 Рефералы и кампании", "stats_referrals"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👷 Рейтинг исполнителей", "stats_executors"),
+		),
+	)
+}
+
+// ReferralStatsMenu generates the period selection menu for the referral report
+func (m *MenuGenerator) ReferralStatsMenu() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Неделя", "stats_referrals_week"),
+			tgbotapi.NewInlineKeyboardButtonData("Месяц", "stats_referrals_month"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Год", "stats_referrals_year"),
+			tgbotapi.NewInlineKeyboardButtonData("Всё время", "stats_referrals_all"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "back_to_stats"),
+		),
+	)
+}
+
+// ExecutorStatsMenu generates the period selection menu for the executor ranking
+func (m *MenuGenerator) ExecutorStatsMenu() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Неделя", "stats_executors_week"),
+			tgbotapi.NewInlineKeyboardButtonData("Месяц", "stats_executors_month"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Год", "stats_executors_year"),
+			tgbotapi.NewInlineKeyboardButtonData("Всё время", "stats_executors_all"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "back_to_stats"),
+		),
+	)
+}
\ No newline at end of file
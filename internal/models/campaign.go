@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Campaign represents a marketing campaign code used in /start links
+type Campaign struct {
+	ID        int       `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	CreatedBy int64     `json:"created_by"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+}
\ No newline at end of file
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// DriverBalance represents the cash a driver holds and has not yet handed over
+type DriverBalance struct {
+	UserID       int64     `json:"user_id"`
+	Name         string    `json:"name"`
+	Balance      Money     `json:"balance"`
+	LastMovement time.Time `json:"last_movement"`
+}
+
+// DriverStatement lists a driver's cash movements for a period with running balances
+type DriverStatement struct {
+	DriverID int64                 `json:"driver_id"`
+	From     time.Time             `json:"from"`
+	Opening  Money                 `json:"opening"`
+	Closing  Money                 `json:"closing"`
+	Lines    []DriverStatementLine `json:"lines"`
+}
+
+// DriverStatementLine is a single cash movement in a driver statement
+type DriverStatementLine struct {
+	EntryID     int       `json:"entry_id"`
+	OrderID     int       `json:"order_id"`
+	Type        string    `json:"type"`
+	Amount      Money     `json:"amount"`
+	Balance     Money     `json:"balance"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
\ No newline at end of file
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Escalation represents a service-recovery ticket opened for a negative review
+type Escalation struct {
+	ID             int       `json:"id"`
+	ReviewID       int       `json:"review_id"`
+	OrderID        int       `json:"order_id"`
+	UserID         int64     `json:"user_id"`
+	Rating         int       `json:"rating"`
+	Status         string    `json:"status"`
+	OperatorID     int64     `json:"operator_id"`
+	Resolution     string    `json:"resolution"`
+	ResolutionNote string    `json:"resolution_note"`
+	Amount         float64   `json:"amount"`
+	CreatedAt      time.Time `json:"created_at"`
+	ResolvedAt     time.Time `json:"resolved_at"`
+}
\ No newline at end of file
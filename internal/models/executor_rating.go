@@ -0,0 +1,13 @@
+package models
+
+// ExecutorRating aggregates client ratings of orders an executor worked on
+type ExecutorRating struct {
+	UserID      int64   `json:"user_id"`
+	Name        string  `json:"name"`
+	Role        string  `json:"role"`
+	AvgRating   float64 `json:"avg_rating"`
+	Reviews     int     `json:"reviews"`
+	Orders      int     `json:"orders"`
+	RecentAvg   float64 `json:"recent_avg"`
+	PreviousAvg float64 `json:"previous_avg"`
+}
\ No newline at end of file
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Expense represents a cost paid by a staff member and waiting to be posted to the ledger
+type Expense struct {
+	ID          int       `json:"id"`
+	UserID      int64     `json:"user_id"`
+	Category    string    `json:"category"`
+	Amount      Money     `json:"amount"`
+	OrderID     int       `json:"order_id"`
+	PhotoFileID string    `json:"photo_file_id"`
+	Comment     string    `json:"comment"`
+	Status      string    `json:"status"`
+	ReviewedBy  int64     `json:"reviewed_by"`
+	ReviewedAt  time.Time `json:"reviewed_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
\ No newline at end of file
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// LedgerEntry is a balanced journal entry: the amounts of its postings sum to zero
+type LedgerEntry struct {
+	ID          int             `json:"id"`
+	Kind        string          `json:"kind"`
+	OrderID     int             `json:"order_id"`
+	UserID      int64           `json:"user_id"`
+	UserName    string          `json:"user_name"`
+	Description string          `json:"description"`
+	Postings    []LedgerPosting `json:"postings"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// LedgerPosting moves money on one account; debits are positive and credits negative
+type LedgerPosting struct {
+	ID       int    `json:"id"`
+	EntryID  int    `json:"entry_id"`
+	Account  string `json:"account"`
+	DriverID int64  `json:"driver_id"`
+	Amount   Money  `json:"amount"`
+}
+
+// AccountBalance is the turnover of an account over a period
+type AccountBalance struct {
+	Account string `json:"account"`
+	Opening Money  `json:"opening"`
+	Debit   Money  `json:"debit"`
+	Credit  Money  `json:"credit"`
+	Closing Money  `json:"closing"`
+}
+
+// BalanceReport lists the balances of all ledger accounts over a period
+type BalanceReport struct {
+	From     time.Time        `json:"from"`
+	To       time.Time        `json:"to"`
+	Accounts []AccountBalance `json:"accounts"`
+}
+
+// ExportRecord is a payment or expense prepared for accounting software
+type ExportRecord struct {
+	EntryID      int       `json:"entry_id"`
+	Kind         string    `json:"kind"`
+	Date         time.Time `json:"date"`
+	Incoming     bool      `json:"incoming"`
+	Account      string    `json:"account"` // money account: cash desk, driver cash or bank
+	Article      string    `json:"article"` // income or expense account
+	Counterparty string    `json:"counterparty"`
+	OrderID      int       `json:"order_id"`
+	Amount       Money     `json:"amount"`
+	Description  string    `json:"description"`
+}
\ No newline at end of file
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in kopecks; it keeps ledger arithmetic exact where float64 would drift
+type Money int64
+
+// NewMoney converts an amount in roubles to Money, rounding to the nearest kopeck
+func NewMoney(roubles float64) Money {
+	return Money(math.Round(roubles * 100))
+}
+
+// ParseMoney parses an amount like "1500", "1 500,50" or "-12.3" without going through float64
+func ParseMoney(s string) (Money, error) {
+	s = strings.NewReplacer(" ", "", " ", "", ",", ".").Replace(strings.TrimSpace(s))
+	negative := strings.HasPrefix(s, "-")
+	if negative || strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, errors.New("empty amount")
+	}
+	if !digits(whole) || !digits(frac) {
+		return 0, fmt.Errorf("invalid amount: %s", s)
+	}
+	if len(frac) > 2 {
+		if strings.Trim(frac[2:], "0") != "" {
+			return 0, fmt.Errorf("amount has more than two decimal places: %s", s)
+		}
+		frac = frac[:2]
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+	roubles, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || roubles > (math.MaxInt64-99)/100 {
+		return 0, fmt.Errorf("amount out of range: %s", s)
+	}
+	kopecks, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %s", s)
+	}
+	m := Money(roubles*100 + kopecks)
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+// digits reports whether s holds only ASCII digits; signs are allowed only before the amount
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Float64 returns the amount in roubles
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// Abs returns the absolute amount
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// String formats the amount in roubles with two decimals, e.g. "-1500.05"
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+	}
+	abs := m.Abs()
+	return fmt.Sprintf("%s%d.%02d", sign, abs/100, abs%100)
+}
+
+// Value stores the amount in a NUMERIC column
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan reads the amount from a NUMERIC, integer or float column
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	case int64:
+		*m = Money(v * 100)
+	case float64:
+		*m = NewMoney(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+// scanString parses a database representation of the amount
+func (m *Money) scanString(s string) error {
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,14 @@
+package models
+
+// OpenProblems counts items waiting for staff action, reported in owner digests
+type OpenProblems struct {
+	OpenEscalations int `json:"open_escalations"`
+	UnconfirmedCash int `json:"unconfirmed_cash"`
+	PendingExpenses int `json:"pending_expenses"`
+	DraftPayrolls   int `json:"draft_payrolls"`
+}
+
+// Total returns the number of open problems
+func (p OpenProblems) Total() int {
+	return p.OpenEscalations + p.UnconfirmedCash + p.PendingExpenses + p.DraftPayrolls
+}
\ No newline at end of file
@@ -20,8 +20,20 @@ type Order struct {
 	Cost          float64    `json:"cost"`
 	PaymentMethod string     `json:"payment_method"`
 	PaymentConfirmed bool    `json:"payment_confirmed"`
+	Latitude      float64    `json:"latitude"`
+	Longitude     float64    `json:"longitude"`
+	Zone          string     `json:"zone"`
+	ZoneSurcharge float64    `json:"zone_surcharge"`
+	Volume        float64    `json:"volume"`
+	Weight        float64    `json:"weight"`
+	VehicleID     int        `json:"vehicle_id"`
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
 	Executors     []Executor `json:"executors"`
 	Confirmed     bool       `json:"confirmed"`
+}
+
+// HasLocation reports whether the client shared coordinates for the address
+func (o *Order) HasLocation() bool {
+	return o.Latitude != 0 || o.Longitude != 0
 }
\ No newline at end of file
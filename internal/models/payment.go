@@ -3,12 +3,20 @@ package models
 import "time"
 
 type Payment struct {
-	ID        int64     `json:"id"`
-	OrderID   int64     `json:"order_id"`
-	UserID    int64     `json:"user_id"`
-	Amount    float64   `json:"amount"`
-	Method    string    `json:"method"`
-	DriverID  int64     `json:"driver_id"`
-	Confirmed bool      `json:"confirmed"`
-	CreatedAt time.Time `json:"created_at"`
+	ID               int       `json:"id"`
+	OrderID          int       `json:"order_id"`
+	UserID           int64     `json:"user_id"`
+	Amount           float64   `json:"amount"`
+	Method           string    `json:"method"`
+	DriverID         int64     `json:"driver_id"`
+	Confirmed        bool      `json:"confirmed"`
+	ConfirmedBy      int64     `json:"confirmed_by"`
+	ConfirmedAt      time.Time `json:"confirmed_at"`
+	ProviderChargeID string    `json:"provider_charge_id"`
+	TelegramChargeID string    `json:"telegram_charge_id"`
+	Kind             string    `json:"kind"`
+	Reason           string    `json:"reason"`
+	RequestedBy      int64     `json:"requested_by"`
+	ReceiptID        int       `json:"receipt_id"`
+	CreatedAt        time.Time `json:"created_at"`
 }
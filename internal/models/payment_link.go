@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PaymentLink represents an external payment link issued by an acquiring provider
+type PaymentLink struct {
+	ID         int       `json:"id"`
+	OrderID    int       `json:"order_id"`
+	UserID     int64     `json:"user_id"`
+	Provider   string    `json:"provider"`
+	ExternalID string    `json:"external_id"`
+	URL        string    `json:"url"`
+	Amount     float64   `json:"amount"`
+	Status     string    `json:"status"`
+	PaymentID  int       `json:"payment_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	PaidAt     time.Time `json:"paid_at"`
+}
\ No newline at end of file
@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// PayRule defines how an executor is paid; all parts add up
+type PayRule struct {
+	UserID          int64            `json:"user_id"`
+	PerOrder        Money            `json:"per_order"`
+	PerHour         Money            `json:"per_hour"`
+	Percent         float64          `json:"percent"`
+	CategoryBonuses map[string]Money `json:"category_bonuses"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+}
+
+// PayrollWork is a completed order an executor worked on
+type PayrollWork struct {
+	UserID      int64     `json:"user_id"`
+	Name        string    `json:"name"`
+	Role        string    `json:"role"`
+	OrderID     int       `json:"order_id"`
+	Category    string    `json:"category"`
+	Subcategory string    `json:"subcategory"`
+	Cost        float64   `json:"cost"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// PayrollRun is a payroll computed for a period and waiting for or given the owner's approval
+type PayrollRun struct {
+	ID         int           `json:"id"`
+	From       time.Time     `json:"from"`
+	To         time.Time     `json:"to"`
+	Status     string        `json:"status"`
+	CreatedBy  int64         `json:"created_by"`
+	ApprovedBy int64         `json:"approved_by"`
+	ApprovedAt time.Time     `json:"approved_at"`
+	Lines      []PayrollLine `json:"lines"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// PayrollLine is one person's pay in a payroll run
+type PayrollLine struct {
+	ID          int           `json:"id"`
+	RunID       int           `json:"run_id"`
+	UserID      int64         `json:"user_id"`
+	Name        string        `json:"name"`
+	Role        string        `json:"role"`
+	Orders      int           `json:"orders"`
+	Minutes     int           `json:"minutes"`
+	PerOrderPay Money         `json:"per_order_pay"`
+	HourlyPay   Money         `json:"hourly_pay"`
+	PercentPay  Money         `json:"percent_pay"`
+	BonusPay    Money         `json:"bonus_pay"`
+	Total       Money         `json:"total"`
+	NoRule      bool          `json:"no_rule"`
+	Items       []PayrollItem `json:"items"`
+}
+
+// PayrollItem is the pay earned for a single order
+type PayrollItem struct {
+	OrderID  int    `json:"order_id"`
+	Category string `json:"category"`
+	Cost     Money  `json:"cost"`
+	Minutes  int    `json:"minutes"`
+	Amount   Money  `json:"amount"`
+}
\ No newline at end of file
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Receipt represents a fiscal receipt issued for a payment or refund
+type Receipt struct {
+	ID            int           `json:"id"`
+	PaymentID     int           `json:"payment_id"`
+	OrderID       int           `json:"order_id"`
+	UserID        int64         `json:"user_id"`
+	Provider      string        `json:"provider"`
+	FiscalID      string        `json:"fiscal_id"`
+	Status        string        `json:"status"`
+	Operation     string        `json:"operation"`
+	Items         []ReceiptItem `json:"items"`
+	Total         float64       `json:"total"`
+	VATMode       string        `json:"vat_mode"`
+	VATAmount     float64       `json:"vat_amount"`
+	PaymentMethod string        `json:"payment_method"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// ReceiptItem is a single line of a fiscal receipt
+type ReceiptItem struct {
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity"`
+	Price    float64 `json:"price"`
+	Amount   float64 `json:"amount"`
+}
\ No newline at end of file
@@ -8,6 +8,7 @@ type Referral struct {
 	InviterID      int64     `json:"inviter_id"`
 	InviteeID      int64     `json:"invitee_id"`
 	OrderID        int       `json:"order_id"`
+	CampaignID     int       `json:"campaign_id"`
 	PayoutRequested bool      `json:"payout_requested"`
 	CreatedAt      time.Time `json:"created_at"`
 }
\ No newline at end of file
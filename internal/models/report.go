@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// FinancialReport collects the money movements of a period for export
+type FinancialReport struct {
+	From        time.Time       `json:"from"`
+	To          time.Time       `json:"to"`
+	Orders      []ReportOrder   `json:"orders"`
+	Payments    []ReportPayment `json:"payments"`
+	Expenses    []ReportExpense `json:"expenses"`
+	Salaries    []ReportSalary  `json:"salaries"`
+	DriverDebts []DriverBalance `json:"driver_debts"`
+	GeneratedAt time.Time       `json:"generated_at"`
+}
+
+// ReportOrder is an order created or completed in the report period
+type ReportOrder struct {
+	ID            int       `json:"id"`
+	ClientName    string    `json:"client_name"`
+	Category      string    `json:"category"`
+	Subcategory   string    `json:"subcategory"`
+	Address       string    `json:"address"`
+	Status        string    `json:"status"`
+	PaymentMethod string    `json:"payment_method"`
+	Cost          Money     `json:"cost"`
+	CreatedAt     time.Time `json:"created_at"`
+	CompletedAt   time.Time `json:"completed_at"`
+}
+
+// ReportPayment is a payment, prepayment or refund registered in the report period
+type ReportPayment struct {
+	ID          int       `json:"id"`
+	OrderID     int       `json:"order_id"`
+	Kind        string    `json:"kind"`
+	Method      string    `json:"method"`
+	Amount      Money     `json:"amount"`
+	Confirmed   bool      `json:"confirmed"`
+	CreatedAt   time.Time `json:"created_at"`
+	ConfirmedAt time.Time `json:"confirmed_at"`
+}
+
+// ReportExpense is an expense submitted in the report period
+type ReportExpense struct {
+	ID        int       `json:"id"`
+	UserName  string    `json:"user_name"`
+	Category  string    `json:"category"`
+	OrderID   int       `json:"order_id"`
+	Comment   string    `json:"comment"`
+	Status    string    `json:"status"`
+	Amount    Money     `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReportSalary is a payroll line of a run approved in the report period
+type ReportSalary struct {
+	RunID      int       `json:"run_id"`
+	PeriodFrom time.Time `json:"period_from"`
+	PeriodTo   time.Time `json:"period_to"`
+	ApprovedAt time.Time `json:"approved_at"`
+	UserName   string    `json:"user_name"`
+	Role       string    `json:"role"`
+	Orders     int       `json:"orders"`
+	Minutes    int       `json:"minutes"`
+	Total      Money     `json:"total"`
+}
\ No newline at end of file
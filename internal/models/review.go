@@ -4,10 +4,15 @@ import "time"
 
 // Review represents a review for an order
 type Review struct {
-	ID        int       `json:"id"`
-	OrderID   int       `json:"order_id"`
-	UserID    int64     `json:"user_id"`
-	Rating    int       `json:"rating"`
-	Comment   string    `json:"comment"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          int       `json:"id"`
+	OrderID     int       `json:"order_id"`
+	UserID      int64     `json:"user_id"`
+	Rating      int       `json:"rating"`
+	Comment     string    `json:"comment"`
+	Photos      []string  `json:"photos"`
+	Status      string    `json:"status"`
+	ModeratedBy int64     `json:"moderated_by"`
+	ModeratedAt time.Time `json:"moderated_at"`
+	PublishedAt time.Time `json:"published_at"`
+	CreatedAt   time.Time `json:"created_at"`
 }
\ No newline at end of file
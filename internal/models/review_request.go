@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// ReviewRequest tracks the rating prompt sent to a client after order completion
+type ReviewRequest struct {
+	OrderID     int       `json:"order_id"`
+	UserID      int64     `json:"user_id"`
+	CompletedAt time.Time `json:"completed_at"`
+	SentAt      time.Time `json:"sent_at"`
+	RemindedAt  time.Time `json:"reminded_at"`
+}
\ No newline at end of file
@@ -1,11 +1,106 @@
 package models
 
+import "time"
+
 // Stats represents statistics data
 type Stats struct {
-	TotalOrders        int     `json:"total_orders"`
-	WasteRemovalOrders int     `json:"waste_removal_orders"`
-	DemolitionOrders   int     `json:"demolition_orders"`
-	ConstructionOrders int     `json:"construction_orders"`
-	TotalAmount        float64 `json:"total_amount"`
-	DriverDebts        float64 `json:"driver_debts"`
-}
\ No newline at end of file
+	TotalOrders         int                  `json:"total_orders"`
+	WasteRemovalOrders  int                  `json:"waste_removal_orders"`
+	DemolitionOrders    int                  `json:"demolition_orders"`
+	ConstructionOrders  int                  `json:"construction_orders"`
+	TotalAmount         float64              `json:"total_amount"`
+	DriverDebts         float64              `json:"driver_debts"`
+	Escalations         EscalationStats      `json:"escalations"`
+	CreatedOrders       int                  `json:"created_orders"`
+	CanceledOrders      int                  `json:"canceled_orders"`
+	AverageCheck        float64              `json:"average_check"`
+	ConversionRate      float64              `json:"conversion_rate"`   // percent of created orders completed
+	CancellationRate    float64              `json:"cancellation_rate"` // percent of created orders canceled
+	AvgCompletionHours  float64              `json:"avg_completion_hours"`
+	RepeatClientShare   float64              `json:"repeat_client_share"` // percent of clients with an earlier completed order
+	RevenuePerExecutor  float64              `json:"revenue_per_executor"`
+	CancellationReasons []CancellationReason `json:"cancellation_reasons"`
+	Executors           []ExecutorRevenue    `json:"executors"`
+	Subcategories       []SubcategoryStats   `json:"subcategories"`
+}
+
+// OrderKPIs aggregates orders created within a period
+type OrderKPIs struct {
+	Created            int     `json:"created"`
+	Completed          int     `json:"completed"`
+	Canceled           int     `json:"canceled"`
+	Revenue            float64 `json:"revenue"`
+	AvgCompletionHours float64 `json:"avg_completion_hours"`
+	Clients            int     `json:"clients"`        // clients with completed orders
+	RepeatClients      int     `json:"repeat_clients"` // of them, clients who had completed an order before
+}
+
+// RevenuePoint represents completed orders created within one time bucket
+type RevenuePoint struct {
+	Time    time.Time `json:"time"`
+	Orders  int       `json:"orders"`
+	Revenue float64   `json:"revenue"`
+}
+
+// CancellationReason counts canceled orders sharing a reason
+type CancellationReason struct {
+	Reason string `json:"reason"`
+	Orders int    `json:"orders"`
+}
+
+// ExecutorRevenue represents the completed orders an executor worked on within a period
+type ExecutorRevenue struct {
+	UserID  int64   `json:"user_id"`
+	Name    string  `json:"name"`
+	Role    string  `json:"role"`
+	Orders  int     `json:"orders"`
+	Revenue float64 `json:"revenue"`
+}
+
+// SubcategoryStats represents completed orders of a subcategory within a period
+type SubcategoryStats struct {
+	Category    string  `json:"category"`
+	Subcategory string  `json:"subcategory"`
+	Orders      int     `json:"orders"`
+	Revenue     float64 `json:"revenue"`
+}
+
+// StatsReport compares the statistics of a period with the previous period of equal length
+type StatsReport struct {
+	Title         string `json:"title"`
+	PreviousTitle string `json:"previous_title"`
+	Current       Stats  `json:"current"`
+	Previous      *Stats `json:"previous"` // nil when the period has no previous one
+}
+
+// EscalationStats summarizes negative-review escalations opened within a period
+type EscalationStats struct {
+	Opened             int     `json:"opened"`
+	Resolved           int     `json:"resolved"`
+	AvgResolutionHours float64 `json:"avg_resolution_hours"`
+}
+
+// ReferrerStats represents referral results of a single inviter for a period
+type ReferrerStats struct {
+	InviterID int64   `json:"inviter_id"`
+	Name      string  `json:"name"`
+	NewUsers  int     `json:"new_users"`
+	Orders    int     `json:"orders"`
+	Revenue   float64 `json:"revenue"`
+}
+
+// CampaignStats represents attribution results of a campaign code for a period
+type CampaignStats struct {
+	CampaignID int     `json:"campaign_id"`
+	Code       string  `json:"code"`
+	Name       string  `json:"name"`
+	NewUsers   int     `json:"new_users"`
+	Orders     int     `json:"orders"`
+	Revenue    float64 `json:"revenue"`
+}
+
+// ReferralReport represents the owner report on referrers and campaigns
+type ReferralReport struct {
+	TopReferrers []ReferrerStats `json:"top_referrers"`
+	Campaigns    []CampaignStats `json:"campaigns"`
+}
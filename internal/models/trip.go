@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Trip is an executor's drive to an order, tracked by shared live location
+type Trip struct {
+	OrderID   int        `json:"order_id"`
+	UserID    int64      `json:"user_id"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	Latitude  float64    `json:"latitude"`
+	Longitude float64    `json:"longitude"`
+	LocatedAt *time.Time `json:"located_at"`
+	LiveUntil *time.Time `json:"live_until"`
+}
+
+// HasLocation reports whether the executor has shared a location during the trip
+func (t *Trip) HasLocation() bool {
+	return t.LocatedAt != nil
+}
\ No newline at end of file
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Vehicle is a truck of the fleet
+type Vehicle struct {
+	ID           int       `json:"id"`
+	Plate        string    `json:"plate"`
+	Type         string    `json:"type"`
+	CapacityM3   float64   `json:"capacity_m3"`
+	CapacityTons float64   `json:"capacity_tons"`
+	Status       string    `json:"status"`
+	DriverID     int64     `json:"driver_id"`
+	Mileage      int       `json:"mileage"`
+	// ServiceDueDate and ServiceDueMileage are when the next maintenance is due, by date or odometer
+	ServiceDueDate    *time.Time `json:"service_due_date"`
+	ServiceDueMileage int        `json:"service_due_mileage"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
\ No newline at end of file
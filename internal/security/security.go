@@ -42,11 +42,32 @@ func (s *SecurityChecker) HasAccess(chatID int64, module string) (bool, error) {
 		return user.Role == "client" || user.Role == "operator" || user.Role == "main_operator" || user.Role == "owner", nil
 	case "stats":
 		return user.Role == "owner", nil
+	case "payments":
+		return user.Role == "main_operator" || user.Role == "accountant" || user.Role == "owner", nil
+	case "debts":
+		return user.Role == "owner", nil
+	case "ledger":
+		return user.Role == "accountant" || user.Role == "owner", nil
+	case "payroll":
+		return user.Role == "owner", nil
+	case "expenses":
+		return user.Role == "main_operator" || user.Role == "accountant" || user.Role == "owner", nil
+	case "fleet":
+		return user.Role == "owner" || user.Role == "main_operator", nil
 	default:
 		return false, nil
 	}
 }
 
+// HasRole checks if a non-blocked user has the given role; "user" matches any role
+func (s *SecurityChecker) HasRole(chatID int64, role string) bool {
+	user, err := s.userService.GetUser(chatID)
+	if err != nil || user.IsBlocked {
+		return false
+	}
+	return role == "user" || user.Role == role
+}
+
 // IsBlocked checks if a user is blocked
 func (s *SecurityChecker) IsBlocked(chatID int64) (bool, error) {
 	user, err := s.userService.GetUser(chatID)
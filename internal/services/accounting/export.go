@@ -0,0 +1,278 @@
+package accounting
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"github.com/skyzeper/telegram-bot/internal/models"
+)
+
+// Export formats
+const (
+	FormatCSV = "csv" // semicolon-separated UTF-8 table for spreadsheets
+	Format1C  = "1c"  // 1CClientBankExchange bank statement in Windows-1251
+)
+
+// exportKinds are the entry kinds exported: client payments, refunds, expenses and salaries, but not internal transfers
+var exportKinds = []string{EntryDriverCash, EntryIncome, EntryRefund, EntryExpense, EntrySalary}
+
+// ExportConfig holds the company requisites written to 1C exchange files
+type ExportConfig struct {
+	// CompanyName is the legal name of the company
+	CompanyName string
+	// INN is the company taxpayer number
+	INN string
+	// BankAccount is the settlement account number
+	BankAccount string
+}
+
+// Export holds the records of a period together with the settlement account balances around it
+type Export struct {
+	From    time.Time
+	To      time.Time
+	Records []models.ExportRecord
+	// BankOpening and BankClosing are the settlement account balances at the start and end of the period
+	BankOpening models.Money
+	BankClosing models.Money
+}
+
+// GetExport collects the export records of [from, to) and the settlement account balances from the ledger
+func (s *Service) GetExport(from, to time.Time) (*Export, error) {
+	// Timestamps are stored without a zone as server wall-clock time
+	start, end := from.In(time.Local), to.In(time.Local)
+	records, err := s.GetExportRecords(start, end)
+	if err != nil {
+		return nil, err
+	}
+	report, err := s.GetBalanceReport(start, end)
+	if err != nil {
+		return nil, err
+	}
+	export := &Export{From: from, To: to, Records: records}
+	for _, balance := range report.Accounts {
+		if balance.Account == AccountBank {
+			export.BankOpening = balance.Opening
+			export.BankClosing = balance.Closing
+		}
+	}
+	return export, nil
+}
+
+// GetExportRecords turns the payments and expenses posted in [from, to) into export records
+func (s *Service) GetExportRecords(from, to time.Time) ([]models.ExportRecord, error) {
+	if !from.Before(to) {
+		return nil, errors.New("invalid export period")
+	}
+	entries, err := s.repo.GetEntries(from, to, exportKinds)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]models.ExportRecord, 0, len(entries))
+	for _, entry := range entries {
+		if record, ok := ExportRecord(entry); ok {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// ExportRecord describes an entry by its money side: the asset account that received or paid out the amount
+// and the income or expense account it was booked against
+func ExportRecord(entry models.LedgerEntry) (models.ExportRecord, bool) {
+	record := models.ExportRecord{
+		EntryID:      entry.ID,
+		Kind:         entry.Kind,
+		Date:         entry.CreatedAt,
+		Counterparty: entry.UserName,
+		OrderID:      entry.OrderID,
+		Description:  entry.Description,
+	}
+	var moneyChange models.Money
+	for _, posting := range entry.Postings {
+		account, _ := LookupAccount(posting.Account)
+		if account.Class == ClassAsset {
+			moneyChange += posting.Amount
+			record.Account = posting.Account
+		} else {
+			record.Article = posting.Account
+		}
+	}
+	if moneyChange == 0 || record.Account == "" {
+		return record, false
+	}
+	record.Incoming = moneyChange > 0
+	record.Amount = moneyChange.Abs()
+	return record, true
+}
+
+// accountName returns the display name of an account code
+func accountName(code string) string {
+	if account, ok := LookupAccount(code); ok {
+		return account.Name
+	}
+	return code
+}
+
+// WriteCSV writes export records as a semicolon-separated table with a BOM so Excel and 1C detect UTF-8
+func WriteCSV(w io.Writer, records []models.ExportRecord) error {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return fmt.Errorf("failed to write CSV: %v", err)
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	cw.UseCRLF = true
+	header := []string{"Дата", "Номер", "Направление", "Счёт", "Статья", "Контрагент", "Заказ", "Сумма", "Назначение"}
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV: %v", err)
+	}
+	for _, record := range records {
+		direction := "Списание"
+		if record.Incoming {
+			direction = "Поступление"
+		}
+		order := ""
+		if record.OrderID != 0 {
+			order = strconv.Itoa(record.OrderID)
+		}
+		row := []string{
+			record.Date.Format("02.01.2006"),
+			strconv.Itoa(record.EntryID),
+			direction,
+			accountName(record.Account),
+			accountName(record.Article),
+			record.Counterparty,
+			order,
+			strings.Replace(record.Amount.String(), ".", ",", 1),
+			record.Description,
+		}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV: %v", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %v", err)
+	}
+	return nil
+}
+
+// Write1C writes the settlement account movements as a 1CClientBankExchange bank statement;
+// cash movements are not part of a bank statement and are left out
+func Write1C(w io.Writer, export *Export, cfg ExportConfig) error {
+	now := time.Now()
+	from := export.From
+	lastDay := export.To.AddDate(0, 0, -1).Format("02.01.2006")
+	// Characters outside Windows-1251, such as emoji in names, are replaced rather than failing the export
+	bw := bufio.NewWriter(encoding.ReplaceUnsupported(charmap.Windows1251.NewEncoder()).Writer(w))
+	line := func(key, value string) {
+		// Line breaks would end the value early; the format has no escaping
+		value = strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+		fmt.Fprintf(bw, "%s=%s\r\n", key, value)
+	}
+
+	var records []models.ExportRecord
+	var received, spent models.Money
+	for _, record := range export.Records {
+		if record.Account != AccountBank {
+			continue
+		}
+		records = append(records, record)
+		if record.Incoming {
+			received += record.Amount
+		} else {
+			spent += record.Amount
+		}
+	}
+
+	bw.WriteString("1CClientBankExchange\r\n")
+	line("ВерсияФормата", "1.03")
+	line("Кодировка", "Windows")
+	line("Отправитель", cfg.CompanyName)
+	line("Получатель", "1С:Бухгалтерия")
+	line("ДатаСоздания", now.Format("02.01.2006"))
+	line("ВремяСоздания", now.Format("15:04:05"))
+	line("ДатаНачала", from.Format("02.01.2006"))
+	line("ДатаКонца", lastDay)
+	line("РасчСчет", cfg.BankAccount)
+	line("Документ", "Платежное поручение")
+	bw.WriteString("СекцияРасчСчет\r\n")
+	line("ДатаНачала", from.Format("02.01.2006"))
+	line("ДатаКонца", lastDay)
+	line("РасчСчет", cfg.BankAccount)
+	line("НачальныйОстаток", export.BankOpening.String())
+	line("ВсегоПоступило", received.String())
+	line("ВсегоСписано", spent.String())
+	line("КонечныйОстаток", export.BankClosing.String())
+	bw.WriteString("КонецРасчСчет\r\n")
+
+	for _, record := range records {
+		date := record.Date.Format("02.01.2006")
+		purpose := record.Description
+		if purpose == "" {
+			purpose = accountName(record.Article)
+		}
+		if record.OrderID != 0 {
+			purpose = fmt.Sprintf("%s (заказ #%d)", purpose, record.OrderID)
+		}
+
+		line("СекцияДокумент", "Платежное поручение")
+		line("Номер", strconv.Itoa(record.EntryID))
+		line("Дата", date)
+		line("Сумма", record.Amount.String())
+		if record.Incoming {
+			line("ПлательщикСчет", "")
+			line("Плательщик", record.Counterparty)
+			line("ПлательщикИНН", "")
+			line("ПолучательСчет", cfg.BankAccount)
+			line("Получатель", cfg.CompanyName)
+			line("ПолучательИНН", cfg.INN)
+			line("ДатаПоступило", date)
+		} else {
+			line("ПлательщикСчет", cfg.BankAccount)
+			line("Плательщик", cfg.CompanyName)
+			line("ПлательщикИНН", cfg.INN)
+			line("ПолучательСчет", "")
+			line("Получатель", record.Counterparty)
+			line("ПолучательИНН", "")
+			line("ДатаСписано", date)
+		}
+		line("ВидОплаты", "01")
+		line("Очередность", "5")
+		line("НазначениеПлатежа", purpose)
+		bw.WriteString("КонецДокумента\r\n")
+	}
+	bw.WriteString("КонецФайла\r\n")
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write 1C file: %v", err)
+	}
+	return nil
+}
+
+// WriteExport writes an export in the given format
+func WriteExport(w io.Writer, format string, export *Export, cfg ExportConfig) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, export.Records)
+	case Format1C:
+		return Write1C(w, export, cfg)
+	default:
+		return fmt.Errorf("unknown export format: %s", format)
+	}
+}
+
+// ExportFileName names an export file after its format and inclusive period
+func ExportFileName(format string, from, to time.Time) string {
+	period := from.Format("02.01.2006") + "-" + to.AddDate(0, 0, -1).Format("02.01.2006")
+	if format == Format1C {
+		return "kl_to_1c_" + period + ".txt"
+	}
+	return "accounting_" + period + ".csv"
+}
@@ -0,0 +1,128 @@
+package accounting_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"golang.org/x/text/encoding/charmap"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/accounting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	exportFrom = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	exportTo   = time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func exportEntries() []models.LedgerEntry {
+	at := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	return []models.LedgerEntry{
+		{ID: 1, Kind: accounting.EntryIncome, OrderID: 10, UserID: 100, UserName: "Иван Петров", Description: "Оплата заказа картой", CreatedAt: at,
+			Postings: []models.LedgerPosting{{Account: accounting.AccountBank, Amount: 500000}, {Account: accounting.AccountRevenue, Amount: -500000}}},
+		{ID: 2, Kind: accounting.EntryExpense, UserID: 200, UserName: "Водитель", Description: "Топливо", CreatedAt: at,
+			Postings: []models.LedgerPosting{{Account: accounting.AccountFuel, Amount: 120050}, {Account: accounting.AccountDriverCash, DriverID: 200, Amount: -120050}}},
+		{ID: 3, Kind: accounting.EntryRefund, OrderID: 10, UserID: 100, UserName: "Иван Петров", CreatedAt: at,
+			Postings: []models.LedgerPosting{{Account: accounting.AccountRevenue, Amount: 50000}, {Account: accounting.AccountBank, Amount: -50000}}},
+	}
+}
+
+func TestService_GetExportRecords(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := accounting.NewService(mockRepo)
+
+	mockRepo.On("GetEntries", exportFrom, exportTo, mock.MatchedBy(func(kinds []string) bool {
+		return len(kinds) == 5
+	})).Return(exportEntries(), nil).Once()
+
+	records, err := service.GetExportRecords(exportFrom, exportTo)
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.True(t, records[0].Incoming)
+	assert.Equal(t, accounting.AccountBank, records[0].Account)
+	assert.Equal(t, accounting.AccountRevenue, records[0].Article)
+	assert.Equal(t, models.Money(500000), records[0].Amount)
+	assert.False(t, records[1].Incoming)
+	assert.Equal(t, accounting.AccountDriverCash, records[1].Account)
+	assert.Equal(t, accounting.AccountFuel, records[1].Article)
+	assert.Equal(t, models.Money(120050), records[1].Amount)
+	assert.False(t, records[2].Incoming)
+	mockRepo.AssertExpectations(t)
+
+	_, err = service.GetExportRecords(exportTo, exportFrom)
+	assert.Error(t, err)
+}
+
+func TestService_GetExport(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := accounting.NewService(mockRepo)
+
+	// The period is queried in server wall-clock time and reported as given
+	mockRepo.On("GetEntries", exportFrom.In(time.Local), exportTo.In(time.Local), mock.Anything).Return(exportEntries(), nil).Once()
+	mockRepo.On("GetAccountBalances", exportFrom.In(time.Local), exportTo.In(time.Local)).Return([]models.AccountBalance{
+		{Account: accounting.AccountBank, Opening: 1000000, Debit: 500000, Credit: 50000},
+		{Account: accounting.AccountCashDesk, Opening: 300000},
+	}, nil).Once()
+
+	export, err := service.GetExport(exportFrom, exportTo)
+	assert.NoError(t, err)
+	assert.Equal(t, exportFrom, export.From)
+	assert.Len(t, export.Records, 3)
+	assert.Equal(t, models.Money(1000000), export.BankOpening)
+	assert.Equal(t, models.Money(1450000), export.BankClosing)
+	mockRepo.AssertExpectations(t)
+}
+
+func exportRecords() []models.ExportRecord {
+	var records []models.ExportRecord
+	for _, entry := range exportEntries() {
+		record, _ := accounting.ExportRecord(entry)
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, accounting.WriteCSV(&buf, exportRecords()))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	assert.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "\ufeffДата;Номер;"))
+	assert.Equal(t, "05.03.2024;1;Поступление;Расчётный счёт;Выручка;Иван Петров;10;5000,00;Оплата заказа картой", lines[1])
+	assert.Equal(t, "05.03.2024;2;Списание;Наличные у водителей;Топливо;Водитель;;1200,50;Топливо", lines[2])
+}
+
+func TestWrite1C(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := accounting.ExportConfig{CompanyName: "ООО Вывоз", INN: "7701234567", BankAccount: "40702810900000000001"}
+	export := &accounting.Export{From: exportFrom, To: exportTo, Records: exportRecords(), BankOpening: 1000000, BankClosing: 1450000}
+	assert.NoError(t, accounting.Write1C(&buf, export, cfg))
+
+	decoded, err := charmap.Windows1251.NewDecoder().Bytes(buf.Bytes())
+	assert.NoError(t, err)
+	text := string(decoded)
+
+	assert.True(t, strings.HasPrefix(text, "1CClientBankExchange\r\nВерсияФормата=1.03\r\nКодировка=Windows\r\n"))
+	assert.Contains(t, text, "ДатаКонца=31.03.2024\r\n")
+	assert.Contains(t, text, "НачальныйОстаток=10000.00\r\nВсегоПоступило=5000.00\r\nВсегоСписано=500.00\r\nКонечныйОстаток=14500.00\r\n")
+	assert.Contains(t, text, "СекцияДокумент=Платежное поручение\r\nНомер=1\r\nДата=05.03.2024\r\nСумма=5000.00\r\n")
+	assert.Contains(t, text, "Плательщик=Иван Петров\r\n")
+	assert.Contains(t, text, "ПолучательИНН=7701234567\r\n")
+	assert.Contains(t, text, "НазначениеПлатежа=Выручка (заказ #10)\r\n")
+	// Driver cash spent on fuel is not a bank movement
+	assert.NotContains(t, text, "Номер=2\r\n")
+	assert.NotContains(t, text, "кассовый ордер")
+	assert.Equal(t, 2, strings.Count(text, "КонецДокумента\r\n"))
+	assert.True(t, strings.HasSuffix(text, "КонецФайла\r\n"))
+}
+
+func TestWriteExport_UnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	err := accounting.WriteExport(&buf, "xml", &accounting.Export{From: exportFrom, To: exportTo}, accounting.ExportConfig{})
+	assert.Error(t, err)
+	assert.Equal(t, "accounting_01.03.2024-31.03.2024.csv", accounting.ExportFileName(accounting.FormatCSV, exportFrom, exportTo))
+	assert.Equal(t, "kl_to_1c_01.03.2024-31.03.2024.txt", accounting.ExportFileName(accounting.Format1C, exportFrom, exportTo))
+}
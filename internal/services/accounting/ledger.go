@@ -0,0 +1,283 @@
+package accounting
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+)
+
+// Ledger accounts
+const (
+	AccountCashDesk    = "cash_desk"      // office cash desk
+	AccountDriverCash  = "driver_cash"    // cash held by drivers, kept per driver
+	AccountBank        = "bank"           // settlement account receiving online payments
+	AccountAccountable = "accountable"    // money advanced to staff for expenses, kept per person
+	AccountRevenue     = "revenue"        // income from orders
+	AccountSalaries    = "salaries"       // staff pay
+	AccountFuel        = "fuel"           // fuel for vehicles
+	AccountDisposal    = "disposal_fees"  // landfill and disposal fees
+	AccountParking     = "parking"        // parking and entry fees
+	AccountOther       = "other_expenses" // costs without a category, such as those recorded before the ledger
+)
+
+// Account classes decide which side of the ledger an account normally grows on
+const (
+	ClassAsset   = "asset"   // debit balance
+	ClassIncome  = "income"  // credit balance
+	ClassExpense = "expense" // debit balance
+)
+
+// Account describes a ledger account
+type Account struct {
+	Code  string
+	Name  string
+	Class string
+}
+
+// accounts is the chart of accounts in report order
+var accounts = []Account{
+	{Code: AccountCashDesk, Name: "Касса", Class: ClassAsset},
+	{Code: AccountDriverCash, Name: "Наличные у водителей", Class: ClassAsset},
+	{Code: AccountBank, Name: "Расчётный счёт", Class: ClassAsset},
+	{Code: AccountAccountable, Name: "Подотчётные лица", Class: ClassAsset},
+	{Code: AccountRevenue, Name: "Выручка", Class: ClassIncome},
+	{Code: AccountSalaries, Name: "Зарплата", Class: ClassExpense},
+	{Code: AccountFuel, Name: "Топливо", Class: ClassExpense},
+	{Code: AccountDisposal, Name: "Утилизация", Class: ClassExpense},
+	{Code: AccountParking, Name: "Парковка", Class: ClassExpense},
+	{Code: AccountOther, Name: "Прочие расходы", Class: ClassExpense},
+}
+
+// Accounts returns the chart of accounts
+func Accounts() []Account {
+	return append([]Account(nil), accounts...)
+}
+
+// LookupAccount finds an account by its code
+func LookupAccount(code string) (Account, bool) {
+	for _, account := range accounts {
+		if account.Code == code {
+			return account, true
+		}
+	}
+	return Account{}, false
+}
+
+// Entry kinds
+const (
+	EntryDriverCash   = "driver_cash"   // cash collected by a driver from a client
+	EntryCashHandOver = "cash_handover" // driver cash handed over to the cash desk
+	EntryIncome       = "income"        // payment received directly by the cash desk or bank
+	EntryRefund       = "refund"        // money returned to a client
+	EntryExpense      = "expense"       // fuel, disposal, parking and other costs
+	EntrySalary       = "salary"        // staff pay
+)
+
+// ValidateEntry checks that an entry uses known accounts and that its postings balance
+func ValidateEntry(entry *models.LedgerEntry) error {
+	if entry == nil || entry.Kind == "" || entry.UserID <= 0 {
+		return errors.New("missing required ledger entry fields")
+	}
+	if len(entry.Postings) < 2 {
+		return errors.New("ledger entry needs at least two postings")
+	}
+
+	var total models.Money
+	for _, posting := range entry.Postings {
+		if _, ok := LookupAccount(posting.Account); !ok {
+			return fmt.Errorf("unknown account: %s", posting.Account)
+		}
+		if posting.Amount == 0 {
+			return errors.New("posting amount must not be zero")
+		}
+		if personalAccount(posting.Account) != (posting.DriverID > 0) {
+			return errors.New("person is required on driver cash and accountable postings only")
+		}
+		total += posting.Amount
+	}
+	if total != 0 {
+		return fmt.Errorf("ledger entry is unbalanced by %s", total)
+	}
+	return nil
+}
+
+// Post validates and stores a balanced ledger entry
+func (s *Service) Post(entry *models.LedgerEntry) error {
+	if err := ValidateEntry(entry); err != nil {
+		return err
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	return s.repo.CreateEntry(entry)
+}
+
+// transfer posts a two-sided entry debiting one account and crediting another
+func (s *Service) transfer(kind string, orderID int, userID int64, debit, credit models.LedgerPosting, amount models.Money, description string) error {
+	entry, err := transferEntry(kind, orderID, userID, debit, credit, amount, description)
+	if err != nil {
+		return err
+	}
+	return s.Post(entry)
+}
+
+// transferEntry builds a two-sided entry debiting one account and crediting another
+func transferEntry(kind string, orderID int, userID int64, debit, credit models.LedgerPosting, amount models.Money, description string) (*models.LedgerEntry, error) {
+	if amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	debit.Amount = amount
+	credit.Amount = -amount
+	return &models.LedgerEntry{
+		Kind:        kind,
+		OrderID:     orderID,
+		UserID:      userID,
+		Description: description,
+		Postings:    []models.LedgerPosting{debit, credit},
+	}, nil
+}
+
+// posting builds a posting on an account, attaching the person to personal account postings
+func posting(account string, driverID int64) models.LedgerPosting {
+	p := models.LedgerPosting{Account: account}
+	if personalAccount(account) {
+		p.DriverID = driverID
+	}
+	return p
+}
+
+// personalAccount reports whether an account keeps a balance per person
+func personalAccount(account string) bool {
+	return account == AccountDriverCash || account == AccountAccountable
+}
+
+// RecordIncome records an order payment received by the cash desk or the bank
+func (s *Service) RecordIncome(orderID int, userID int64, amount models.Money, account, description string) error {
+	if orderID <= 0 || userID <= 0 {
+		return errors.New("invalid order or user ID")
+	}
+	if account != AccountCashDesk && account != AccountBank {
+		return fmt.Errorf("income cannot be received on %s", account)
+	}
+	return s.transfer(EntryIncome, orderID, userID, posting(account, 0), posting(AccountRevenue, 0), amount, description)
+}
+
+// RecordRefund records money returned to a client from the cash desk or the bank
+func (s *Service) RecordRefund(orderID int, userID int64, amount models.Money, account, description string) error {
+	entry, err := RefundEntry(orderID, userID, amount, account, description)
+	if err != nil {
+		return err
+	}
+	return s.Post(entry)
+}
+
+// RefundEntry builds the entry of a refund for callers that store it in their own transaction
+func RefundEntry(orderID int, userID int64, amount models.Money, account, description string) (*models.LedgerEntry, error) {
+	if orderID <= 0 || userID <= 0 {
+		return nil, errors.New("invalid order or user ID")
+	}
+	if account != AccountCashDesk && account != AccountBank {
+		return nil, fmt.Errorf("refunds cannot be paid from %s", account)
+	}
+	return transferEntry(EntryRefund, orderID, userID, posting(AccountRevenue, 0), posting(account, 0), amount, description)
+}
+
+// RecordExpense records a cost paid from an asset account; a driver paying from collected cash is the user
+func (s *Service) RecordExpense(userID int64, expenseAccount string, amount models.Money, paidFrom, description string) error {
+	entry, err := expenseEntry(userID, expenseAccount, amount, paidFrom, description)
+	if err != nil {
+		return err
+	}
+	return s.Post(entry)
+}
+
+// RecordSalary records a salary payout from the cash desk or the bank
+func (s *Service) RecordSalary(userID int64, amount models.Money, paidFrom, description string) error {
+	return s.RecordExpense(userID, AccountSalaries, amount, paidFrom, description)
+}
+
+// SalaryEntry builds a validated salary payout entry for callers that store it in their own transaction
+func SalaryEntry(userID int64, amount models.Money, paidFrom, description string) (*models.LedgerEntry, error) {
+	entry, err := expenseEntry(userID, AccountSalaries, amount, paidFrom, description)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateEntry(entry); err != nil {
+		return nil, err
+	}
+	entry.CreatedAt = time.Now()
+	return entry, nil
+}
+
+// expenseEntry builds the entry of a cost paid from an asset account
+func expenseEntry(userID int64, expenseAccount string, amount models.Money, paidFrom, description string) (*models.LedgerEntry, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+	if account, ok := LookupAccount(expenseAccount); !ok || account.Class != ClassExpense {
+		return nil, fmt.Errorf("not an expense account: %s", expenseAccount)
+	}
+	if account, ok := LookupAccount(paidFrom); !ok || account.Class != ClassAsset {
+		return nil, fmt.Errorf("expenses cannot be paid from %s", paidFrom)
+	}
+	kind := EntryExpense
+	if expenseAccount == AccountSalaries {
+		kind = EntrySalary
+	}
+	return transferEntry(kind, 0, userID, posting(expenseAccount, 0), posting(paidFrom, userID), amount, description)
+}
+
+// GetBalanceReport computes the opening balance, turnover and closing balance of every account for [from, to)
+func (s *Service) GetBalanceReport(from, to time.Time) (*models.BalanceReport, error) {
+	if !to.After(from) {
+		return nil, errors.New("invalid report period")
+	}
+	balances, err := s.repo.GetAccountBalances(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account balances: %v", err)
+	}
+	byAccount := make(map[string]models.AccountBalance, len(balances))
+	for _, balance := range balances {
+		byAccount[balance.Account] = balance
+	}
+
+	report := &models.BalanceReport{From: from, To: to}
+	for _, account := range accounts {
+		balance := byAccount[account.Code]
+		balance.Account = account.Code
+		balance.Closing = balance.Opening + balance.Debit - balance.Credit
+		report.Accounts = append(report.Accounts, balance)
+	}
+	return report, nil
+}
+
+// Imbalance sums closing balances across accounts; it is zero when every entry balanced
+func Imbalance(report *models.BalanceReport) models.Money {
+	var total models.Money
+	for _, balance := range report.Accounts {
+		total += balance.Closing
+	}
+	return total
+}
+
+// FormatBalanceReport renders account balances, showing income accounts with their natural (credit) sign
+func FormatBalanceReport(report *models.BalanceReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📒 Баланс счетов %s — %s\n",
+		report.From.Format("02.01.2006"), report.To.Add(-time.Nanosecond).Format("02.01.2006"))
+	for _, balance := range report.Accounts {
+		account, _ := LookupAccount(balance.Account)
+		opening, closing := balance.Opening, balance.Closing
+		if account.Class == ClassIncome {
+			opening, closing = -opening, -closing
+		}
+		fmt.Fprintf(&b, "\n%s\nНачало: %s · Дт %s · Кт %s · Конец: %s\n",
+			account.Name, opening, balance.Debit, balance.Credit, closing)
+	}
+	if imbalance := Imbalance(report); imbalance != 0 {
+		fmt.Fprintf(&b, "\n⚠️ Расхождение проводок: %s руб.", imbalance)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
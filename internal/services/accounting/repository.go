@@ -3,6 +3,8 @@ package accounting
 import (
 	"database/sql"
 	"fmt"
+	"time"
+	"github.com/lib/pq"
 	"github.com/skyzeper/telegram-bot/internal/db"
 	"github.com/skyzeper/telegram-bot/internal/models"
 	"github.com/skyzeper/telegram-bot/internal/utils"
@@ -18,148 +20,264 @@ func NewPostgresRepository(db *db.DB) *PostgresRepository {
 	return &PostgresRepository{db: db}
 }
 
-// CreateRecord creates a new accounting record
-func (r *PostgresRepository) CreateRecord(record *models.AccountingRecord) error {
+// CreateEntry stores a ledger entry with its postings in one transaction
+func (r *PostgresRepository) CreateEntry(entry *models.LedgerEntry) error {
+	tx, err := r.db.Conn().Begin()
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := InsertEntry(tx, entry); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to commit ledger entry: %v", err)
+	}
+	return nil
+}
+
+// InsertEntry stores a ledger entry with its postings inside a caller's transaction
+func InsertEntry(tx *sql.Tx, entry *models.LedgerEntry) error {
 	query := `
-		INSERT INTO accounting_records (order_id, user_id, type, amount, description, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO ledger_entries (kind, order_id, user_id, description, created_at)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id
 	`
 	var orderID sql.NullInt64
-	if record.OrderID != 0 {
+	if entry.OrderID != 0 {
 		orderID.Valid = true
-		orderID.Int64 = int64(record.OrderID)
+		orderID.Int64 = int64(entry.OrderID)
 	}
-	err := r.db.Conn().QueryRow(
-		query,
-		orderID, record.UserID, record.Type, record.Amount, record.Description, record.CreatedAt,
-	).Scan(&record.ID)
+	err := tx.QueryRow(query, entry.Kind, orderID, entry.UserID, entry.Description, entry.CreatedAt).Scan(&entry.ID)
 	if err != nil {
 		utils.LogError(err)
-		return fmt.Errorf("failed to create accounting record: %v", err)
+		return fmt.Errorf("failed to create ledger entry: %v", err)
+	}
+
+	for i := range entry.Postings {
+		posting := &entry.Postings[i]
+		posting.EntryID = entry.ID
+		var driverID sql.NullInt64
+		if posting.DriverID != 0 {
+			driverID.Valid = true
+			driverID.Int64 = posting.DriverID
+		}
+		err := tx.QueryRow(
+			`INSERT INTO ledger_postings (entry_id, account, driver_id, amount) VALUES ($1, $2, $3, $4) RETURNING id`,
+			entry.ID, posting.Account, driverID, posting.Amount,
+		).Scan(&posting.ID)
+		if err != nil {
+			utils.LogError(err)
+			return fmt.Errorf("failed to create ledger posting: %v", err)
+		}
 	}
 	return nil
 }
 
-// GetRecordsByOrder retrieves accounting records for an order
-func (r *PostgresRepository) GetRecordsByOrder(orderID int) ([]models.AccountingRecord, error) {
+// GetEntriesByOrder retrieves the ledger entries of an order with their postings
+func (r *PostgresRepository) GetEntriesByOrder(orderID int) ([]models.LedgerEntry, error) {
 	query := `
-		SELECT id, order_id, user_id, type, amount, description, created_at
-		FROM accounting_records
-		WHERE order_id = $1
+		SELECT e.id, e.kind, e.order_id, e.user_id, e.description, e.created_at,
+		       p.id, p.account, p.driver_id, p.amount
+		FROM ledger_entries e
+		JOIN ledger_postings p ON p.entry_id = e.id
+		WHERE e.order_id = $1
+		ORDER BY e.created_at, e.id, p.id
 	`
 	rows, err := r.db.Conn().Query(query, orderID)
 	if err != nil {
 		utils.LogError(err)
-		return nil, fmt.Errorf("failed to get records by order: %v", err)
+		return nil, fmt.Errorf("failed to get ledger entries: %v", err)
 	}
 	defer rows.Close()
 
-	var records []models.AccountingRecord
+	var entries []models.LedgerEntry
 	for rows.Next() {
-		var record models.AccountingRecord
-		var orderID sql.NullInt64
+		var entry models.LedgerEntry
+		var posting models.LedgerPosting
+		var entryOrderID, driverID sql.NullInt64
+		var description sql.NullString
 		if err := rows.Scan(
-			&record.ID, &orderID, &record.UserID, &record.Type,
-			&record.Amount, &record.Description, &record.CreatedAt,
+			&entry.ID, &entry.Kind, &entryOrderID, &entry.UserID, &description, &entry.CreatedAt,
+			&posting.ID, &posting.Account, &driverID, &posting.Amount,
 		); err != nil {
 			utils.LogError(err)
 			continue
 		}
-		if orderID.Valid {
-			record.OrderID = int(orderID.Int64)
+		posting.EntryID = entry.ID
+		posting.DriverID = driverID.Int64
+		if n := len(entries); n > 0 && entries[n-1].ID == entry.ID {
+			entries[n-1].Postings = append(entries[n-1].Postings, posting)
+			continue
 		}
-		records = append(records, record)
+		entry.OrderID = int(entryOrderID.Int64)
+		entry.Description = description.String
+		entry.Postings = []models.LedgerPosting{posting}
+		entries = append(entries, entry)
 	}
-	return records, nil
+	return entries, nil
 }
 
-// GetRecordsByUser retrieves accounting records for a user
-func (r *PostgresRepository) GetRecordsByUser(userID int64) ([]models.AccountingRecord, error) {
+// GetEntries retrieves ledger entries of the given kinds created in [from, to) with their postings and user names
+func (r *PostgresRepository) GetEntries(from, to time.Time, kinds []string) ([]models.LedgerEntry, error) {
 	query := `
-		SELECT id, order_id, user_id, type, amount, description, created_at
-		FROM accounting_records
-		WHERE user_id = $1
+		SELECT e.id, e.kind, e.order_id, e.user_id, TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, '')),
+		       e.description, e.created_at, p.id, p.account, p.driver_id, p.amount
+		FROM ledger_entries e
+		JOIN ledger_postings p ON p.entry_id = e.id
+		LEFT JOIN users u ON u.chat_id = e.user_id
+		WHERE e.created_at >= $1 AND e.created_at < $2 AND e.kind = ANY($3)
+		ORDER BY e.created_at, e.id, p.id
 	`
-	rows, err := r.db.Conn().Query(query, userID)
+	rows, err := r.db.Conn().Query(query, from, to, pq.Array(kinds))
 	if err != nil {
 		utils.LogError(err)
-		return nil, fmt.Errorf("failed to get records by user: %v", err)
+		return nil, fmt.Errorf("failed to get ledger entries: %v", err)
 	}
 	defer rows.Close()
 
-	var records []models.AccountingRecord
+	var entries []models.LedgerEntry
 	for rows.Next() {
-		var record models.AccountingRecord
-		var orderID sql.NullInt64
+		var entry models.LedgerEntry
+		var posting models.LedgerPosting
+		var entryOrderID, driverID sql.NullInt64
+		var description sql.NullString
 		if err := rows.Scan(
-			&record.ID, &orderID, &record.UserID, &record.Type,
-			&record.Amount, &record.Description, &record.CreatedAt,
+			&entry.ID, &entry.Kind, &entryOrderID, &entry.UserID, &entry.UserName, &description, &entry.CreatedAt,
+			&posting.ID, &posting.Account, &driverID, &posting.Amount,
 		); err != nil {
 			utils.LogError(err)
 			continue
 		}
-		if orderID.Valid {
-			record.OrderID = int(orderID.Int64)
+		posting.EntryID = entry.ID
+		posting.DriverID = driverID.Int64
+		if n := len(entries); n > 0 && entries[n-1].ID == entry.ID {
+			entries[n-1].Postings = append(entries[n-1].Postings, posting)
+			continue
+		}
+		entry.OrderID = int(entryOrderID.Int64)
+		entry.Description = description.String
+		entry.Postings = []models.LedgerPosting{posting}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// GetAccountBalances sums postings per account: the balance before from and debit and credit turnover in [from, to)
+func (r *PostgresRepository) GetAccountBalances(from, to time.Time) ([]models.AccountBalance, error) {
+	query := `
+		SELECT p.account,
+		       COALESCE(SUM(p.amount) FILTER (WHERE e.created_at < $1), 0),
+		       COALESCE(SUM(p.amount) FILTER (WHERE e.created_at >= $1 AND p.amount > 0), 0),
+		       COALESCE(-SUM(p.amount) FILTER (WHERE e.created_at >= $1 AND p.amount < 0), 0)
+		FROM ledger_postings p
+		JOIN ledger_entries e ON e.id = p.entry_id
+		WHERE e.created_at < $2
+		GROUP BY p.account
+	`
+	rows, err := r.db.Conn().Query(query, from, to)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get account balances: %v", err)
+	}
+	defer rows.Close()
+
+	var balances []models.AccountBalance
+	for rows.Next() {
+		var balance models.AccountBalance
+		if err := rows.Scan(&balance.Account, &balance.Opening, &balance.Debit, &balance.Credit); err != nil {
+			utils.LogError(err)
+			continue
 		}
-		records = append(records, record)
+		balances = append(balances, balance)
+	}
+	return balances, nil
+}
+
+// GetDriverCashBalance sums a driver's cash postings recorded before the given time
+func (r *PostgresRepository) GetDriverCashBalance(driverID int64, before time.Time) (models.Money, error) {
+	query := `
+		SELECT COALESCE(SUM(p.amount), 0)
+		FROM ledger_postings p
+		JOIN ledger_entries e ON e.id = p.entry_id
+		WHERE p.account = $1 AND p.driver_id = $2 AND e.created_at < $3
+	`
+	var balance models.Money
+	if err := r.db.Conn().QueryRow(query, AccountDriverCash, driverID, before).Scan(&balance); err != nil {
+		utils.LogError(err)
+		return 0, fmt.Errorf("failed to get driver cash balance: %v", err)
 	}
-	return records, nil
+	return balance, nil
 }
 
-// GetRecordsByType retrieves accounting records by type
-func (r *PostgresRepository) GetRecordsByType(recordType string) ([]models.AccountingRecord, error) {
+// GetDriverCashLines retrieves a driver's cash movements since the given time in chronological order
+func (r *PostgresRepository) GetDriverCashLines(driverID int64, since time.Time) ([]models.DriverStatementLine, error) {
 	query := `
-		SELECT id, order_id, user_id, type, amount, description, created_at
-		FROM accounting_records
-		WHERE type = $1
+		SELECT e.id, e.order_id, e.kind, p.amount, e.description, e.created_at
+		FROM ledger_postings p
+		JOIN ledger_entries e ON e.id = p.entry_id
+		WHERE p.account = $1 AND p.driver_id = $2 AND e.created_at >= $3
+		ORDER BY e.created_at, e.id
 	`
-	rows, err := r.db.Conn().Query(query, recordType)
+	rows, err := r.db.Conn().Query(query, AccountDriverCash, driverID, since)
 	if err != nil {
 		utils.LogError(err)
-		return nil, fmt.Errorf("failed to get records by type: %v", err)
+		return nil, fmt.Errorf("failed to get driver cash movements: %v", err)
 	}
 	defer rows.Close()
 
-	var records []models.AccountingRecord
+	var lines []models.DriverStatementLine
 	for rows.Next() {
-		var record models.AccountingRecord
+		var line models.DriverStatementLine
 		var orderID sql.NullInt64
+		var description sql.NullString
 		if err := rows.Scan(
-			&record.ID, &orderID, &record.UserID, &record.Type,
-			&record.Amount, &record.Description, &record.CreatedAt,
+			&line.EntryID, &orderID, &line.Type, &line.Amount, &description, &line.CreatedAt,
 		); err != nil {
 			utils.LogError(err)
 			continue
 		}
 		if orderID.Valid {
-			record.OrderID = int(orderID.Int64)
+			line.OrderID = int(orderID.Int64)
 		}
-		records = append(records, record)
+		if description.Valid {
+			line.Description = description.String
+		}
+		lines = append(lines, line)
 	}
-	return records, nil
+	return lines, nil
 }
 
-// UpdateRecord updates an existing accounting record
-func (r *PostgresRepository) UpdateRecord(record *models.AccountingRecord) error {
+// GetDriverCashBalances retrieves the outstanding cash balance of every driver that holds money
+func (r *PostgresRepository) GetDriverCashBalances() ([]models.DriverBalance, error) {
 	query := `
-		UPDATE accounting_records
-		SET order_id = $1, user_id = $2, type = $3, amount = $4, description = $5, created_at = $6
-		WHERE id = $7
+		SELECT p.driver_id, COALESCE(u.first_name, ''), SUM(p.amount), MAX(e.created_at)
+		FROM ledger_postings p
+		JOIN ledger_entries e ON e.id = p.entry_id
+		JOIN users u ON u.chat_id = p.driver_id
+		WHERE p.account = $1
+		GROUP BY p.driver_id, u.first_name
+		HAVING SUM(p.amount) <> 0
+		ORDER BY 3 DESC
 	`
-	var orderID sql.NullInt64
-	if record.OrderID != 0 {
-		orderID.Valid = true
-		orderID.Int64 = int64(record.OrderID)
-	}
-	_, err := r.db.Conn().Exec(
-		query,
-		orderID, record.UserID, record.Type, record.Amount, record.Description,
-		record.CreatedAt, record.ID,
-	)
+	rows, err := r.db.Conn().Query(query, AccountDriverCash)
 	if err != nil {
 		utils.LogError(err)
-		return fmt.Errorf("failed to update accounting record: %v", err)
+		return nil, fmt.Errorf("failed to get driver cash balances: %v", err)
 	}
-	return nil
-}
\ No newline at end of file
+	defer rows.Close()
+
+	var balances []models.DriverBalance
+	for rows.Next() {
+		var balance models.DriverBalance
+		if err := rows.Scan(&balance.UserID, &balance.Name, &balance.Balance, &balance.LastMovement); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		balances = append(balances, balance)
+	}
+	return balances, nil
+}
@@ -3,6 +3,7 @@ package accounting
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 	"github.com/skyzeper/telegram-bot/internal/models"
 )
@@ -12,125 +13,151 @@ type Service struct {
 	repo Repository
 }
 
-// Repository defines the interface for accounting data access
+// Repository defines the interface for ledger data access
 type Repository interface {
-	CreateRecord(record *models.AccountingRecord) error
-	GetRecordsByOrder(orderID int) ([]models.AccountingRecord, error)
-	GetRecordsByUser(userID int64) ([]models.AccountingRecord, error)
-	GetRecordsByType(recordType string) ([]models.AccountingRecord, error)
-	UpdateRecord(record *models.AccountingRecord) error
+	CreateEntry(entry *models.LedgerEntry) error
+	GetEntriesByOrder(orderID int) ([]models.LedgerEntry, error)
+	GetEntries(from, to time.Time, kinds []string) ([]models.LedgerEntry, error)
+	GetAccountBalances(from, to time.Time) ([]models.AccountBalance, error)
+	GetDriverCashBalance(driverID int64, before time.Time) (models.Money, error)
+	GetDriverCashLines(driverID int64, since time.Time) ([]models.DriverStatementLine, error)
+	GetDriverCashBalances() ([]models.DriverBalance, error)
 }
 
+// StatementPeriod is the default period covered by a driver statement
+const StatementPeriod = 30 * 24 * time.Hour
+
 // NewService creates a new accounting service
 func NewService(repo Repository) *Service {
 	return &Service{repo: repo}
 }
 
-// CreateRecord creates a new accounting record
-func (s *Service) CreateRecord(record *models.AccountingRecord) error {
-	if record.UserID <= 0 || record.Type == "" || record.Amount == 0 {
-		return errors.New("missing required accounting fields")
-	}
-
-	if record.CreatedAt.IsZero() {
-		record.CreatedAt = time.Now()
+// RecordDriverCash records cash collected by a driver, increasing the driver's debt
+func (s *Service) RecordDriverCash(orderID int, driverID int64, amount models.Money) error {
+	entry, err := DriverCashEntry(orderID, driverID, amount)
+	if err != nil {
+		return err
 	}
-
-	return s.repo.CreateRecord(record)
+	return s.Post(entry)
 }
 
-// RecordIncome records an income entry
-func (s *Service) RecordIncome(orderID int, userID int64, amount float64, description string) error {
-	if orderID <= 0 || userID <= 0 || amount <= 0 {
-		return errors.New("invalid order ID, user ID, or amount")
+// DriverCashEntry builds the entry of cash collected by a driver for callers that store it in their own transaction
+func DriverCashEntry(orderID int, driverID int64, amount models.Money) (*models.LedgerEntry, error) {
+	if orderID <= 0 || driverID <= 0 {
+		return nil, errors.New("invalid order or driver ID")
 	}
+	return transferEntry(EntryDriverCash, orderID, driverID,
+		posting(AccountDriverCash, driverID), posting(AccountRevenue, 0),
+		amount, fmt.Sprintf("Наличные по заказу #%d", orderID))
+}
 
-	record := &models.AccountingRecord{
-		OrderID:     orderID,
-		UserID:      userID,
-		Type:        "income",
-		Amount:      amount,
-		Description: description,
-		CreatedAt:   time.Now(),
+// RecordCashHandOver records cash handed over to the cash desk, reducing the driver's debt
+func (s *Service) RecordCashHandOver(orderID int, driverID int64, amount models.Money, receiverID int64) error {
+	entry, err := CashHandOverEntry(orderID, driverID, amount, receiverID)
+	if err != nil {
+		return err
 	}
-
-	return s.repo.CreateRecord(record)
+	return s.Post(entry)
 }
 
-// RecordExpense records an expense entry
-func (s *Service) RecordExpense(userID int64, amount float64, description string) error {
-	if userID <= 0 || amount <= 0 {
-		return errors.New("invalid user ID or amount")
+// CashHandOverEntry builds the entry of cash handed over to the cash desk for callers that store it in their own transaction
+func CashHandOverEntry(orderID int, driverID int64, amount models.Money, receiverID int64) (*models.LedgerEntry, error) {
+	if driverID <= 0 || receiverID <= 0 {
+		return nil, errors.New("invalid driver or receiver ID")
 	}
-
-	record := &models.AccountingRecord{
-		UserID:      userID,
-		Type:        "expense",
-		Amount:      -amount, // Expenses are negative
-		Description: description,
-		CreatedAt:   time.Now(),
+	description := fmt.Sprintf("Сдано в кассу, принял %d", receiverID)
+	if orderID > 0 {
+		description = fmt.Sprintf("Сдано в кассу по заказу #%d, принял %d", orderID, receiverID)
 	}
-
-	return s.repo.CreateRecord(record)
+	return transferEntry(EntryCashHandOver, orderID, driverID,
+		posting(AccountCashDesk, 0), posting(AccountDriverCash, driverID),
+		amount, description)
 }
 
-// RecordSalary records a salary payment
-func (s *Service) RecordSalary(userID int64, amount float64, description string) error {
-	if userID <= 0 || amount <= 0 {
-		return errors.New("invalid user ID or amount")
+// GetDriverDebt returns the cash a driver has collected and not yet handed over
+func (s *Service) GetDriverDebt(userID int64) (models.Money, error) {
+	if userID <= 0 {
+		return 0, errors.New("invalid user ID")
 	}
 
-	record := &models.AccountingRecord{
-		UserID:      userID,
-		Type:        "salary",
-		Amount:      -amount, // Salaries are negative (payout)
-		Description: description,
-		CreatedAt:   time.Now(),
+	debt, err := s.repo.GetDriverCashBalance(userID, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get driver debt: %v", err)
 	}
-
-	return s.repo.CreateRecord(record)
+	return debt, nil
 }
 
-// GetDriverDebt calculates the total debt for a driver
-func (s *Service) GetDriverDebt(userID int64) (float64, error) {
+// GetDriverStatement lists a driver's cash movements since the given time with running balances
+func (s *Service) GetDriverStatement(userID int64, since time.Time) (*models.DriverStatement, error) {
 	if userID <= 0 {
-		return 0, errors.New("invalid user ID")
+		return nil, errors.New("invalid user ID")
 	}
 
-	records, err := s.repo.GetRecordsByUser(userID)
+	opening, err := s.repo.GetDriverCashBalance(userID, since)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get records: %v", err)
+		return nil, fmt.Errorf("failed to get opening balance: %v", err)
+	}
+	lines, err := s.repo.GetDriverCashLines(userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get driver cash movements: %v", err)
 	}
 
-	var debt float64
-	for _, record := range records {
-		if record.Type == "expense" || record.Type == "salary" {
-			debt += record.Amount // Negative amounts increase debt
-		}
+	statement := &models.DriverStatement{
+		DriverID: userID,
+		From:     since,
+		Opening:  opening,
+		Closing:  opening,
 	}
-	return debt, nil
+	for _, line := range lines {
+		statement.Closing += line.Amount
+		line.Balance = statement.Closing
+		statement.Lines = append(statement.Lines, line)
+	}
+	return statement, nil
 }
 
-// GetRecordsByOrder retrieves accounting records for an order
-func (s *Service) GetRecordsByOrder(orderID int) ([]models.AccountingRecord, error) {
-	if orderID <= 0 {
-		return nil, errors.New("invalid order ID")
-	}
-	return s.repo.GetRecordsByOrder(orderID)
+// GetDriverBalances retrieves all drivers currently holding cash, largest debt first
+func (s *Service) GetDriverBalances() ([]models.DriverBalance, error) {
+	return s.repo.GetDriverCashBalances()
 }
 
-// GetRecordsByUser retrieves accounting records for a user
-func (s *Service) GetRecordsByUser(userID int64) ([]models.AccountingRecord, error) {
-	if userID <= 0 {
-		return nil, errors.New("invalid user ID")
+// FormatDriverStatement renders a driver statement with one line per movement
+func FormatDriverStatement(statement *models.DriverStatement) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "💵 Наличные с %s\n", statement.From.Format("02.01.2006"))
+	fmt.Fprintf(&b, "Входящий остаток: %s руб.\n", statement.Opening)
+	if len(statement.Lines) == 0 {
+		b.WriteString("\nДвижений нет.\n")
+	} else {
+		b.WriteString("\n")
+	}
+	for _, line := range statement.Lines {
+		order := "—"
+		if line.OrderID > 0 {
+			order = fmt.Sprintf("#%d", line.OrderID)
+		}
+		label := "получено"
+		switch line.Type {
+		case EntryCashHandOver:
+			label = "сдано"
+		case EntryExpense, EntrySalary:
+			label = "расход"
+		}
+		sign := "+"
+		if line.Amount < 0 {
+			sign = ""
+		}
+		fmt.Fprintf(&b, "%s %s %s %s%s → %s\n",
+			line.CreatedAt.Format("02.01 15:04"), order, label, sign, line.Amount, line.Balance)
 	}
-	return s.repo.GetRecordsByUser(userID)
+	fmt.Fprintf(&b, "\nТекущий долг: %s руб.", statement.Closing)
+	return b.String()
 }
 
-// GetRecordsByType retrieves accounting records by type
-func (s *Service) GetRecordsByType(recordType string) ([]models.AccountingRecord, error) {
-	if recordType == "" {
-		return nil, errors.New("invalid record type")
+// GetEntriesByOrder retrieves the ledger entries of an order
+func (s *Service) GetEntriesByOrder(orderID int) ([]models.LedgerEntry, error) {
+	if orderID <= 0 {
+		return nil, errors.New("invalid order ID")
 	}
-	return s.repo.GetRecordsByType(recordType)
-}
\ No newline at end of file
+	return s.repo.GetEntriesByOrder(orderID)
+}
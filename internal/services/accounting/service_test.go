@@ -0,0 +1,212 @@
+package accounting_test
+
+import (
+	"testing"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/accounting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository is a mock implementation of accounting.Repository
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateEntry(entry *models.LedgerEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetEntriesByOrder(orderID int) ([]models.LedgerEntry, error) {
+	args := m.Called(orderID)
+	return args.Get(0).([]models.LedgerEntry), args.Error(1)
+}
+
+func (m *MockRepository) GetEntries(from, to time.Time, kinds []string) ([]models.LedgerEntry, error) {
+	args := m.Called(from, to, kinds)
+	return args.Get(0).([]models.LedgerEntry), args.Error(1)
+}
+
+func (m *MockRepository) GetAccountBalances(from, to time.Time) ([]models.AccountBalance, error) {
+	args := m.Called(from, to)
+	return args.Get(0).([]models.AccountBalance), args.Error(1)
+}
+
+func (m *MockRepository) GetDriverCashBalance(driverID int64, before time.Time) (models.Money, error) {
+	args := m.Called(driverID, before)
+	return args.Get(0).(models.Money), args.Error(1)
+}
+
+func (m *MockRepository) GetDriverCashLines(driverID int64, since time.Time) ([]models.DriverStatementLine, error) {
+	args := m.Called(driverID, since)
+	return args.Get(0).([]models.DriverStatementLine), args.Error(1)
+}
+
+func (m *MockRepository) GetDriverCashBalances() ([]models.DriverBalance, error) {
+	args := m.Called()
+	return args.Get(0).([]models.DriverBalance), args.Error(1)
+}
+
+// balanced reports whether an entry's postings sum to zero
+func balanced(entry *models.LedgerEntry) bool {
+	var total models.Money
+	for _, p := range entry.Postings {
+		total += p.Amount
+	}
+	return total == 0
+}
+
+// amountOn returns the amount posted to an account in an entry
+func amountOn(entry *models.LedgerEntry, account string) models.Money {
+	var amount models.Money
+	for _, p := range entry.Postings {
+		if p.Account == account {
+			amount += p.Amount
+		}
+	}
+	return amount
+}
+
+func TestService_DriverCashMovements(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := accounting.NewService(mockRepo)
+
+	mockRepo.On("CreateEntry", mock.MatchedBy(func(e *models.LedgerEntry) bool {
+		return e.Kind == accounting.EntryDriverCash && e.OrderID == 10 && balanced(e) &&
+			amountOn(e, accounting.AccountDriverCash) == 350050 && amountOn(e, accounting.AccountRevenue) == -350050 &&
+			e.Postings[0].DriverID == 200
+	})).Return(nil).Once()
+	mockRepo.On("CreateEntry", mock.MatchedBy(func(e *models.LedgerEntry) bool {
+		return e.Kind == accounting.EntryCashHandOver && balanced(e) &&
+			amountOn(e, accounting.AccountCashDesk) == 350050 && amountOn(e, accounting.AccountDriverCash) == -350050
+	})).Return(nil).Once()
+
+	assert.NoError(t, service.RecordDriverCash(10, 200, models.NewMoney(3500.50)))
+	assert.NoError(t, service.RecordCashHandOver(10, 200, models.NewMoney(3500.50), 300))
+	assert.Error(t, service.RecordDriverCash(10, 200, 0))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_Post(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := accounting.NewService(mockRepo)
+
+	unbalanced := &models.LedgerEntry{Kind: accounting.EntryExpense, UserID: 1, Postings: []models.LedgerPosting{
+		{Account: accounting.AccountFuel, Amount: 1000},
+		{Account: accounting.AccountCashDesk, Amount: -999},
+	}}
+	assert.Error(t, service.Post(unbalanced))
+
+	unknown := &models.LedgerEntry{Kind: accounting.EntryExpense, UserID: 1, Postings: []models.LedgerPosting{
+		{Account: "travel", Amount: 1000},
+		{Account: accounting.AccountCashDesk, Amount: -1000},
+	}}
+	assert.Error(t, service.Post(unknown))
+
+	noDriver := &models.LedgerEntry{Kind: accounting.EntryDriverCash, UserID: 1, Postings: []models.LedgerPosting{
+		{Account: accounting.AccountDriverCash, Amount: 1000},
+		{Account: accounting.AccountRevenue, Amount: -1000},
+	}}
+	assert.Error(t, service.Post(noDriver))
+
+	// Expenses must hit an expense account and be paid from an asset
+	assert.Error(t, service.RecordExpense(1, accounting.AccountRevenue, 1000, accounting.AccountCashDesk, "x"))
+	assert.Error(t, service.RecordExpense(1, accounting.AccountFuel, 1000, accounting.AccountSalaries, "x"))
+
+	mockRepo.On("CreateEntry", mock.MatchedBy(func(e *models.LedgerEntry) bool {
+		return e.Kind == accounting.EntryExpense && balanced(e) && amountOn(e, accounting.AccountFuel) == 250000 &&
+			e.Postings[1].Account == accounting.AccountDriverCash && e.Postings[1].DriverID == 200
+	})).Return(nil).Once()
+	assert.NoError(t, service.RecordExpense(200, accounting.AccountFuel, 250000, accounting.AccountDriverCash, "Заправка"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_GetBalanceReport(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := accounting.NewService(mockRepo)
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	mockRepo.On("GetAccountBalances", from, to).Return([]models.AccountBalance{
+		{Account: accounting.AccountCashDesk, Opening: 100000, Debit: 500000, Credit: 200000},
+		{Account: accounting.AccountRevenue, Opening: -100000, Credit: 500000},
+		{Account: accounting.AccountFuel, Debit: 200000},
+	}, nil).Once()
+
+	report, err := service.GetBalanceReport(from, to)
+	assert.NoError(t, err)
+	assert.Len(t, report.Accounts, len(accounting.Accounts()))
+	assert.Equal(t, models.Money(400000), report.Accounts[0].Closing)
+	assert.Equal(t, models.Money(0), accounting.Imbalance(report))
+
+	text := accounting.FormatBalanceReport(report)
+	assert.Contains(t, text, "Выручка\nНачало: 1000.00 · Дт 0.00 · Кт 5000.00 · Конец: 6000.00")
+	assert.NotContains(t, text, "Расхождение")
+
+	_, err = service.GetBalanceReport(to, from)
+	assert.Error(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_GetDriverStatement(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := accounting.NewService(mockRepo)
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	mockRepo.On("GetDriverCashBalance", int64(200), since).Return(models.Money(100000), nil).Once()
+	mockRepo.On("GetDriverCashLines", int64(200), since).Return([]models.DriverStatementLine{
+		{EntryID: 1, OrderID: 10, Type: accounting.EntryDriverCash, Amount: 350000, CreatedAt: since.Add(time.Hour)},
+		{EntryID: 2, OrderID: 10, Type: accounting.EntryCashHandOver, Amount: -400000, CreatedAt: since.Add(2 * time.Hour)},
+	}, nil).Once()
+
+	statement, err := service.GetDriverStatement(200, since)
+	assert.NoError(t, err)
+	assert.Equal(t, models.Money(100000), statement.Opening)
+	assert.Equal(t, models.Money(50000), statement.Closing)
+	assert.Len(t, statement.Lines, 2)
+	assert.Equal(t, models.Money(450000), statement.Lines[0].Balance)
+	text := accounting.FormatDriverStatement(statement)
+	assert.Contains(t, text, "#10 получено +3500.00 → 4500.00")
+	assert.Contains(t, text, "#10 сдано -4000.00 → 500.00")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestParseMoney(t *testing.T) {
+	cases := map[string]models.Money{
+		"1500":      150000,
+		"1 500,5":   150050,
+		"-12.30":    -1230,
+		"0.1":       10,
+		"99.990":    9999,
+	}
+	for input, want := range cases {
+		got, err := models.ParseMoney(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+	for _, input := range []string{
+		"", "abc", "1.234", "1.2.3",
+		"1.-5", "1.+5", // signs after the separator
+		"--5", "+-5", "-+5", // more than one sign
+		"1-5", "1e3", "NaN", "Inf",
+		"99999999999999999", // overflows int64 kopecks
+		"-92233720368547758.08",
+	} {
+		_, err := models.ParseMoney(input)
+		assert.Error(t, err, input)
+	}
+	got, err := models.ParseMoney("+5")
+	assert.NoError(t, err)
+	assert.Equal(t, models.Money(500), got)
+	got, err = models.ParseMoney("92233720368547757.00")
+	assert.NoError(t, err)
+	assert.Equal(t, models.Money(9223372036854775700), got)
+
+	var m models.Money
+	assert.NoError(t, m.Scan([]byte("-1234.56")))
+	assert.Equal(t, models.Money(-123456), m)
+	assert.Equal(t, "-1234.56", m.String())
+	assert.Equal(t, models.Money(30), models.NewMoney(0.1+0.2))
+}
@@ -3,6 +3,7 @@ package chat
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/skyzeper/telegram-bot/internal/models"
@@ -106,4 +107,22 @@ func (s *Service) IsInChat(userID int64) bool {
 		}
 	}
 	return false
+}
+
+// GetHistory retrieves the latest chat messages of a user in chronological order
+func (s *Service) GetHistory(userID int64, limit int) ([]models.Message, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+	messages, err := s.repo.GetMessagesByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+	})
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return messages, nil
 }
\ No newline at end of file
@@ -0,0 +1,112 @@
+package digest
+
+import (
+	"fmt"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/db"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// PostgresRepository implements the Repository interface for PostgreSQL
+type PostgresRepository struct {
+	db *db.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL repository
+func NewPostgresRepository(db *db.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// IsDigestSent reports whether a digest for a period has been recorded
+func (r *PostgresRepository) IsDigestSent(kind string, periodStart time.Time) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM digest_runs WHERE kind = $1 AND period_start = $2)
+	`
+	var sent bool
+	if err := r.db.Conn().QueryRow(query, kind, periodStart).Scan(&sent); err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to check digest: %v", err)
+	}
+	return sent, nil
+}
+
+// MarkDigestSent records a digest for a period, reporting false when it was already recorded
+func (r *PostgresRepository) MarkDigestSent(kind string, periodStart, sentAt time.Time) (bool, error) {
+	query := `
+		INSERT INTO digest_runs (kind, period_start, sent_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (kind, period_start) DO NOTHING
+	`
+	result, err := r.db.Conn().Exec(query, kind, periodStart, sentAt)
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to mark digest sent: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to mark digest sent: %v", err)
+	}
+	return affected == 1, nil
+}
+
+// GetOwnerChatIDs retrieves the chat IDs of active owners
+func (r *PostgresRepository) GetOwnerChatIDs() ([]int64, error) {
+	query := `
+		SELECT chat_id
+		FROM users
+		WHERE role = 'owner' AND is_blocked = FALSE
+	`
+	rows, err := r.db.Conn().Query(query)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get owners: %v", err)
+	}
+	defer rows.Close()
+
+	var chatIDs []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+	return chatIDs, nil
+}
+
+// GetOpenProblems counts open escalations, cash not handed over, expenses and payrolls awaiting approval
+func (r *PostgresRepository) GetOpenProblems() (models.OpenProblems, error) {
+	query := `
+		SELECT (SELECT COUNT(*) FROM escalations WHERE status <> 'resolved'),
+		       (SELECT COUNT(*) FROM payments WHERE method = 'cash' AND confirmed = FALSE),
+		       (SELECT COUNT(*) FROM expenses WHERE status = 'pending'),
+		       (SELECT COUNT(*) FROM payroll_runs WHERE status = 'draft')
+	`
+	var problems models.OpenProblems
+	err := r.db.Conn().QueryRow(query).Scan(
+		&problems.OpenEscalations, &problems.UnconfirmedCash, &problems.PendingExpenses, &problems.DraftPayrolls,
+	)
+	if err != nil {
+		utils.LogError(err)
+		return problems, fmt.Errorf("failed to get open problems: %v", err)
+	}
+	return problems, nil
+}
+
+// GetDriverDebtTotal sums the cash drivers hold and have not handed over yet
+func (r *PostgresRepository) GetDriverDebtTotal() (models.Money, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM ledger_postings
+		WHERE account = 'driver_cash'
+	`
+	var total models.Money
+	if err := r.db.Conn().QueryRow(query).Scan(&total); err != nil {
+		utils.LogError(err)
+		return 0, fmt.Errorf("failed to get driver debt total: %v", err)
+	}
+	return total, nil
+}
@@ -0,0 +1,270 @@
+package digest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/stats"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// Digest kinds
+const (
+	KindDaily   = "daily"   // the current day, sent in the evening
+	KindWeekly  = "weekly"  // the previous ISO week, sent on Monday
+	KindMonthly = "monthly" // the previous month, sent on the 1st
+)
+
+// catchUpWindow is how late a digest may still be sent, e.g. after a restart
+const catchUpWindow = 12 * time.Hour
+
+// topExecutors is the number of executors listed in a digest
+const topExecutors = 3
+
+// Config holds digest schedules and recipients
+type Config struct {
+	// Kinds lists the enabled digests (empty enables all)
+	Kinds []string
+	// DailyAt, WeeklyAt and MonthlyAt are the times of day digests are sent in the business time zone
+	DailyAt   time.Duration
+	WeeklyAt  time.Duration
+	MonthlyAt time.Duration
+	// ChatIDs are additional recipients besides the owners, e.g. the accounting or group chat
+	ChatIDs []int64
+}
+
+// Service sends scheduled owner digests
+type Service struct {
+	bot   *tgbotapi.BotAPI
+	repo  Repository
+	stats *stats.Service
+	cfg   Config
+}
+
+// Repository defines the interface for digest data access
+type Repository interface {
+	IsDigestSent(kind string, periodStart time.Time) (bool, error)
+	MarkDigestSent(kind string, periodStart, sentAt time.Time) (bool, error)
+	GetOwnerChatIDs() ([]int64, error)
+	GetOpenProblems() (models.OpenProblems, error)
+	GetDriverDebtTotal() (models.Money, error)
+}
+
+// NewService creates a new digest service
+func NewService(bot *tgbotapi.BotAPI, repo Repository, statsService *stats.Service, cfg Config) *Service {
+	if len(cfg.Kinds) == 0 {
+		cfg.Kinds = []string{KindDaily, KindWeekly, KindMonthly}
+	}
+	if cfg.DailyAt <= 0 {
+		cfg.DailyAt = 21 * time.Hour
+	}
+	if cfg.WeeklyAt <= 0 {
+		cfg.WeeklyAt = 9 * time.Hour
+	}
+	if cfg.MonthlyAt <= 0 {
+		cfg.MonthlyAt = 9 * time.Hour
+	}
+	return &Service{
+		bot:   bot,
+		repo:  repo,
+		stats: statsService,
+		cfg:   cfg,
+	}
+}
+
+// ParseKinds reads a comma-separated list of digest kinds such as "daily,weekly"
+func ParseKinds(value string) ([]string, error) {
+	var kinds []string
+	for _, kind := range strings.Split(value, ",") {
+		kind = strings.TrimSpace(strings.ToLower(kind))
+		switch kind {
+		case "":
+			continue
+		case KindDaily, KindWeekly, KindMonthly:
+			kinds = append(kinds, kind)
+		default:
+			return nil, fmt.Errorf("unknown digest kind: %s", kind)
+		}
+	}
+	return kinds, nil
+}
+
+// ParseClock reads a time of day such as "21:00" as the offset from midnight
+func ParseClock(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q: %v", value, err)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Due returns the period a digest covers and whether it is time to send it at now
+func (s *Service) Due(kind string, now time.Time) (stats.Period, bool, error) {
+	loc := s.stats.Location()
+	now = now.In(loc)
+
+	var period stats.Period
+	var fire time.Time
+	switch kind {
+	case KindDaily:
+		period = stats.DayPeriod(now, loc)
+		fire = period.Start.Add(s.cfg.DailyAt)
+	case KindWeekly:
+		week := stats.WeekPeriodOf(now, loc)
+		period, _ = week.Previous()
+		fire = week.Start.Add(s.cfg.WeeklyAt)
+	case KindMonthly:
+		month := stats.MonthPeriod(now.Year(), now.Month(), loc)
+		period, _ = month.Previous()
+		fire = month.Start.Add(s.cfg.MonthlyAt)
+	default:
+		return stats.Period{}, false, fmt.Errorf("unknown digest kind: %s", kind)
+	}
+	return period, !now.Before(fire) && now.Sub(fire) < catchUpWindow, nil
+}
+
+// ProcessDigests sends every digest that is due and has not been sent for its period yet
+func (s *Service) ProcessDigests() error {
+	now := time.Now()
+	for _, kind := range s.cfg.Kinds {
+		period, due, err := s.Due(kind, now)
+		if err != nil {
+			return err
+		}
+		if !due {
+			continue
+		}
+		sent, err := s.repo.IsDigestSent(kind, period.Start)
+		if err != nil {
+			return err
+		}
+		if sent {
+			continue
+		}
+		text, err := s.BuildDigest(kind, period)
+		if err != nil {
+			return fmt.Errorf("failed to build %s digest: %v", kind, err)
+		}
+		marked, err := s.repo.MarkDigestSent(kind, period.Start, now)
+		if err != nil {
+			utils.LogError(err)
+			continue // Never send without a record
+		}
+		if !marked {
+			continue
+		}
+		if err := s.send(text); err != nil {
+			utils.LogError(err)
+		}
+	}
+	return nil
+}
+
+// BuildDigest renders the digest of a period
+func (s *Service) BuildDigest(kind string, period stats.Period) (string, error) {
+	report, err := s.stats.GetStatsReport(period)
+	if err != nil {
+		return "", err
+	}
+	problems, err := s.repo.GetOpenProblems()
+	if err != nil {
+		return "", err
+	}
+	debt, err := s.repo.GetDriverDebtTotal()
+	if err != nil {
+		return "", err
+	}
+	return FormatDigest(kind, report, problems, debt), nil
+}
+
+// send delivers a digest to the owners and the configured chats
+func (s *Service) send(text string) error {
+	owners, err := s.repo.GetOwnerChatIDs()
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[int64]bool)
+	var failed []string
+	for _, chatID := range append(owners, s.cfg.ChatIDs...) {
+		if chatID == 0 || seen[chatID] {
+			continue
+		}
+		seen[chatID] = true
+		if _, err := s.bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+			failed = append(failed, fmt.Sprintf("%d: %v", chatID, err))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New("failed to send digest to " + strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// FormatDigest renders a digest: orders, revenue, debts, open problems and top executors
+func FormatDigest(kind string, report models.StatsReport, problems models.OpenProblems, debt models.Money) string {
+	cur, prev := report.Current, report.Previous
+	var p models.Stats
+	if prev != nil {
+		p = *prev
+	}
+	has := prev != nil
+
+	titles := map[string]string{
+		KindDaily:   "🗞 Сводка за день",
+		KindWeekly:  "🗞 Сводка за неделю",
+		KindMonthly: "🗞 Сводка за месяц",
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s: %s\n", titles[kind], report.Title))
+	if has {
+		sb.WriteString(fmt.Sprintf("↔️ Сравнение с: %s\n", report.PreviousTitle))
+	}
+
+	sb.WriteString("\n📦 Выполнено заказов: " + stats.WithChange(float64(cur.TotalOrders), float64(p.TotalOrders), has, "%.0f"))
+	sb.WriteString(fmt.Sprintf(" из %d, отменено %d\n", cur.CreatedOrders, cur.CanceledOrders))
+	sb.WriteString("💰 Выручка: " + stats.WithChange(cur.TotalAmount, p.TotalAmount, has, "%.2f руб.") + "\n")
+	sb.WriteString("🧾 Средний чек: " + stats.WithChange(cur.AverageCheck, p.AverageCheck, has, "%.2f руб.") + "\n")
+	sb.WriteString(fmt.Sprintf("💵 Наличные у водителей: %s руб.\n", debt))
+
+	sb.WriteString("\n⚠️ Открытые проблемы: ")
+	if problems.Total() == 0 {
+		sb.WriteString("нет")
+	} else {
+		sb.WriteString(fmt.Sprintf("%d", problems.Total()))
+		for _, item := range []struct {
+			label string
+			count int
+		}{
+			{"жалобы клиентов", problems.OpenEscalations},
+			{"наличные не сданы", problems.UnconfirmedCash},
+			{"расходы на проверке", problems.PendingExpenses},
+			{"зарплата ждёт утверждения", problems.DraftPayrolls},
+		} {
+			if item.count > 0 {
+				sb.WriteString(fmt.Sprintf("\n- %s: %d", item.label, item.count))
+			}
+		}
+	}
+
+	if len(cur.Executors) > 0 {
+		sb.WriteString("\n\n🏆 Лучшие исполнители")
+		for i, executor := range cur.Executors {
+			if i == topExecutors {
+				break
+			}
+			name := executor.Name
+			if name == "" {
+				name = fmt.Sprintf("ID %d", executor.UserID)
+			}
+			sb.WriteString(fmt.Sprintf("\n%d. %s — %d зак., %.2f руб.", i+1, name, executor.Orders, executor.Revenue))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,141 @@
+package digest_test
+
+import (
+	"testing"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/digest"
+	"github.com/skyzeper/telegram-bot/internal/services/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository is a mock implementation of digest.Repository
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) IsDigestSent(kind string, periodStart time.Time) (bool, error) {
+	args := m.Called(kind, periodStart)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) MarkDigestSent(kind string, periodStart, sentAt time.Time) (bool, error) {
+	args := m.Called(kind, periodStart, sentAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetOwnerChatIDs() ([]int64, error) {
+	args := m.Called()
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRepository) GetOpenProblems() (models.OpenProblems, error) {
+	args := m.Called()
+	return args.Get(0).(models.OpenProblems), args.Error(1)
+}
+
+func (m *MockRepository) GetDriverDebtTotal() (models.Money, error) {
+	args := m.Called()
+	return args.Get(0).(models.Money), args.Error(1)
+}
+
+func newService(repo *MockRepository, cfg digest.Config) *digest.Service {
+	return digest.NewService(nil, repo, stats.NewService(nil, time.UTC), cfg)
+}
+
+func TestDue(t *testing.T) {
+	service := newService(new(MockRepository), digest.Config{})
+
+	// Daily digests cover the current day from 21:00
+	period, due, err := service.Due(digest.KindDaily, time.Date(2024, 3, 13, 20, 59, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.False(t, due)
+	period, due, err = service.Due(digest.KindDaily, time.Date(2024, 3, 13, 21, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.True(t, due)
+	assert.Equal(t, time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC), period.Start)
+
+	// Weekly digests cover the previous ISO week from Monday 09:00
+	period, due, _ = service.Due(digest.KindWeekly, time.Date(2024, 3, 18, 9, 30, 0, 0, time.UTC))
+	assert.True(t, due)
+	assert.Equal(t, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), period.Start)
+	assert.Equal(t, time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC), period.End)
+	_, due, _ = service.Due(digest.KindWeekly, time.Date(2024, 3, 19, 9, 30, 0, 0, time.UTC))
+	assert.False(t, due)
+
+	// Monthly digests cover the previous month from the 1st at 09:00
+	period, due, _ = service.Due(digest.KindMonthly, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	assert.True(t, due)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), period.Start)
+
+	_, _, err = service.Due("hourly", time.Now())
+	assert.Error(t, err)
+}
+
+func TestParseConfig(t *testing.T) {
+	kinds, err := digest.ParseKinds(" Daily, monthly ")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{digest.KindDaily, digest.KindMonthly}, kinds)
+	_, err = digest.ParseKinds("daily,hourly")
+	assert.Error(t, err)
+
+	at, err := digest.ParseClock("20:30")
+	assert.NoError(t, err)
+	assert.Equal(t, 20*time.Hour+30*time.Minute, at)
+	at, err = digest.ParseClock("")
+	assert.NoError(t, err)
+	assert.Zero(t, at)
+	_, err = digest.ParseClock("25:00")
+	assert.Error(t, err)
+}
+
+func TestProcessDigestsSkipsSentPeriods(t *testing.T) {
+	repo := new(MockRepository)
+	// Schedule the daily digest for the current time of day so it is due
+	now := time.Now().UTC()
+	dailyAt := now.Sub(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+	if dailyAt <= 0 {
+		dailyAt = time.Nanosecond
+	}
+	service := newService(repo, digest.Config{Kinds: []string{digest.KindDaily}, DailyAt: dailyAt})
+
+	repo.On("IsDigestSent", digest.KindDaily, mock.Anything).Return(true, nil)
+
+	assert.NoError(t, service.ProcessDigests())
+	repo.AssertCalled(t, "IsDigestSent", digest.KindDaily, mock.Anything)
+	repo.AssertNotCalled(t, "MarkDigestSent", mock.Anything, mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "GetOpenProblems")
+}
+
+func TestFormatDigest(t *testing.T) {
+	report := models.StatsReport{
+		Title:         "13.03.2024",
+		PreviousTitle: "12.03.2024",
+		Current: models.Stats{
+			TotalOrders: 4, CreatedOrders: 5, CanceledOrders: 1, TotalAmount: 20000, AverageCheck: 5000,
+			Executors: []models.ExecutorRevenue{
+				{UserID: 1, Name: "Олег", Orders: 3, Revenue: 15000},
+				{UserID: 2, Orders: 2, Revenue: 9000},
+				{UserID: 3, Name: "Иван", Orders: 1, Revenue: 5000},
+				{UserID: 4, Name: "Пётр", Orders: 1, Revenue: 1000},
+			},
+		},
+		Previous: &models.Stats{TotalOrders: 2, TotalAmount: 10000, AverageCheck: 5000},
+	}
+	text := digest.FormatDigest(digest.KindDaily, report, models.OpenProblems{OpenEscalations: 1, PendingExpenses: 2}, models.NewMoney(3500))
+
+	assert.Contains(t, text, "🗞 Сводка за день: 13.03.2024")
+	assert.Contains(t, text, "Выполнено заказов: 4 (было 2, ▲ +100%) из 5, отменено 1")
+	assert.Contains(t, text, "Выручка: 20000.00 руб. (было 10000.00 руб., ▲ +100%)")
+	assert.Contains(t, text, "Наличные у водителей: 3500.00 руб.")
+	assert.Contains(t, text, "Открытые проблемы: 3")
+	assert.Contains(t, text, "- расходы на проверке: 2")
+	assert.NotContains(t, text, "наличные не сданы")
+	assert.Contains(t, text, "2. ID 2 — 2 зак., 9000.00 руб.")
+	assert.NotContains(t, text, "Пётр")
+
+	text = digest.FormatDigest(digest.KindMonthly, models.StatsReport{Title: "март 2024"}, models.OpenProblems{}, 0)
+	assert.Contains(t, text, "Открытые проблемы: нет")
+	assert.NotContains(t, text, "Сравнение")
+}
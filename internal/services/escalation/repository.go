@@ -0,0 +1,169 @@
+package escalation
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/skyzeper/telegram-bot/internal/db"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// PostgresRepository implements the Repository interface for PostgreSQL
+type PostgresRepository struct {
+	db *db.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL repository
+func NewPostgresRepository(db *db.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// escalationColumns lists the columns scanned by scanEscalation
+const escalationColumns = `id, review_id, order_id, user_id, rating, status, operator_id, resolution, resolution_note, amount, created_at, resolved_at`
+
+// CreateEscalation creates a new escalation
+func (r *PostgresRepository) CreateEscalation(escalation *models.Escalation) error {
+	query := `
+		INSERT INTO escalations (review_id, order_id, user_id, rating, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+	err := r.db.Conn().QueryRow(
+		query,
+		escalation.ReviewID, escalation.OrderID, escalation.UserID, escalation.Rating,
+		escalation.Status, escalation.CreatedAt,
+	).Scan(&escalation.ID)
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to create escalation: %v", err)
+	}
+	return nil
+}
+
+// GetEscalation retrieves an escalation by ID
+func (r *PostgresRepository) GetEscalation(id int) (*models.Escalation, error) {
+	query := `SELECT ` + escalationColumns + ` FROM escalations WHERE id = $1`
+	escalation, err := scanEscalation(r.db.Conn().QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("escalation not found")
+	}
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get escalation: %v", err)
+	}
+	return escalation, nil
+}
+
+// GetEscalationsByStatus retrieves escalations with any of the given statuses
+func (r *PostgresRepository) GetEscalationsByStatus(statuses ...string) ([]models.Escalation, error) {
+	query := `SELECT ` + escalationColumns + ` FROM escalations WHERE status = ANY($1) ORDER BY created_at`
+	rows, err := r.db.Conn().Query(query, pq.Array(statuses))
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get escalations: %v", err)
+	}
+	defer rows.Close()
+
+	var escalations []models.Escalation
+	for rows.Next() {
+		escalation, err := scanEscalation(rows)
+		if err != nil {
+			utils.LogError(err)
+			continue
+		}
+		escalations = append(escalations, *escalation)
+	}
+	return escalations, nil
+}
+
+// ResolveEscalation records the resolution of an escalation still in progress with its operator,
+// reporting false if it was resolved meanwhile
+func (r *PostgresRepository) ResolveEscalation(escalation *models.Escalation) (bool, error) {
+	query := `
+		UPDATE escalations
+		SET status = $1, resolution = $2, resolution_note = $3, amount = $4, resolved_at = $5
+		WHERE id = $6 AND status = 'in_progress' AND operator_id = $7
+	`
+	var resolution, note sql.NullString
+	var resolvedAt sql.NullTime
+	if escalation.Resolution != "" {
+		resolution.Valid = true
+		resolution.String = escalation.Resolution
+	}
+	if escalation.ResolutionNote != "" {
+		note.Valid = true
+		note.String = escalation.ResolutionNote
+	}
+	if !escalation.ResolvedAt.IsZero() {
+		resolvedAt.Valid = true
+		resolvedAt.Time = escalation.ResolvedAt
+	}
+	result, err := r.db.Conn().Exec(
+		query,
+		escalation.Status, resolution, note, escalation.Amount, resolvedAt, escalation.ID, escalation.OperatorID,
+	)
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to resolve escalation: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to resolve escalation: %v", err)
+	}
+	return affected == 1, nil
+}
+
+// TakeEscalation assigns an unassigned escalation to an operator, reporting false if someone already took it
+func (r *PostgresRepository) TakeEscalation(id int, operatorID int64) (bool, error) {
+	query := `
+		UPDATE escalations
+		SET status = 'in_progress', operator_id = $1
+		WHERE id = $2 AND operator_id IS NULL AND status <> 'resolved'
+	`
+	result, err := r.db.Conn().Exec(query, operatorID, id)
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to take escalation: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to take escalation: %v", err)
+	}
+	return affected == 1, nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanEscalation scans an escalation selected with escalationColumns
+func scanEscalation(row rowScanner) (*models.Escalation, error) {
+	escalation := &models.Escalation{}
+	var operatorID sql.NullInt64
+	var resolution, note sql.NullString
+	var resolvedAt sql.NullTime
+	err := row.Scan(
+		&escalation.ID, &escalation.ReviewID, &escalation.OrderID, &escalation.UserID, &escalation.Rating,
+		&escalation.Status, &operatorID, &resolution, &note, &escalation.Amount, &escalation.CreatedAt, &resolvedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if operatorID.Valid {
+		escalation.OperatorID = operatorID.Int64
+	}
+	if resolution.Valid {
+		escalation.Resolution = resolution.String
+	}
+	if note.Valid {
+		escalation.ResolutionNote = note.String
+	}
+	if resolvedAt.Valid {
+		escalation.ResolvedAt = resolvedAt.Time
+	}
+	return escalation, nil
+}
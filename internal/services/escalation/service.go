@@ -0,0 +1,196 @@
+package escalation
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+)
+
+// Escalation statuses
+const (
+	StatusOpen       = "open"
+	StatusInProgress = "in_progress"
+	StatusResolved   = "resolved"
+)
+
+// Resolution types recorded by the operator
+const (
+	ResolutionRefund   = "refund"
+	ResolutionDiscount = "discount"
+	ResolutionRedo     = "redo"
+	ResolutionNone     = "none"
+)
+
+// MaxRating is the highest review rating that opens an escalation
+const MaxRating = 2
+
+// Service handles escalation-related business logic
+type Service struct {
+	repo Repository
+}
+
+// Repository defines the interface for escalation data access
+type Repository interface {
+	CreateEscalation(escalation *models.Escalation) error
+	GetEscalation(id int) (*models.Escalation, error)
+	GetEscalationsByStatus(statuses ...string) ([]models.Escalation, error)
+	ResolveEscalation(escalation *models.Escalation) (bool, error)
+	TakeEscalation(id int, operatorID int64) (bool, error)
+}
+
+var (
+	// ErrAlreadyTaken is returned when another operator has already taken an escalation
+	ErrAlreadyTaken = errors.New("escalation already taken by another operator")
+	// ErrNotTaken is returned when an operator resolves an escalation they have not taken
+	ErrNotTaken = errors.New("escalation is not taken by this operator")
+)
+
+// NewService creates a new escalation service
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// OpenForReview opens an escalation for a negative review, returning nil for higher ratings
+func (s *Service) OpenForReview(review *models.Review) (*models.Escalation, error) {
+	if review == nil || review.ID <= 0 {
+		return nil, errors.New("invalid review")
+	}
+	if review.Rating > MaxRating {
+		return nil, nil
+	}
+
+	escalation := &models.Escalation{
+		ReviewID:  review.ID,
+		OrderID:   review.OrderID,
+		UserID:    review.UserID,
+		Rating:    review.Rating,
+		Status:    StatusOpen,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.CreateEscalation(escalation); err != nil {
+		return nil, err
+	}
+	return escalation, nil
+}
+
+// Take assigns an unassigned escalation to the operator who contacts the client
+func (s *Service) Take(id int, operatorID int64) (*models.Escalation, error) {
+	if id <= 0 || operatorID <= 0 {
+		return nil, errors.New("invalid escalation or operator ID")
+	}
+
+	escalation, err := s.repo.GetEscalation(id)
+	if err != nil {
+		return nil, err
+	}
+	if escalation.Status == StatusResolved {
+		return nil, errors.New("escalation already resolved")
+	}
+	if escalation.OperatorID == operatorID {
+		return escalation, nil
+	}
+
+	taken, err := s.repo.TakeEscalation(id, operatorID)
+	if err != nil {
+		return nil, err
+	}
+	if !taken {
+		return nil, ErrAlreadyTaken
+	}
+	escalation.Status = StatusInProgress
+	escalation.OperatorID = operatorID
+	return escalation, nil
+}
+
+// Resolve records how the complaint was settled and closes the escalation taken by the operator
+func (s *Service) Resolve(id int, operatorID int64, resolution, note string, amount float64) (*models.Escalation, error) {
+	if id <= 0 || operatorID <= 0 {
+		return nil, errors.New("invalid escalation or operator ID")
+	}
+	if ResolutionLabel(resolution) == "" {
+		return nil, fmt.Errorf("unknown resolution: %s", resolution)
+	}
+	if amount < 0 {
+		return nil, errors.New("amount cannot be negative")
+	}
+	if amount > 0 && !HasAmount(resolution) {
+		return nil, fmt.Errorf("%s has no amount", resolution)
+	}
+
+	escalation, err := s.repo.GetEscalation(id)
+	if err != nil {
+		return nil, err
+	}
+	if escalation.Status == StatusResolved {
+		return nil, errors.New("escalation already resolved")
+	}
+	if escalation.Status != StatusInProgress || escalation.OperatorID != operatorID {
+		return nil, ErrNotTaken
+	}
+
+	escalation.Status = StatusResolved
+	escalation.Resolution = resolution
+	escalation.ResolutionNote = strings.TrimSpace(note)
+	escalation.Amount = amount
+	escalation.ResolvedAt = time.Now()
+	resolved, err := s.repo.ResolveEscalation(escalation)
+	if err != nil {
+		return nil, err
+	}
+	if !resolved {
+		return nil, errors.New("escalation already resolved")
+	}
+	return escalation, nil
+}
+
+// GetEscalation retrieves an escalation by ID
+func (s *Service) GetEscalation(id int) (*models.Escalation, error) {
+	if id <= 0 {
+		return nil, errors.New("invalid escalation ID")
+	}
+	return s.repo.GetEscalation(id)
+}
+
+// GetUnresolved retrieves open and in-progress escalations
+func (s *Service) GetUnresolved() ([]models.Escalation, error) {
+	return s.repo.GetEscalationsByStatus(StatusOpen, StatusInProgress)
+}
+
+// ResolutionLabel returns the Russian name of a resolution type, or "" if unknown
+func ResolutionLabel(resolution string) string {
+	switch resolution {
+	case ResolutionRefund:
+		return "Возврат средств"
+	case ResolutionDiscount:
+		return "Скидка"
+	case ResolutionRedo:
+		return "Повторное выполнение"
+	case ResolutionNone:
+		return "Без компенсации"
+	default:
+		return ""
+	}
+}
+
+// HasAmount reports whether a resolution type carries a compensation amount
+func HasAmount(resolution string) bool {
+	return resolution == ResolutionRefund || resolution == ResolutionDiscount
+}
+
+// ParseResolutionInput splits operator input like "500 вернули за опоздание" into amount and note;
+// only refunds and discounts start with an amount, other resolutions are all note
+func ParseResolutionInput(resolution, text string) (float64, string) {
+	text = strings.TrimSpace(text)
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !HasAmount(resolution) {
+		return 0, text
+	}
+	amount, err := strconv.ParseFloat(strings.Replace(fields[0], ",", ".", 1), 64)
+	if err != nil || amount < 0 {
+		return 0, text
+	}
+	return amount, strings.TrimSpace(strings.TrimPrefix(text, fields[0]))
+}
@@ -0,0 +1,198 @@
+package escalation_test
+
+import (
+	"testing"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/escalation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository is a mock implementation of escalation.Repository
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateEscalation(e *models.Escalation) error {
+	args := m.Called(e)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetEscalation(id int) (*models.Escalation, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Escalation), args.Error(1)
+}
+
+func (m *MockRepository) GetEscalationsByStatus(statuses ...string) ([]models.Escalation, error) {
+	args := m.Called(statuses)
+	return args.Get(0).([]models.Escalation), args.Error(1)
+}
+
+func (m *MockRepository) ResolveEscalation(e *models.Escalation) (bool, error) {
+	args := m.Called(e)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) TakeEscalation(id int, operatorID int64) (bool, error) {
+	args := m.Called(id, operatorID)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestService_OpenForReview(t *testing.T) {
+	t.Run("LowRating", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := escalation.NewService(mockRepo)
+
+		mockRepo.On("CreateEscalation", mock.MatchedBy(func(e *models.Escalation) bool {
+			return e.ReviewID == 3 && e.OrderID == 10 && e.UserID == 100 && e.Status == escalation.StatusOpen
+		})).Return(nil).Once()
+
+		e, err := service.OpenForReview(&models.Review{ID: 3, OrderID: 10, UserID: 100, Rating: 2})
+		assert.NoError(t, err)
+		assert.NotNil(t, e)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("HigherRating", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := escalation.NewService(mockRepo)
+
+		e, err := service.OpenForReview(&models.Review{ID: 3, OrderID: 10, UserID: 100, Rating: 3})
+		assert.NoError(t, err)
+		assert.Nil(t, e)
+		mockRepo.AssertNotCalled(t, "CreateEscalation", mock.Anything)
+	})
+}
+
+func TestService_Take(t *testing.T) {
+	t.Run("Unassigned", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := escalation.NewService(mockRepo)
+
+		mockRepo.On("GetEscalation", 1).Return(&models.Escalation{ID: 1, Status: escalation.StatusOpen}, nil).Once()
+		mockRepo.On("TakeEscalation", 1, int64(42)).Return(true, nil).Once()
+
+		e, err := service.Take(1, 42)
+		assert.NoError(t, err)
+		assert.Equal(t, escalation.StatusInProgress, e.Status)
+		assert.Equal(t, int64(42), e.OperatorID)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("TakenByAnotherOperator", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := escalation.NewService(mockRepo)
+
+		// Loaded before the other operator's update landed
+		mockRepo.On("GetEscalation", 1).Return(&models.Escalation{ID: 1, Status: escalation.StatusOpen}, nil).Once()
+		mockRepo.On("TakeEscalation", 1, int64(42)).Return(false, nil).Once()
+
+		_, err := service.Take(1, 42)
+		assert.Equal(t, escalation.ErrAlreadyTaken, err)
+	})
+
+	t.Run("AlreadyOwn", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := escalation.NewService(mockRepo)
+
+		mockRepo.On("GetEscalation", 1).Return(&models.Escalation{ID: 1, Status: escalation.StatusInProgress, OperatorID: 42}, nil).Once()
+
+		_, err := service.Take(1, 42)
+		assert.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "TakeEscalation", mock.Anything, mock.Anything)
+	})
+}
+
+func TestService_Resolve(t *testing.T) {
+	t.Run("Refund", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := escalation.NewService(mockRepo)
+
+		created := time.Now().Add(-3 * time.Hour)
+		mockRepo.On("GetEscalation", 1).Return(&models.Escalation{ID: 1, Status: escalation.StatusInProgress, OperatorID: 42, CreatedAt: created}, nil).Once()
+		mockRepo.On("ResolveEscalation", mock.MatchedBy(func(e *models.Escalation) bool {
+			return e.Status == escalation.StatusResolved && e.Resolution == escalation.ResolutionRefund &&
+				e.Amount == 500 && e.OperatorID == 42 && !e.ResolvedAt.IsZero()
+		})).Return(true, nil).Once()
+
+		e, err := service.Resolve(1, 42, escalation.ResolutionRefund, "вернули за опоздание", 500)
+		assert.NoError(t, err)
+		assert.True(t, e.ResolvedAt.Sub(e.CreatedAt) >= 3*time.Hour)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("AlreadyResolved", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := escalation.NewService(mockRepo)
+
+		mockRepo.On("GetEscalation", 1).Return(&models.Escalation{ID: 1, Status: escalation.StatusResolved}, nil).Once()
+
+		_, err := service.Resolve(1, 42, escalation.ResolutionRedo, "", 0)
+		assert.Error(t, err)
+		mockRepo.AssertNotCalled(t, "ResolveEscalation", mock.Anything)
+	})
+
+	t.Run("NotTakenByOperator", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := escalation.NewService(mockRepo)
+
+		mockRepo.On("GetEscalation", 1).Return(&models.Escalation{ID: 1, Status: escalation.StatusOpen}, nil).Once()
+		mockRepo.On("GetEscalation", 2).Return(&models.Escalation{ID: 2, Status: escalation.StatusInProgress, OperatorID: 7}, nil).Once()
+
+		_, err := service.Resolve(1, 42, escalation.ResolutionRedo, "", 0)
+		assert.Equal(t, escalation.ErrNotTaken, err)
+		_, err = service.Resolve(2, 42, escalation.ResolutionRedo, "", 0)
+		assert.Equal(t, escalation.ErrNotTaken, err)
+		mockRepo.AssertNotCalled(t, "ResolveEscalation", mock.Anything)
+	})
+
+	t.Run("ResolvedConcurrently", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := escalation.NewService(mockRepo)
+
+		// A repeated resolve lost the race to the first one
+		mockRepo.On("GetEscalation", 1).Return(&models.Escalation{ID: 1, Status: escalation.StatusInProgress, OperatorID: 42}, nil).Once()
+		mockRepo.On("ResolveEscalation", mock.AnythingOfType("*models.Escalation")).Return(false, nil).Once()
+
+		_, err := service.Resolve(1, 42, escalation.ResolutionRedo, "", 0)
+		assert.Error(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("UnknownResolution", func(t *testing.T) {
+		service := escalation.NewService(new(MockRepository))
+
+		_, err := service.Resolve(1, 42, "voucher", "", 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("AmountWithoutCompensation", func(t *testing.T) {
+		service := escalation.NewService(new(MockRepository))
+
+		_, err := service.Resolve(1, 42, escalation.ResolutionRedo, "", 500)
+		assert.Error(t, err)
+	})
+}
+
+func TestParseResolutionInput(t *testing.T) {
+	amount, note := escalation.ParseResolutionInput(escalation.ResolutionRefund, "500 вернули за опоздание")
+	assert.Equal(t, 500.0, amount)
+	assert.Equal(t, "вернули за опоздание", note)
+
+	amount, note = escalation.ParseResolutionInput(escalation.ResolutionDiscount, "250,5")
+	assert.Equal(t, 250.5, amount)
+	assert.Empty(t, note)
+
+	amount, note = escalation.ParseResolutionInput(escalation.ResolutionRefund, "Договорились на повторный выезд")
+	assert.Zero(t, amount)
+	assert.Equal(t, "Договорились на повторный выезд", note)
+
+	// Other resolutions keep a leading number as part of the note
+	amount, note = escalation.ParseResolutionInput(escalation.ResolutionRedo, "2 грузчика приедут завтра")
+	assert.Zero(t, amount)
+	assert.Equal(t, "2 грузчика приедут завтра", note)
+}
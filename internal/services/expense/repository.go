@@ -0,0 +1,139 @@
+package expense
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/db"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// PostgresRepository implements the Repository interface for PostgreSQL
+type PostgresRepository struct {
+	db *db.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL repository
+func NewPostgresRepository(db *db.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// expenseColumns lists the columns scanned by scanExpense
+const expenseColumns = `id, user_id, category, amount, order_id, photo_file_id, comment, status, reviewed_by, reviewed_at, created_at`
+
+// CreateExpense creates a new expense
+func (r *PostgresRepository) CreateExpense(expense *models.Expense) error {
+	query := `
+		INSERT INTO expenses (user_id, category, amount, order_id, photo_file_id, comment, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+	var orderID sql.NullInt64
+	if expense.OrderID != 0 {
+		orderID.Valid = true
+		orderID.Int64 = int64(expense.OrderID)
+	}
+	err := r.db.Conn().QueryRow(
+		query,
+		expense.UserID, expense.Category, expense.Amount, orderID, expense.PhotoFileID,
+		expense.Comment, expense.Status, expense.CreatedAt,
+	).Scan(&expense.ID)
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to create expense: %v", err)
+	}
+	return nil
+}
+
+// GetExpense retrieves an expense by its ID
+func (r *PostgresRepository) GetExpense(id int) (*models.Expense, error) {
+	query := `SELECT ` + expenseColumns + ` FROM expenses WHERE id = $1`
+	expense, err := scanExpense(r.db.Conn().QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("expense not found")
+	}
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get expense: %v", err)
+	}
+	return expense, nil
+}
+
+// GetExpensesByStatus retrieves expenses with the given status, oldest first
+func (r *PostgresRepository) GetExpensesByStatus(status string) ([]models.Expense, error) {
+	query := `SELECT ` + expenseColumns + ` FROM expenses WHERE status = $1 ORDER BY created_at, id`
+	rows, err := r.db.Conn().Query(query, status)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get expenses: %v", err)
+	}
+	defer rows.Close()
+
+	var expenses []models.Expense
+	for rows.Next() {
+		expense, err := scanExpense(rows)
+		if err != nil {
+			utils.LogError(err)
+			continue
+		}
+		expenses = append(expenses, *expense)
+	}
+	return expenses, nil
+}
+
+// UpdateExpenseStatus moves an expense from one status to another, reporting false if it was no longer in the first
+func (r *PostgresRepository) UpdateExpenseStatus(id int, from, to string, reviewedBy int64, reviewedAt time.Time) (bool, error) {
+	query := `
+		UPDATE expenses
+		SET status = $1, reviewed_by = $2, reviewed_at = $3
+		WHERE id = $4 AND status = $5
+	`
+	var reviewer sql.NullInt64
+	var reviewed sql.NullTime
+	if reviewedBy != 0 {
+		reviewer.Valid = true
+		reviewer.Int64 = reviewedBy
+	}
+	if !reviewedAt.IsZero() {
+		reviewed.Valid = true
+		reviewed.Time = reviewedAt
+	}
+	result, err := r.db.Conn().Exec(query, to, reviewer, reviewed, id, from)
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to update expense: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to update expense: %v", err)
+	}
+	return affected > 0, nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanExpense reads an expense selected with expenseColumns
+func scanExpense(row rowScanner) (*models.Expense, error) {
+	var expense models.Expense
+	var orderID, reviewedBy sql.NullInt64
+	var comment sql.NullString
+	var reviewedAt sql.NullTime
+	if err := row.Scan(
+		&expense.ID, &expense.UserID, &expense.Category, &expense.Amount, &orderID, &expense.PhotoFileID,
+		&comment, &expense.Status, &reviewedBy, &reviewedAt, &expense.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	expense.OrderID = int(orderID.Int64)
+	expense.Comment = comment.String
+	expense.ReviewedBy = reviewedBy.Int64
+	if reviewedAt.Valid {
+		expense.ReviewedAt = reviewedAt.Time
+	}
+	return &expense, nil
+}
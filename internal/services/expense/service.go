@@ -0,0 +1,226 @@
+package expense
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/accounting"
+)
+
+// Expense review statuses
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// Category is a kind of expense staff can submit and the ledger account it is posted to
+type Category struct {
+	Code    string
+	Name    string
+	Icon    string
+	Account string
+}
+
+// categories lists the expenses staff can submit from the bot
+var categories = []Category{
+	{Code: "fuel", Name: "Топливо", Icon: "⛽", Account: accounting.AccountFuel},
+	{Code: "disposal", Name: "Полигон", Icon: "🏭", Account: accounting.AccountDisposal},
+	{Code: "parking", Name: "Парковка", Icon: "🅿️", Account: accounting.AccountParking},
+}
+
+// Categories returns the expense categories
+func Categories() []Category {
+	return append([]Category(nil), categories...)
+}
+
+// LookupCategory finds an expense category by its code
+func LookupCategory(code string) (Category, bool) {
+	for _, category := range categories {
+		if category.Code == code {
+			return category, true
+		}
+	}
+	return Category{}, false
+}
+
+// Ledger posts approved expenses
+type Ledger interface {
+	RecordExpense(userID int64, expenseAccount string, amount models.Money, paidFrom, description string) error
+}
+
+// Users looks up who submitted an expense
+type Users interface {
+	GetUser(chatID int64) (*models.User, error)
+}
+
+// Service handles expense-related business logic
+type Service struct {
+	repo   Repository
+	ledger Ledger
+	users  Users
+}
+
+// Repository defines the interface for expense data access
+type Repository interface {
+	CreateExpense(expense *models.Expense) error
+	GetExpense(id int) (*models.Expense, error)
+	GetExpensesByStatus(status string) ([]models.Expense, error)
+	UpdateExpenseStatus(id int, from, to string, reviewedBy int64, reviewedAt time.Time) (bool, error)
+}
+
+// NewService creates a new expense service
+func NewService(repo Repository, ledger Ledger, users Users) *Service {
+	return &Service{
+		repo:   repo,
+		ledger: ledger,
+		users:  users,
+	}
+}
+
+// Submit records an expense with its receipt photo for review by the main operator
+func (s *Service) Submit(userID int64, category string, amount models.Money, orderID int, photoFileID, comment string) (*models.Expense, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+	if _, ok := LookupCategory(category); !ok {
+		return nil, fmt.Errorf("unknown expense category: %s", category)
+	}
+	if amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	if orderID < 0 {
+		return nil, errors.New("invalid order ID")
+	}
+	if photoFileID == "" {
+		return nil, errors.New("receipt photo is required")
+	}
+
+	expense := &models.Expense{
+		UserID:      userID,
+		Category:    category,
+		Amount:      amount,
+		OrderID:     orderID,
+		PhotoFileID: photoFileID,
+		Comment:     strings.TrimSpace(comment),
+		Status:      StatusPending,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.repo.CreateExpense(expense); err != nil {
+		return nil, err
+	}
+	return expense, nil
+}
+
+// Approve posts a pending expense to the ledger against the money the submitter paid from; nobody approves their own expense
+func (s *Service) Approve(expenseID int, approverID int64) (*models.Expense, error) {
+	expense, err := s.review(expenseID, approverID, StatusApproved)
+	if err != nil {
+		return nil, err
+	}
+
+	category, _ := LookupCategory(expense.Category)
+	submitter, err := s.users.GetUser(expense.UserID)
+	if err == nil {
+		err = s.ledger.RecordExpense(expense.UserID, category.Account, expense.Amount, PaidFrom(submitter.Role), Description(expense))
+	}
+	if err != nil {
+		// Put the expense back in the queue so it can be approved again
+		if _, revertErr := s.repo.UpdateExpenseStatus(expense.ID, StatusApproved, StatusPending, 0, time.Time{}); revertErr != nil {
+			return nil, fmt.Errorf("failed to post expense: %v (revert failed: %v)", err, revertErr)
+		}
+		return nil, fmt.Errorf("failed to post expense: %v", err)
+	}
+	return expense, nil
+}
+
+// PaidFrom returns the account a submitter's expenses are paid from: drivers spend collected cash,
+// loaders spend money advanced to them and office staff pay from the cash desk
+func PaidFrom(role string) string {
+	switch role {
+	case "driver":
+		return accounting.AccountDriverCash
+	case "loader":
+		return accounting.AccountAccountable
+	default:
+		return accounting.AccountCashDesk
+	}
+}
+
+// Reject declines a pending expense; it never reaches the ledger
+func (s *Service) Reject(expenseID int, approverID int64) (*models.Expense, error) {
+	return s.review(expenseID, approverID, StatusRejected)
+}
+
+// review moves a pending expense to the given status
+func (s *Service) review(expenseID int, approverID int64, status string) (*models.Expense, error) {
+	if expenseID <= 0 || approverID <= 0 {
+		return nil, errors.New("invalid expense or approver ID")
+	}
+
+	expense, err := s.repo.GetExpense(expenseID)
+	if err != nil {
+		return nil, err
+	}
+	if expense.Status != StatusPending {
+		return nil, fmt.Errorf("expense already reviewed: %s", expense.Status)
+	}
+	if expense.UserID == approverID {
+		return nil, errors.New("expenses cannot be reviewed by their submitter")
+	}
+
+	now := time.Now()
+	updated, err := s.repo.UpdateExpenseStatus(expense.ID, StatusPending, status, approverID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, errors.New("expense already reviewed")
+	}
+	expense.Status = status
+	expense.ReviewedBy = approverID
+	expense.ReviewedAt = now
+	return expense, nil
+}
+
+// GetPending retrieves expenses waiting for review, oldest first
+func (s *Service) GetPending() ([]models.Expense, error) {
+	return s.repo.GetExpensesByStatus(StatusPending)
+}
+
+// GetExpense retrieves an expense by its ID
+func (s *Service) GetExpense(expenseID int) (*models.Expense, error) {
+	if expenseID <= 0 {
+		return nil, errors.New("invalid expense ID")
+	}
+	return s.repo.GetExpense(expenseID)
+}
+
+// Description is the ledger description of an expense
+func Description(expense *models.Expense) string {
+	category, _ := LookupCategory(expense.Category)
+	text := fmt.Sprintf("Расход #%d: %s", expense.ID, category.Name)
+	if expense.OrderID > 0 {
+		text += fmt.Sprintf(", заказ #%d", expense.OrderID)
+	}
+	if expense.Comment != "" {
+		text += ", " + expense.Comment
+	}
+	return text
+}
+
+// FormatExpense renders an expense for review
+func FormatExpense(expense *models.Expense) string {
+	category, _ := LookupCategory(expense.Category)
+	text := fmt.Sprintf("🧾 Расход #%d\n%s %s: %s руб.\nСотрудник: %d, %s",
+		expense.ID, category.Icon, category.Name, expense.Amount, expense.UserID, expense.CreatedAt.Format("02.01.2006 15:04"))
+	if expense.OrderID > 0 {
+		text += fmt.Sprintf("\nЗаказ: #%d", expense.OrderID)
+	}
+	if expense.Comment != "" {
+		text += "\nКомментарий: " + expense.Comment
+	}
+	return text
+}
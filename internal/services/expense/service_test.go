@@ -0,0 +1,157 @@
+package expense_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/accounting"
+	"github.com/skyzeper/telegram-bot/internal/services/expense"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository is a mock implementation of expense.Repository
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateExpense(e *models.Expense) error {
+	args := m.Called(e)
+	e.ID = 1
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetExpense(id int) (*models.Expense, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Expense), args.Error(1)
+}
+
+func (m *MockRepository) GetExpensesByStatus(status string) ([]models.Expense, error) {
+	args := m.Called(status)
+	return args.Get(0).([]models.Expense), args.Error(1)
+}
+
+func (m *MockRepository) UpdateExpenseStatus(id int, from, to string, reviewedBy int64, reviewedAt time.Time) (bool, error) {
+	args := m.Called(id, from, to, reviewedBy, reviewedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockLedger is a mock implementation of expense.Ledger
+type MockLedger struct {
+	mock.Mock
+}
+
+func (m *MockLedger) RecordExpense(userID int64, expenseAccount string, amount models.Money, paidFrom, description string) error {
+	args := m.Called(userID, expenseAccount, amount, paidFrom, description)
+	return args.Error(0)
+}
+
+// MockUsers is a mock implementation of expense.Users
+type MockUsers struct {
+	mock.Mock
+}
+
+func (m *MockUsers) GetUser(chatID int64) (*models.User, error) {
+	args := m.Called(chatID)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
+}
+
+func TestService_Submit(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := expense.NewService(mockRepo, new(MockLedger), new(MockUsers))
+
+	mockRepo.On("CreateExpense", mock.MatchedBy(func(e *models.Expense) bool {
+		return e.UserID == 200 && e.Category == "fuel" && e.Amount == 250000 && e.OrderID == 10 && e.Status == expense.StatusPending
+	})).Return(nil).Once()
+
+	e, err := service.Submit(200, "fuel", 250000, 10, "photo-1", " АЗС на трассе ")
+	assert.NoError(t, err)
+	assert.Equal(t, "АЗС на трассе", e.Comment)
+
+	_, err = service.Submit(200, "lunch", 50000, 0, "photo-1", "")
+	assert.Error(t, err)
+	_, err = service.Submit(200, "parking", 0, 0, "photo-1", "")
+	assert.Error(t, err)
+	_, err = service.Submit(200, "parking", 20000, 0, "", "")
+	assert.Error(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_Approve(t *testing.T) {
+	mockRepo := new(MockRepository)
+	ledger := new(MockLedger)
+	users := new(MockUsers)
+	service := expense.NewService(mockRepo, ledger, users)
+	users.On("GetUser", int64(200)).Return(&models.User{ChatID: 200, Role: "driver"}, nil)
+
+	pending := func() *models.Expense {
+		return &models.Expense{ID: 5, UserID: 200, Category: "disposal", Amount: 120000, OrderID: 10, Status: expense.StatusPending}
+	}
+
+	// Submitters cannot approve their own expenses
+	mockRepo.On("GetExpense", 5).Return(pending(), nil).Once()
+	_, err := service.Approve(5, 200)
+	assert.Error(t, err)
+
+	mockRepo.On("GetExpense", 5).Return(pending(), nil).Once()
+	mockRepo.On("UpdateExpenseStatus", 5, expense.StatusPending, expense.StatusApproved, int64(300), mock.AnythingOfType("time.Time")).Return(true, nil).Once()
+	ledger.On("RecordExpense", int64(200), accounting.AccountDisposal, models.Money(120000), accounting.AccountDriverCash, "Расход #5: Полигон, заказ #10").Return(nil).Once()
+	approved, err := service.Approve(5, 300)
+	assert.NoError(t, err)
+	assert.Equal(t, expense.StatusApproved, approved.Status)
+	assert.Equal(t, int64(300), approved.ReviewedBy)
+
+	// A failed posting returns the expense to the queue
+	mockRepo.On("GetExpense", 5).Return(pending(), nil).Once()
+	mockRepo.On("UpdateExpenseStatus", 5, expense.StatusPending, expense.StatusApproved, int64(300), mock.AnythingOfType("time.Time")).Return(true, nil).Once()
+	ledger.On("RecordExpense", int64(200), accounting.AccountDisposal, models.Money(120000), accounting.AccountDriverCash, mock.Anything).Return(errors.New("db down")).Once()
+	mockRepo.On("UpdateExpenseStatus", 5, expense.StatusApproved, expense.StatusPending, int64(0), time.Time{}).Return(true, nil).Once()
+	_, err = service.Approve(5, 300)
+	assert.Error(t, err)
+
+	mockRepo.AssertExpectations(t)
+	ledger.AssertExpectations(t)
+}
+
+func TestService_ApprovePaysFromSubmittersMoney(t *testing.T) {
+	assert.Equal(t, accounting.AccountDriverCash, expense.PaidFrom("driver"))
+	assert.Equal(t, accounting.AccountAccountable, expense.PaidFrom("loader"))
+	assert.Equal(t, accounting.AccountCashDesk, expense.PaidFrom("operator"))
+
+	mockRepo := new(MockRepository)
+	ledger := new(MockLedger)
+	users := new(MockUsers)
+	service := expense.NewService(mockRepo, ledger, users)
+	users.On("GetUser", int64(400)).Return(&models.User{ChatID: 400, Role: "loader"}, nil)
+
+	mockRepo.On("GetExpense", 7).Return(&models.Expense{ID: 7, UserID: 400, Category: "parking", Amount: 30000, Status: expense.StatusPending}, nil).Once()
+	mockRepo.On("UpdateExpenseStatus", 7, expense.StatusPending, expense.StatusApproved, int64(300), mock.AnythingOfType("time.Time")).Return(true, nil).Once()
+	ledger.On("RecordExpense", int64(400), accounting.AccountParking, models.Money(30000), accounting.AccountAccountable, mock.Anything).Return(nil).Once()
+	_, err := service.Approve(7, 300)
+	assert.NoError(t, err)
+	ledger.AssertExpectations(t)
+}
+
+func TestService_Reject(t *testing.T) {
+	mockRepo := new(MockRepository)
+	ledger := new(MockLedger)
+	service := expense.NewService(mockRepo, ledger, new(MockUsers))
+
+	mockRepo.On("GetExpense", 6).Return(&models.Expense{ID: 6, UserID: 200, Category: "parking", Amount: 30000, Status: expense.StatusPending}, nil).Once()
+	mockRepo.On("UpdateExpenseStatus", 6, expense.StatusPending, expense.StatusRejected, int64(300), mock.AnythingOfType("time.Time")).Return(true, nil).Once()
+	rejected, err := service.Reject(6, 300)
+	assert.NoError(t, err)
+	assert.Equal(t, expense.StatusRejected, rejected.Status)
+
+	mockRepo.On("GetExpense", 6).Return(rejected, nil).Once()
+	_, err = service.Reject(6, 300)
+	assert.Error(t, err)
+
+	mockRepo.AssertExpectations(t)
+	ledger.AssertNotCalled(t, "RecordExpense", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,45 @@
+package fiscal
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+)
+
+// Provider registers receipts with a fiscal data operator (OFD) or an online cash register
+type Provider interface {
+	// Name identifies the provider in stored receipts
+	Name() string
+	// Register fiscalizes a receipt and returns its fiscal identifier
+	Register(receipt *models.Receipt) (fiscalID string, err error)
+}
+
+// StubProvider is a local provider for development: receipts are numbered but never sent anywhere
+type StubProvider struct {
+	mu  sync.Mutex
+	seq int
+	now func() time.Time
+}
+
+// NewStubProvider creates a stub fiscal provider
+func NewStubProvider() *StubProvider {
+	return &StubProvider{now: time.Now}
+}
+
+// Name identifies the stub in stored receipts
+func (p *StubProvider) Name() string {
+	return "stub"
+}
+
+// Register assigns the receipt a local fiscal identifier
+func (p *StubProvider) Register(receipt *models.Receipt) (string, error) {
+	if receipt == nil || receipt.PaymentID <= 0 {
+		return "", errors.New("invalid receipt")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seq++
+	return fmt.Sprintf("STUB-%s-%d-%d", p.now().Format("20060102150405"), receipt.PaymentID, p.seq), nil
+}
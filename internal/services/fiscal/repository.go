@@ -0,0 +1,113 @@
+package fiscal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/skyzeper/telegram-bot/internal/db"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// PostgresRepository implements the Repository interface for PostgreSQL
+type PostgresRepository struct {
+	db *db.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL repository
+func NewPostgresRepository(db *db.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// CreateReceipt stores a receipt before it is registered; it returns false when the payment already has one
+func (r *PostgresRepository) CreateReceipt(receipt *models.Receipt) (bool, error) {
+	items, err := json.Marshal(receipt.Items)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode receipt items: %v", err)
+	}
+
+	query := `
+		INSERT INTO receipts (payment_id, order_id, user_id, provider, fiscal_id, status, operation, items,
+			total, vat_mode, vat_amount, payment_method, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (payment_id) DO NOTHING
+		RETURNING id
+	`
+	err = r.db.Conn().QueryRow(
+		query,
+		receipt.PaymentID, receipt.OrderID, receipt.UserID, receipt.Provider, receipt.FiscalID, receipt.Status,
+		receipt.Operation, items, receipt.Total, receipt.VATMode, receipt.VATAmount, receipt.PaymentMethod, receipt.CreatedAt,
+	).Scan(&receipt.ID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to create receipt: %v", err)
+	}
+	return true, nil
+}
+
+// CompleteReceipt stores the fiscal ID of a registered receipt and links it to its payment
+func (r *PostgresRepository) CompleteReceipt(receipt *models.Receipt) error {
+	tx, err := r.db.Conn().Begin()
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE receipts SET provider = $1, fiscal_id = $2, status = $3 WHERE id = $4`
+	if _, err := tx.Exec(query, receipt.Provider, receipt.FiscalID, receipt.Status, receipt.ID); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to update receipt: %v", err)
+	}
+	if _, err := tx.Exec(`UPDATE payments SET receipt_id = $1 WHERE id = $2`, receipt.ID, receipt.PaymentID); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to link receipt to payment: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to commit receipt: %v", err)
+	}
+	return nil
+}
+
+// DeletePendingReceipt removes a receipt the provider refused, so the payment can be fiscalized again
+func (r *PostgresRepository) DeletePendingReceipt(id int) error {
+	query := `DELETE FROM receipts WHERE id = $1 AND status = 'pending'`
+	if _, err := r.db.Conn().Exec(query, id); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to delete receipt: %v", err)
+	}
+	return nil
+}
+
+// GetReceiptByPayment retrieves the receipt of a payment, or nil if none was issued
+func (r *PostgresRepository) GetReceiptByPayment(paymentID int) (*models.Receipt, error) {
+	query := `
+		SELECT id, payment_id, order_id, user_id, provider, fiscal_id, status, operation, items,
+			total, vat_mode, vat_amount, payment_method, created_at
+		FROM receipts
+		WHERE payment_id = $1
+	`
+	var receipt models.Receipt
+	var items []byte
+	var vatAmount sql.NullFloat64
+	err := r.db.Conn().QueryRow(query, paymentID).Scan(
+		&receipt.ID, &receipt.PaymentID, &receipt.OrderID, &receipt.UserID, &receipt.Provider, &receipt.FiscalID, &receipt.Status,
+		&receipt.Operation, &items, &receipt.Total, &receipt.VATMode, &vatAmount, &receipt.PaymentMethod, &receipt.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get receipt: %v", err)
+	}
+	if err := json.Unmarshal(items, &receipt.Items); err != nil {
+		return nil, fmt.Errorf("failed to decode receipt items: %v", err)
+	}
+	receipt.VATAmount = vatAmount.Float64
+	return &receipt, nil
+}
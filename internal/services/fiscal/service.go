@@ -0,0 +1,219 @@
+package fiscal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/payment"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// Receipt operations
+const (
+	OperationIncome = "income"        // приход
+	OperationRefund = "income_return" // возврат прихода
+)
+
+// Receipt payment methods
+const (
+	PaymentCash     = "cash"     // наличными
+	PaymentCashless = "cashless" // безналичными
+)
+
+// Receipt statuses
+const (
+	ReceiptStatusPending    = "pending"    // stored, registration with the provider not finished
+	ReceiptStatusRegistered = "registered" // fiscalized, the fiscal ID is known
+)
+
+// VAT modes and their rates in percent
+var vatRates = map[string]float64{
+	"none":  0,
+	"vat0":  0,
+	"vat10": 10,
+	"vat20": 20,
+}
+
+// Config holds fiscal receipt settings
+type Config struct {
+	// VATMode is one of none, vat0, vat10 or vat20
+	VATMode string
+	// Provider fiscalizes receipts (nil disables receipts)
+	Provider Provider
+}
+
+// Service handles fiscal receipt business logic
+type Service struct {
+	bot  *tgbotapi.BotAPI
+	repo Repository
+	cfg  Config
+}
+
+// Repository defines the interface for receipt data access
+type Repository interface {
+	CreateReceipt(receipt *models.Receipt) (bool, error)
+	CompleteReceipt(receipt *models.Receipt) error
+	DeletePendingReceipt(id int) error
+	GetReceiptByPayment(paymentID int) (*models.Receipt, error)
+}
+
+// ErrReceiptPending is returned when a payment's receipt was stored but its registration has not finished;
+// it may already be fiscalized, so it is not registered again without checking with the provider
+var ErrReceiptPending = errors.New("receipt registration is pending")
+
+// NewService creates a new fiscal service
+func NewService(bot *tgbotapi.BotAPI, repo Repository, cfg Config) *Service {
+	if cfg.VATMode == "" {
+		cfg.VATMode = "none"
+	}
+	return &Service{
+		bot:  bot,
+		repo: repo,
+		cfg:  cfg,
+	}
+}
+
+// ValidVATMode reports whether a VAT mode is supported
+func ValidVATMode(mode string) bool {
+	_, ok := vatRates[mode]
+	return ok
+}
+
+// IssueReceipt fiscalizes a confirmed payment, keeps the receipt ID on it and sends the receipt to the client.
+// The receipt is stored as pending before registration, so a payment is never fiscalized twice.
+// Without a configured provider no receipt is issued and nil is returned.
+func (s *Service) IssueReceipt(order *models.Order, p *models.Payment) (*models.Receipt, error) {
+	if s.cfg.Provider == nil {
+		return nil, nil
+	}
+	if order == nil || p == nil || p.ID <= 0 || p.OrderID != order.ID {
+		return nil, errors.New("invalid order or payment")
+	}
+	if !p.Confirmed {
+		return nil, errors.New("payment is not confirmed")
+	}
+
+	existing, err := s.repo.GetReceiptByPayment(p.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if existing.Status == ReceiptStatusPending {
+			return nil, ErrReceiptPending
+		}
+		p.ReceiptID = existing.ID
+		return existing, nil
+	}
+
+	receipt, err := BuildReceipt(order, p, s.cfg.VATMode)
+	if err != nil {
+		return nil, err
+	}
+	receipt.Provider = s.cfg.Provider.Name()
+	receipt.Status = ReceiptStatusPending
+	created, err := s.repo.CreateReceipt(receipt)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrReceiptPending
+	}
+
+	fiscalID, err := s.cfg.Provider.Register(receipt)
+	if err != nil {
+		if delErr := s.repo.DeletePendingReceipt(receipt.ID); delErr != nil {
+			utils.LogError(delErr)
+		}
+		return nil, fmt.Errorf("failed to register receipt: %v", err)
+	}
+	receipt.FiscalID = fiscalID
+	receipt.Status = ReceiptStatusRegistered
+	if err := s.repo.CompleteReceipt(receipt); err != nil {
+		return nil, fmt.Errorf("receipt %d registered as %s but not saved: %v", receipt.ID, fiscalID, err)
+	}
+	p.ReceiptID = receipt.ID
+
+	if _, err := s.bot.Send(tgbotapi.NewMessage(receipt.UserID, FormatReceipt(receipt))); err != nil {
+		return receipt, fmt.Errorf("failed to send receipt: %v", err)
+	}
+	return receipt, nil
+}
+
+// BuildReceipt composes the receipt for a payment from the order it settles
+func BuildReceipt(order *models.Order, p *models.Payment, vatMode string) (*models.Receipt, error) {
+	rate, ok := vatRates[vatMode]
+	if !ok {
+		return nil, fmt.Errorf("unknown VAT mode: %s", vatMode)
+	}
+	if p.Amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+
+	name := order.Category
+	if order.Subcategory != "" {
+		name += " — " + order.Subcategory
+	}
+	name = fmt.Sprintf("%s (заказ #%d)", name, order.ID)
+	operation := OperationIncome
+	switch p.Kind {
+	case payment.KindPrepayment:
+		name = "Предоплата: " + name
+	case payment.KindRefund:
+		operation = OperationRefund
+	}
+
+	method := PaymentCashless
+	if p.Method == payment.MethodCash || p.Method == payment.MethodManual {
+		method = PaymentCash
+	}
+
+	amount := roundKopecks(p.Amount)
+	return &models.Receipt{
+		PaymentID: p.ID,
+		OrderID:   order.ID,
+		UserID:    p.UserID,
+		Operation: operation,
+		Items: []models.ReceiptItem{
+			{Name: name, Quantity: 1, Price: amount, Amount: amount},
+		},
+		Total:         amount,
+		VATMode:       vatMode,
+		VATAmount:     roundKopecks(amount * rate / (100 + rate)),
+		PaymentMethod: method,
+		CreatedAt:     time.Now(),
+	}, nil
+}
+
+// FormatReceipt renders a receipt for the client
+func FormatReceipt(receipt *models.Receipt) string {
+	var b strings.Builder
+	title := "Кассовый чек — приход"
+	if receipt.Operation == OperationRefund {
+		title = "Кассовый чек — возврат прихода"
+	}
+	fmt.Fprintf(&b, "🧾 %s\n%s\n\n", title, receipt.CreatedAt.Format("02.01.2006 15:04"))
+	for _, item := range receipt.Items {
+		fmt.Fprintf(&b, "%s\n%.0f × %.2f = %.2f руб.\n", item.Name, item.Quantity, item.Price, item.Amount)
+	}
+	fmt.Fprintf(&b, "\nИтого: %.2f руб.\n", receipt.Total)
+	if receipt.VATMode == "none" {
+		b.WriteString("Без НДС\n")
+	} else {
+		fmt.Fprintf(&b, "НДС %.0f%%: %.2f руб.\n", vatRates[receipt.VATMode], receipt.VATAmount)
+	}
+	method := "безналичными"
+	if receipt.PaymentMethod == PaymentCash {
+		method = "наличными"
+	}
+	fmt.Fprintf(&b, "Оплата: %s\nФП: %s", method, receipt.FiscalID)
+	return b.String()
+}
+
+// roundKopecks rounds an amount to kopecks
+func roundKopecks(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
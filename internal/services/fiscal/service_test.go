@@ -0,0 +1,205 @@
+package fiscal_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/fiscal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository is a mock implementation of fiscal.Repository
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateReceipt(receipt *models.Receipt) (bool, error) {
+	args := m.Called(receipt)
+	receipt.ID = 7
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) CompleteReceipt(receipt *models.Receipt) error {
+	args := m.Called(receipt)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeletePendingReceipt(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetReceiptByPayment(paymentID int) (*models.Receipt, error) {
+	args := m.Called(paymentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Receipt), args.Error(1)
+}
+
+// MockProvider is a mock implementation of fiscal.Provider
+type MockProvider struct {
+	mock.Mock
+}
+
+func (m *MockProvider) Name() string {
+	return "mock"
+}
+
+func (m *MockProvider) Register(receipt *models.Receipt) (string, error) {
+	args := m.Called(receipt)
+	return args.String(0), args.Error(1)
+}
+
+// newTestBot creates a bot talking to a fake Bot API server and records sent texts
+func newTestBot(t *testing.T) (*tgbotapi.BotAPI, func() []string) {
+	var mu sync.Mutex
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path.Base(r.URL.Path) == "sendMessage" && r.ParseForm() == nil {
+			mu.Lock()
+			texts = append(texts, r.FormValue("text"))
+			mu.Unlock()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot","message_id":1,"date":0,"chat":{"id":1}}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint("test-token", server.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatalf("failed to create test bot: %v", err)
+	}
+	return bot, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), texts...)
+	}
+}
+
+func TestBuildReceipt(t *testing.T) {
+	order := &models.Order{ID: 10, Category: "Вывоз мусора", Subcategory: "Строительный"}
+
+	receipt, err := fiscal.BuildReceipt(order, &models.Payment{ID: 3, OrderID: 10, UserID: 100, Amount: 1200, Method: "cash", Kind: "payment"}, "vat20")
+	assert.NoError(t, err)
+	assert.Equal(t, fiscal.OperationIncome, receipt.Operation)
+	assert.Equal(t, fiscal.PaymentCash, receipt.PaymentMethod)
+	assert.Equal(t, 200.0, receipt.VATAmount)
+	assert.Len(t, receipt.Items, 1)
+	assert.Equal(t, "Вывоз мусора — Строительный (заказ #10)", receipt.Items[0].Name)
+
+	receipt, err = fiscal.BuildReceipt(order, &models.Payment{ID: 4, OrderID: 10, UserID: 100, Amount: 500, Method: "card", Kind: "prepayment"}, "none")
+	assert.NoError(t, err)
+	assert.Equal(t, fiscal.PaymentCashless, receipt.PaymentMethod)
+	assert.Equal(t, 0.0, receipt.VATAmount)
+	assert.Contains(t, receipt.Items[0].Name, "Предоплата")
+
+	receipt, err = fiscal.BuildReceipt(order, &models.Payment{ID: 5, OrderID: 10, UserID: 100, Amount: 300, Method: "manual", Kind: "refund"}, "none")
+	assert.NoError(t, err)
+	assert.Equal(t, fiscal.OperationRefund, receipt.Operation)
+	assert.Contains(t, fiscal.FormatReceipt(receipt), "возврат прихода")
+
+	_, err = fiscal.BuildReceipt(order, &models.Payment{ID: 6, OrderID: 10, Amount: 300}, "vat18")
+	assert.Error(t, err)
+}
+
+func TestService_IssueReceipt(t *testing.T) {
+	bot, sent := newTestBot(t)
+	repo := new(MockRepository)
+	provider := new(MockProvider)
+	service := fiscal.NewService(bot, repo, fiscal.Config{Provider: provider})
+
+	order := &models.Order{ID: 10, UserID: 100, Category: "Вывоз мусора"}
+	p := &models.Payment{ID: 3, OrderID: 10, UserID: 100, Amount: 1500, Method: "link", Kind: "payment", Confirmed: true}
+	repo.On("GetReceiptByPayment", 3).Return(nil, nil).Once()
+	// The receipt is stored as pending before it is registered
+	repo.On("CreateReceipt", mock.MatchedBy(func(r *models.Receipt) bool {
+		return r.PaymentID == 3 && r.FiscalID == "" && r.Status == fiscal.ReceiptStatusPending && r.Total == 1500
+	})).Return(true, nil).Once()
+	provider.On("Register", mock.MatchedBy(func(r *models.Receipt) bool { return r.ID == 7 })).Return("FP-1", nil).Once()
+	repo.On("CompleteReceipt", mock.MatchedBy(func(r *models.Receipt) bool {
+		return r.ID == 7 && r.FiscalID == "FP-1" && r.Provider == "mock" && r.Status == fiscal.ReceiptStatusRegistered
+	})).Return(nil).Once()
+
+	receipt, err := service.IssueReceipt(order, p)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, receipt.ID)
+	assert.Equal(t, 7, p.ReceiptID)
+	assert.Len(t, sent(), 1)
+	assert.Contains(t, sent()[0], "FP-1")
+
+	// A payment is fiscalized only once
+	repo.On("GetReceiptByPayment", 3).Return(receipt, nil).Once()
+	again, err := service.IssueReceipt(order, p)
+	assert.NoError(t, err)
+	assert.Equal(t, receipt, again)
+	assert.Len(t, sent(), 1)
+
+	_, err = service.IssueReceipt(order, &models.Payment{ID: 4, OrderID: 10, UserID: 100, Amount: 100})
+	assert.Error(t, err)
+
+	repo.AssertExpectations(t)
+	provider.AssertExpectations(t)
+}
+
+func TestService_IssueReceiptPending(t *testing.T) {
+	repo := new(MockRepository)
+	provider := new(MockProvider)
+	service := fiscal.NewService(nil, repo, fiscal.Config{Provider: provider})
+
+	order := &models.Order{ID: 10, UserID: 100, Category: "Вывоз мусора"}
+	p := &models.Payment{ID: 3, OrderID: 10, UserID: 100, Amount: 1500, Method: "link", Kind: "payment", Confirmed: true}
+
+	// A receipt left pending by an interrupted attempt may already be fiscalized, so it is not registered again
+	repo.On("GetReceiptByPayment", 3).Return(&models.Receipt{ID: 7, PaymentID: 3, Status: fiscal.ReceiptStatusPending}, nil).Once()
+	_, err := service.IssueReceipt(order, p)
+	assert.ErrorIs(t, err, fiscal.ErrReceiptPending)
+
+	// A concurrent attempt that stored the receipt first wins
+	repo.On("GetReceiptByPayment", 3).Return(nil, nil).Once()
+	repo.On("CreateReceipt", mock.AnythingOfType("*models.Receipt")).Return(false, nil).Once()
+	_, err = service.IssueReceipt(order, p)
+	assert.ErrorIs(t, err, fiscal.ErrReceiptPending)
+
+	// A receipt the provider refused is removed so the payment can be fiscalized later
+	repo.On("GetReceiptByPayment", 3).Return(nil, nil).Once()
+	repo.On("CreateReceipt", mock.AnythingOfType("*models.Receipt")).Return(true, nil).Once()
+	provider.On("Register", mock.AnythingOfType("*models.Receipt")).Return("", errors.New("provider unavailable")).Once()
+	repo.On("DeletePendingReceipt", 7).Return(nil).Once()
+	_, err = service.IssueReceipt(order, p)
+	assert.Error(t, err)
+	assert.Zero(t, p.ReceiptID)
+
+	repo.AssertExpectations(t)
+	provider.AssertExpectations(t)
+}
+
+func TestStubProvider_Register(t *testing.T) {
+	provider := fiscal.NewStubProvider()
+	first, err := provider.Register(&models.Receipt{PaymentID: 1})
+	assert.NoError(t, err)
+	second, err := provider.Register(&models.Receipt{PaymentID: 1})
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	_, err = provider.Register(&models.Receipt{})
+	assert.Error(t, err)
+}
+
+func TestService_IssueReceiptWithoutProvider(t *testing.T) {
+	repo := new(MockRepository)
+	service := fiscal.NewService(nil, repo, fiscal.Config{})
+
+	p := &models.Payment{ID: 3, OrderID: 10, UserID: 100, Amount: 1500, Method: "link", Kind: "payment", Confirmed: true}
+	receipt, err := service.IssueReceipt(&models.Order{ID: 10, UserID: 100}, p)
+	assert.NoError(t, err)
+	assert.Nil(t, receipt)
+	assert.Zero(t, p.ReceiptID)
+	repo.AssertNotCalled(t, "GetReceiptByPayment", mock.Anything)
+}
@@ -0,0 +1,254 @@
+package fleet
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/db"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// PostgresRepository implements the Repository interface for PostgreSQL
+type PostgresRepository struct {
+	db *db.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL repository
+func NewPostgresRepository(db *db.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// vehicleColumns lists the columns read by scanVehicle
+const vehicleColumns = `id, plate, type, capacity_m3, capacity_tons, status, driver_id, mileage,
+	service_due_date, service_due_mileage, created_at, updated_at`
+
+// CreateVehicle creates a new vehicle
+func (r *PostgresRepository) CreateVehicle(vehicle *models.Vehicle) error {
+	query := `
+		INSERT INTO vehicles (
+			plate, type, capacity_m3, capacity_tons, status, driver_id, mileage,
+			service_due_date, service_due_mileage, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`
+	driverID, dueDate, dueMileage := vehicleArgs(vehicle)
+	err := r.db.Conn().QueryRow(
+		query,
+		vehicle.Plate, vehicle.Type, vehicle.CapacityM3, vehicle.CapacityTons, vehicle.Status, driverID,
+		vehicle.Mileage, dueDate, dueMileage, vehicle.CreatedAt, vehicle.UpdatedAt,
+	).Scan(&vehicle.ID)
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to create vehicle: %v", err)
+	}
+	return nil
+}
+
+// GetVehicles retrieves all vehicles
+func (r *PostgresRepository) GetVehicles() ([]models.Vehicle, error) {
+	query := `SELECT ` + vehicleColumns + ` FROM vehicles ORDER BY status, type, plate`
+	rows, err := r.db.Conn().Query(query)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get vehicles: %v", err)
+	}
+	defer rows.Close()
+
+	var vehicles []models.Vehicle
+	for rows.Next() {
+		vehicle, err := scanVehicle(rows)
+		if err != nil {
+			utils.LogError(err)
+			continue
+		}
+		vehicles = append(vehicles, *vehicle)
+	}
+	return vehicles, nil
+}
+
+// GetVehicle retrieves a vehicle by ID, returning nil when there is none
+func (r *PostgresRepository) GetVehicle(id int) (*models.Vehicle, error) {
+	query := `SELECT ` + vehicleColumns + ` FROM vehicles WHERE id = $1`
+	vehicle, err := scanVehicle(r.db.Conn().QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get vehicle: %v", err)
+	}
+	return vehicle, nil
+}
+
+// GetVehicleByPlate retrieves a vehicle by its normalized plate, returning nil when there is none
+func (r *PostgresRepository) GetVehicleByPlate(plate string) (*models.Vehicle, error) {
+	query := `SELECT ` + vehicleColumns + ` FROM vehicles WHERE plate = $1`
+	vehicle, err := scanVehicle(r.db.Conn().QueryRow(query, plate))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get vehicle: %v", err)
+	}
+	return vehicle, nil
+}
+
+// UpdateVehicle updates a vehicle's status, driver and maintenance schedule
+func (r *PostgresRepository) UpdateVehicle(vehicle *models.Vehicle) error {
+	query := `
+		UPDATE vehicles
+		SET status = $1, driver_id = $2, service_due_date = $3, service_due_mileage = $4, updated_at = $5
+		WHERE id = $6
+	`
+	driverID, dueDate, dueMileage := vehicleArgs(vehicle)
+	_, err := r.db.Conn().Exec(query, vehicle.Status, driverID, dueDate, dueMileage, vehicle.UpdatedAt, vehicle.ID)
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to update vehicle: %v", err)
+	}
+	return nil
+}
+
+// RecordMileage stores an odometer reading in the history and as the vehicle's mileage
+func (r *PostgresRepository) RecordMileage(vehicleID, mileage int, userID int64, at time.Time) error {
+	tx, err := r.db.Conn().Begin()
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO vehicle_mileage (vehicle_id, mileage, recorded_by, recorded_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	if _, err := tx.Exec(query, vehicleID, mileage, userID, at); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to record mileage: %v", err)
+	}
+	query = `UPDATE vehicles SET mileage = $1, updated_at = $2 WHERE id = $3`
+	if _, err := tx.Exec(query, mileage, at, vehicleID); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to update vehicle mileage: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to commit mileage: %v", err)
+	}
+	return nil
+}
+
+// AssignVehicle links a vehicle to an order and, when given, makes the driver its current driver
+func (r *PostgresRepository) AssignVehicle(orderID, vehicleID int, driverID int64, at time.Time) error {
+	tx, err := r.db.Conn().Begin()
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE orders SET vehicle_id = $1, updated_at = $2 WHERE id = $3`
+	if _, err := tx.Exec(query, vehicleID, at, orderID); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to assign vehicle to order: %v", err)
+	}
+	if driverID != 0 {
+		query = `UPDATE vehicles SET driver_id = $1, updated_at = $2 WHERE id = $3`
+		if _, err := tx.Exec(query, driverID, at, vehicleID); err != nil {
+			utils.LogError(err)
+			return fmt.Errorf("failed to assign driver to vehicle: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to commit vehicle assignment: %v", err)
+	}
+	return nil
+}
+
+// MarkReminderSent records a maintenance reminder, reporting false when it was already recorded
+func (r *PostgresRepository) MarkReminderSent(vehicleID int, key string, sentAt time.Time) (bool, error) {
+	query := `
+		INSERT INTO vehicle_reminders (vehicle_id, due_key, sent_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (vehicle_id, due_key) DO NOTHING
+	`
+	result, err := r.db.Conn().Exec(query, vehicleID, key, sentAt)
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to mark reminder sent: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to mark reminder sent: %v", err)
+	}
+	return affected == 1, nil
+}
+
+// GetReminderChatIDs retrieves the chat IDs of active owners and main operators
+func (r *PostgresRepository) GetReminderChatIDs() ([]int64, error) {
+	query := `
+		SELECT chat_id
+		FROM users
+		WHERE role IN ('owner', 'main_operator') AND is_blocked = FALSE
+	`
+	rows, err := r.db.Conn().Query(query)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get reminder recipients: %v", err)
+	}
+	defer rows.Close()
+
+	var chatIDs []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+	return chatIDs, nil
+}
+
+// scanVehicle reads a vehicle row selected with vehicleColumns
+func scanVehicle(row interface{ Scan(...interface{}) error }) (*models.Vehicle, error) {
+	vehicle := &models.Vehicle{}
+	var driverID, dueMileage sql.NullInt64
+	var dueDate sql.NullTime
+	err := row.Scan(
+		&vehicle.ID, &vehicle.Plate, &vehicle.Type, &vehicle.CapacityM3, &vehicle.CapacityTons,
+		&vehicle.Status, &driverID, &vehicle.Mileage, &dueDate, &dueMileage,
+		&vehicle.CreatedAt, &vehicle.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if driverID.Valid {
+		vehicle.DriverID = driverID.Int64
+	}
+	if dueDate.Valid {
+		vehicle.ServiceDueDate = &dueDate.Time
+	}
+	if dueMileage.Valid {
+		vehicle.ServiceDueMileage = int(dueMileage.Int64)
+	}
+	return vehicle, nil
+}
+
+// vehicleArgs returns the nullable driver and maintenance columns of a vehicle
+func vehicleArgs(vehicle *models.Vehicle) (driverID sql.NullInt64, dueDate sql.NullTime, dueMileage sql.NullInt64) {
+	if vehicle.DriverID != 0 {
+		driverID = sql.NullInt64{Int64: vehicle.DriverID, Valid: true}
+	}
+	if vehicle.ServiceDueDate != nil {
+		dueDate = sql.NullTime{Time: *vehicle.ServiceDueDate, Valid: true}
+	}
+	if vehicle.ServiceDueMileage > 0 {
+		dueMileage = sql.NullInt64{Int64: int64(vehicle.ServiceDueMileage), Valid: true}
+	}
+	return driverID, dueDate, dueMileage
+}
@@ -0,0 +1,518 @@
+package fleet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// Vehicle types
+const (
+	TypeGazelle     = "gazelle"
+	TypeDumpTruck   = "dump_truck"
+	TypeManipulator = "manipulator"
+)
+
+// Vehicle statuses
+const (
+	StatusActive  = "active"  // on the line and can be assigned
+	StatusRepair  = "repair"  // temporarily out of service
+	StatusRetired = "retired" // no longer in the fleet
+)
+
+var typeLabels = map[string]string{
+	TypeGazelle:     "Газель",
+	TypeDumpTruck:   "Самосвал",
+	TypeManipulator: "Манипулятор",
+}
+
+var statusLabels = map[string]string{
+	StatusActive:  "на линии",
+	StatusRepair:  "в ремонте",
+	StatusRetired: "списана",
+}
+
+var (
+	// ErrVehicleNotFound is returned for unknown vehicles
+	ErrVehicleNotFound = errors.New("vehicle not found")
+	// ErrPlateExists is returned when a vehicle with the same plate is already registered
+	ErrPlateExists = errors.New("vehicle with this plate already exists")
+	// ErrVehicleUnavailable is returned when a vehicle that is not on the line is assigned to an order
+	ErrVehicleUnavailable = errors.New("vehicle is not available")
+	// ErrMileageDecreased is returned when a mileage reading is below the last one
+	ErrMileageDecreased = errors.New("mileage must not decrease")
+)
+
+// Config holds maintenance reminder settings
+type Config struct {
+	// ReminderLead is how long before the maintenance date reminders are sent
+	ReminderLead time.Duration
+	// ReminderMileage is how many kilometres before the maintenance mileage reminders are sent
+	ReminderMileage int
+}
+
+// Service manages the vehicle fleet
+type Service struct {
+	bot  *tgbotapi.BotAPI
+	repo Repository
+	cfg  Config
+}
+
+// Repository defines the interface for fleet data access
+type Repository interface {
+	CreateVehicle(vehicle *models.Vehicle) error
+	GetVehicles() ([]models.Vehicle, error)
+	GetVehicle(id int) (*models.Vehicle, error)
+	GetVehicleByPlate(plate string) (*models.Vehicle, error)
+	UpdateVehicle(vehicle *models.Vehicle) error
+	RecordMileage(vehicleID, mileage int, userID int64, at time.Time) error
+	AssignVehicle(orderID, vehicleID int, driverID int64, at time.Time) error
+	MarkReminderSent(vehicleID int, key string, sentAt time.Time) (bool, error)
+	GetReminderChatIDs() ([]int64, error)
+}
+
+// NewService creates a new fleet service
+func NewService(bot *tgbotapi.BotAPI, repo Repository, cfg Config) *Service {
+	if cfg.ReminderLead <= 0 {
+		cfg.ReminderLead = 72 * time.Hour
+	}
+	if cfg.ReminderMileage <= 0 {
+		cfg.ReminderMileage = 500
+	}
+	return &Service{
+		bot:  bot,
+		repo: repo,
+		cfg:  cfg,
+	}
+}
+
+// AddVehicle validates and registers a vehicle
+func (s *Service) AddVehicle(vehicle *models.Vehicle) error {
+	vehicle.Plate = NormalizePlate(vehicle.Plate)
+	if vehicle.Plate == "" || len([]rune(vehicle.Plate)) > 20 {
+		return errors.New("invalid plate")
+	}
+	if _, ok := typeLabels[vehicle.Type]; !ok {
+		return fmt.Errorf("unknown vehicle type: %s", vehicle.Type)
+	}
+	if vehicle.CapacityM3 <= 0 || vehicle.CapacityTons <= 0 {
+		return errors.New("capacity must be positive")
+	}
+	if vehicle.Status == "" {
+		vehicle.Status = StatusActive
+	}
+	existing, err := s.repo.GetVehicleByPlate(vehicle.Plate)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return ErrPlateExists
+	}
+	vehicle.CreatedAt = time.Now()
+	vehicle.UpdatedAt = vehicle.CreatedAt
+	return s.repo.CreateVehicle(vehicle)
+}
+
+// GetVehicles retrieves all vehicles
+func (s *Service) GetVehicles() ([]models.Vehicle, error) {
+	return s.repo.GetVehicles()
+}
+
+// GetVehicle retrieves a vehicle by ID
+func (s *Service) GetVehicle(id int) (*models.Vehicle, error) {
+	vehicle, err := s.repo.GetVehicle(id)
+	if err != nil {
+		return nil, err
+	}
+	if vehicle == nil {
+		return nil, ErrVehicleNotFound
+	}
+	return vehicle, nil
+}
+
+// GetVehicleByPlate retrieves a vehicle by its plate in any spelling
+func (s *Service) GetVehicleByPlate(plate string) (*models.Vehicle, error) {
+	vehicle, err := s.repo.GetVehicleByPlate(NormalizePlate(plate))
+	if err != nil {
+		return nil, err
+	}
+	if vehicle == nil {
+		return nil, ErrVehicleNotFound
+	}
+	return vehicle, nil
+}
+
+// AvailableVehicles retrieves the vehicles on the line, the driver's own vehicles first
+func (s *Service) AvailableVehicles(driverID int64) ([]models.Vehicle, error) {
+	vehicles, err := s.repo.GetVehicles()
+	if err != nil {
+		return nil, err
+	}
+	var available []models.Vehicle
+	for _, vehicle := range vehicles {
+		if vehicle.Status == StatusActive {
+			available = append(available, vehicle)
+		}
+	}
+	sort.SliceStable(available, func(i, j int) bool {
+		return available[i].DriverID == driverID && available[j].DriverID != driverID
+	})
+	return available, nil
+}
+
+// DriverVehicles retrieves the vehicles a driver was last assigned to
+func (s *Service) DriverVehicles(driverID int64) ([]models.Vehicle, error) {
+	vehicles, err := s.repo.GetVehicles()
+	if err != nil {
+		return nil, err
+	}
+	var own []models.Vehicle
+	for _, vehicle := range vehicles {
+		if vehicle.DriverID == driverID && vehicle.Status != StatusRetired {
+			own = append(own, vehicle)
+		}
+	}
+	return own, nil
+}
+
+// SetStatus changes a vehicle's status
+func (s *Service) SetStatus(plate, status string) (*models.Vehicle, error) {
+	if _, ok := statusLabels[status]; !ok {
+		return nil, fmt.Errorf("unknown vehicle status: %s", status)
+	}
+	vehicle, err := s.GetVehicleByPlate(plate)
+	if err != nil {
+		return nil, err
+	}
+	vehicle.Status = status
+	vehicle.UpdatedAt = time.Now()
+	if err := s.repo.UpdateVehicle(vehicle); err != nil {
+		return nil, err
+	}
+	return vehicle, nil
+}
+
+// SetService schedules a vehicle's next maintenance by date, by mileage or both, keeping the part not given
+func (s *Service) SetService(plate string, due *time.Time, dueMileage int) (*models.Vehicle, error) {
+	if due == nil && dueMileage <= 0 {
+		return nil, errors.New("maintenance date or mileage is required")
+	}
+	vehicle, err := s.GetVehicleByPlate(plate)
+	if err != nil {
+		return nil, err
+	}
+	if due != nil {
+		vehicle.ServiceDueDate = due
+	}
+	if dueMileage > 0 {
+		vehicle.ServiceDueMileage = dueMileage
+	}
+	vehicle.UpdatedAt = time.Now()
+	if err := s.repo.UpdateVehicle(vehicle); err != nil {
+		return nil, err
+	}
+	return vehicle, nil
+}
+
+// RecordMileage stores a vehicle's odometer reading
+func (s *Service) RecordMileage(plate string, mileage int, userID int64) (*models.Vehicle, error) {
+	if mileage <= 0 {
+		return nil, errors.New("mileage must be positive")
+	}
+	vehicle, err := s.GetVehicleByPlate(plate)
+	if err != nil {
+		return nil, err
+	}
+	if mileage < vehicle.Mileage {
+		return nil, ErrMileageDecreased
+	}
+	now := time.Now()
+	if err := s.repo.RecordMileage(vehicle.ID, mileage, userID, now); err != nil {
+		return nil, err
+	}
+	vehicle.Mileage = mileage
+	vehicle.UpdatedAt = now
+	return vehicle, nil
+}
+
+// AssignVehicle links a vehicle to an order and its driver and returns warnings about the load and the vehicle's state
+func (s *Service) AssignVehicle(order *models.Order, vehicleID int, driverID int64) (*models.Vehicle, []string, error) {
+	vehicle, err := s.GetVehicle(vehicleID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if vehicle.Status != StatusActive {
+		return nil, nil, ErrVehicleUnavailable
+	}
+	now := time.Now()
+	if err := s.repo.AssignVehicle(order.ID, vehicle.ID, driverID, now); err != nil {
+		return nil, nil, err
+	}
+	order.VehicleID = vehicle.ID
+	if driverID != 0 {
+		vehicle.DriverID = driverID
+	}
+	return vehicle, CheckLoad(order, vehicle, now), nil
+}
+
+// CheckLoad lists the problems of carrying an order's load with a vehicle
+func CheckLoad(order *models.Order, vehicle *models.Vehicle, now time.Time) []string {
+	var warnings []string
+	if order.Volume > vehicle.CapacityM3 {
+		warnings = append(warnings, fmt.Sprintf("⚠️ Объём груза %g м³ больше вместимости машины %g м³", order.Volume, vehicle.CapacityM3))
+	}
+	if order.Weight > vehicle.CapacityTons {
+		warnings = append(warnings, fmt.Sprintf("⚠️ Вес груза %g т больше грузоподъёмности машины %g т", order.Weight, vehicle.CapacityTons))
+	}
+	if vehicle.Status != StatusActive {
+		warnings = append(warnings, fmt.Sprintf("⚠️ Машина %s", StatusLabel(vehicle.Status)))
+	}
+	if serviceOverdue(vehicle, now) {
+		warnings = append(warnings, "⚠️ Машина просрочила ТО")
+	}
+	return warnings
+}
+
+// serviceOverdue reports whether a vehicle's maintenance date or mileage has passed
+func serviceOverdue(vehicle *models.Vehicle, now time.Time) bool {
+	if vehicle.ServiceDueDate != nil && !now.Before(*vehicle.ServiceDueDate) {
+		return true
+	}
+	return vehicle.ServiceDueMileage > 0 && vehicle.Mileage >= vehicle.ServiceDueMileage
+}
+
+// Reminder is a maintenance reminder for a vehicle; Key identifies it so it is sent once
+type Reminder struct {
+	Key  string
+	Text string
+}
+
+// DueReminders lists the maintenance reminders a vehicle is due at now
+func (s *Service) DueReminders(vehicle *models.Vehicle, now time.Time) []Reminder {
+	if vehicle.Status == StatusRetired {
+		return nil
+	}
+	name := VehicleName(vehicle)
+	var reminders []Reminder
+	if due := vehicle.ServiceDueDate; due != nil {
+		key := "date:" + due.Format("2006-01-02")
+		switch {
+		case !now.Before(*due):
+			reminders = append(reminders, Reminder{
+				Key:  key + ":overdue",
+				Text: fmt.Sprintf("🔧 %s: ТО просрочено, срок был %s.", name, due.Format("02.01.2006")),
+			})
+		case due.Sub(now) <= s.cfg.ReminderLead:
+			reminders = append(reminders, Reminder{
+				Key:  key,
+				Text: fmt.Sprintf("🔧 %s: ТО до %s.", name, due.Format("02.01.2006")),
+			})
+		}
+	}
+	if dueMileage := vehicle.ServiceDueMileage; dueMileage > 0 {
+		key := "km:" + strconv.Itoa(dueMileage)
+		switch {
+		case vehicle.Mileage >= dueMileage:
+			reminders = append(reminders, Reminder{
+				Key:  key + ":overdue",
+				Text: fmt.Sprintf("🔧 %s: пробег %d км, ТО было нужно на %d км.", name, vehicle.Mileage, dueMileage),
+			})
+		case dueMileage-vehicle.Mileage <= s.cfg.ReminderMileage:
+			reminders = append(reminders, Reminder{
+				Key:  key,
+				Text: fmt.Sprintf("🔧 %s: пробег %d км, ТО на %d км — осталось %d км.", name, vehicle.Mileage, dueMileage, dueMileage-vehicle.Mileage),
+			})
+		}
+	}
+	return reminders
+}
+
+// ProcessReminders sends every due maintenance reminder that has not been sent yet
+func (s *Service) ProcessReminders() error {
+	vehicles, err := s.repo.GetVehicles()
+	if err != nil {
+		return err
+	}
+	// Recipients are fetched before any reminder is marked so a failure here loses nothing
+	chatIDs, err := s.repo.GetReminderChatIDs()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range vehicles {
+		vehicle := &vehicles[i]
+		for _, reminder := range s.DueReminders(vehicle, now) {
+			marked, err := s.repo.MarkReminderSent(vehicle.ID, reminder.Key, now)
+			if err != nil {
+				utils.LogError(err)
+				continue // Never send without a record
+			}
+			if !marked {
+				continue
+			}
+			recipients := append(append([]int64{}, chatIDs...), vehicle.DriverID)
+			s.send(recipients, reminder.Text)
+		}
+	}
+	return nil
+}
+
+// send delivers a message to every distinct chat
+func (s *Service) send(chatIDs []int64, text string) {
+	seen := make(map[int64]bool)
+	for _, chatID := range chatIDs {
+		if chatID == 0 || seen[chatID] {
+			continue
+		}
+		seen[chatID] = true
+		if _, err := s.bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+			utils.LogError(err)
+		}
+	}
+}
+
+// NormalizePlate uppercases a plate and drops spaces and dashes
+func NormalizePlate(plate string) string {
+	plate = strings.ToUpper(strings.TrimSpace(plate))
+	return strings.NewReplacer(" ", "", "-", "").Replace(plate)
+}
+
+// ParseType parses a vehicle type by its key or Russian name
+func ParseType(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for key, label := range typeLabels {
+		if s == key || s == strings.ToLower(label) {
+			return key, nil
+		}
+	}
+	return "", fmt.Errorf("unknown vehicle type: %s", s)
+}
+
+// ParseStatus parses a vehicle status by its key or Russian name
+func ParseStatus(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case StatusActive, "на линии", "линия", "работает":
+		return StatusActive, nil
+	case StatusRepair, "в ремонте", "ремонт":
+		return StatusRepair, nil
+	case StatusRetired, "списана", "списание":
+		return StatusRetired, nil
+	}
+	return "", fmt.Errorf("unknown vehicle status: %s", s)
+}
+
+// ParseVehicle parses "<plate> <type> <m³> <tonnes>"
+func ParseVehicle(fields []string) (*models.Vehicle, error) {
+	if len(fields) != 4 {
+		return nil, errors.New("expected plate, type, volume and weight capacity")
+	}
+	vehicleType, err := ParseType(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	capacityM3, err := parseAmount(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid volume capacity: %s", fields[2])
+	}
+	capacityTons, err := parseAmount(fields[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid weight capacity: %s", fields[3])
+	}
+	return &models.Vehicle{
+		Plate:        NormalizePlate(fields[0]),
+		Type:         vehicleType,
+		CapacityM3:   capacityM3,
+		CapacityTons: capacityTons,
+	}, nil
+}
+
+// ParseLoad parses an order's load as "<m³> [tonnes]", units optional
+func ParseLoad(text string) (volume, weight float64, err error) {
+	replacer := strings.NewReplacer("м³", " ", "м3", " ", "тонн", " ", "т", " ", ";", " ")
+	fields := strings.Fields(replacer.Replace(strings.ToLower(text)))
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, 0, errors.New("expected volume and optional weight")
+	}
+	if volume, err = parseAmount(fields[0]); err != nil {
+		return 0, 0, fmt.Errorf("invalid volume: %s", fields[0])
+	}
+	if len(fields) == 2 {
+		if weight, err = parseAmount(fields[1]); err != nil {
+			return 0, 0, fmt.Errorf("invalid weight: %s", fields[1])
+		}
+	}
+	return volume, weight, nil
+}
+
+// parseAmount parses a non-negative decimal with a dot or comma
+func parseAmount(s string) (float64, error) {
+	value, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid amount: %s", s)
+	}
+	return value, nil
+}
+
+// TypeLabel returns the Russian name of a vehicle type
+func TypeLabel(vehicleType string) string {
+	if label, ok := typeLabels[vehicleType]; ok {
+		return label
+	}
+	return vehicleType
+}
+
+// StatusLabel returns the Russian name of a vehicle status
+func StatusLabel(status string) string {
+	if label, ok := statusLabels[status]; ok {
+		return label
+	}
+	return status
+}
+
+// VehicleName renders a vehicle's type and plate
+func VehicleName(vehicle *models.Vehicle) string {
+	return fmt.Sprintf("%s %s", TypeLabel(vehicle.Type), vehicle.Plate)
+}
+
+// FormatVehicle renders a vehicle's capacity, status, mileage and maintenance
+func FormatVehicle(vehicle *models.Vehicle, now time.Time) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🚛 %s — %g м³, %g т, %s", VehicleName(vehicle), vehicle.CapacityM3, vehicle.CapacityTons, StatusLabel(vehicle.Status)))
+	sb.WriteString(fmt.Sprintf("\nПробег: %d км", vehicle.Mileage))
+	if vehicle.DriverID != 0 {
+		sb.WriteString(fmt.Sprintf("\nВодитель: ID %d", vehicle.DriverID))
+	}
+	var due []string
+	if vehicle.ServiceDueDate != nil {
+		due = append(due, "до "+vehicle.ServiceDueDate.Format("02.01.2006"))
+	}
+	if vehicle.ServiceDueMileage > 0 {
+		due = append(due, fmt.Sprintf("на %d км", vehicle.ServiceDueMileage))
+	}
+	if len(due) > 0 {
+		sb.WriteString("\nТО: " + strings.Join(due, " или "))
+		if serviceOverdue(vehicle, now) {
+			sb.WriteString(" ⚠️ просрочено")
+		}
+	}
+	return sb.String()
+}
+
+// FormatFleet renders the list of vehicles
+func FormatFleet(vehicles []models.Vehicle, now time.Time) string {
+	if len(vehicles) == 0 {
+		return "🚛 В автопарке пока нет машин."
+	}
+	parts := []string{fmt.Sprintf("🚛 Автопарк: %d", len(vehicles))}
+	for i := range vehicles {
+		parts = append(parts, FormatVehicle(&vehicles[i], now))
+	}
+	return strings.Join(parts, "\n\n")
+}
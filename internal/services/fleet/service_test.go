@@ -0,0 +1,202 @@
+package fleet_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/fleet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository is a mock implementation of fleet.Repository
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateVehicle(vehicle *models.Vehicle) error {
+	args := m.Called(vehicle)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetVehicles() ([]models.Vehicle, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Vehicle), args.Error(1)
+}
+
+func (m *MockRepository) GetVehicle(id int) (*models.Vehicle, error) {
+	args := m.Called(id)
+	vehicle, _ := args.Get(0).(*models.Vehicle)
+	return vehicle, args.Error(1)
+}
+
+func (m *MockRepository) GetVehicleByPlate(plate string) (*models.Vehicle, error) {
+	args := m.Called(plate)
+	vehicle, _ := args.Get(0).(*models.Vehicle)
+	return vehicle, args.Error(1)
+}
+
+func (m *MockRepository) UpdateVehicle(vehicle *models.Vehicle) error {
+	args := m.Called(vehicle)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RecordMileage(vehicleID, mileage int, userID int64, at time.Time) error {
+	args := m.Called(vehicleID, mileage, userID, at)
+	return args.Error(0)
+}
+
+func (m *MockRepository) AssignVehicle(orderID, vehicleID int, driverID int64, at time.Time) error {
+	args := m.Called(orderID, vehicleID, driverID, at)
+	return args.Error(0)
+}
+
+func (m *MockRepository) MarkReminderSent(vehicleID int, key string, sentAt time.Time) (bool, error) {
+	args := m.Called(vehicleID, key, sentAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetReminderChatIDs() ([]int64, error) {
+	args := m.Called()
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func TestAddVehicle(t *testing.T) {
+	repo := new(MockRepository)
+	service := fleet.NewService(nil, repo, fleet.Config{})
+
+	vehicle, err := fleet.ParseVehicle([]string{"а123вс-77", "Газель", "16", "1,5"})
+	assert.NoError(t, err)
+	assert.Equal(t, "А123ВС77", vehicle.Plate)
+	assert.Equal(t, fleet.TypeGazelle, vehicle.Type)
+	assert.Equal(t, 1.5, vehicle.CapacityTons)
+
+	repo.On("GetVehicleByPlate", "А123ВС77").Return(nil, nil).Once()
+	repo.On("CreateVehicle", vehicle).Return(nil)
+	assert.NoError(t, service.AddVehicle(vehicle))
+	assert.Equal(t, fleet.StatusActive, vehicle.Status)
+
+	repo.On("GetVehicleByPlate", "А123ВС77").Return(&models.Vehicle{ID: 1}, nil)
+	assert.Equal(t, fleet.ErrPlateExists, service.AddVehicle(&models.Vehicle{Plate: "А123ВС 77", Type: fleet.TypeGazelle, CapacityM3: 16, CapacityTons: 1.5}))
+	assert.Error(t, service.AddVehicle(&models.Vehicle{Plate: "В456ОР77", Type: "tractor", CapacityM3: 16, CapacityTons: 1.5}))
+
+	_, err = fleet.ParseVehicle([]string{"А123ВС77", "трактор", "16", "1.5"})
+	assert.Error(t, err)
+	repo.AssertNumberOfCalls(t, "CreateVehicle", 1)
+}
+
+func TestRecordMileageRejectsDecrease(t *testing.T) {
+	repo := new(MockRepository)
+	service := fleet.NewService(nil, repo, fleet.Config{})
+	repo.On("GetVehicleByPlate", "А123ВС77").Return(&models.Vehicle{ID: 1, Plate: "А123ВС77", Mileage: 120000}, nil)
+	repo.On("RecordMileage", 1, 120450, int64(7), mock.Anything).Return(nil)
+
+	_, err := service.RecordMileage("а123вс77", 119000, 7)
+	assert.Equal(t, fleet.ErrMileageDecreased, err)
+
+	vehicle, err := service.RecordMileage("а123вс77", 120450, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, 120450, vehicle.Mileage)
+	repo.AssertNumberOfCalls(t, "RecordMileage", 1)
+}
+
+func TestAssignVehicleWarnsAboutLoad(t *testing.T) {
+	repo := new(MockRepository)
+	service := fleet.NewService(nil, repo, fleet.Config{})
+	overdue := time.Now().Add(-24 * time.Hour)
+	repo.On("GetVehicle", 1).Return(&models.Vehicle{ID: 1, Plate: "А123ВС77", Type: fleet.TypeGazelle, CapacityM3: 16, CapacityTons: 1.5, Status: fleet.StatusActive, ServiceDueDate: &overdue}, nil)
+	repo.On("GetVehicle", 2).Return(&models.Vehicle{ID: 2, Status: fleet.StatusRepair}, nil)
+	repo.On("AssignVehicle", 10, 1, int64(5), mock.Anything).Return(nil)
+
+	order := &models.Order{ID: 10, Volume: 20, Weight: 1.2}
+	vehicle, warnings, err := service.AssignVehicle(order, 1, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), vehicle.DriverID)
+	assert.Equal(t, 1, order.VehicleID)
+	assert.Equal(t, []string{"⚠️ Объём груза 20 м³ больше вместимости машины 16 м³", "⚠️ Машина просрочила ТО"}, warnings)
+
+	_, _, err = service.AssignVehicle(order, 2, 5)
+	assert.Equal(t, fleet.ErrVehicleUnavailable, err)
+	repo.AssertNumberOfCalls(t, "AssignVehicle", 1)
+}
+
+func TestDueReminders(t *testing.T) {
+	service := fleet.NewService(nil, new(MockRepository), fleet.Config{ReminderLead: 72 * time.Hour, ReminderMileage: 500})
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	due := time.Date(2024, 5, 12, 0, 0, 0, 0, time.UTC)
+	vehicle := &models.Vehicle{ID: 1, Plate: "А123ВС77", Type: fleet.TypeDumpTruck, Status: fleet.StatusActive,
+		Mileage: 149600, ServiceDueDate: &due, ServiceDueMileage: 150000}
+
+	reminders := service.DueReminders(vehicle, now)
+	assert.Len(t, reminders, 2)
+	assert.Equal(t, "date:2024-05-12", reminders[0].Key)
+	assert.Equal(t, "🔧 Самосвал А123ВС77: ТО до 12.05.2024.", reminders[0].Text)
+	assert.Equal(t, "km:150000", reminders[1].Key)
+	assert.Contains(t, reminders[1].Text, "осталось 400 км")
+
+	// Overdue maintenance gets its own reminder
+	vehicle.Mileage = 150100
+	reminders = service.DueReminders(vehicle, due)
+	assert.Equal(t, "date:2024-05-12:overdue", reminders[0].Key)
+	assert.Equal(t, "km:150000:overdue", reminders[1].Key)
+
+	// Far from maintenance or retired vehicles get none
+	vehicle.Mileage = 100000
+	assert.Empty(t, service.DueReminders(vehicle, now.AddDate(0, 0, -10)))
+	vehicle.Status = fleet.StatusRetired
+	assert.Empty(t, service.DueReminders(vehicle, due))
+}
+
+func TestSetServiceKeepsPartNotGiven(t *testing.T) {
+	repo := new(MockRepository)
+	service := fleet.NewService(nil, repo, fleet.Config{})
+	due := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		repo.On("GetVehicleByPlate", "А123ВС77").Return(&models.Vehicle{ID: 1, Plate: "А123ВС77", ServiceDueDate: &due, ServiceDueMileage: 150000}, nil).Once()
+	}
+	repo.On("UpdateVehicle", mock.AnythingOfType("*models.Vehicle")).Return(nil)
+
+	vehicle, err := service.SetService("А123ВС77", nil, 160000)
+	assert.NoError(t, err)
+	assert.Equal(t, &due, vehicle.ServiceDueDate)
+	assert.Equal(t, 160000, vehicle.ServiceDueMileage)
+
+	next := due.AddDate(0, 6, 0)
+	vehicle, err = service.SetService("А123ВС77", &next, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, &next, vehicle.ServiceDueDate)
+	assert.Equal(t, 150000, vehicle.ServiceDueMileage)
+
+	_, err = service.SetService("А123ВС77", nil, 0)
+	assert.Error(t, err)
+}
+
+func TestProcessRemindersFetchesRecipientsFirst(t *testing.T) {
+	repo := new(MockRepository)
+	service := fleet.NewService(nil, repo, fleet.Config{ReminderLead: 72 * time.Hour})
+	overdue := time.Now().Add(-24 * time.Hour)
+	repo.On("GetVehicles").Return([]models.Vehicle{{ID: 1, Plate: "А123ВС77", Status: fleet.StatusActive, ServiceDueDate: &overdue}}, nil)
+	repo.On("GetReminderChatIDs").Return([]int64(nil), errors.New("db error"))
+
+	// A reminder marked sent without recipients would never be delivered
+	assert.Error(t, service.ProcessReminders())
+	repo.AssertNotCalled(t, "MarkReminderSent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestParseLoad(t *testing.T) {
+	volume, weight, err := fleet.ParseLoad("12 м³ 1,5 т")
+	assert.NoError(t, err)
+	assert.Equal(t, 12.0, volume)
+	assert.Equal(t, 1.5, weight)
+
+	volume, weight, err = fleet.ParseLoad("8.5")
+	assert.NoError(t, err)
+	assert.Equal(t, 8.5, volume)
+	assert.Zero(t, weight)
+
+	_, _, err = fleet.ParseLoad("много")
+	assert.Error(t, err)
+	_, _, err = fleet.ParseLoad("-3")
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// earthRadius is the mean Earth radius in meters
+const earthRadius = 6371000
+
+// ErrOutsideServiceArea is returned for points that are not inside any service zone
+var ErrOutsideServiceArea = errors.New("point is outside the service area")
+
+// Point is a WGS 84 coordinate
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Valid reports whether the coordinate is within the latitude and longitude ranges and not the zero point
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180 && (p.Lat != 0 || p.Lon != 0)
+}
+
+// ParsePoint reads a "lat,lon" pair such as "55.751244,37.618423"
+func ParsePoint(value string) (Point, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return Point{}, fmt.Errorf("invalid point %q: expected lat,lon", value)
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lon, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	p := Point{Lat: lat, Lon: lon}
+	if err1 != nil || err2 != nil || !p.Valid() {
+		return Point{}, fmt.Errorf("invalid point %q", value)
+	}
+	return p, nil
+}
+
+// Distance returns the great-circle distance between two points in meters
+func Distance(a, b Point) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Zone is a service-area polygon with its delivery surcharge
+type Zone struct {
+	Name      string  `json:"name"`
+	Surcharge float64 `json:"surcharge"`
+	Polygon   []Point `json:"polygon"`
+}
+
+// ServiceArea is the set of zones the business serves; zones are matched in order,
+// so inner zones with a lower surcharge go before the outer ones
+type ServiceArea struct {
+	Zones []Zone `json:"zones"`
+}
+
+// LoadServiceArea reads service zones from a JSON file:
+// {"zones": [{"name": "Город", "surcharge": 0, "polygon": [{"lat": 55.9, "lon": 37.3}, ...]}]}
+func LoadServiceArea(path string) (*ServiceArea, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service area: %v", err)
+	}
+	var area ServiceArea
+	if err := json.Unmarshal(data, &area); err != nil {
+		return nil, fmt.Errorf("failed to parse service area: %v", err)
+	}
+	if err := area.Validate(); err != nil {
+		return nil, err
+	}
+	return &area, nil
+}
+
+// Validate checks that every zone is a named polygon of valid points with a non-negative surcharge
+func (a *ServiceArea) Validate() error {
+	for i, zone := range a.Zones {
+		if zone.Name == "" {
+			return fmt.Errorf("zone %d has no name", i+1)
+		}
+		if zone.Surcharge < 0 {
+			return fmt.Errorf("zone %s has a negative surcharge", zone.Name)
+		}
+		if len(zone.Polygon) < 3 {
+			return fmt.Errorf("zone %s needs at least 3 points", zone.Name)
+		}
+		for _, p := range zone.Polygon {
+			if !p.Valid() {
+				return fmt.Errorf("zone %s has an invalid point %.6f, %.6f", zone.Name, p.Lat, p.Lon)
+			}
+		}
+	}
+	return nil
+}
+
+// Locate returns the first zone containing p; without configured zones every point is served with no zone
+func (a *ServiceArea) Locate(p Point) (*Zone, error) {
+	if !p.Valid() {
+		return nil, errors.New("invalid coordinates")
+	}
+	if a == nil || len(a.Zones) == 0 {
+		return nil, nil
+	}
+	for i := range a.Zones {
+		if Contains(a.Zones[i].Polygon, p) {
+			return &a.Zones[i], nil
+		}
+	}
+	return nil, ErrOutsideServiceArea
+}
+
+// Contains reports whether p lies inside the polygon using ray casting;
+// polygons are small enough for latitude and longitude to be treated as plane coordinates
+func Contains(polygon []Point, p Point) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// MapLink returns a Yandex Maps link to a point
+func MapLink(p Point) string {
+	return fmt.Sprintf("https://yandex.ru/maps/?pt=%.6f,%.6f&z=17&l=map", p.Lon, p.Lat)
+}
+
+// AddressLink returns a Yandex Maps link to the point when it is known, or a search for the address text
+func AddressLink(p Point, address string) string {
+	if p.Valid() {
+		return MapLink(p)
+	}
+	return "https://yandex.ru/maps/?text=" + url.QueryEscape(address)
+}
+
+// RouteLink returns a Yandex Maps driving route through the points in order
+func RouteLink(points []Point) string {
+	stops := make([]string, len(points))
+	for i, p := range points {
+		stops[i] = fmt.Sprintf("%.6f,%.6f", p.Lat, p.Lon)
+	}
+	return "https://yandex.ru/maps/?rtext=" + strings.Join(stops, "~") + "&rtt=auto"
+}
@@ -0,0 +1,105 @@
+package geo_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"github.com/skyzeper/telegram-bot/internal/services/geo"
+	"github.com/stretchr/testify/assert"
+)
+
+// square returns a square polygon around a center
+func square(lat, lon, half float64) []geo.Point {
+	return []geo.Point{
+		{Lat: lat - half, Lon: lon - half},
+		{Lat: lat - half, Lon: lon + half},
+		{Lat: lat + half, Lon: lon + half},
+		{Lat: lat + half, Lon: lon - half},
+	}
+}
+
+func TestLocate(t *testing.T) {
+	area := &geo.ServiceArea{Zones: []geo.Zone{
+		{Name: "Центр", Surcharge: 0, Polygon: square(55.75, 37.62, 0.1)},
+		{Name: "Область", Surcharge: 1500, Polygon: square(55.75, 37.62, 1)},
+	}}
+	assert.NoError(t, area.Validate())
+
+	zone, err := area.Locate(geo.Point{Lat: 55.76, Lon: 37.61})
+	assert.NoError(t, err)
+	assert.Equal(t, "Центр", zone.Name)
+
+	zone, err = area.Locate(geo.Point{Lat: 56.2, Lon: 37.9})
+	assert.NoError(t, err)
+	assert.Equal(t, "Область", zone.Name)
+	assert.Equal(t, 1500.0, zone.Surcharge)
+
+	_, err = area.Locate(geo.Point{Lat: 59.93, Lon: 30.31})
+	assert.ErrorIs(t, err, geo.ErrOutsideServiceArea)
+
+	_, err = area.Locate(geo.Point{})
+	assert.Error(t, err)
+
+	// Without zones every valid point is served
+	zone, err = (*geo.ServiceArea)(nil).Locate(geo.Point{Lat: 59.93, Lon: 30.31})
+	assert.NoError(t, err)
+	assert.Nil(t, zone)
+}
+
+func TestContainsConcavePolygon(t *testing.T) {
+	// A "U" shape: the notch between the arms is outside
+	u := []geo.Point{
+		{Lat: 0, Lon: 0}, {Lat: 0, Lon: 3}, {Lat: 3, Lon: 3}, {Lat: 3, Lon: 2},
+		{Lat: 1, Lon: 2}, {Lat: 1, Lon: 1}, {Lat: 3, Lon: 1}, {Lat: 3, Lon: 0},
+	}
+	assert.True(t, geo.Contains(u, geo.Point{Lat: 2, Lon: 0.5}))
+	assert.True(t, geo.Contains(u, geo.Point{Lat: 0.5, Lon: 1.5}))
+	assert.False(t, geo.Contains(u, geo.Point{Lat: 2, Lon: 1.5}))
+}
+
+func TestLoadServiceArea(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "zones.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"zones": [{"name": "Город", "surcharge": 500,
+		"polygon": [{"lat": 55, "lon": 37}, {"lat": 55, "lon": 38}, {"lat": 56, "lon": 38}]}]}`), 0644))
+
+	area, err := geo.LoadServiceArea(path)
+	assert.NoError(t, err)
+	assert.Len(t, area.Zones, 1)
+	assert.Equal(t, 500.0, area.Zones[0].Surcharge)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"zones": [{"name": "Линия", "polygon": [{"lat": 55, "lon": 37}, {"lat": 56, "lon": 38}]}]}`), 0644))
+	_, err = geo.LoadServiceArea(path)
+	assert.Error(t, err)
+}
+
+func TestMapLinks(t *testing.T) {
+	p := geo.Point{Lat: 55.751244, Lon: 37.618423}
+	assert.Equal(t, "https://yandex.ru/maps/?pt=37.618423,55.751244&z=17&l=map", geo.MapLink(p))
+	assert.Equal(t, geo.MapLink(p), geo.AddressLink(p, "Москва"))
+	assert.Equal(t, "https://yandex.ru/maps/?text=%D0%9C%D0%BE%D1%81%D0%BA%D0%B2%D0%B0%2C+%D0%A2%D0%B2%D0%B5%D1%80%D1%81%D0%BA%D0%B0%D1%8F+1",
+		geo.AddressLink(geo.Point{}, "Москва, Тверская 1"))
+}
+
+func TestDistance(t *testing.T) {
+	moscow := geo.Point{Lat: 55.7558, Lon: 37.6173}
+	petersburg := geo.Point{Lat: 59.9343, Lon: 30.3351}
+	assert.InDelta(t, 634000, geo.Distance(moscow, petersburg), 2000)
+	assert.Equal(t, 0.0, geo.Distance(moscow, moscow))
+}
+
+func TestParsePoint(t *testing.T) {
+	p, err := geo.ParsePoint("55.751244, 37.618423")
+	assert.NoError(t, err)
+	assert.Equal(t, geo.Point{Lat: 55.751244, Lon: 37.618423}, p)
+
+	_, err = geo.ParsePoint("55.75")
+	assert.Error(t, err)
+	_, err = geo.ParsePoint("95,37")
+	assert.Error(t, err)
+}
+
+func TestRouteLink(t *testing.T) {
+	link := geo.RouteLink([]geo.Point{{Lat: 55.75, Lon: 37.61}, {Lat: 55.76, Lon: 37.62}})
+	assert.Equal(t, "https://yandex.ru/maps/?rtext=55.750000,37.610000~55.760000,37.620000&rtt=auto", link)
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/geo"
 )
 
 // Service handles notification-related business logic
@@ -57,9 +58,12 @@ func (s *Service) SendOrderNotification(userID int64, order *models.Order, event
 			"> **Заказ #%d назначен!** 👷\n"+
 				"> Категория: %s (%s)\n"+
 				"> Адрес: %s\n"+
+				"> Карта: %s\n"+
 				"> Дата: %s\n"+
 				"> Подтвердите выполнение после завершения.",
-			order.ID, order.Category, order.Subcategory, order.Address, order.Date.Format("2 January 2006"),
+			order.ID, order.Category, order.Subcategory, order.Address,
+			geo.AddressLink(geo.Point{Lat: order.Latitude, Lon: order.Longitude}, order.Address),
+			order.Date.Format("2 January 2006"),
 		)
 	default:
 		return fmt.Errorf("unknown order event: %s", event)
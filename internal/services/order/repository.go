@@ -24,8 +24,9 @@ func (r *PostgresRepository) CreateOrder(order *models.Order) error {
 		INSERT INTO orders (
 			user_id, category, subcategory, photos, video, date, time, phone, address, 
 			description, status, reason, cost, payment_method, payment_confirmed, 
-			created_at, updated_at, confirmed
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+			created_at, updated_at, confirmed, latitude, longitude, zone, zone_surcharge,
+			volume, weight
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)
 		RETURNING id
 	`
 	var date, timeVal sql.NullTime
@@ -50,12 +51,14 @@ func (r *PostgresRepository) CreateOrder(order *models.Order) error {
 		paymentMethod.Valid = true
 		paymentMethod.String = order.PaymentMethod
 	}
+	lat, lon, zone := locationArgs(order)
 	err := r.db.Conn().QueryRow(
 		query,
 		order.UserID, order.Category, order.Subcategory, order.Photos, video,
 		date, timeVal, order.Phone, order.Address, order.Description,
 		order.Status, reason, order.Cost, paymentMethod, order.PaymentConfirmed,
 		order.CreatedAt, order.UpdatedAt, order.Confirmed,
+		lat, lon, zone, order.ZoneSurcharge, order.Volume, order.Weight,
 	).Scan(&order.ID)
 	if err != nil {
 		utils.LogError(err)
@@ -69,18 +72,23 @@ func (r *PostgresRepository) GetOrder(id int) (*models.Order, error) {
 	query := `
 		SELECT id, user_id, category, subcategory, photos, video, date, time, phone, 
 		       address, description, status, reason, cost, payment_method, payment_confirmed, 
-		       created_at, updated_at, confirmed
+		       created_at, updated_at, confirmed, latitude, longitude, zone, zone_surcharge,
+		       volume, weight, vehicle_id
 		FROM orders
 		WHERE id = $1
 	`
 	order := &models.Order{}
 	var date, timeVal sql.NullTime
 	var video, reason, paymentMethod sql.NullString
+	var lat, lon sql.NullFloat64
+	var zone sql.NullString
+	var vehicleID sql.NullInt64
 	err := r.db.Conn().QueryRow(query, id).Scan(
 		&order.ID, &order.UserID, &order.Category, &order.Subcategory, &order.Photos,
 		&video, &date, &timeVal, &order.Phone, &order.Address,
 		&order.Description, &order.Status, &reason, &order.Cost, &paymentMethod,
 		&order.PaymentConfirmed, &order.CreatedAt, &order.UpdatedAt, &order.Confirmed,
+		&lat, &lon, &zone, &order.ZoneSurcharge, &order.Volume, &order.Weight, &vehicleID,
 	)
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("order not found")
@@ -104,6 +112,10 @@ func (r *PostgresRepository) GetOrder(id int) (*models.Order, error) {
 	if paymentMethod.Valid {
 		order.PaymentMethod = paymentMethod.String
 	}
+	setLocation(order, lat, lon, zone)
+	if vehicleID.Valid {
+		order.VehicleID = int(vehicleID.Int64)
+	}
 
 	// Fetch executors
 	executors, err := r.getExecutors(id)
@@ -120,7 +132,8 @@ func (r *PostgresRepository) GetOrdersByStatus(status string) ([]models.Order, e
 	query := `
 		SELECT id, user_id, category, subcategory, photos, video, date, time, phone, 
 		       address, description, status, reason, cost, payment_method, payment_confirmed, 
-		       created_at, updated_at, confirmed
+		       created_at, updated_at, confirmed, latitude, longitude, zone, zone_surcharge,
+		       volume, weight, vehicle_id
 		FROM orders
 		WHERE status = $1
 	`
@@ -136,11 +149,15 @@ func (r *PostgresRepository) GetOrdersByStatus(status string) ([]models.Order, e
 		var order models.Order
 		var date, timeVal sql.NullTime
 		var video, reason, paymentMethod sql.NullString
+		var lat, lon sql.NullFloat64
+		var zone sql.NullString
+		var vehicleID sql.NullInt64
 		if err := rows.Scan(
 			&order.ID, &order.UserID, &order.Category, &order.Subcategory, &order.Photos,
 			&video, &date, &timeVal, &order.Phone, &order.Address,
 			&order.Description, &order.Status, &reason, &order.Cost, &paymentMethod,
 			&order.PaymentConfirmed, &order.CreatedAt, &order.UpdatedAt, &order.Confirmed,
+			&lat, &lon, &zone, &order.ZoneSurcharge, &order.Volume, &order.Weight, &vehicleID,
 		); err != nil {
 			utils.LogError(err)
 			continue
@@ -160,6 +177,10 @@ func (r *PostgresRepository) GetOrdersByStatus(status string) ([]models.Order, e
 		if paymentMethod.Valid {
 			order.PaymentMethod = paymentMethod.String
 		}
+		setLocation(&order, lat, lon, zone)
+		if vehicleID.Valid {
+			order.VehicleID = int(vehicleID.Int64)
+		}
 		// Fetch executors
 		executors, err := r.getExecutors(order.ID)
 		if err != nil {
@@ -176,7 +197,8 @@ func (r *PostgresRepository) GetOrdersByStatusAndCategory(status, category strin
 	query := `
 		SELECT id, user_id, category, subcategory, photos, video, date, time, phone, 
 		       address, description, status, reason, cost, payment_method, payment_confirmed, 
-		       created_at, updated_at, confirmed
+		       created_at, updated_at, confirmed, latitude, longitude, zone, zone_surcharge,
+		       volume, weight, vehicle_id
 		FROM orders
 		WHERE status = $1 AND category = $2
 	`
@@ -192,11 +214,15 @@ func (r *PostgresRepository) GetOrdersByStatusAndCategory(status, category strin
 		var order models.Order
 		var date, timeVal sql.NullTime
 		var video, reason, paymentMethod sql.NullString
+		var lat, lon sql.NullFloat64
+		var zone sql.NullString
+		var vehicleID sql.NullInt64
 		if err := rows.Scan(
 			&order.ID, &order.UserID, &order.Category, &order.Subcategory, &order.Photos,
 			&video, &date, &timeVal, &order.Phone, &order.Address,
 			&order.Description, &order.Status, &reason, &order.Cost, &paymentMethod,
 			&order.PaymentConfirmed, &order.CreatedAt, &order.UpdatedAt, &order.Confirmed,
+			&lat, &lon, &zone, &order.ZoneSurcharge, &order.Volume, &order.Weight, &vehicleID,
 		); err != nil {
 			utils.LogError(err)
 			continue
@@ -216,6 +242,10 @@ func (r *PostgresRepository) GetOrdersByStatusAndCategory(status, category strin
 		if paymentMethod.Valid {
 			order.PaymentMethod = paymentMethod.String
 		}
+		setLocation(&order, lat, lon, zone)
+		if vehicleID.Valid {
+			order.VehicleID = int(vehicleID.Int64)
+		}
 		// Fetch executors
 		executors, err := r.getExecutors(order.ID)
 		if err != nil {
@@ -232,7 +262,8 @@ func (r *PostgresRepository) GetExecutorOrders(userID int64) ([]models.Order, er
 	query := `
 		SELECT o.id, o.user_id, o.category, o.subcategory, o.photos, o.video, o.date, o.time, 
 		       o.phone, o.address, o.description, o.status, o.reason, o.cost, o.payment_method, 
-		       o.payment_confirmed, o.created_at, o.updated_at, o.confirmed
+		       o.payment_confirmed, o.created_at, o.updated_at, o.confirmed,
+		       o.latitude, o.longitude, o.zone, o.zone_surcharge, o.volume, o.weight, o.vehicle_id
 		FROM orders o
 		JOIN executors e ON o.id = e.order_id
 		WHERE e.user_id = $1
@@ -249,11 +280,15 @@ func (r *PostgresRepository) GetExecutorOrders(userID int64) ([]models.Order, er
 		var order models.Order
 		var date, timeVal sql.NullTime
 		var video, reason, paymentMethod sql.NullString
+		var lat, lon sql.NullFloat64
+		var zone sql.NullString
+		var vehicleID sql.NullInt64
 		if err := rows.Scan(
 			&order.ID, &order.UserID, &order.Category, &order.Subcategory, &order.Photos,
 			&video, &date, &timeVal, &order.Phone, &order.Address,
 			&order.Description, &order.Status, &reason, &order.Cost, &paymentMethod,
 			&order.PaymentConfirmed, &order.CreatedAt, &order.UpdatedAt, &order.Confirmed,
+			&lat, &lon, &zone, &order.ZoneSurcharge, &order.Volume, &order.Weight, &vehicleID,
 		); err != nil {
 			utils.LogError(err)
 			continue
@@ -273,6 +308,10 @@ func (r *PostgresRepository) GetExecutorOrders(userID int64) ([]models.Order, er
 		if paymentMethod.Valid {
 			order.PaymentMethod = paymentMethod.String
 		}
+		setLocation(&order, lat, lon, zone)
+		if vehicleID.Valid {
+			order.VehicleID = int(vehicleID.Int64)
+		}
 		// Fetch executors
 		executors, err := r.getExecutors(order.ID)
 		if err != nil {
@@ -306,8 +345,11 @@ func (r *PostgresRepository) UpdateOrder(order *models.Order) error {
 		SET user_id = $1, category = $2, subcategory = $3, photos = $4, video = $5, 
 		    date = $6, time = $7, phone = $8, address = $9, description = $10, 
 		    status = $11, reason = $12, cost = $13, payment_method = $14, 
-		    payment_confirmed = $15, created_at = $16, updated_at = $17, confirmed = $18
-		WHERE id = $19
+		    payment_confirmed = $15, created_at = $16, updated_at = $17, confirmed = $18,
+		    latitude = $19, longitude = $20, zone = $21, zone_surcharge = $22,
+		    volume = $23, weight = $24,
+		    completed_at = CASE WHEN $11 = 'completed' THEN COALESCE(completed_at, $17) ELSE completed_at END
+		WHERE id = $25
 	`
 	var date, timeVal sql.NullTime
 	var video, reason, paymentMethod sql.NullString
@@ -331,12 +373,14 @@ func (r *PostgresRepository) UpdateOrder(order *models.Order) error {
 		paymentMethod.Valid = true
 		paymentMethod.String = order.PaymentMethod
 	}
+	lat, lon, zone := locationArgs(order)
 	_, err := r.db.Conn().Exec(
 		query,
 		order.UserID, order.Category, order.Subcategory, order.Photos, video,
 		date, timeVal, order.Phone, order.Address, order.Description,
 		order.Status, reason, order.Cost, paymentMethod, order.PaymentConfirmed,
-		order.CreatedAt, order.UpdatedAt, order.Confirmed, order.ID,
+		order.CreatedAt, order.UpdatedAt, order.Confirmed,
+		lat, lon, zone, order.ZoneSurcharge, order.Volume, order.Weight, order.ID,
 	)
 	if err != nil {
 		utils.LogError(err)
@@ -375,7 +419,7 @@ func (r *PostgresRepository) ConfirmOrder(orderID int, userID int64) error {
 	if total > 0 && total == confirmed {
 		query = `
 			UPDATE orders
-			SET status = 'completed', confirmed = TRUE
+			SET status = 'completed', confirmed = TRUE, updated_at = NOW(), completed_at = COALESCE(completed_at, NOW())
 			WHERE id = $1
 		`
 		_, err = r.db.Conn().Exec(query, orderID)
@@ -413,4 +457,27 @@ func (r *PostgresRepository) getExecutors(orderID int) ([]models.Executor, error
 		executors = append(executors, exec)
 	}
 	return executors, nil
+}
+
+// locationArgs returns the nullable coordinate and zone columns of an order
+func locationArgs(order *models.Order) (lat, lon sql.NullFloat64, zone sql.NullString) {
+	if order.HasLocation() {
+		lat = sql.NullFloat64{Float64: order.Latitude, Valid: true}
+		lon = sql.NullFloat64{Float64: order.Longitude, Valid: true}
+	}
+	if order.Zone != "" {
+		zone = sql.NullString{String: order.Zone, Valid: true}
+	}
+	return lat, lon, zone
+}
+
+// setLocation copies scanned coordinate and zone columns into an order
+func setLocation(order *models.Order, lat, lon sql.NullFloat64, zone sql.NullString) {
+	if lat.Valid && lon.Valid {
+		order.Latitude = lat.Float64
+		order.Longitude = lon.Float64
+	}
+	if zone.Valid {
+		order.Zone = zone.String
+	}
 }
\ No newline at end of file
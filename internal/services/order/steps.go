@@ -7,6 +7,7 @@ import (
 	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/skyzeper/telegram-bot/internal/menus"
 	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/geo"
 	"github.com/skyzeper/telegram-bot/internal/state"
 	"github.com/skyzeper/telegram-bot/internal/utils"
 )
@@ -17,15 +18,17 @@ type StepHandler struct {
 	menus   *menus.MenuGenerator
 	service *Service
 	state   *state.Manager
+	area    *geo.ServiceArea
 }
 
-// NewStepHandler creates a new StepHandler
-func NewStepHandler(bot *tgbotapi.BotAPI, menus *menus.MenuGenerator, service *Service, state *state.Manager) *StepHandler {
+// NewStepHandler creates a new StepHandler; a nil area accepts addresses anywhere
+func NewStepHandler(bot *tgbotapi.BotAPI, menus *menus.MenuGenerator, service *Service, state *state.Manager, area *geo.ServiceArea) *StepHandler {
 	return &StepHandler{
 		bot:     bot,
 		menus:   menus,
 		service: service,
 		state:   state,
+		area:    area,
 	}
 }
 
@@ -240,19 +243,59 @@ func (h *StepHandler) handlePhoneStep(update *tgbotapi.Update) {
 	currentState.Data["phone"] = phone
 	currentState.Step = 8
 	h.state.Set(chatID, currentState)
-	h.sendStepMessage(chatID, "📍 Введите адрес:", nil)
+	h.sendStepMessage(chatID, "📍 Введите адрес или отправьте геолокацию:", h.menus.LocationMenu())
 }
 
-// handleAddressStep handles the address input step
+// handleAddressStep handles the address input step; the client may type the address,
+// share a location or pick a venue
 func (h *StepHandler) handleAddressStep(update *tgbotapi.Update) {
 	chatID := update.Message.Chat.ID
-	if update.Message.Text == "" {
-		h.sendStepMessage(chatID, "📍 Введите адрес:", nil)
+	address := update.Message.Text
+	var point geo.Point
+	switch {
+	case update.Message.Venue != nil:
+		venue := update.Message.Venue
+		point = geo.Point{Lat: venue.Location.Latitude, Lon: venue.Location.Longitude}
+		address = venue.Title
+		if venue.Address != "" {
+			address += ", " + venue.Address
+		}
+	case update.Message.Location != nil:
+		point = geo.Point{Lat: update.Message.Location.Latitude, Lon: update.Message.Location.Longitude}
+		address = fmt.Sprintf("Геолокация: %.6f, %.6f", point.Lat, point.Lon)
+	}
+	if address == "" {
+		h.sendStepMessage(chatID, "📍 Введите адрес или отправьте геолокацию:", h.menus.LocationMenu())
 		return
 	}
 
 	currentState := h.state.Get(chatID)
-	currentState.Data["address"] = update.Message.Text
+	currentState.Data["latitude"] = 0.0
+	currentState.Data["longitude"] = 0.0
+	currentState.Data["zone"] = ""
+	currentState.Data["zone_surcharge"] = 0.0
+	if point.Valid() {
+		zone, err := h.area.Locate(point)
+		if err == geo.ErrOutsideServiceArea {
+			h.sendStepMessage(chatID, "❌ К сожалению, этот адрес вне зоны обслуживания. Укажите другой адрес:", h.menus.LocationMenu())
+			return
+		}
+		if err != nil {
+			h.sendStepMessage(chatID, "❌ Не удалось распознать геолокацию. Введите адрес текстом:", h.menus.LocationMenu())
+			return
+		}
+		currentState.Data["latitude"] = point.Lat
+		currentState.Data["longitude"] = point.Lon
+		if zone != nil {
+			currentState.Data["zone"] = zone.Name
+			currentState.Data["zone_surcharge"] = zone.Surcharge
+			if zone.Surcharge > 0 {
+				h.sendStepMessage(chatID, fmt.Sprintf("🚚 Адрес в зоне «%s»: доплата за выезд %.2f руб.", zone.Name, zone.Surcharge), nil)
+			}
+		}
+	}
+
+	currentState.Data["address"] = address
 	currentState.Step = 9
 	h.state.Set(chatID, currentState)
 	h.sendStepMessage(chatID, "💬 Введите описание заказа (или пропустите):", h.menus.SkipMenu())
@@ -282,7 +325,8 @@ func (h *StepHandler) handlePaymentMethodStep(update *tgbotapi.Update) {
 		return
 	}
 
-	paymentMethod := strings.ToLower(update.Message.Text)
+	// Menu buttons carry an emoji prefix ("💳 Карта"), typed answers do not
+	paymentMethod := strings.ToLower(strings.TrimSpace(strings.TrimLeft(update.Message.Text, "💵💳 ")))
 	if paymentMethod != "наличные" && paymentMethod != "карта" {
 		h.sendStepMessage(chatID, "❌ Неверный способ оплаты. Выберите из предложенных:", h.menus.PaymentMenu())
 		return
@@ -329,6 +373,10 @@ func (h *StepHandler) handleConfirmationStep(update *tgbotapi.Update) {
 		Description:   currentState.Data["description"].(string),
 		PaymentMethod: currentState.Data["payment_method"].(string),
 	}
+	order.Latitude, _ = currentState.Data["latitude"].(float64)
+	order.Longitude, _ = currentState.Data["longitude"].(float64)
+	order.Zone, _ = currentState.Data["zone"].(string)
+	order.ZoneSurcharge, _ = currentState.Data["zone_surcharge"].(float64)
 
 	if err := h.service.CreateOrder(order); err != nil {
 		user := &models.User{ChatID: chatID}
@@ -348,7 +396,7 @@ func (h *StepHandler) handleConfirmationStep(update *tgbotapi.Update) {
 
 // generateOrderSummary creates a summary of the order
 func (h *StepHandler) generateOrderSummary(data map[string]interface{}) string {
-	return fmt.Sprintf(
+	summary := fmt.Sprintf(
 		"> **Детали заказа** 🚛\n"+
 			"> Категория: %s (%s)\n"+
 			"> Дата: %s\n"+
@@ -360,6 +408,13 @@ func (h *StepHandler) generateOrderSummary(data map[string]interface{}) string {
 		data["category"], data["subcategory"], data["date"], data["time"],
 		data["phone"], data["address"], data["description"], data["payment_method"],
 	)
+	if zone, _ := data["zone"].(string); zone != "" {
+		summary += fmt.Sprintf("\n> Зона: %s", zone)
+		if surcharge, _ := data["zone_surcharge"].(float64); surcharge > 0 {
+			summary += fmt.Sprintf(" (доплата %.2f руб.)", surcharge)
+		}
+	}
+	return summary
 }
 
 // convertToStringSlice converts interface to string slice
@@ -36,7 +36,7 @@ func TestStepHandler_CategoryStep(t *testing.T) {
 	menus := menus.NewMenuGenerator()
 	stateManager := state.NewManager()
 
-	handler := order.NewStepHandler(mockBot, menus, service, stateManager)
+	handler := order.NewStepHandler(mockBot, menus, service, stateManager, nil)
 
 	t.Run("ValidCategory", func(t *testing.T) {
 		update := &tgbotapi.Update{
@@ -94,7 +94,7 @@ func TestStepHandler_ConfirmationStep(t *testing.T) {
 	menus := menus.NewMenuGenerator()
 	stateManager := state.NewManager()
 
-	handler := order.NewStepHandler(mockBot, menus, service, stateManager)
+	handler := order.NewStepHandler(mockBot, menus, service, stateManager, nil)
 
 	t.Run("ValidConfirmation", func(t *testing.T) {
 		update := &tgbotapi.Update{
@@ -0,0 +1,197 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/accounting"
+)
+
+// OrderBalance summarises confirmed money movements of an order
+type OrderBalance struct {
+	Cost     float64
+	Paid     float64
+	Refunded float64
+	Due      float64 // cost − paid + refunded; negative means the client overpaid
+}
+
+// FullyPaid reports whether nothing is left to pay
+func (b OrderBalance) FullyPaid() bool {
+	return b.Due <= 0.005
+}
+
+// GetOrderBalance computes the order balance from confirmed payments and refunds
+func (s *Service) GetOrderBalance(order *models.Order) (OrderBalance, error) {
+	if order == nil || order.ID <= 0 {
+		return OrderBalance{}, errors.New("invalid order")
+	}
+	paid, refunded, err := s.repo.GetConfirmedTotals(order.ID)
+	if err != nil {
+		return OrderBalance{}, fmt.Errorf("failed to get paid amount: %v", err)
+	}
+	return OrderBalance{
+		Cost:     order.Cost,
+		Paid:     paid,
+		Refunded: refunded,
+		Due:      math.Round((order.Cost-paid+refunded)*100) / 100,
+	}, nil
+}
+
+// RequestRefund registers a refund that takes effect once another staff member approves it
+func (s *Service) RequestRefund(order *models.Order, amount float64, reason string, requestedBy int64) (*models.Payment, error) {
+	if order == nil || order.ID <= 0 || requestedBy <= 0 {
+		return nil, errors.New("invalid order or requester ID")
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("refund reason is required")
+	}
+	if amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+
+	balance, err := s.GetOrderBalance(order)
+	if err != nil {
+		return nil, err
+	}
+	// Refunds still awaiting approval are already promised to the client
+	pending, err := s.repo.GetPendingPayments(order.ID)
+	if err != nil {
+		return nil, err
+	}
+	refundable := balance.Paid - balance.Refunded
+	for _, p := range pending {
+		if p.Kind == KindRefund {
+			refundable -= p.Amount
+		}
+	}
+	if minorUnits(amount) > minorUnits(refundable) {
+		return nil, fmt.Errorf("refund %.2f exceeds the refundable amount %.2f", amount, refundable)
+	}
+	method, err := s.refundMethod(order.ID, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := &models.Payment{
+		OrderID:     order.ID,
+		UserID:      order.UserID,
+		Amount:      amount,
+		Method:      method,
+		Kind:        KindRefund,
+		Reason:      reason,
+		RequestedBy: requestedBy,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.repo.CreatePayment(refund); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// ApproveRefund confirms a pending refund and records it in the ledger in one transaction that
+// rechecks the net paid amount; the approver must differ from the requester
+func (s *Service) ApproveRefund(paymentID int, approverID int64) (*models.Payment, error) {
+	refund, err := s.pendingRefund(paymentID, approverID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	description := fmt.Sprintf("Возврат по заказу #%d: %s", refund.OrderID, refund.Reason)
+	entry, err := accounting.RefundEntry(refund.OrderID, refund.UserID, models.NewMoney(refund.Amount), refundAccount(refund), description)
+	if err != nil {
+		return nil, err
+	}
+	entry.CreatedAt = now
+
+	refund.Confirmed = true
+	refund.ConfirmedBy = approverID
+	refund.ConfirmedAt = now
+	confirmed, err := s.repo.ApproveRefund(refund, entry)
+	if err != nil {
+		return nil, err
+	}
+	if !confirmed {
+		return nil, ErrAlreadyConfirmed
+	}
+	return refund, nil
+}
+
+// refundAccount returns the ledger account a refund is paid from: online payments are returned through the bank
+func refundAccount(refund *models.Payment) string {
+	if refund.Method == MethodCard || refund.Method == MethodLink {
+		return accounting.AccountBank
+	}
+	return accounting.AccountCashDesk
+}
+
+// RejectRefund discards a pending refund request
+func (s *Service) RejectRefund(paymentID int, approverID int64) (*models.Payment, error) {
+	refund, err := s.pendingRefund(paymentID, approverID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.DeletePayment(refund.ID); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// pendingRefund loads a refund awaiting a decision by the approver
+func (s *Service) pendingRefund(paymentID int, approverID int64) (*models.Payment, error) {
+	if paymentID <= 0 || approverID <= 0 {
+		return nil, errors.New("invalid payment or approver ID")
+	}
+	refund, err := s.repo.GetPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if refund.Kind != KindRefund {
+		return nil, errors.New("payment is not a refund")
+	}
+	if refund.Confirmed {
+		return nil, ErrAlreadyConfirmed
+	}
+	if refund.RequestedBy == approverID {
+		return nil, errors.New("refund must be approved by another staff member")
+	}
+	return refund, nil
+}
+
+// refundMethod picks how a refund goes back to the client: to the card or payment link the order was paid
+// online with while those payments cover it, otherwise in cash by staff
+func (s *Service) refundMethod(orderID int, amount float64) (string, error) {
+	payments, err := s.repo.GetConfirmedPayments(orderID)
+	if err != nil {
+		return "", err
+	}
+	method := MethodManual
+	var online float64
+	for _, p := range payments {
+		if p.Method != MethodCard && p.Method != MethodLink {
+			continue
+		}
+		if p.Kind == KindRefund {
+			online -= p.Amount
+			continue
+		}
+		online += p.Amount
+		method = p.Method
+	}
+	if minorUnits(amount) > minorUnits(online) {
+		return MethodManual, nil
+	}
+	return method, nil
+}
+
+// kindFor classifies an incoming payment: partial payments before completion are prepayments
+func kindFor(order *models.Order, amount, due float64) string {
+	if order.Status != "completed" && minorUnits(amount) < minorUnits(due) {
+		return KindPrepayment
+	}
+	return KindPayment
+}
@@ -0,0 +1,142 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"github.com/skyzeper/telegram-bot/internal/models"
+)
+
+// FakeSignatureHeader carries the HMAC-SHA256 signature of fake provider webhooks
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// FakeProvider is an in-memory PaymentProvider for tests and local development.
+// Links point to PayHandler, which marks the payment paid when opened.
+type FakeProvider struct {
+	secret  string
+	baseURL string
+
+	mu       sync.Mutex
+	next     int
+	payments map[string]*ProviderEvent
+}
+
+// fakeWebhook is the JSON body of a fake provider webhook
+type fakeWebhook struct {
+	ID     string  `json:"id"`
+	Status string  `json:"status"`
+	Amount float64 `json:"amount"`
+}
+
+// NewFakeProvider creates a fake provider signing webhooks with secret and serving links under baseURL
+func NewFakeProvider(secret, baseURL string) *FakeProvider {
+	return &FakeProvider{
+		secret:   secret,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		payments: make(map[string]*ProviderEvent),
+	}
+}
+
+// Name identifies the provider
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// CreateLink registers a pending payment
+func (p *FakeProvider) CreateLink(order *models.Order, amount float64) (string, string, error) {
+	if amount <= 0 {
+		return "", "", errors.New("amount must be positive")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.next++
+	externalID := fmt.Sprintf("fake_%d_%d", order.ID, p.next)
+	p.payments[externalID] = &ProviderEvent{ExternalID: externalID, Status: LinkStatusPending, Amount: amount}
+	return externalID, p.baseURL + "/pay/" + externalID, nil
+}
+
+// VerifyWebhook checks the body signature and decodes the event
+func (p *FakeProvider) VerifyWebhook(r *http.Request) (*ProviderEvent, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook body: %v", err)
+	}
+	if !hmac.Equal([]byte(r.Header.Get(FakeSignatureHeader)), []byte(p.Sign(body))) {
+		return nil, errors.New("invalid webhook signature")
+	}
+	var hook fakeWebhook
+	if err := json.Unmarshal(body, &hook); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook: %v", err)
+	}
+	return &ProviderEvent{ExternalID: hook.ID, Status: hook.Status, Amount: hook.Amount}, nil
+}
+
+// GetStatus returns the current state of a payment
+func (p *FakeProvider) GetStatus(externalID string) (*ProviderEvent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	event, ok := p.payments[externalID]
+	if !ok {
+		return nil, fmt.Errorf("unknown payment: %s", externalID)
+	}
+	copied := *event
+	return &copied, nil
+}
+
+// MarkPaid simulates the client completing the payment
+func (p *FakeProvider) MarkPaid(externalID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	event, ok := p.payments[externalID]
+	if !ok {
+		return fmt.Errorf("unknown payment: %s", externalID)
+	}
+	event.Status = LinkStatusPaid
+	return nil
+}
+
+// Sign computes the webhook signature of a body
+func (p *FakeProvider) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(p.secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookRequest builds a signed webhook request for a payment, as the provider would send it
+func (p *FakeProvider) WebhookRequest(url, externalID string) (*http.Request, error) {
+	event, err := p.GetStatus(externalID)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(fakeWebhook{ID: event.ExternalID, Status: event.Status, Amount: event.Amount})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(FakeSignatureHeader, p.Sign(body))
+	return req, nil
+}
+
+// PayHandler serves the fake payment page: opening a link marks it paid, status polling picks it up
+func (p *FakeProvider) PayHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		externalID := strings.TrimPrefix(r.URL.Path, "/pay/")
+		if err := p.MarkPaid(externalID); err != nil {
+			http.Error(w, "payment not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "Платёж %s оплачен (тестовый провайдер).\n", externalID)
+	})
+}
@@ -0,0 +1,138 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/models"
+)
+
+// invoicePayloadPrefix prefixes the order ID in invoice payloads
+const invoicePayloadPrefix = "order_"
+
+// InvoicesEnabled reports whether a payment provider token is configured
+func (s *Service) InvoicesEnabled() bool {
+	return s.cfg.ProviderToken != ""
+}
+
+// SendInvoice sends the client a Telegram invoice for the amount, either the whole balance or a prepayment
+func (s *Service) SendInvoice(order *models.Order, amount float64) error {
+	if order == nil || order.ID <= 0 || order.UserID <= 0 {
+		return errors.New("invalid order")
+	}
+	if amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+	if !s.InvoicesEnabled() {
+		return errors.New("payment provider is not configured")
+	}
+
+	invoice := tgbotapi.NewInvoice(
+		order.UserID,
+		fmt.Sprintf("Заказ #%d", order.ID),
+		fmt.Sprintf("Оплата заказа #%d: %s", order.ID, order.Subcategory),
+		InvoicePayload(order.ID),
+		s.cfg.ProviderToken,
+		"",
+		s.cfg.Currency,
+		[]tgbotapi.LabeledPrice{{Label: fmt.Sprintf("Заказ #%d", order.ID), Amount: minorUnits(amount)}},
+	)
+	// A nil slice is sent as null, which the Bot API rejects
+	invoice.SuggestedTipAmounts = []int{}
+	if _, err := s.bot.Send(invoice); err != nil {
+		return fmt.Errorf("failed to send invoice: %v", err)
+	}
+	return nil
+}
+
+// AnswerPreCheckout approves the checkout only if the invoice does not exceed the order balance
+func (s *Service) AnswerPreCheckout(query *tgbotapi.PreCheckoutQuery, order *models.Order) error {
+	errorMessage := ""
+	if order == nil {
+		errorMessage = "Заказ не найден."
+	} else if balance, err := s.GetOrderBalance(order); err != nil {
+		errorMessage = "Не удалось проверить заказ, попробуйте позже."
+	} else if balance.FullyPaid() {
+		errorMessage = "Заказ уже оплачен."
+	} else if query.Currency != s.cfg.Currency || query.TotalAmount > minorUnits(balance.Due) {
+		errorMessage = "Стоимость заказа изменилась, дождитесь нового счёта."
+	}
+
+	// PreCheckoutConfig drops ok=false, which the Bot API requires, so the request is built by hand
+	params := tgbotapi.Params{
+		"pre_checkout_query_id": query.ID,
+		"ok":                    strconv.FormatBool(errorMessage == ""),
+	}
+	params.AddNonEmpty("error_message", errorMessage)
+	if _, err := s.bot.MakeRequest("answerPreCheckoutQuery", params); err != nil {
+		return fmt.Errorf("failed to answer pre-checkout query: %v", err)
+	}
+	return nil
+}
+
+// RecordCardPayment records a successful Telegram payment, ignoring repeated deliveries of the same charge
+func (s *Service) RecordCardPayment(order *models.Order, sp *tgbotapi.SuccessfulPayment) (*models.Payment, error) {
+	if order == nil || order.ID <= 0 || sp == nil {
+		return nil, errors.New("invalid order or payment")
+	}
+	if sp.ProviderPaymentChargeID == "" {
+		return nil, errors.New("missing provider charge ID")
+	}
+
+	existing, err := s.repo.GetPaymentByChargeID(sp.ProviderPaymentChargeID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+	balance, err := s.GetOrderBalance(order)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	amount := float64(sp.TotalAmount) / 100
+	payment := &models.Payment{
+		OrderID:          order.ID,
+		UserID:           order.UserID,
+		Amount:           amount,
+		Method:           MethodCard,
+		Kind:             kindFor(order, amount, balance.Due),
+		Confirmed:        true,
+		ConfirmedAt:      now,
+		ProviderChargeID: sp.ProviderPaymentChargeID,
+		TelegramChargeID: sp.TelegramPaymentChargeID,
+		CreatedAt:        now,
+	}
+	if err := s.repo.CreatePayment(payment); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// InvoicePayload builds the invoice payload for an order
+func InvoicePayload(orderID int) string {
+	return invoicePayloadPrefix + strconv.Itoa(orderID)
+}
+
+// ParseInvoicePayload extracts the order ID from an invoice payload
+func ParseInvoicePayload(payload string) (int, error) {
+	if !strings.HasPrefix(payload, invoicePayloadPrefix) {
+		return 0, fmt.Errorf("unexpected invoice payload: %s", payload)
+	}
+	orderID, err := strconv.Atoi(strings.TrimPrefix(payload, invoicePayloadPrefix))
+	if err != nil || orderID <= 0 {
+		return 0, fmt.Errorf("invalid order ID in invoice payload: %s", payload)
+	}
+	return orderID, nil
+}
+
+// minorUnits converts an amount to kopecks
+func minorUnits(amount float64) int {
+	return int(math.Round(amount * 100))
+}
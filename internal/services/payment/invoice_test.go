@@ -0,0 +1,148 @@
+package payment_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/payment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeBotAPI records Bot API calls made by the service
+type fakeBotAPI struct {
+	mu    sync.Mutex
+	calls []fakeCall
+}
+
+// fakeCall is a single recorded Bot API request
+type fakeCall struct {
+	method string
+	form   map[string]string
+}
+
+func (f *fakeBotAPI) last(method string) (fakeCall, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.calls) - 1; i >= 0; i-- {
+		if f.calls[i].method == method {
+			return f.calls[i], true
+		}
+	}
+	return fakeCall{}, false
+}
+
+// newTestBot creates a bot talking to a fake Bot API server
+func newTestBot(t *testing.T) (*tgbotapi.BotAPI, *fakeBotAPI) {
+	fake := &fakeBotAPI{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := fakeCall{method: path.Base(r.URL.Path), form: map[string]string{}}
+		if err := r.ParseForm(); err == nil {
+			for key := range r.Form {
+				call.form[key] = r.FormValue(key)
+			}
+		}
+		fake.mu.Lock()
+		fake.calls = append(fake.calls, call)
+		fake.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot","message_id":1,"date":0,"chat":{"id":1}}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint("test-token", server.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatalf("failed to create test bot: %v", err)
+	}
+	return bot, fake
+}
+
+func TestService_SendInvoice(t *testing.T) {
+	bot, fake := newTestBot(t)
+	service := payment.NewService(bot, new(MockRepository), payment.Config{ProviderToken: "provider-token"})
+
+	err := service.SendInvoice(&models.Order{ID: 10, UserID: 100, Subcategory: "Вывоз мусора", Cost: 5000}, 3500.5)
+	assert.NoError(t, err)
+
+	call, ok := fake.last("sendInvoice")
+	assert.True(t, ok)
+	assert.Equal(t, "100", call.form["chat_id"])
+	assert.Equal(t, "order_10", call.form["payload"])
+	assert.Equal(t, "RUB", call.form["currency"])
+	assert.Equal(t, "provider-token", call.form["provider_token"])
+	assert.Contains(t, call.form["prices"], `"amount":350050`)
+
+	disabled := payment.NewService(bot, new(MockRepository), payment.Config{})
+	assert.Error(t, disabled.SendInvoice(&models.Order{ID: 10, UserID: 100, Cost: 3500}, 3500))
+}
+
+func TestService_AnswerPreCheckout(t *testing.T) {
+	bot, fake := newTestBot(t)
+	mockRepo := new(MockRepository)
+	service := payment.NewService(bot, mockRepo, payment.Config{ProviderToken: "provider-token"})
+	order := &models.Order{ID: 10, UserID: 100, Cost: 3500}
+	mockRepo.On("GetConfirmedTotals", 10).Return(0.0, 0.0, nil)
+
+	query := &tgbotapi.PreCheckoutQuery{ID: "q1", Currency: "RUB", TotalAmount: 350000, InvoicePayload: "order_10"}
+	assert.NoError(t, service.AnswerPreCheckout(query, order))
+	call, _ := fake.last("answerPreCheckoutQuery")
+	assert.Equal(t, "q1", call.form["pre_checkout_query_id"])
+	assert.Equal(t, "true", call.form["ok"])
+
+	query = &tgbotapi.PreCheckoutQuery{ID: "q2", Currency: "RUB", TotalAmount: 400000, InvoicePayload: "order_10"}
+	assert.NoError(t, service.AnswerPreCheckout(query, order))
+	call, _ = fake.last("answerPreCheckoutQuery")
+	assert.Equal(t, "q2", call.form["pre_checkout_query_id"])
+	assert.Equal(t, "false", call.form["ok"])
+	assert.NotEmpty(t, call.form["error_message"])
+}
+
+func TestService_RecordCardPayment(t *testing.T) {
+	order := &models.Order{ID: 10, UserID: 100, Cost: 3500}
+	sp := &tgbotapi.SuccessfulPayment{
+		Currency: "RUB", TotalAmount: 350000, InvoicePayload: "order_10",
+		TelegramPaymentChargeID: "tg-1", ProviderPaymentChargeID: "prov-1",
+	}
+
+	t.Run("New", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := payment.NewService(nil, mockRepo, payment.Config{})
+
+		mockRepo.On("GetPaymentByChargeID", "prov-1").Return(nil, nil).Once()
+		mockRepo.On("GetConfirmedTotals", 10).Return(0.0, 0.0, nil).Once()
+		mockRepo.On("CreatePayment", mock.MatchedBy(func(p *models.Payment) bool {
+			return p.OrderID == 10 && p.Amount == 3500 && p.Method == payment.MethodCard && p.Kind == payment.KindPayment &&
+				p.Confirmed && p.ProviderChargeID == "prov-1" && p.TelegramChargeID == "tg-1"
+		})).Return(nil).Once()
+
+		p, err := service.RecordCardPayment(order, sp)
+		assert.NoError(t, err)
+		assert.NotNil(t, p)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := payment.NewService(nil, mockRepo, payment.Config{})
+
+		mockRepo.On("GetPaymentByChargeID", "prov-1").Return(&models.Payment{ID: 7, ProviderChargeID: "prov-1"}, nil).Once()
+
+		p, err := service.RecordCardPayment(order, sp)
+		assert.NoError(t, err)
+		assert.Equal(t, 7, p.ID)
+		mockRepo.AssertNotCalled(t, "CreatePayment", mock.Anything)
+	})
+}
+
+func TestParseInvoicePayload(t *testing.T) {
+	orderID, err := payment.ParseInvoicePayload(payment.InvoicePayload(42))
+	assert.NoError(t, err)
+	assert.Equal(t, 42, orderID)
+
+	_, err = payment.ParseInvoicePayload("referral_42")
+	assert.Error(t, err)
+}
@@ -0,0 +1,204 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// Payment link statuses
+const (
+	LinkStatusPending  = "pending"
+	LinkStatusPaid     = "paid"
+	LinkStatusCanceled = "canceled"
+)
+
+// MethodLink is the payment method for orders paid through an external payment link
+const MethodLink = "link"
+
+// PaymentProvider is an acquiring provider that issues external payment links (SBP, YooKassa and the like)
+type PaymentProvider interface {
+	// Name identifies the provider in stored links and charge IDs
+	Name() string
+	// CreateLink registers a payment for the order and returns the provider's payment ID and link
+	CreateLink(order *models.Order, amount float64) (externalID, url string, err error)
+	// VerifyWebhook authenticates a webhook request and extracts the payment event
+	VerifyWebhook(r *http.Request) (*ProviderEvent, error)
+	// GetStatus polls the provider for the current state of a payment
+	GetStatus(externalID string) (*ProviderEvent, error)
+}
+
+// ProviderEvent is the state of an external payment reported by a provider
+type ProviderEvent struct {
+	ExternalID string
+	Status     string // one of the LinkStatus* constants
+	Amount     float64
+}
+
+// LinkPaidFunc is called once a payment link is confirmed as paid
+type LinkPaidFunc func(link *models.PaymentLink, payment *models.Payment)
+
+// LinksEnabled reports whether an acquiring provider is configured
+func (s *Service) LinksEnabled() bool {
+	return s.cfg.Provider != nil
+}
+
+// SendPaymentLink creates a payment link for the amount and sends it to the client
+func (s *Service) SendPaymentLink(order *models.Order, amount float64) (*models.PaymentLink, error) {
+	if order == nil || order.ID <= 0 || order.UserID <= 0 {
+		return nil, errors.New("invalid order")
+	}
+	if amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	if !s.LinksEnabled() {
+		return nil, errors.New("payment provider is not configured")
+	}
+
+	externalID, url, err := s.cfg.Provider.CreateLink(order, amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payment link: %v", err)
+	}
+	link := &models.PaymentLink{
+		OrderID:    order.ID,
+		UserID:     order.UserID,
+		Provider:   s.cfg.Provider.Name(),
+		ExternalID: externalID,
+		URL:        url,
+		Amount:     amount,
+		Status:     LinkStatusPending,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.repo.CreatePaymentLink(link); err != nil {
+		return nil, err
+	}
+
+	msg := tgbotapi.NewMessage(order.UserID, fmt.Sprintf("💳 Счёт на оплату заказа #%d: %.2f руб.", order.ID, amount))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("💳 Оплатить", url),
+		),
+	)
+	if _, err := s.bot.Send(msg); err != nil {
+		return link, fmt.Errorf("failed to send payment link: %v", err)
+	}
+	return link, nil
+}
+
+// HandleWebhook verifies a provider webhook and confirms the payment it reports.
+// The returned payment is nil when the event does not (newly) confirm a payment.
+func (s *Service) HandleWebhook(r *http.Request) (*models.PaymentLink, *models.Payment, error) {
+	if !s.LinksEnabled() {
+		return nil, nil, errors.New("payment provider is not configured")
+	}
+	event, err := s.cfg.Provider.VerifyWebhook(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to verify webhook: %v", err)
+	}
+	link, err := s.repo.GetPaymentLink(s.cfg.Provider.Name(), event.ExternalID)
+	if err != nil {
+		return nil, nil, err
+	}
+	payment, err := s.applyEvent(link, event)
+	return link, payment, err
+}
+
+// PollPaymentLinks asks the provider about pending links in case a webhook was lost
+func (s *Service) PollPaymentLinks(onPaid LinkPaidFunc) error {
+	if !s.LinksEnabled() {
+		return nil
+	}
+	links, err := s.repo.GetPaymentLinksByStatus(s.cfg.Provider.Name(), LinkStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to get pending payment links: %v", err)
+	}
+	for i := range links {
+		link := &links[i]
+		event, err := s.cfg.Provider.GetStatus(link.ExternalID)
+		if err != nil {
+			utils.LogError(err)
+			continue
+		}
+		payment, err := s.applyEvent(link, event)
+		if err != nil {
+			utils.LogError(err)
+			continue
+		}
+		if payment != nil && onPaid != nil {
+			onPaid(link, payment)
+		}
+	}
+	return nil
+}
+
+// applyEvent moves a pending link to the reported status, recording a confirmed payment when it is paid
+func (s *Service) applyEvent(link *models.PaymentLink, event *ProviderEvent) (*models.Payment, error) {
+	if link.Status != LinkStatusPending || event.Status == LinkStatusPending {
+		return nil, nil
+	}
+	if event.Status == LinkStatusCanceled {
+		link.Status = LinkStatusCanceled
+		return nil, s.repo.UpdatePaymentLink(link)
+	}
+	if event.Status != LinkStatusPaid {
+		return nil, fmt.Errorf("unknown payment status: %s", event.Status)
+	}
+	if minorUnits(event.Amount) != minorUnits(link.Amount) {
+		return nil, fmt.Errorf("paid amount %.2f does not match link amount %.2f", event.Amount, link.Amount)
+	}
+
+	order, err := s.repo.GetOrder(link.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := s.GetOrderBalance(order)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	payment := &models.Payment{
+		OrderID:          link.OrderID,
+		UserID:           link.UserID,
+		Amount:           event.Amount,
+		Method:           MethodLink,
+		Kind:             kindFor(order, event.Amount, balance.Due),
+		Confirmed:        true,
+		ConfirmedAt:      now,
+		ProviderChargeID: link.Provider + ":" + link.ExternalID,
+		CreatedAt:        now,
+	}
+	// Only the caller that claims the pending link records the payment, so it is counted and reported once
+	paid, err := s.repo.PayPaymentLink(link, payment)
+	if err != nil || !paid {
+		return nil, err
+	}
+	link.Status = LinkStatusPaid
+	link.PaymentID = payment.ID
+	link.PaidAt = payment.ConfirmedAt
+	return payment, nil
+}
+
+// NewWebhookHandler returns the HTTP endpoint providers post payment notifications to
+func NewWebhookHandler(s *Service, onPaid LinkPaidFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		link, payment, err := s.HandleWebhook(r)
+		if err != nil {
+			utils.LogError(err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if payment != nil && onPaid != nil {
+			onPaid(link, payment)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,114 @@
+package payment_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/payment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestService_SendPaymentLink(t *testing.T) {
+	bot, fake := newTestBot(t)
+	mockRepo := new(MockRepository)
+	provider := payment.NewFakeProvider("secret", "http://localhost:8080")
+	service := payment.NewService(bot, mockRepo, payment.Config{Provider: provider})
+
+	mockRepo.On("CreatePaymentLink", mock.MatchedBy(func(l *models.PaymentLink) bool {
+		return l.OrderID == 10 && l.Provider == "fake" && l.Amount == 3500 && l.Status == payment.LinkStatusPending
+	})).Return(nil).Once()
+
+	link, err := service.SendPaymentLink(&models.Order{ID: 10, UserID: 100, Cost: 3500}, 3500)
+	assert.NoError(t, err)
+	assert.Contains(t, link.URL, "/pay/"+link.ExternalID)
+	call, ok := fake.last("sendMessage")
+	assert.True(t, ok)
+	assert.Equal(t, "100", call.form["chat_id"])
+	assert.Contains(t, call.form["reply_markup"], link.URL)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWebhookHandler(t *testing.T) {
+	mockRepo := new(MockRepository)
+	provider := payment.NewFakeProvider("secret", "http://localhost:8080")
+	service := payment.NewService(nil, mockRepo, payment.Config{Provider: provider})
+
+	externalID, _, err := provider.CreateLink(&models.Order{ID: 10}, 3500)
+	assert.NoError(t, err)
+	assert.NoError(t, provider.MarkPaid(externalID))
+	link := &models.PaymentLink{ID: 1, OrderID: 10, UserID: 100, Provider: "fake", ExternalID: externalID, Amount: 3500, Status: payment.LinkStatusPending}
+
+	mockRepo.On("GetPaymentLink", "fake", externalID).Return(link, nil).Once()
+	mockRepo.On("GetOrder", 10).Return(&models.Order{ID: 10, UserID: 100, Status: "new", Cost: 5000}, nil).Once()
+	mockRepo.On("GetConfirmedTotals", 10).Return(0.0, 0.0, nil).Once()
+	mockRepo.On("PayPaymentLink", link, mock.MatchedBy(func(p *models.Payment) bool {
+		return p.OrderID == 10 && p.Amount == 3500 && p.Method == payment.MethodLink && p.Confirmed &&
+			p.Kind == payment.KindPrepayment
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Payment).ID = 5
+	}).Return(true, nil).Once()
+
+	var paid *models.Payment
+	handler := payment.NewWebhookHandler(service, func(l *models.PaymentLink, p *models.Payment) { paid = p })
+
+	req, err := provider.WebhookRequest("/payments/webhook", externalID)
+	assert.NoError(t, err)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotNil(t, paid)
+	assert.Equal(t, payment.LinkStatusPaid, link.Status)
+	assert.Equal(t, 5, link.PaymentID)
+	mockRepo.AssertExpectations(t)
+
+	// A delivery that loses the claim to the poller records nothing and reports nothing
+	paid = nil
+	raced := &models.PaymentLink{ID: 1, OrderID: 10, UserID: 100, Provider: "fake", ExternalID: externalID, Amount: 3500, Status: payment.LinkStatusPending}
+	mockRepo.On("GetPaymentLink", "fake", externalID).Return(raced, nil).Once()
+	mockRepo.On("GetOrder", 10).Return(&models.Order{ID: 10, UserID: 100, Status: "new", Cost: 5000}, nil).Once()
+	mockRepo.On("GetConfirmedTotals", 10).Return(0.0, 0.0, nil).Once()
+	mockRepo.On("PayPaymentLink", raced, mock.AnythingOfType("*models.Payment")).Return(false, nil).Once()
+	req, _ = provider.WebhookRequest("/payments/webhook", externalID)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Nil(t, paid)
+	mockRepo.AssertExpectations(t)
+
+	// A tampered signature is rejected before touching the repository
+	req, _ = provider.WebhookRequest("/payments/webhook", externalID)
+	req.Header.Set(payment.FakeSignatureHeader, "bad")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestService_PollPaymentLinks(t *testing.T) {
+	mockRepo := new(MockRepository)
+	provider := payment.NewFakeProvider("secret", "http://localhost:8080")
+	service := payment.NewService(nil, mockRepo, payment.Config{Provider: provider})
+
+	paidID, _, _ := provider.CreateLink(&models.Order{ID: 10}, 3500)
+	pendingID, _, _ := provider.CreateLink(&models.Order{ID: 11}, 1200)
+	assert.NoError(t, provider.MarkPaid(paidID))
+
+	mockRepo.On("GetPaymentLinksByStatus", "fake", payment.LinkStatusPending).Return([]models.PaymentLink{
+		{ID: 1, OrderID: 10, UserID: 100, Provider: "fake", ExternalID: paidID, Amount: 3500, Status: payment.LinkStatusPending},
+		{ID: 2, OrderID: 11, UserID: 101, Provider: "fake", ExternalID: pendingID, Amount: 1200, Status: payment.LinkStatusPending},
+	}, nil).Once()
+	mockRepo.On("GetOrder", 10).Return(&models.Order{ID: 10, UserID: 100, Status: "completed", Cost: 3500}, nil).Once()
+	mockRepo.On("GetConfirmedTotals", 10).Return(0.0, 0.0, nil).Once()
+	mockRepo.On("PayPaymentLink", mock.AnythingOfType("*models.PaymentLink"), mock.MatchedBy(func(p *models.Payment) bool {
+		return p.Kind == payment.KindPayment
+	})).Return(true, nil).Once()
+
+	var paidOrders []int
+	err := service.PollPaymentLinks(func(l *models.PaymentLink, p *models.Payment) {
+		paidOrders = append(paidOrders, l.OrderID)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{10}, paidOrders)
+	mockRepo.AssertExpectations(t)
+}
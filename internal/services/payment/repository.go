@@ -3,8 +3,10 @@ package payment
 import (
 	"database/sql"
 	"fmt"
+	"time"
 	"github.com/skyzeper/telegram-bot/internal/db"
 	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/accounting"
 	"github.com/skyzeper/telegram-bot/internal/utils"
 )
 
@@ -18,22 +20,83 @@ func NewPostgresRepository(db *db.DB) *PostgresRepository {
 	return &PostgresRepository{db: db}
 }
 
+// paymentColumns lists the columns scanned by scanPayment
+const paymentColumns = `id, order_id, user_id, amount, method, driver_id, confirmed, confirmed_by, confirmed_at,
+	provider_charge_id, telegram_charge_id, kind, reason, requested_by, receipt_id, created_at`
+
 // CreatePayment creates a new payment
 func (r *PostgresRepository) CreatePayment(payment *models.Payment) error {
+	return insertPayment(r.db.Conn(), payment)
+}
+
+// CreateCashPayment creates a payment together with its ledger entry in one transaction
+func (r *PostgresRepository) CreateCashPayment(payment *models.Payment, entry *models.LedgerEntry) error {
+	tx, err := r.db.Conn().Begin()
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertPayment(tx, payment); err != nil {
+		return err
+	}
+	if err := accounting.InsertEntry(tx, entry); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to commit payment: %v", err)
+	}
+	return nil
+}
+
+// queryRower is implemented by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insertPayment stores a payment through a connection or a transaction
+func insertPayment(q queryRower, payment *models.Payment) error {
 	query := `
-		INSERT INTO payments (order_id, user_id, amount, method, driver_id, confirmed, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO payments (order_id, user_id, amount, method, driver_id, confirmed, confirmed_at,
+			provider_charge_id, telegram_charge_id, kind, reason, requested_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 		RETURNING id
 	`
-	var driverID sql.NullInt64
+	var driverID, requestedBy sql.NullInt64
+	var reason sql.NullString
+	var confirmedAt sql.NullTime
+	var providerChargeID, telegramChargeID sql.NullString
 	if payment.DriverID != 0 {
 		driverID.Valid = true
 		driverID.Int64 = payment.DriverID
 	}
-	err := r.db.Conn().QueryRow(
+	if !payment.ConfirmedAt.IsZero() {
+		confirmedAt.Valid = true
+		confirmedAt.Time = payment.ConfirmedAt
+	}
+	if payment.ProviderChargeID != "" {
+		providerChargeID.Valid = true
+		providerChargeID.String = payment.ProviderChargeID
+	}
+	if payment.TelegramChargeID != "" {
+		telegramChargeID.Valid = true
+		telegramChargeID.String = payment.TelegramChargeID
+	}
+	if payment.Reason != "" {
+		reason.Valid = true
+		reason.String = payment.Reason
+	}
+	if payment.RequestedBy != 0 {
+		requestedBy.Valid = true
+		requestedBy.Int64 = payment.RequestedBy
+	}
+	err := q.QueryRow(
 		query,
 		payment.OrderID, payment.UserID, payment.Amount, payment.Method,
-		driverID, payment.Confirmed, payment.CreatedAt,
+		driverID, payment.Confirmed, confirmedAt, providerChargeID, telegramChargeID,
+		payment.Kind, reason, requestedBy, payment.CreatedAt,
 	).Scan(&payment.ID)
 	if err != nil {
 		utils.LogError(err)
@@ -43,75 +106,428 @@ func (r *PostgresRepository) CreatePayment(payment *models.Payment) error {
 }
 
 // GetPendingPayments retrieves pending payments for an order
-func (r *PostgresRepository) GetPendingPayments(orderID int64) ([]models.Payment, error) {
+func (r *PostgresRepository) GetPendingPayments(orderID int) ([]models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE order_id = $1 AND confirmed = FALSE`
+	return r.queryPayments(query, orderID)
+}
+
+// GetUnconfirmedPayments retrieves all payments waiting for hand-over confirmation
+func (r *PostgresRepository) GetUnconfirmedPayments() ([]models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE confirmed = FALSE AND kind <> 'refund' ORDER BY created_at`
+	return r.queryPayments(query)
+}
+
+// GetPendingRefunds retrieves all refunds waiting for approval
+func (r *PostgresRepository) GetPendingRefunds() ([]models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE confirmed = FALSE AND kind = 'refund' ORDER BY created_at`
+	return r.queryPayments(query)
+}
+
+// GetConfirmedPayments retrieves the confirmed payments and refunds of an order
+func (r *PostgresRepository) GetConfirmedPayments(orderID int) ([]models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE order_id = $1 AND confirmed = TRUE ORDER BY created_at`
+	return r.queryPayments(query, orderID)
+}
+
+// ConfirmPayment confirms a payment
+func (r *PostgresRepository) ConfirmPayment(orderID int, driverID int64) error {
+	query := `
+		UPDATE payments
+		SET confirmed = TRUE
+		WHERE order_id = $1 AND driver_id = $2
+	`
+	_, err := r.db.Conn().Exec(query, orderID, driverID)
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to confirm payment: %v", err)
+	}
+	return nil
+}
+
+// ConfirmHandOver marks a payment as handed over and stores its ledger entry, if any, in one transaction,
+// reporting false when the payment was already confirmed
+func (r *PostgresRepository) ConfirmHandOver(id int, confirmedBy int64, confirmedAt time.Time, entry *models.LedgerEntry) (bool, error) {
+	tx, err := r.db.Conn().Begin()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE payments
+		SET confirmed = TRUE, confirmed_by = $1, confirmed_at = $2
+		WHERE id = $3 AND confirmed = FALSE
+	`
+	result, err := tx.Exec(query, confirmedBy, confirmedAt, id)
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to confirm payment: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to confirm payment: %v", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	if entry != nil {
+		if err := accounting.InsertEntry(tx, entry); err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to commit hand-over: %v", err)
+	}
+	return true, nil
+}
+
+// ApproveRefund confirms a refund and stores its ledger entry in one transaction, reporting false when
+// it was already confirmed and ErrRefundExceedsPaid when confirmed refunds would exceed the net paid amount
+func (r *PostgresRepository) ApproveRefund(refund *models.Payment, entry *models.LedgerEntry) (bool, error) {
+	tx, err := r.db.Conn().Begin()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Locking the order's payments makes concurrent approvals of its refunds wait for each other
+	if _, err := tx.Exec(`SELECT id FROM payments WHERE order_id = $1 FOR UPDATE`, refund.OrderID); err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to lock payments: %v", err)
+	}
+	paid, refunded, err := confirmedTotals(tx, refund.OrderID)
+	if err != nil {
+		return false, err
+	}
+	if minorUnits(refund.Amount) > minorUnits(paid-refunded) {
+		return false, ErrRefundExceedsPaid
+	}
+
 	query := `
-		SELECT id, order_id, user_id, amount, method, driver_id, confirmed, created_at
+		UPDATE payments
+		SET confirmed = TRUE, confirmed_by = $1, confirmed_at = $2
+		WHERE id = $3 AND confirmed = FALSE
+	`
+	result, err := tx.Exec(query, refund.ConfirmedBy, refund.ConfirmedAt, refund.ID)
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to confirm refund: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to confirm refund: %v", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	if err := accounting.InsertEntry(tx, entry); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to commit refund: %v", err)
+	}
+	return true, nil
+}
+
+// GetPayment retrieves a specific payment
+func (r *PostgresRepository) GetPayment(orderID int, driverID int64) (*models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE order_id = $1 AND driver_id = $2`
+	payment, err := scanPayment(r.db.Conn().QueryRow(query, orderID, driverID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("payment not found")
+	}
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get payment: %v", err)
+	}
+	return payment, nil
+}
+
+// GetPaymentByID retrieves a payment by its ID
+func (r *PostgresRepository) GetPaymentByID(id int) (*models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE id = $1`
+	payment, err := scanPayment(r.db.Conn().QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("payment not found")
+	}
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get payment: %v", err)
+	}
+	return payment, nil
+}
+
+// GetPaymentByChargeID retrieves a payment by the provider charge ID, returning nil when none exists
+func (r *PostgresRepository) GetPaymentByChargeID(chargeID string) (*models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE provider_charge_id = $1`
+	payment, err := scanPayment(r.db.Conn().QueryRow(query, chargeID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get payment: %v", err)
+	}
+	return payment, nil
+}
+
+// GetConfirmedTotals sums confirmed payments and confirmed refunds of an order
+func (r *PostgresRepository) GetConfirmedTotals(orderID int) (float64, float64, error) {
+	return confirmedTotals(r.db.Conn(), orderID)
+}
+
+// confirmedTotals sums the confirmed payments and refunds of an order through a connection or a transaction
+func confirmedTotals(q queryRower, orderID int) (float64, float64, error) {
+	query := `
+		SELECT COALESCE(SUM(amount) FILTER (WHERE kind <> 'refund'), 0),
+		       COALESCE(SUM(amount) FILTER (WHERE kind = 'refund'), 0)
 		FROM payments
-		WHERE order_id = $1 AND confirmed = FALSE
+		WHERE order_id = $1 AND confirmed = TRUE
 	`
-	rows, err := r.db.Conn().Query(query, orderID)
+	var paid, refunded float64
+	if err := q.QueryRow(query, orderID).Scan(&paid, &refunded); err != nil {
+		utils.LogError(err)
+		return 0, 0, fmt.Errorf("failed to get paid amount: %v", err)
+	}
+	return paid, refunded, nil
+}
+
+// DeletePayment removes an unconfirmed payment, such as a rejected refund request
+func (r *PostgresRepository) DeletePayment(id int) error {
+	query := `DELETE FROM payments WHERE id = $1 AND confirmed = FALSE`
+	_, err := r.db.Conn().Exec(query, id)
 	if err != nil {
 		utils.LogError(err)
-		return nil, fmt.Errorf("failed to get pending payments: %v", err)
+		return fmt.Errorf("failed to delete payment: %v", err)
+	}
+	return nil
+}
+
+// paymentLinkColumns lists the columns scanned by scanPaymentLink
+const paymentLinkColumns = `id, order_id, user_id, provider, external_id, url, amount, status, payment_id, created_at, paid_at`
+
+// CreatePaymentLink stores a payment link issued by a provider
+func (r *PostgresRepository) CreatePaymentLink(link *models.PaymentLink) error {
+	query := `
+		INSERT INTO payment_links (order_id, user_id, provider, external_id, url, amount, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+	err := r.db.Conn().QueryRow(
+		query,
+		link.OrderID, link.UserID, link.Provider, link.ExternalID, link.URL, link.Amount, link.Status, link.CreatedAt,
+	).Scan(&link.ID)
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to create payment link: %v", err)
+	}
+	return nil
+}
+
+// GetPaymentLink retrieves a payment link by the provider's payment ID
+func (r *PostgresRepository) GetPaymentLink(provider, externalID string) (*models.PaymentLink, error) {
+	query := `SELECT ` + paymentLinkColumns + ` FROM payment_links WHERE provider = $1 AND external_id = $2`
+	link, err := scanPaymentLink(r.db.Conn().QueryRow(query, provider, externalID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("payment link not found")
+	}
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get payment link: %v", err)
+	}
+	return link, nil
+}
+
+// GetPaymentLinksByStatus retrieves a provider's payment links with the given status
+func (r *PostgresRepository) GetPaymentLinksByStatus(provider, status string) ([]models.PaymentLink, error) {
+	query := `SELECT ` + paymentLinkColumns + ` FROM payment_links WHERE provider = $1 AND status = $2 ORDER BY created_at`
+	rows, err := r.db.Conn().Query(query, provider, status)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get payment links: %v", err)
 	}
 	defer rows.Close()
 
-	var payments []models.Payment
+	var links []models.PaymentLink
 	for rows.Next() {
-		var payment models.Payment
-		var driverID sql.NullInt64
-		if err := rows.Scan(
-			&payment.ID, &payment.OrderID, &payment.UserID, &payment.Amount, &payment.Method,
-			&driverID, &payment.Confirmed, &payment.CreatedAt,
-		); err != nil {
+		link, err := scanPaymentLink(rows)
+		if err != nil {
 			utils.LogError(err)
 			continue
 		}
-		if driverID.Valid {
-			payment.DriverID = driverID.Int64
-		}
-		payments = append(payments, payment)
+		links = append(links, *link)
 	}
-	return payments, nil
+	return links, nil
 }
 
-// ConfirmPayment confirms a payment
-func (r *PostgresRepository) ConfirmPayment(orderID int64, driverID int64) error {
+// PayPaymentLink claims a pending link as paid and records its payment in one transaction.
+// It returns false when the link was no longer pending, e.g. the webhook and the poller raced.
+func (r *PostgresRepository) PayPaymentLink(link *models.PaymentLink, payment *models.Payment) (bool, error) {
+	tx, err := r.db.Conn().Begin()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE payment_links SET status = $1, paid_at = $2 WHERE id = $3 AND status = $4`
+	result, err := tx.Exec(query, LinkStatusPaid, payment.ConfirmedAt, link.ID, LinkStatusPending)
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to claim payment link: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to claim payment link: %v", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	if err := insertPayment(tx, payment); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`UPDATE payment_links SET payment_id = $1 WHERE id = $2`, payment.ID, link.ID); err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to update payment link: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to commit link payment: %v", err)
+	}
+	return true, nil
+}
+
+// GetOrder retrieves the order fields a payment is classified by
+func (r *PostgresRepository) GetOrder(orderID int) (*models.Order, error) {
+	order := &models.Order{}
+	query := `SELECT id, user_id, status, COALESCE(cost, 0) FROM orders WHERE id = $1`
+	err := r.db.Conn().QueryRow(query, orderID).Scan(&order.ID, &order.UserID, &order.Status, &order.Cost)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("order not found")
+	}
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get order: %v", err)
+	}
+	return order, nil
+}
+
+// UpdatePaymentLink updates the status of a payment link
+func (r *PostgresRepository) UpdatePaymentLink(link *models.PaymentLink) error {
 	query := `
-		UPDATE payments
-		SET confirmed = TRUE
-		WHERE order_id = $1 AND driver_id = $2
+		UPDATE payment_links
+		SET status = $1, payment_id = $2, paid_at = $3
+		WHERE id = $4
 	`
-	_, err := r.db.Conn().Exec(query, orderID, driverID)
+	var paymentID sql.NullInt64
+	var paidAt sql.NullTime
+	if link.PaymentID != 0 {
+		paymentID.Valid = true
+		paymentID.Int64 = int64(link.PaymentID)
+	}
+	if !link.PaidAt.IsZero() {
+		paidAt.Valid = true
+		paidAt.Time = link.PaidAt
+	}
+	_, err := r.db.Conn().Exec(query, link.Status, paymentID, paidAt, link.ID)
 	if err != nil {
 		utils.LogError(err)
-		return fmt.Errorf("failed to confirm payment: %v", err)
+		return fmt.Errorf("failed to update payment link: %v", err)
 	}
 	return nil
 }
 
-// GetPayment retrieves a specific payment
-func (r *PostgresRepository) GetPayment(orderID int64, driverID int64) (*models.Payment, error) {
-	query := `
-		SELECT id, order_id, user_id, amount, method, driver_id, confirmed, created_at
-		FROM payments
-		WHERE order_id = $1 AND driver_id = $2
-	`
+// queryPayments runs a payment query and scans all rows
+func (r *PostgresRepository) queryPayments(query string, args ...interface{}) ([]models.Payment, error) {
+	rows, err := r.db.Conn().Query(query, args...)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get payments: %v", err)
+	}
+	defer rows.Close()
+
+	var payments []models.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			utils.LogError(err)
+			continue
+		}
+		payments = append(payments, *payment)
+	}
+	return payments, nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPayment scans a payment selected with paymentColumns
+func scanPayment(row rowScanner) (*models.Payment, error) {
 	payment := &models.Payment{}
-	var driverIDVal sql.NullInt64
-	err := r.db.Conn().QueryRow(query, orderID, driverID).Scan(
+	var driverID, confirmedBy, requestedBy, receiptID sql.NullInt64
+	var confirmedAt sql.NullTime
+	var providerChargeID, telegramChargeID, reason sql.NullString
+	err := row.Scan(
 		&payment.ID, &payment.OrderID, &payment.UserID, &payment.Amount, &payment.Method,
-		&driverIDVal, &payment.Confirmed, &payment.CreatedAt,
+		&driverID, &payment.Confirmed, &confirmedBy, &confirmedAt,
+		&providerChargeID, &telegramChargeID, &payment.Kind, &reason, &requestedBy, &receiptID, &payment.CreatedAt,
 	)
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("payment not found")
-	}
 	if err != nil {
-		utils.LogError(err)
-		return nil, fmt.Errorf("failed to get payment: %v", err)
+		return nil, err
+	}
+	if driverID.Valid {
+		payment.DriverID = driverID.Int64
 	}
-	if driverIDVal.Valid {
-		payment.DriverID = driverIDVal.Int64
+	if confirmedBy.Valid {
+		payment.ConfirmedBy = confirmedBy.Int64
+	}
+	if confirmedAt.Valid {
+		payment.ConfirmedAt = confirmedAt.Time
+	}
+	if providerChargeID.Valid {
+		payment.ProviderChargeID = providerChargeID.String
+	}
+	if telegramChargeID.Valid {
+		payment.TelegramChargeID = telegramChargeID.String
+	}
+	if reason.Valid {
+		payment.Reason = reason.String
+	}
+	if requestedBy.Valid {
+		payment.RequestedBy = requestedBy.Int64
+	}
+	if receiptID.Valid {
+		payment.ReceiptID = int(receiptID.Int64)
 	}
 	return payment, nil
-}
\ No newline at end of file
+}
+
+// scanPaymentLink scans a payment link selected with paymentLinkColumns
+func scanPaymentLink(row rowScanner) (*models.PaymentLink, error) {
+	link := &models.PaymentLink{}
+	var paymentID sql.NullInt64
+	var paidAt sql.NullTime
+	err := row.Scan(
+		&link.ID, &link.OrderID, &link.UserID, &link.Provider, &link.ExternalID, &link.URL,
+		&link.Amount, &link.Status, &paymentID, &link.CreatedAt, &paidAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if paymentID.Valid {
+		link.PaymentID = int(paymentID.Int64)
+	}
+	if paidAt.Valid {
+		link.PaidAt = paidAt.Time
+	}
+	return link, nil
+}
@@ -2,26 +2,105 @@ package payment
 
 import (
 	"errors"
+	"math"
 	"time"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/accounting"
 )
 
 // Service handles payment-related business logic
 type Service struct {
+	bot  *tgbotapi.BotAPI
 	repo Repository
+	cfg  Config
+}
+
+// Config holds online payment settings
+type Config struct {
+	// ProviderToken is the Telegram Payments provider token (empty disables invoices)
+	ProviderToken string
+	// Currency is the ISO 4217 invoice currency
+	Currency string
+	// Provider issues external payment links (nil disables links)
+	Provider PaymentProvider
 }
 
 // Repository defines the interface for payment data access
 type Repository interface {
 	CreatePayment(payment *models.Payment) error
+	CreateCashPayment(payment *models.Payment, entry *models.LedgerEntry) error
 	GetPendingPayments(orderID int) ([]models.Payment, error)
 	ConfirmPayment(orderID int, driverID int64) error
 	GetPayment(orderID int, driverID int64) (*models.Payment, error)
+	GetPaymentByID(id int) (*models.Payment, error)
+	GetUnconfirmedPayments() ([]models.Payment, error)
+	GetPendingRefunds() ([]models.Payment, error)
+	GetConfirmedPayments(orderID int) ([]models.Payment, error)
+	ApproveRefund(refund *models.Payment, entry *models.LedgerEntry) (bool, error)
+	ConfirmHandOver(id int, confirmedBy int64, confirmedAt time.Time, entry *models.LedgerEntry) (bool, error)
+	GetConfirmedTotals(orderID int) (paid, refunded float64, err error)
+	DeletePayment(id int) error
+	GetPaymentByChargeID(chargeID string) (*models.Payment, error)
+	CreatePaymentLink(link *models.PaymentLink) error
+	GetPaymentLink(provider, externalID string) (*models.PaymentLink, error)
+	GetPaymentLinksByStatus(provider, status string) ([]models.PaymentLink, error)
+	UpdatePaymentLink(link *models.PaymentLink) error
+	PayPaymentLink(link *models.PaymentLink, payment *models.Payment) (bool, error)
+	GetOrder(orderID int) (*models.Order, error)
+}
+
+// ErrAlreadyConfirmed is returned when a payment was confirmed before, possibly by another staff member at the same time
+var ErrAlreadyConfirmed = errors.New("payment already confirmed")
+
+// ErrRefundExceedsPaid is returned when approving a refund would return more than the order's net paid amount
+var ErrRefundExceedsPaid = errors.New("refund exceeds the net paid amount")
+
+// Payment methods
+const (
+	MethodCash   = "cash"   // cash collected by a driver
+	MethodCard   = "card"   // card paid online through Telegram Payments
+	MethodManual = "manual" // money returned in cash by staff outside the bot
+)
+
+// Payment kinds
+const (
+	KindPayment    = "payment"    // settles the order balance
+	KindPrepayment = "prepayment" // part of the cost paid before the order is completed
+	KindRefund     = "refund"     // money returned to the client
+)
+
+// Reconciliation compares the money received for an order with its cost
+type Reconciliation struct {
+	Cost       float64
+	Paid       float64
+	Difference float64 // positive is overpayment, negative is shortage
+}
+
+// Covered reports whether payments cover the order cost
+func (r Reconciliation) Covered() bool {
+	return r.Difference >= -0.005
+}
+
+// Reconcile compares an amount with the order cost, rounding to kopecks
+func Reconcile(cost, paid float64) Reconciliation {
+	return Reconciliation{
+		Cost:       cost,
+		Paid:       paid,
+		Difference: math.Round((paid-cost)*100) / 100,
+	}
 }
 
 // NewService creates a new payment service
-func NewService(repo Repository) *Service {
-	return &Service{repo: repo}
+func NewService(bot *tgbotapi.BotAPI, repo Repository, cfg Config) *Service {
+	if cfg.Currency == "" {
+		cfg.Currency = "RUB"
+	}
+	return &Service{
+		bot:  bot,
+		repo: repo,
+		cfg:  cfg,
+	}
 }
 
 // CreatePayment creates a new payment
@@ -59,4 +138,99 @@ func (s *Service) GetPayment(orderID int, driverID int64) (*models.Payment, erro
 		return nil, errors.New("invalid order or driver ID")
 	}
 	return s.repo.GetPayment(orderID, driverID)
+}
+
+// RecordCash records cash collected by a driver at the site together with the driver's debt;
+// it stays unconfirmed until hand-over
+func (s *Service) RecordCash(order *models.Order, driverID int64, amount float64) (*models.Payment, Reconciliation, error) {
+	if order == nil || order.ID <= 0 || driverID <= 0 {
+		return nil, Reconciliation{}, errors.New("invalid order or driver ID")
+	}
+	if amount <= 0 {
+		return nil, Reconciliation{}, errors.New("amount must be positive")
+	}
+
+	balance, err := s.GetOrderBalance(order)
+	if err != nil {
+		return nil, Reconciliation{}, err
+	}
+
+	payment := &models.Payment{
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		Amount:    amount,
+		Method:    MethodCash,
+		DriverID:  driverID,
+		Kind:      kindFor(order, amount, balance.Due),
+		CreatedAt: time.Now(),
+	}
+	entry, err := accounting.DriverCashEntry(order.ID, driverID, models.NewMoney(amount))
+	if err != nil {
+		return nil, Reconciliation{}, err
+	}
+	entry.CreatedAt = payment.CreatedAt
+	if err := s.repo.CreateCashPayment(payment, entry); err != nil {
+		return nil, Reconciliation{}, err
+	}
+	return payment, Reconcile(balance.Due, amount), nil
+}
+
+// ConfirmHandOver confirms that collected money was handed over to the main operator or accountant,
+// moving a driver's cash to the cash desk in the same transaction
+func (s *Service) ConfirmHandOver(paymentID int, confirmerID int64) (*models.Payment, error) {
+	if paymentID <= 0 || confirmerID <= 0 {
+		return nil, errors.New("invalid payment or confirmer ID")
+	}
+
+	payment, err := s.repo.GetPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Confirmed {
+		return nil, ErrAlreadyConfirmed
+	}
+	if payment.Kind == KindRefund {
+		return nil, errors.New("refunds are confirmed with ApproveRefund")
+	}
+
+	now := time.Now()
+	var entry *models.LedgerEntry
+	if payment.DriverID != 0 {
+		entry, err = accounting.CashHandOverEntry(payment.OrderID, payment.DriverID, models.NewMoney(payment.Amount), confirmerID)
+		if err != nil {
+			return nil, err
+		}
+		entry.CreatedAt = now
+	}
+
+	payment.Confirmed = true
+	payment.ConfirmedBy = confirmerID
+	payment.ConfirmedAt = now
+	confirmed, err := s.repo.ConfirmHandOver(payment.ID, confirmerID, payment.ConfirmedAt, entry)
+	if err != nil {
+		return nil, err
+	}
+	if !confirmed {
+		return nil, ErrAlreadyConfirmed
+	}
+	return payment, nil
+}
+
+// ReconcileOrder compares confirmed payments net of refunds with the order cost
+func (s *Service) ReconcileOrder(order *models.Order) (Reconciliation, error) {
+	balance, err := s.GetOrderBalance(order)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	return Reconcile(balance.Cost, balance.Paid-balance.Refunded), nil
+}
+
+// GetUnconfirmedPayments retrieves all payments waiting for hand-over confirmation
+func (s *Service) GetUnconfirmedPayments() ([]models.Payment, error) {
+	return s.repo.GetUnconfirmedPayments()
+}
+
+// GetPendingRefunds retrieves all refunds waiting for approval
+func (s *Service) GetPendingRefunds() ([]models.Payment, error) {
+	return s.repo.GetPendingRefunds()
 }
\ No newline at end of file
@@ -0,0 +1,383 @@
+package payment_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/payment"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestMain keeps errors logged by the code under test out of the package directory
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "payment-test")
+	if err == nil {
+		utils.SetLogFile(filepath.Join(dir, "error.log"))
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// MockRepository is a mock implementation of payment.Repository
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreatePayment(p *models.Payment) error {
+	args := m.Called(p)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CreateCashPayment(p *models.Payment, entry *models.LedgerEntry) error {
+	args := m.Called(p, entry)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetPendingPayments(orderID int) ([]models.Payment, error) {
+	args := m.Called(orderID)
+	return args.Get(0).([]models.Payment), args.Error(1)
+}
+
+func (m *MockRepository) ConfirmPayment(orderID int, driverID int64) error {
+	args := m.Called(orderID, driverID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetPayment(orderID int, driverID int64) (*models.Payment, error) {
+	args := m.Called(orderID, driverID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Payment), args.Error(1)
+}
+
+func (m *MockRepository) GetPaymentByID(id int) (*models.Payment, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Payment), args.Error(1)
+}
+
+func (m *MockRepository) GetUnconfirmedPayments() ([]models.Payment, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Payment), args.Error(1)
+}
+
+func (m *MockRepository) GetPendingRefunds() ([]models.Payment, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Payment), args.Error(1)
+}
+
+func (m *MockRepository) GetConfirmedPayments(orderID int) ([]models.Payment, error) {
+	args := m.Called(orderID)
+	return args.Get(0).([]models.Payment), args.Error(1)
+}
+
+func (m *MockRepository) ApproveRefund(refund *models.Payment, entry *models.LedgerEntry) (bool, error) {
+	args := m.Called(refund, entry)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) ConfirmHandOver(id int, confirmedBy int64, confirmedAt time.Time, entry *models.LedgerEntry) (bool, error) {
+	args := m.Called(id, confirmedBy, confirmedAt, entry)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetPaymentByChargeID(chargeID string) (*models.Payment, error) {
+	args := m.Called(chargeID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Payment), args.Error(1)
+}
+
+func (m *MockRepository) CreatePaymentLink(link *models.PaymentLink) error {
+	args := m.Called(link)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetPaymentLink(provider, externalID string) (*models.PaymentLink, error) {
+	args := m.Called(provider, externalID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PaymentLink), args.Error(1)
+}
+
+func (m *MockRepository) GetPaymentLinksByStatus(provider, status string) ([]models.PaymentLink, error) {
+	args := m.Called(provider, status)
+	return args.Get(0).([]models.PaymentLink), args.Error(1)
+}
+
+func (m *MockRepository) UpdatePaymentLink(link *models.PaymentLink) error {
+	args := m.Called(link)
+	return args.Error(0)
+}
+
+func (m *MockRepository) PayPaymentLink(link *models.PaymentLink, p *models.Payment) (bool, error) {
+	args := m.Called(link, p)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetOrder(orderID int) (*models.Order, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Order), args.Error(1)
+}
+
+func (m *MockRepository) GetConfirmedTotals(orderID int) (float64, float64, error) {
+	args := m.Called(orderID)
+	return args.Get(0).(float64), args.Get(1).(float64), args.Error(2)
+}
+
+func (m *MockRepository) DeletePayment(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func TestService_RecordCash(t *testing.T) {
+	order := &models.Order{ID: 10, UserID: 100, Cost: 3500}
+
+	t.Run("Shortage", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := payment.NewService(nil, mockRepo, payment.Config{})
+
+		mockRepo.On("GetConfirmedTotals", 10).Return(0.0, 0.0, nil).Once()
+		mockRepo.On("CreateCashPayment", mock.MatchedBy(func(p *models.Payment) bool {
+			return p.OrderID == 10 && p.UserID == 100 && p.DriverID == 200 &&
+				p.Method == payment.MethodCash && p.Amount == 3000 && !p.Confirmed &&
+				p.Kind == payment.KindPrepayment
+		}), mock.MatchedBy(func(e *models.LedgerEntry) bool {
+			// The driver's debt is stored in the same transaction as the payment
+			return e.Kind == "driver_cash" && e.OrderID == 10 && e.UserID == 200 &&
+				len(e.Postings) == 2 && e.Postings[0].DriverID == 200 && e.Postings[0].Amount == models.NewMoney(3000)
+		})).Return(nil).Once()
+
+		p, rec, err := service.RecordCash(order, 200, 3000)
+		assert.NoError(t, err)
+		assert.NotNil(t, p)
+		assert.Equal(t, -500.0, rec.Difference)
+		assert.False(t, rec.Covered())
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("InvalidAmount", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := payment.NewService(nil, mockRepo, payment.Config{})
+
+		_, _, err := service.RecordCash(order, 200, 0)
+		assert.Error(t, err)
+		mockRepo.AssertNotCalled(t, "CreateCashPayment", mock.Anything, mock.Anything)
+	})
+}
+
+func TestService_ConfirmHandOver(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := payment.NewService(nil, mockRepo, payment.Config{})
+
+		mockRepo.On("GetPaymentByID", 5).Return(&models.Payment{ID: 5, OrderID: 10, Amount: 3500, DriverID: 200}, nil).Once()
+		mockRepo.On("ConfirmHandOver", 5, int64(300), mock.AnythingOfType("time.Time"), mock.MatchedBy(func(e *models.LedgerEntry) bool {
+			return e.Kind == "cash_handover" && e.OrderID == 10 && e.UserID == 200 &&
+				len(e.Postings) == 2 && e.Postings[1].DriverID == 200 && e.Postings[1].Amount == -models.NewMoney(3500)
+		})).Return(true, nil).Once()
+
+		p, err := service.ConfirmHandOver(5, 300)
+		assert.NoError(t, err)
+		assert.True(t, p.Confirmed)
+		assert.Equal(t, int64(300), p.ConfirmedBy)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("WithoutDriver", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := payment.NewService(nil, mockRepo, payment.Config{})
+
+		// Money nobody collected in the field has no driver debt to settle
+		mockRepo.On("GetPaymentByID", 5).Return(&models.Payment{ID: 5, OrderID: 10, Amount: 3500}, nil).Once()
+		mockRepo.On("ConfirmHandOver", 5, int64(300), mock.AnythingOfType("time.Time"), (*models.LedgerEntry)(nil)).Return(true, nil).Once()
+
+		_, err := service.ConfirmHandOver(5, 300)
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("ConfirmedConcurrently", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := payment.NewService(nil, mockRepo, payment.Config{})
+
+		// Another operator confirmed the payment between the read and the update
+		mockRepo.On("GetPaymentByID", 5).Return(&models.Payment{ID: 5, OrderID: 10, Amount: 3500, DriverID: 200}, nil).Once()
+		mockRepo.On("ConfirmHandOver", 5, int64(300), mock.AnythingOfType("time.Time"), mock.Anything).Return(false, nil).Once()
+
+		_, err := service.ConfirmHandOver(5, 300)
+		assert.Equal(t, payment.ErrAlreadyConfirmed, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("AlreadyConfirmed", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := payment.NewService(nil, mockRepo, payment.Config{})
+
+		mockRepo.On("GetPaymentByID", 5).Return(&models.Payment{ID: 5, Confirmed: true}, nil).Once()
+
+		_, err := service.ConfirmHandOver(5, 300)
+		assert.Error(t, err)
+		mockRepo.AssertNotCalled(t, "ConfirmHandOver", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestService_ReconcileOrder(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := payment.NewService(nil, mockRepo, payment.Config{})
+
+	mockRepo.On("GetConfirmedTotals", 10).Return(3500.0, 0.0, nil).Once()
+	rec, err := service.ReconcileOrder(&models.Order{ID: 10, Cost: 3500})
+	assert.NoError(t, err)
+	assert.True(t, rec.Covered())
+
+	// A refund reopens the balance
+	mockRepo.On("GetConfirmedTotals", 12).Return(3500.0, 500.0, nil).Once()
+	rec, err = service.ReconcileOrder(&models.Order{ID: 12, Cost: 3500})
+	assert.NoError(t, err)
+	assert.False(t, rec.Covered())
+	assert.Equal(t, -500.0, rec.Difference)
+
+	mockRepo.On("GetConfirmedTotals", 11).Return(0.0, 0.0, errors.New("db error")).Once()
+	_, err = service.ReconcileOrder(&models.Order{ID: 11, Cost: 3500})
+	assert.Error(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_Refunds(t *testing.T) {
+	order := &models.Order{ID: 10, UserID: 100, Cost: 3500, Status: "completed"}
+
+	t.Run("RequestAndApprove", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := payment.NewService(nil, mockRepo, payment.Config{})
+
+		mockRepo.On("GetConfirmedTotals", 10).Return(3500.0, 0.0, nil)
+		mockRepo.On("GetPendingPayments", 10).Return([]models.Payment{}, nil).Once()
+		mockRepo.On("GetConfirmedPayments", 10).Return([]models.Payment{{ID: 5, Method: payment.MethodCash, Kind: payment.KindPayment, Amount: 3500}}, nil).Once()
+		mockRepo.On("CreatePayment", mock.MatchedBy(func(p *models.Payment) bool {
+			return p.Kind == payment.KindRefund && p.Amount == 500 && p.Reason == "Не вывезли часть мусора" &&
+				p.Method == payment.MethodManual && p.RequestedBy == 300 && !p.Confirmed
+		})).Run(func(args mock.Arguments) {
+			args.Get(0).(*models.Payment).ID = 8
+		}).Return(nil).Once()
+
+		refund, err := service.RequestRefund(order, 500, " Не вывезли часть мусора ", 300)
+		assert.NoError(t, err)
+
+		mockRepo.On("GetPaymentByID", 8).Return(refund, nil)
+		_, err = service.ApproveRefund(8, 300)
+		assert.Error(t, err, "requester cannot approve their own refund")
+
+		// The refund is booked against the cash desk in the approval transaction
+		mockRepo.On("ApproveRefund", mock.MatchedBy(func(p *models.Payment) bool {
+			return p.ID == 8 && p.Confirmed && p.ConfirmedBy == 400
+		}), mock.MatchedBy(func(e *models.LedgerEntry) bool {
+			return e.Kind == "refund" && e.OrderID == 10 && len(e.Postings) == 2 &&
+				e.Postings[1].Account == "cash_desk" && e.Postings[1].Amount == -models.NewMoney(500)
+		})).Return(true, nil).Once()
+		approved, err := service.ApproveRefund(8, 400)
+		assert.NoError(t, err)
+		assert.True(t, approved.Confirmed)
+		assert.Equal(t, int64(400), approved.ConfirmedBy)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("OnlinePaymentsRefundedOnline", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := payment.NewService(nil, mockRepo, payment.Config{})
+
+		mockRepo.On("GetConfirmedTotals", 10).Return(3500.0, 0.0, nil)
+		mockRepo.On("GetPendingPayments", 10).Return([]models.Payment{}, nil)
+		mockRepo.On("GetConfirmedPayments", 10).Return([]models.Payment{
+			{ID: 5, Method: payment.MethodCash, Kind: payment.KindPrepayment, Amount: 1500},
+			{ID: 6, Method: payment.MethodLink, Kind: payment.KindPayment, Amount: 2000},
+		}, nil)
+		mockRepo.On("CreatePayment", mock.AnythingOfType("*models.Payment")).Return(nil)
+
+		refund, err := service.RequestRefund(order, 2000, "Отмена части работ", 300)
+		assert.NoError(t, err)
+		assert.Equal(t, payment.MethodLink, refund.Method)
+
+		// More than was paid online is returned in cash
+		refund, err = service.RequestRefund(order, 2500, "Отмена части работ", 300)
+		assert.NoError(t, err)
+		assert.Equal(t, payment.MethodManual, refund.Method)
+	})
+
+	t.Run("PendingRefundsCountTowardsCap", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := payment.NewService(nil, mockRepo, payment.Config{})
+
+		mockRepo.On("GetConfirmedTotals", 10).Return(3500.0, 0.0, nil).Once()
+		mockRepo.On("GetPendingPayments", 10).Return([]models.Payment{
+			{ID: 7, Kind: payment.KindRefund, Amount: 3000},
+			{ID: 9, Kind: payment.KindPayment, Amount: 1000},
+		}, nil).Once()
+		_, err := service.RequestRefund(order, 1000, "Повторный возврат", 300)
+		assert.Error(t, err)
+		mockRepo.AssertNotCalled(t, "CreatePayment", mock.Anything)
+	})
+
+	t.Run("ApproveRechecksCap", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := payment.NewService(nil, mockRepo, payment.Config{})
+
+		// Another refund was approved after this one was requested
+		mockRepo.On("GetPaymentByID", 8).Return(&models.Payment{ID: 8, OrderID: 10, UserID: 100, Kind: payment.KindRefund, Amount: 500, RequestedBy: 300}, nil)
+		mockRepo.On("ApproveRefund", mock.AnythingOfType("*models.Payment"), mock.AnythingOfType("*models.LedgerEntry")).Return(false, payment.ErrRefundExceedsPaid).Once()
+		_, err := service.ApproveRefund(8, 400)
+		assert.Equal(t, payment.ErrRefundExceedsPaid, err)
+	})
+
+	t.Run("ApprovedConcurrently", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := payment.NewService(nil, mockRepo, payment.Config{})
+
+		mockRepo.On("GetPaymentByID", 8).Return(&models.Payment{ID: 8, OrderID: 10, UserID: 100, Kind: payment.KindRefund, Amount: 500, RequestedBy: 300}, nil)
+		mockRepo.On("ApproveRefund", mock.AnythingOfType("*models.Payment"), mock.AnythingOfType("*models.LedgerEntry")).Return(false, nil).Once()
+		_, err := service.ApproveRefund(8, 400)
+		assert.Equal(t, payment.ErrAlreadyConfirmed, err)
+	})
+
+	t.Run("ExceedsPaid", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := payment.NewService(nil, mockRepo, payment.Config{})
+
+		mockRepo.On("GetConfirmedTotals", 10).Return(1000.0, 200.0, nil).Once()
+		mockRepo.On("GetPendingPayments", 10).Return([]models.Payment{}, nil).Once()
+		_, err := service.RequestRefund(order, 900, "Ошибка в стоимости", 300)
+		assert.Error(t, err)
+		mockRepo.AssertNotCalled(t, "CreatePayment", mock.Anything)
+	})
+
+	t.Run("MissingReason", func(t *testing.T) {
+		service := payment.NewService(nil, new(MockRepository), payment.Config{})
+		_, err := service.RequestRefund(order, 100, "  ", 300)
+		assert.Error(t, err)
+	})
+}
+
+func TestService_GetOrderBalance(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := payment.NewService(nil, mockRepo, payment.Config{})
+
+	mockRepo.On("GetConfirmedTotals", 10).Return(1000.0, 200.0, nil).Once()
+	balance, err := service.GetOrderBalance(&models.Order{ID: 10, Cost: 3500})
+	assert.NoError(t, err)
+	assert.Equal(t, 2700.0, balance.Due)
+	assert.False(t, balance.FullyPaid())
+}
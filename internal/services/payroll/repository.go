@@ -0,0 +1,327 @@
+package payroll
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+	"github.com/lib/pq"
+	"github.com/skyzeper/telegram-bot/internal/db"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/accounting"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// PostgresRepository implements the Repository interface for PostgreSQL
+type PostgresRepository struct {
+	db *db.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL repository
+func NewPostgresRepository(db *db.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// payRuleColumns lists the columns scanned by scanPayRule
+const payRuleColumns = `user_id, per_order, per_hour, percent, category_bonuses, updated_at`
+
+// GetPayRule retrieves an executor's pay rule, or nil if none is set
+func (r *PostgresRepository) GetPayRule(userID int64) (*models.PayRule, error) {
+	query := `SELECT ` + payRuleColumns + ` FROM pay_rules WHERE user_id = $1`
+	rule, err := scanPayRule(r.db.Conn().QueryRow(query, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get pay rule: %v", err)
+	}
+	return rule, nil
+}
+
+// GetPayRules retrieves all pay rules
+func (r *PostgresRepository) GetPayRules() ([]models.PayRule, error) {
+	query := `SELECT ` + payRuleColumns + ` FROM pay_rules ORDER BY user_id`
+	rows, err := r.db.Conn().Query(query)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get pay rules: %v", err)
+	}
+	defer rows.Close()
+
+	var rules []models.PayRule
+	for rows.Next() {
+		rule, err := scanPayRule(rows)
+		if err != nil {
+			utils.LogError(err)
+			continue
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, nil
+}
+
+// SavePayRule creates or replaces an executor's pay rule
+func (r *PostgresRepository) SavePayRule(rule *models.PayRule) error {
+	bonuses, err := json.Marshal(rule.CategoryBonuses)
+	if err != nil {
+		return fmt.Errorf("failed to encode category bonuses: %v", err)
+	}
+	query := `
+		INSERT INTO pay_rules (user_id, per_order, per_hour, percent, category_bonuses, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE
+		SET per_order = $2, per_hour = $3, percent = $4, category_bonuses = $5, updated_at = $6
+	`
+	_, err = r.db.Conn().Exec(query, rule.UserID, rule.PerOrder, rule.PerHour, rule.Percent, bonuses, rule.UpdatedAt)
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to save pay rule: %v", err)
+	}
+	return nil
+}
+
+// GetCompletedWork retrieves executors of orders completed in [from, to), grouped by executor.
+// Work starts when the trip to the order started; orders without a trip fall back to the scheduled slot.
+func (r *PostgresRepository) GetCompletedWork(from, to time.Time) ([]models.PayrollWork, error) {
+	query := `
+		SELECT e.user_id, COALESCE(u.first_name, ''), e.role, o.id, o.category, o.subcategory, o.cost,
+		       COALESCE(t.started_at, CASE WHEN o.date IS NOT NULL AND o.time IS NOT NULL THEN o.date::date + o.time END),
+		       o.completed_at
+		FROM executors e
+		JOIN orders o ON o.id = e.order_id
+		JOIN users u ON u.chat_id = e.user_id
+		LEFT JOIN trips t ON t.order_id = o.id
+		WHERE o.status = 'completed' AND o.completed_at >= $1 AND o.completed_at < $2
+		ORDER BY e.user_id, o.completed_at, o.id
+	`
+	rows, err := r.db.Conn().Query(query, from, to)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get completed work: %v", err)
+	}
+	defer rows.Close()
+
+	var works []models.PayrollWork
+	for rows.Next() {
+		var work models.PayrollWork
+		var startedAt sql.NullTime
+		if err := rows.Scan(
+			&work.UserID, &work.Name, &work.Role, &work.OrderID, &work.Category, &work.Subcategory,
+			&work.Cost, &startedAt, &work.CompletedAt,
+		); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		if startedAt.Valid {
+			work.StartedAt = startedAt.Time
+		}
+		works = append(works, work)
+	}
+	return works, nil
+}
+
+// CreatePayrollRun stores a payroll run with its lines in one transaction
+func (r *PostgresRepository) CreatePayrollRun(run *models.PayrollRun) error {
+	tx, err := r.db.Conn().Begin()
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO payroll_runs (period_start, period_end, status, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	if err := tx.QueryRow(query, run.From, run.To, run.Status, run.CreatedBy, run.CreatedAt).Scan(&run.ID); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to create payroll run: %v", err)
+	}
+
+	lineQuery := `
+		INSERT INTO payroll_lines (run_id, user_id, name, role, orders, minutes, per_order_pay, hourly_pay,
+			percent_pay, bonus_pay, total, no_rule, items)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id
+	`
+	for i := range run.Lines {
+		line := &run.Lines[i]
+		line.RunID = run.ID
+		items, err := json.Marshal(line.Items)
+		if err != nil {
+			return fmt.Errorf("failed to encode payroll items: %v", err)
+		}
+		err = tx.QueryRow(
+			lineQuery,
+			line.RunID, line.UserID, line.Name, line.Role, line.Orders, line.Minutes, line.PerOrderPay, line.HourlyPay,
+			line.PercentPay, line.BonusPay, line.Total, line.NoRule, items,
+		).Scan(&line.ID)
+		if err != nil {
+			utils.LogError(err)
+			return fmt.Errorf("failed to create payroll line: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to commit payroll run: %v", err)
+	}
+	return nil
+}
+
+// GetPayrollRun retrieves a payroll run with its lines
+func (r *PostgresRepository) GetPayrollRun(id int) (*models.PayrollRun, error) {
+	query := `
+		SELECT id, period_start, period_end, status, created_by, approved_by, approved_at, created_at
+		FROM payroll_runs
+		WHERE id = $1
+	`
+	var run models.PayrollRun
+	var approvedBy sql.NullInt64
+	var approvedAt sql.NullTime
+	err := r.db.Conn().QueryRow(query, id).Scan(
+		&run.ID, &run.From, &run.To, &run.Status, &run.CreatedBy, &approvedBy, &approvedAt, &run.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("payroll run not found")
+	}
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get payroll run: %v", err)
+	}
+	run.ApprovedBy = approvedBy.Int64
+	if approvedAt.Valid {
+		run.ApprovedAt = approvedAt.Time
+	}
+
+	lineQuery := `
+		SELECT id, run_id, user_id, name, role, orders, minutes, per_order_pay, hourly_pay,
+		       percent_pay, bonus_pay, total, no_rule, items
+		FROM payroll_lines
+		WHERE run_id = $1
+		ORDER BY id
+	`
+	rows, err := r.db.Conn().Query(lineQuery, id)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get payroll lines: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line models.PayrollLine
+		var items []byte
+		if err := rows.Scan(
+			&line.ID, &line.RunID, &line.UserID, &line.Name, &line.Role, &line.Orders, &line.Minutes, &line.PerOrderPay,
+			&line.HourlyPay, &line.PercentPay, &line.BonusPay, &line.Total, &line.NoRule, &items,
+		); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		if err := json.Unmarshal(items, &line.Items); err != nil {
+			utils.LogError(err)
+		}
+		run.Lines = append(run.Lines, line)
+	}
+	return &run, nil
+}
+
+// UpdatePayrollRunStatus moves a run from one status to another, reporting false if it was no longer in the first
+func (r *PostgresRepository) UpdatePayrollRunStatus(id int, from, to string, approvedBy int64, approvedAt time.Time) (bool, error) {
+	query := `
+		UPDATE payroll_runs
+		SET status = $1, approved_by = $2, approved_at = $3
+		WHERE id = $4 AND status = $5
+	`
+	result, err := r.db.Conn().Exec(query, to, approvedBy, approvedAt, id, from)
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to update payroll run: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to update payroll run: %v", err)
+	}
+	return affected > 0, nil
+}
+
+// ApprovePayrollRun approves a draft run and stores its salary entries in one transaction,
+// reporting false if the run was no longer a draft
+func (r *PostgresRepository) ApprovePayrollRun(id int, approvedBy int64, approvedAt time.Time, salaries []*models.LedgerEntry) (bool, error) {
+	tx, err := r.db.Conn().Begin()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// The payroll_runs_no_overlap constraint catches runs approved concurrently
+	query := `
+		UPDATE payroll_runs r
+		SET status = 'approved', approved_by = $1, approved_at = $2
+		WHERE r.id = $3 AND r.status = 'draft'
+		  AND NOT EXISTS (
+			SELECT 1 FROM payroll_runs o
+			WHERE o.status = 'approved' AND o.period_start < r.period_end AND o.period_end > r.period_start
+		  )
+	`
+	result, err := tx.Exec(query, approvedBy, approvedAt, id)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23P01" { // exclusion_violation
+		return false, ErrPeriodOverlap
+	}
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to approve payroll run: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to approve payroll run: %v", err)
+	}
+	if affected == 0 {
+		var status string
+		if err := tx.QueryRow(`SELECT status FROM payroll_runs WHERE id = $1`, id).Scan(&status); err != nil {
+			utils.LogError(err)
+			return false, fmt.Errorf("failed to get payroll run: %v", err)
+		}
+		if status == "draft" {
+			return false, ErrPeriodOverlap
+		}
+		return false, nil
+	}
+
+	for _, entry := range salaries {
+		if err := accounting.InsertEntry(tx, entry); err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to commit payroll approval: %v", err)
+	}
+	return true, nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPayRule reads a pay rule selected with payRuleColumns
+func scanPayRule(row rowScanner) (*models.PayRule, error) {
+	var rule models.PayRule
+	var bonuses []byte
+	if err := row.Scan(&rule.UserID, &rule.PerOrder, &rule.PerHour, &rule.Percent, &bonuses, &rule.UpdatedAt); err != nil {
+		return nil, err
+	}
+	rule.CategoryBonuses = map[string]models.Money{}
+	if len(bonuses) > 0 {
+		if err := json.Unmarshal(bonuses, &rule.CategoryBonuses); err != nil {
+			return nil, fmt.Errorf("failed to decode category bonuses: %v", err)
+		}
+	}
+	return &rule, nil
+}
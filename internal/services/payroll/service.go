@@ -0,0 +1,356 @@
+package payroll
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/accounting"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// Payroll run statuses
+const (
+	StatusDraft    = "draft"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// maxShift caps the time counted for an order; longer spans mean the start time was not really kept
+const maxShift = 16 * time.Hour
+
+// Service handles payroll business logic
+type Service struct {
+	bot  *tgbotapi.BotAPI
+	repo Repository
+}
+
+// Repository defines the interface for payroll data access
+type Repository interface {
+	GetPayRule(userID int64) (*models.PayRule, error)
+	GetPayRules() ([]models.PayRule, error)
+	SavePayRule(rule *models.PayRule) error
+	GetCompletedWork(from, to time.Time) ([]models.PayrollWork, error)
+	CreatePayrollRun(run *models.PayrollRun) error
+	GetPayrollRun(id int) (*models.PayrollRun, error)
+	UpdatePayrollRunStatus(id int, from, to string, approvedBy int64, approvedAt time.Time) (bool, error)
+	ApprovePayrollRun(id int, approvedBy int64, approvedAt time.Time, salaries []*models.LedgerEntry) (bool, error)
+}
+
+// ErrPeriodOverlap is returned when an approved payroll already covers part of a run's period
+var ErrPeriodOverlap = errors.New("an approved payroll already covers part of this period")
+
+// NewService creates a new payroll service
+func NewService(bot *tgbotapi.BotAPI, repo Repository) *Service {
+	return &Service{
+		bot:  bot,
+		repo: repo,
+	}
+}
+
+// SetPayRule validates and stores an executor's pay rule
+func (s *Service) SetPayRule(rule *models.PayRule) error {
+	if rule == nil || rule.UserID <= 0 {
+		return errors.New("invalid user ID")
+	}
+	if rule.PerOrder < 0 || rule.PerHour < 0 {
+		return errors.New("rates must not be negative")
+	}
+	if math.IsNaN(rule.Percent) || rule.Percent < 0 || rule.Percent > 100 {
+		return errors.New("percent must be between 0 and 100")
+	}
+	for category, bonus := range rule.CategoryBonuses {
+		if category == "" || bonus < 0 {
+			return errors.New("invalid category bonus")
+		}
+	}
+	rule.UpdatedAt = time.Now()
+	return s.repo.SavePayRule(rule)
+}
+
+// GetPayRule retrieves an executor's pay rule, or nil if none is set
+func (s *Service) GetPayRule(userID int64) (*models.PayRule, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+	return s.repo.GetPayRule(userID)
+}
+
+// GetPayRules retrieves all pay rules
+func (s *Service) GetPayRules() ([]models.PayRule, error) {
+	return s.repo.GetPayRules()
+}
+
+// ParsePayRule reads rule parts like "order=1500 hour=300 percent=5 bonus:demolition=500"
+func ParsePayRule(userID int64, fields []string) (*models.PayRule, error) {
+	rule := &models.PayRule{UserID: userID, CategoryBonuses: map[string]models.Money{}}
+	if len(fields) == 0 {
+		return nil, errors.New("no pay rule parts given")
+	}
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected key=value: %s", field)
+		}
+		if key == "percent" {
+			percent, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+			if err != nil || math.IsNaN(percent) || math.IsInf(percent, 0) {
+				return nil, fmt.Errorf("invalid percent: %s", value)
+			}
+			rule.Percent = percent
+			continue
+		}
+		amount, err := models.ParseMoney(value)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case key == "order":
+			rule.PerOrder = amount
+		case key == "hour":
+			rule.PerHour = amount
+		case strings.HasPrefix(key, "bonus:"):
+			rule.CategoryBonuses[strings.TrimPrefix(key, "bonus:")] = amount
+		default:
+			return nil, fmt.Errorf("unknown pay rule part: %s", key)
+		}
+	}
+	return rule, nil
+}
+
+// FormatPayRule renders a pay rule
+func FormatPayRule(rule *models.PayRule) string {
+	text := fmt.Sprintf("💼 Ставка сотрудника %d\nЗа заказ: %s руб.\nЗа час: %s руб.\nПроцент от стоимости: %g%%",
+		rule.UserID, rule.PerOrder, rule.PerHour, rule.Percent)
+	for category, bonus := range rule.CategoryBonuses {
+		text += fmt.Sprintf("\nБонус «%s»: %s руб.", category, bonus)
+	}
+	return text
+}
+
+// ComputePay computes an executor's pay for the completed orders they worked on
+func ComputePay(rule *models.PayRule, works []models.PayrollWork) models.PayrollLine {
+	var line models.PayrollLine
+	if len(works) > 0 {
+		line.UserID = works[0].UserID
+		line.Name = works[0].Name
+		line.Role = works[0].Role
+	}
+	line.NoRule = rule == nil
+
+	for _, work := range works {
+		item := models.PayrollItem{
+			OrderID:  work.OrderID,
+			Category: work.Category,
+			Cost:     models.NewMoney(work.Cost),
+			Minutes:  workMinutes(work),
+		}
+		line.Orders++
+		line.Minutes += item.Minutes
+		if rule != nil {
+			hourly := models.Money(math.Round(float64(rule.PerHour) * float64(item.Minutes) / 60))
+			percent := models.Money(math.Round(float64(item.Cost) * rule.Percent / 100))
+			bonus := rule.CategoryBonuses[work.Category]
+			line.PerOrderPay += rule.PerOrder
+			line.HourlyPay += hourly
+			line.PercentPay += percent
+			line.BonusPay += bonus
+			item.Amount = rule.PerOrder + hourly + percent + bonus
+		}
+		line.Total += item.Amount
+		line.Items = append(line.Items, item)
+	}
+	return line
+}
+
+// workMinutes counts the minutes from the start of work to completion of an order
+func workMinutes(work models.PayrollWork) int {
+	if work.StartedAt.IsZero() || work.CompletedAt.IsZero() {
+		return 0
+	}
+	d := work.CompletedAt.Sub(work.StartedAt)
+	if d <= 0 || d > maxShift {
+		return 0
+	}
+	return int(d / time.Minute)
+}
+
+// PrepareRun computes pay for every executor of orders completed in [from, to) and saves it as a draft
+func (s *Service) PrepareRun(from, to time.Time, createdBy int64) (*models.PayrollRun, error) {
+	if !to.After(from) {
+		return nil, errors.New("invalid payroll period")
+	}
+	if createdBy <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+
+	works, err := s.repo.GetCompletedWork(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get completed work: %v", err)
+	}
+	rules, err := s.repo.GetPayRules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pay rules: %v", err)
+	}
+	byUser := make(map[int64]*models.PayRule, len(rules))
+	for i := range rules {
+		byUser[rules[i].UserID] = &rules[i]
+	}
+
+	run := &models.PayrollRun{
+		From:      from,
+		To:        to,
+		Status:    StatusDraft,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	var order []int64
+	grouped := make(map[int64][]models.PayrollWork)
+	for _, work := range works {
+		if _, ok := grouped[work.UserID]; !ok {
+			order = append(order, work.UserID)
+		}
+		grouped[work.UserID] = append(grouped[work.UserID], work)
+	}
+	for _, userID := range order {
+		run.Lines = append(run.Lines, ComputePay(byUser[userID], grouped[userID]))
+	}
+
+	if err := s.repo.CreatePayrollRun(run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// GetRun retrieves a payroll run with its lines
+func (s *Service) GetRun(runID int) (*models.PayrollRun, error) {
+	if runID <= 0 {
+		return nil, errors.New("invalid payroll run ID")
+	}
+	return s.repo.GetPayrollRun(runID)
+}
+
+// Approve records the salaries of a draft run in the ledger together with the status change and sends everyone their payslip
+func (s *Service) Approve(runID int, approverID int64) (*models.PayrollRun, error) {
+	run, err := s.GetRun(runID)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status != StatusDraft {
+		return nil, fmt.Errorf("payroll run already %s", run.Status)
+	}
+
+	now := time.Now()
+	description := fmt.Sprintf("Зарплата за %s (ведомость #%d)", periodLabel(run), run.ID)
+	var salaries []*models.LedgerEntry
+	for _, line := range run.Lines {
+		if line.Total <= 0 {
+			continue
+		}
+		entry, err := accounting.SalaryEntry(line.UserID, line.Total, accounting.AccountCashDesk, description)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare salary of %d: %v", line.UserID, err)
+		}
+		entry.CreatedAt = now
+		salaries = append(salaries, entry)
+	}
+
+	approved, err := s.repo.ApprovePayrollRun(run.ID, approverID, now, salaries)
+	if err != nil {
+		return nil, err
+	}
+	if !approved {
+		return nil, errors.New("payroll run already reviewed")
+	}
+	run.Status = StatusApproved
+	run.ApprovedBy = approverID
+	run.ApprovedAt = now
+
+	for i := range run.Lines {
+		line := &run.Lines[i]
+		if line.Total <= 0 {
+			continue
+		}
+		if _, err := s.bot.Send(tgbotapi.NewMessage(line.UserID, FormatPayslip(run, line))); err != nil {
+			utils.LogError(err)
+		}
+	}
+	return run, nil
+}
+
+// Reject discards a draft run
+func (s *Service) Reject(runID int, approverID int64) (*models.PayrollRun, error) {
+	run, err := s.GetRun(runID)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.repo.UpdatePayrollRunStatus(run.ID, StatusDraft, StatusRejected, approverID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, fmt.Errorf("payroll run already %s", run.Status)
+	}
+	run.Status = StatusRejected
+	return run, nil
+}
+
+// FormatRun renders a payroll run summary for the owner
+func FormatRun(run *models.PayrollRun) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "💼 Ведомость #%d за %s\n", run.ID, periodLabel(run))
+	if len(run.Lines) == 0 {
+		b.WriteString("\nВыполненных заказов за период нет.")
+		return b.String()
+	}
+	var total models.Money
+	for _, line := range run.Lines {
+		total += line.Total
+		if line.NoRule {
+			fmt.Fprintf(&b, "\n%s (%s): %d зак. — ⚠️ ставка не задана", displayName(line), line.Role, line.Orders)
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s (%s): %d зак., %s ч — %s руб.", displayName(line), line.Role, line.Orders, hours(line.Minutes), line.Total)
+	}
+	fmt.Fprintf(&b, "\n\nИтого к выплате: %s руб.", total)
+	return b.String()
+}
+
+// FormatPayslip renders one person's payslip
+func FormatPayslip(run *models.PayrollRun, line *models.PayrollLine) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🧾 Расчётный листок\nПериод: %s\n\n", periodLabel(run))
+	fmt.Fprintf(&b, "Заказов: %d, часов: %s\n", line.Orders, hours(line.Minutes))
+	fmt.Fprintf(&b, "За заказы: %s руб.\n", line.PerOrderPay)
+	fmt.Fprintf(&b, "Почасовая оплата: %s руб.\n", line.HourlyPay)
+	fmt.Fprintf(&b, "Процент от заказов: %s руб.\n", line.PercentPay)
+	fmt.Fprintf(&b, "Бонусы: %s руб.\n", line.BonusPay)
+	b.WriteString("\n")
+	for _, item := range line.Items {
+		fmt.Fprintf(&b, "#%d %s — %s руб.\n", item.OrderID, item.Category, item.Amount)
+	}
+	fmt.Fprintf(&b, "\nИтого начислено: %s руб.", line.Total)
+	return b.String()
+}
+
+// periodLabel formats the inclusive dates of a run
+func periodLabel(run *models.PayrollRun) string {
+	return fmt.Sprintf("%s — %s", run.From.Format("02.01.2006"), run.To.Add(-time.Nanosecond).Format("02.01.2006"))
+}
+
+// displayName returns the executor's name or ID
+func displayName(line models.PayrollLine) string {
+	if line.Name != "" {
+		return line.Name
+	}
+	return strconv.FormatInt(line.UserID, 10)
+}
+
+// hours formats minutes as hours with one decimal
+func hours(minutes int) string {
+	return strconv.FormatFloat(float64(minutes)/60, 'f', 1, 64)
+}
@@ -0,0 +1,205 @@
+package payroll_test
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+	"time"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/accounting"
+	"github.com/skyzeper/telegram-bot/internal/services/payroll"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository is a mock implementation of payroll.Repository
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) GetPayRule(userID int64) (*models.PayRule, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PayRule), args.Error(1)
+}
+
+func (m *MockRepository) GetPayRules() ([]models.PayRule, error) {
+	args := m.Called()
+	return args.Get(0).([]models.PayRule), args.Error(1)
+}
+
+func (m *MockRepository) SavePayRule(rule *models.PayRule) error {
+	args := m.Called(rule)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetCompletedWork(from, to time.Time) ([]models.PayrollWork, error) {
+	args := m.Called(from, to)
+	return args.Get(0).([]models.PayrollWork), args.Error(1)
+}
+
+func (m *MockRepository) CreatePayrollRun(run *models.PayrollRun) error {
+	args := m.Called(run)
+	run.ID = 1
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetPayrollRun(id int) (*models.PayrollRun, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PayrollRun), args.Error(1)
+}
+
+func (m *MockRepository) UpdatePayrollRunStatus(id int, from, to string, approvedBy int64, approvedAt time.Time) (bool, error) {
+	args := m.Called(id, from, to, approvedBy, approvedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) ApprovePayrollRun(id int, approvedBy int64, approvedAt time.Time, salaries []*models.LedgerEntry) (bool, error) {
+	args := m.Called(id, approvedBy, approvedAt, salaries)
+	return args.Bool(0), args.Error(1)
+}
+
+// newTestBot creates a bot talking to a fake Bot API server and records message recipients
+func newTestBot(t *testing.T) (*tgbotapi.BotAPI, func() []string) {
+	var mu sync.Mutex
+	var chats []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path.Base(r.URL.Path) == "sendMessage" && r.ParseForm() == nil {
+			mu.Lock()
+			chats = append(chats, r.FormValue("chat_id"))
+			mu.Unlock()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot","message_id":1,"date":0,"chat":{"id":1}}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint("test-token", server.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatalf("failed to create test bot: %v", err)
+	}
+	return bot, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), chats...)
+	}
+}
+
+func TestComputePay(t *testing.T) {
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	rule := &models.PayRule{
+		UserID:          200,
+		PerOrder:        100000,
+		PerHour:         30000,
+		Percent:         5,
+		CategoryBonuses: map[string]models.Money{"demolition": 50000},
+	}
+	works := []models.PayrollWork{
+		{UserID: 200, Name: "Иван", Role: "driver", OrderID: 10, Category: "waste_removal", Cost: 5000, StartedAt: start, CompletedAt: start.Add(90 * time.Minute)},
+		{UserID: 200, Name: "Иван", Role: "driver", OrderID: 11, Category: "demolition", Cost: 12000.50},
+		// A span longer than a shift is not counted as worked time
+		{UserID: 200, Name: "Иван", Role: "driver", OrderID: 12, Category: "waste_removal", Cost: 0, StartedAt: start, CompletedAt: start.Add(40 * time.Hour)},
+	}
+
+	line := payroll.ComputePay(rule, works)
+	assert.Equal(t, 3, line.Orders)
+	assert.Equal(t, 90, line.Minutes)
+	assert.Equal(t, models.Money(300000), line.PerOrderPay)
+	assert.Equal(t, models.Money(45000), line.HourlyPay)
+	assert.Equal(t, models.Money(25000+60003), line.PercentPay)
+	assert.Equal(t, models.Money(50000), line.BonusPay)
+	assert.Equal(t, line.PerOrderPay+line.HourlyPay+line.PercentPay+line.BonusPay, line.Total)
+	assert.Len(t, line.Items, 3)
+	assert.Equal(t, models.Money(100000+45000+25000), line.Items[0].Amount)
+
+	noRule := payroll.ComputePay(nil, works[:1])
+	assert.True(t, noRule.NoRule)
+	assert.Equal(t, models.Money(0), noRule.Total)
+}
+
+func TestParsePayRule(t *testing.T) {
+	rule, err := payroll.ParsePayRule(200, []string{"order=1500", "hour=300,50", "percent=2.5", "bonus:demolition=500"})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Money(150000), rule.PerOrder)
+	assert.Equal(t, models.Money(30050), rule.PerHour)
+	assert.Equal(t, 2.5, rule.Percent)
+	assert.Equal(t, models.Money(50000), rule.CategoryBonuses["demolition"])
+
+	_, err = payroll.ParsePayRule(200, []string{"daily=1000"})
+	assert.Error(t, err)
+	_, err = payroll.ParsePayRule(200, nil)
+	assert.Error(t, err)
+	for _, percent := range []string{"percent=NaN", "percent=Inf", "percent=-Inf"} {
+		_, err = payroll.ParsePayRule(200, []string{percent})
+		assert.Error(t, err, percent)
+	}
+
+	service := payroll.NewService(nil, new(MockRepository))
+	assert.Error(t, service.SetPayRule(&models.PayRule{UserID: 200, Percent: 150}))
+	assert.Error(t, service.SetPayRule(&models.PayRule{UserID: 200, Percent: math.NaN()}))
+}
+
+func TestService_PrepareAndApproveRun(t *testing.T) {
+	bot, sent := newTestBot(t)
+	mockRepo := new(MockRepository)
+	service := payroll.NewService(bot, mockRepo)
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	mockRepo.On("GetCompletedWork", from, to).Return([]models.PayrollWork{
+		{UserID: 200, Name: "Иван", Role: "driver", OrderID: 10, Category: "waste_removal", Cost: 5000},
+		{UserID: 300, Name: "Пётр", Role: "loader", OrderID: 10, Category: "waste_removal", Cost: 5000},
+	}, nil).Once()
+	mockRepo.On("GetPayRules").Return([]models.PayRule{{UserID: 200, PerOrder: 200000}}, nil).Once()
+	mockRepo.On("CreatePayrollRun", mock.MatchedBy(func(run *models.PayrollRun) bool {
+		return run.Status == payroll.StatusDraft && len(run.Lines) == 2 && run.Lines[1].NoRule
+	})).Return(nil).Once()
+
+	run, err := service.PrepareRun(from, to, 1)
+	assert.NoError(t, err)
+	assert.Contains(t, payroll.FormatRun(run), "ставка не задана")
+
+	mockRepo.On("GetPayrollRun", 1).Return(run, nil).Once()
+	mockRepo.On("ApprovePayrollRun", 1, int64(1), mock.AnythingOfType("time.Time"), mock.MatchedBy(func(salaries []*models.LedgerEntry) bool {
+		// Only the person with pay due is paid, from the cash desk
+		return len(salaries) == 1 && salaries[0].Kind == accounting.EntrySalary && salaries[0].UserID == 200 &&
+			salaries[0].Description == "Зарплата за 01.03.2024 — 31.03.2024 (ведомость #1)" &&
+			salaries[0].Postings[0].Account == accounting.AccountSalaries && salaries[0].Postings[0].Amount == models.Money(200000) &&
+			salaries[0].Postings[1].Account == accounting.AccountCashDesk
+	})).Return(true, nil).Once()
+
+	approved, err := service.Approve(1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, payroll.StatusApproved, approved.Status)
+	// Only the person with pay due gets a payslip
+	assert.Equal(t, []string{"200"}, sent())
+
+	mockRepo.On("GetPayrollRun", 1).Return(approved, nil).Once()
+	_, err = service.Approve(1, 1)
+	assert.Error(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_ApproveOverlappingRun(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := payroll.NewService(nil, mockRepo)
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	mockRepo.On("GetPayrollRun", 2).Return(&models.PayrollRun{ID: 2, From: from, To: to, Status: payroll.StatusDraft}, nil).Once()
+	mockRepo.On("ApprovePayrollRun", 2, int64(1), mock.AnythingOfType("time.Time"), mock.Anything).Return(false, payroll.ErrPeriodOverlap).Once()
+
+	_, err := service.Approve(2, 1)
+	assert.Equal(t, payroll.ErrPeriodOverlap, err)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,191 @@
+package referral
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"strings"
+	"github.com/skip2/go-qrcode"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// QRConfig holds branding and encoding options for referral QR codes
+type QRConfig struct {
+	Size          int
+	RecoveryLevel qrcode.RecoveryLevel
+	LogoPath      string
+	Caption       string
+}
+
+// DefaultQRConfig returns the QR options used when nothing is configured
+func DefaultQRConfig() QRConfig {
+	return QRConfig{
+		Size:          512,
+		RecoveryLevel: qrcode.High,
+		Caption:       "Сканируйте и закажите вывоз мусора!",
+	}
+}
+
+// ParseRecoveryLevel converts a config value (low, medium, high, highest) to a QR recovery level
+func ParseRecoveryLevel(level string) (qrcode.RecoveryLevel, error) {
+	switch strings.ToLower(level) {
+	case "low":
+		return qrcode.Low, nil
+	case "medium":
+		return qrcode.Medium, nil
+	case "", "high":
+		return qrcode.High, nil
+	case "highest":
+		return qrcode.Highest, nil
+	default:
+		return qrcode.High, fmt.Errorf("unknown QR recovery level: %s", level)
+	}
+}
+
+// qrRenderer draws branded QR codes in memory
+type qrRenderer struct {
+	cfg  QRConfig
+	logo image.Image
+	font *opentype.Font
+}
+
+// newQRRenderer prepares the logo and caption font once for all QR codes
+func newQRRenderer(cfg QRConfig) *qrRenderer {
+	defaults := DefaultQRConfig()
+	if cfg.Size < 128 {
+		cfg.Size = defaults.Size
+	}
+
+	r := &qrRenderer{cfg: cfg}
+	if cfg.LogoPath != "" {
+		logo, err := loadImage(cfg.LogoPath)
+		if err != nil {
+			utils.LogError(fmt.Errorf("failed to load QR logo: %v", err))
+		} else {
+			r.logo = logo
+		}
+	}
+	if cfg.Caption != "" {
+		f, err := opentype.Parse(gobold.TTF)
+		if err != nil {
+			utils.LogError(fmt.Errorf("failed to parse caption font: %v", err))
+		} else {
+			r.font = f
+		}
+	}
+	return r
+}
+
+// render encodes the link and returns the branded PNG image
+func (r *qrRenderer) render(link, caption string) ([]byte, error) {
+	if link == "" {
+		return nil, errors.New("empty referral link")
+	}
+
+	q, err := qrcode.New(link, r.cfg.RecoveryLevel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QR code: %v", err)
+	}
+
+	size := r.cfg.Size
+	captionHeight := 0
+	if caption != "" && r.font != nil {
+		captionHeight = size / 6
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, size, size+captionHeight))
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(canvas, image.Rect(0, 0, size, size), q.Image(size), image.Point{}, draw.Src)
+
+	if r.logo != nil {
+		r.drawLogo(canvas, size)
+	}
+	if captionHeight > 0 {
+		if err := r.drawCaption(canvas, caption, size, captionHeight); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// drawLogo places the logo on a white plate in the middle of the QR code
+func (r *qrRenderer) drawLogo(canvas *image.RGBA, size int) {
+	logoSize := size / 5
+	padding := logoSize / 10
+	offset := (size - logoSize) / 2
+
+	plate := image.Rect(offset-padding, offset-padding, offset+logoSize+padding, offset+logoSize+padding)
+	draw.Draw(canvas, plate, image.White, image.Point{}, draw.Src)
+
+	bounds := r.logo.Bounds()
+	w, h := logoSize, logoSize
+	if bounds.Dx() > bounds.Dy() {
+		h = logoSize * bounds.Dy() / bounds.Dx()
+	} else if bounds.Dy() > bounds.Dx() {
+		w = logoSize * bounds.Dx() / bounds.Dy()
+	}
+	x := offset + (logoSize-w)/2
+	y := offset + (logoSize-h)/2
+	draw.CatmullRom.Scale(canvas, image.Rect(x, y, x+w, y+h), r.logo, bounds, draw.Over, nil)
+}
+
+// drawCaption writes the caption centered below the QR code, shrinking it to fit
+func (r *qrRenderer) drawCaption(canvas *image.RGBA, caption string, size, height int) error {
+	fontSize := float64(height) / 2.5
+	var face font.Face
+	var width fixed.Int26_6
+	for {
+		f, err := opentype.NewFace(r.font, &opentype.FaceOptions{Size: fontSize, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return fmt.Errorf("failed to create caption font: %v", err)
+		}
+		width = font.MeasureString(f, caption)
+		if width.Ceil() <= size-size/10 || fontSize <= 8 {
+			face = f
+			break
+		}
+		f.Close()
+		fontSize *= 0.9
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	textHeight := (metrics.Ascent + metrics.Descent).Ceil()
+	d := &font.Drawer{
+		Dst:  canvas,
+		Src:  image.NewUniform(color.Black),
+		Face: face,
+		Dot: fixed.P(
+			(size-width.Ceil())/2,
+			size+(height-textHeight)/2+metrics.Ascent.Ceil(),
+		),
+	}
+	d.DrawString(caption)
+	return nil
+}
+
+// loadImage reads a PNG or JPEG image from disk
+func loadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
\ No newline at end of file
@@ -0,0 +1,83 @@
+package referral_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"github.com/skyzeper/telegram-bot/internal/services/referral"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestService_GenerateQRCode(t *testing.T) {
+	t.Run("WithCaption", func(t *testing.T) {
+		cfg := referral.DefaultQRConfig()
+		cfg.Size = 256
+		service := referral.NewService(new(MockRepository), cfg)
+
+		data, err := service.GenerateQRCode(service.ReferralLink(42))
+		assert.NoError(t, err)
+
+		img, err := png.Decode(bytes.NewReader(data))
+		assert.NoError(t, err)
+		assert.Equal(t, 256, img.Bounds().Dx())
+		assert.Greater(t, img.Bounds().Dy(), 256)
+	})
+
+	t.Run("WithLogo", func(t *testing.T) {
+		logo := image.NewRGBA(image.Rect(0, 0, 40, 20))
+		for x := 0; x < 40; x++ {
+			for y := 0; y < 20; y++ {
+				logo.Set(x, y, color.RGBA{R: 255, A: 255})
+			}
+		}
+		logoPath := filepath.Join(t.TempDir(), "logo.png")
+		f, err := os.Create(logoPath)
+		assert.NoError(t, err)
+		assert.NoError(t, png.Encode(f, logo))
+		f.Close()
+
+		cfg := referral.DefaultQRConfig()
+		cfg.Size = 256
+		cfg.Caption = ""
+		cfg.LogoPath = logoPath
+		service := referral.NewService(new(MockRepository), cfg)
+
+		data, err := service.GenerateQRCode(service.ReferralLink(42))
+		assert.NoError(t, err)
+
+		img, err := png.Decode(bytes.NewReader(data))
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 256, 256), img.Bounds())
+		r, g, b, _ := img.At(128, 128).RGBA()
+		assert.Equal(t, [3]uint32{0xffff, 0, 0}, [3]uint32{r, g, b})
+	})
+
+	t.Run("EmptyLink", func(t *testing.T) {
+		service := referral.NewService(new(MockRepository), referral.DefaultQRConfig())
+		_, err := service.GenerateQRCode("")
+		assert.Error(t, err)
+	})
+}
+
+func TestService_QRCodeCache(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := referral.NewService(mockRepo, referral.DefaultQRConfig())
+	link := service.ReferralLink(42)
+
+	var fingerprint string
+	mockRepo.On("SaveQRFileID", int64(42), mock.AnythingOfType("string"), "file-1").
+		Run(func(args mock.Arguments) { fingerprint = args.String(1) }).
+		Return(nil).Once()
+	assert.NoError(t, service.CacheQRCode(42, link, "file-1"))
+
+	mockRepo.On("GetQRFileID", int64(42), fingerprint).Return("file-1", nil).Once()
+	fileID, err := service.GetCachedQRCode(42, link)
+	assert.NoError(t, err)
+	assert.Equal(t, "file-1", fileID)
+	mockRepo.AssertExpectations(t)
+}
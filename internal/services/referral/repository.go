@@ -21,13 +21,14 @@ func NewPostgresRepository(db *db.DB) *PostgresRepository {
 // CreateReferral creates a new referral
 func (r *PostgresRepository) CreateReferral(referral *models.Referral) error {
 	query := `
-		INSERT INTO referrals (inviter_id, invitee_id, order_id, payout_requested, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO referrals (inviter_id, invitee_id, order_id, campaign_id, payout_requested, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id
 	`
+	inviterID, orderID, campaignID := referralNullables(referral)
 	err := r.db.Conn().QueryRow(
 		query,
-		referral.InviterID, referral.InviteeID, referral.OrderID, referral.PayoutRequested, referral.CreatedAt,
+		inviterID, referral.InviteeID, orderID, campaignID, referral.PayoutRequested, referral.CreatedAt,
 	).Scan(&referral.ID)
 	if err != nil {
 		utils.LogError(err)
@@ -39,29 +40,31 @@ func (r *PostgresRepository) CreateReferral(referral *models.Referral) error {
 // GetReferralByInvitee retrieves a referral by invitee ID
 func (r *PostgresRepository) GetReferralByInvitee(inviteeID int64) (*models.Referral, error) {
 	query := `
-		SELECT id, inviter_id, invitee_id, order_id, payout_requested, created_at
+		SELECT id, inviter_id, invitee_id, order_id, campaign_id, payout_requested, created_at
 		FROM referrals
 		WHERE invitee_id = $1
 	`
 	referral := &models.Referral{}
+	var inviterID, orderID, campaignID sql.NullInt64
 	err := r.db.Conn().QueryRow(query, inviteeID).Scan(
-		&referral.ID, &referral.InviterID, &referral.InviteeID, &referral.OrderID,
+		&referral.ID, &inviterID, &referral.InviteeID, &orderID, &campaignID,
 		&referral.PayoutRequested, &referral.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("referral not found")
+		return nil, ErrReferralNotFound
 	}
 	if err != nil {
 		utils.LogError(err)
 		return nil, fmt.Errorf("failed to get referral: %v", err)
 	}
+	applyReferralNullables(referral, inviterID, orderID, campaignID)
 	return referral, nil
 }
 
 // GetReferralsByInviter retrieves all referrals by inviter
 func (r *PostgresRepository) GetReferralsByInviter(inviterID int64) ([]models.Referral, error) {
 	query := `
-		SELECT id, inviter_id, invitee_id, order_id, payout_requested, created_at
+		SELECT id, inviter_id, invitee_id, order_id, campaign_id, payout_requested, created_at
 		FROM referrals
 		WHERE inviter_id = $1
 	`
@@ -75,13 +78,15 @@ func (r *PostgresRepository) GetReferralsByInviter(inviterID int64) ([]models.Re
 	var referrals []models.Referral
 	for rows.Next() {
 		var referral models.Referral
+		var inviter, orderID, campaignID sql.NullInt64
 		if err := rows.Scan(
-			&referral.ID, &referral.InviterID, &referral.InviteeID, &referral.OrderID,
+			&referral.ID, &inviter, &referral.InviteeID, &orderID, &campaignID,
 			&referral.PayoutRequested, &referral.CreatedAt,
 		); err != nil {
 			utils.LogError(err)
 			continue
 		}
+		applyReferralNullables(&referral, inviter, orderID, campaignID)
 		referrals = append(referrals, referral)
 	}
 	return referrals, nil
@@ -91,12 +96,13 @@ func (r *PostgresRepository) GetReferralsByInviter(inviterID int64) ([]models.Re
 func (r *PostgresRepository) UpdateReferral(referral *models.Referral) error {
 	query := `
 		UPDATE referrals
-		SET inviter_id = $1, invitee_id = $2, order_id = $3, payout_requested = $4, created_at = $5
-		WHERE id = $6
+		SET inviter_id = $1, invitee_id = $2, order_id = $3, campaign_id = $4, payout_requested = $5, created_at = $6
+		WHERE id = $7
 	`
+	inviterID, orderID, campaignID := referralNullables(referral)
 	_, err := r.db.Conn().Exec(
 		query,
-		referral.InviterID, referral.InviteeID, referral.OrderID, referral.PayoutRequested,
+		inviterID, referral.InviteeID, orderID, campaignID, referral.PayoutRequested,
 		referral.CreatedAt, referral.ID,
 	)
 	if err != nil {
@@ -104,4 +110,138 @@ func (r *PostgresRepository) UpdateReferral(referral *models.Referral) error {
 		return fmt.Errorf("failed to update referral: %v", err)
 	}
 	return nil
+}
+
+// CreateCampaign creates a new campaign code
+func (r *PostgresRepository) CreateCampaign(campaign *models.Campaign) error {
+	query := `
+		INSERT INTO referral_campaigns (code, name, created_by, is_active, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	err := r.db.Conn().QueryRow(
+		query,
+		campaign.Code, campaign.Name, campaign.CreatedBy, campaign.IsActive, campaign.CreatedAt,
+	).Scan(&campaign.ID)
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to create campaign: %v", err)
+	}
+	return nil
+}
+
+// GetCampaignByCode retrieves a campaign by its code
+func (r *PostgresRepository) GetCampaignByCode(code string) (*models.Campaign, error) {
+	query := `
+		SELECT id, code, name, created_by, is_active, created_at
+		FROM referral_campaigns
+		WHERE code = $1
+	`
+	campaign := &models.Campaign{}
+	err := r.db.Conn().QueryRow(query, code).Scan(
+		&campaign.ID, &campaign.Code, &campaign.Name, &campaign.CreatedBy,
+		&campaign.IsActive, &campaign.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("campaign not found")
+	}
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get campaign: %v", err)
+	}
+	return campaign, nil
+}
+
+// ListCampaigns retrieves all campaigns
+func (r *PostgresRepository) ListCampaigns() ([]models.Campaign, error) {
+	query := `
+		SELECT id, code, name, created_by, is_active, created_at
+		FROM referral_campaigns
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.Conn().Query(query)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to list campaigns: %v", err)
+	}
+	defer rows.Close()
+
+	var campaigns []models.Campaign
+	for rows.Next() {
+		var campaign models.Campaign
+		if err := rows.Scan(
+			&campaign.ID, &campaign.Code, &campaign.Name, &campaign.CreatedBy,
+			&campaign.IsActive, &campaign.CreatedAt,
+		); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	return campaigns, nil
+}
+
+// GetQRFileID retrieves the cached QR file_id of a user, empty if missing or outdated
+func (r *PostgresRepository) GetQRFileID(userID int64, fingerprint string) (string, error) {
+	query := `
+		SELECT file_id
+		FROM referral_qr_codes
+		WHERE user_id = $1 AND fingerprint = $2
+	`
+	var fileID string
+	err := r.db.Conn().QueryRow(query, userID, fingerprint).Scan(&fileID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		utils.LogError(err)
+		return "", fmt.Errorf("failed to get QR file ID: %v", err)
+	}
+	return fileID, nil
+}
+
+// SaveQRFileID stores the QR file_id of a user, replacing an outdated one
+func (r *PostgresRepository) SaveQRFileID(userID int64, fingerprint, fileID string) error {
+	query := `
+		INSERT INTO referral_qr_codes (user_id, fingerprint, file_id, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, file_id = EXCLUDED.file_id, updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.Conn().Exec(query, userID, fingerprint, fileID)
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to save QR file ID: %v", err)
+	}
+	return nil
+}
+
+// referralNullables converts optional referral references to nullable values
+func referralNullables(referral *models.Referral) (inviterID, orderID, campaignID sql.NullInt64) {
+	if referral.InviterID != 0 {
+		inviterID.Valid = true
+		inviterID.Int64 = referral.InviterID
+	}
+	if referral.OrderID != 0 {
+		orderID.Valid = true
+		orderID.Int64 = int64(referral.OrderID)
+	}
+	if referral.CampaignID != 0 {
+		campaignID.Valid = true
+		campaignID.Int64 = int64(referral.CampaignID)
+	}
+	return inviterID, orderID, campaignID
+}
+
+// applyReferralNullables copies scanned nullable references into a referral
+func applyReferralNullables(referral *models.Referral, inviterID, orderID, campaignID sql.NullInt64) {
+	if inviterID.Valid {
+		referral.InviterID = inviterID.Int64
+	}
+	if orderID.Valid {
+		referral.OrderID = int(orderID.Int64)
+	}
+	if campaignID.Valid {
+		referral.CampaignID = int(campaignID.Int64)
+	}
 }
\ No newline at end of file
@@ -1,18 +1,34 @@
 package referral
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 	"github.com/skyzeper/telegram-bot/internal/models"
-	"github.com/skip2/go-qrcode"
 )
 
+const (
+	// botLink is the deep link base used for referral and campaign payloads
+	botLink = "https://t.me/vseVsimferopole"
+
+	referralPrefix = "ref_"
+	campaignPrefix = "camp_"
+)
+
+var campaignCodePattern = regexp.MustCompile(`^[a-z0-9-]{2,32}$`)
+
+// ErrReferralNotFound is returned when a user was not invited by anyone
+var ErrReferralNotFound = errors.New("referral not found")
+
 // Service handles referral-related business logic
 type Service struct {
 	repo Repository
+	qr   *qrRenderer
 }
 
 // Repository defines the interface for referral data access
@@ -21,11 +37,19 @@ type Repository interface {
 	GetReferralByInvitee(inviteeID int64) (*models.Referral, error)
 	GetReferralsByInviter(inviterID int64) ([]models.Referral, error)
 	UpdateReferral(referral *models.Referral) error
+	CreateCampaign(campaign *models.Campaign) error
+	GetCampaignByCode(code string) (*models.Campaign, error)
+	ListCampaigns() ([]models.Campaign, error)
+	GetQRFileID(userID int64, fingerprint string) (string, error)
+	SaveQRFileID(userID int64, fingerprint, fileID string) error
 }
 
 // NewService creates a new referral service
-func NewService(repo Repository) *Service {
-	return &Service{repo: repo}
+func NewService(repo Repository, qrCfg QRConfig) *Service {
+	return &Service{
+		repo: repo,
+		qr:   newQRRenderer(qrCfg),
+	}
 }
 
 // CreateReferral creates a new referral
@@ -45,23 +69,33 @@ func (s *Service) CreateReferral(inviterID, inviteeID, orderID int64) error {
 	return s.repo.CreateReferral(referral)
 }
 
-// GenerateQRCode generates a QR code for a referral link
-func (s *Service) GenerateQRCode(link string, userID int64) (string, error) {
-	if link == "" {
-		return "", errors.New("empty referral link")
-	}
+// GenerateQRCode renders a branded QR code PNG for a referral link in memory
+func (s *Service) GenerateQRCode(link string) ([]byte, error) {
+	return s.qr.render(link, s.qr.cfg.Caption)
+}
 
-	// Create a temporary file path
-	filename := fmt.Sprintf("referral_qr_%d.png", userID)
-	filepath := filepath.Join(os.TempDir(), filename)
+// GetCachedQRCode returns the Telegram file_id of a previously uploaded QR code for the link.
+// An empty string means the QR code has to be generated and uploaded again.
+func (s *Service) GetCachedQRCode(userID int64, link string) (string, error) {
+	if userID <= 0 {
+		return "", errors.New("invalid user ID")
+	}
+	return s.repo.GetQRFileID(userID, s.qrFingerprint(link))
+}
 
-	// Generate QR code
-	err := qrcode.WriteFile(link, qrcode.Medium, 256, filepath)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate QR code: %v", err)
+// CacheQRCode remembers the Telegram file_id of an uploaded QR code
+func (s *Service) CacheQRCode(userID int64, link, fileID string) error {
+	if userID <= 0 || fileID == "" {
+		return errors.New("invalid user ID or file ID")
 	}
+	return s.repo.SaveQRFileID(userID, s.qrFingerprint(link), fileID)
+}
 
-	return filepath, nil
+// qrFingerprint identifies the link and branding options a cached QR code was rendered with
+func (s *Service) qrFingerprint(link string) string {
+	cfg := s.qr.cfg
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%d|%d|%s|%s", link, cfg.Size, cfg.RecoveryLevel, cfg.LogoPath, cfg.Caption)))
+	return hex.EncodeToString(sum[:])
 }
 
 // RequestPayout marks a referral payout as requested
@@ -91,4 +125,116 @@ func (s *Service) GetReferralsByInviter(inviterID int64) ([]models.Referral, err
 		return nil, errors.New("invalid inviter ID")
 	}
 	return s.repo.GetReferralsByInviter(inviterID)
+}
+
+// ReferralLink returns the personal referral link of a user
+func (s *Service) ReferralLink(userID int64) string {
+	return fmt.Sprintf("%s?start=%s%d", botLink, referralPrefix, userID)
+}
+
+// CampaignLink returns the /start link of a campaign code
+func (s *Service) CampaignLink(code string) string {
+	return fmt.Sprintf("%s?start=%s%s", botLink, campaignPrefix, code)
+}
+
+// ParseStartPayload extracts the inviter ID or campaign code from a /start payload
+func ParseStartPayload(payload string) (int64, string, error) {
+	payload = strings.TrimSpace(payload)
+	switch {
+	case strings.HasPrefix(payload, referralPrefix):
+		inviterID, err := strconv.ParseInt(strings.TrimPrefix(payload, referralPrefix), 10, 64)
+		if err != nil || inviterID <= 0 {
+			return 0, "", errors.New("invalid referral payload")
+		}
+		return inviterID, "", nil
+	case strings.HasPrefix(payload, campaignPrefix):
+		code := strings.TrimPrefix(payload, campaignPrefix)
+		if !campaignCodePattern.MatchString(code) {
+			return 0, "", errors.New("invalid campaign payload")
+		}
+		return 0, code, nil
+	default:
+		return 0, "", errors.New("unknown start payload")
+	}
+}
+
+// RegisterStart attributes a new user to an inviter or a campaign from the /start payload.
+// It returns nil without error when the payload is empty or the user is already attributed.
+func (s *Service) RegisterStart(inviteeID int64, payload string) (*models.Referral, error) {
+	if inviteeID <= 0 {
+		return nil, errors.New("invalid invitee ID")
+	}
+	if strings.TrimSpace(payload) == "" {
+		return nil, nil
+	}
+
+	inviterID, code, err := ParseStartPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.repo.GetReferralByInvitee(inviteeID)
+	switch {
+	case err == ErrReferralNotFound:
+	case err != nil:
+		return nil, fmt.Errorf("failed to check referral: %v", err)
+	case existing != nil:
+		return nil, nil
+	}
+
+	referral := &models.Referral{
+		InviteeID: inviteeID,
+		CreatedAt: time.Now(),
+	}
+	if code != "" {
+		campaign, err := s.repo.GetCampaignByCode(code)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find campaign: %v", err)
+		}
+		if !campaign.IsActive {
+			return nil, errors.New("campaign is not active")
+		}
+		referral.CampaignID = campaign.ID
+	} else {
+		if inviterID == inviteeID {
+			return nil, errors.New("user cannot invite themselves")
+		}
+		referral.InviterID = inviterID
+	}
+
+	if err := s.repo.CreateReferral(referral); err != nil {
+		return nil, err
+	}
+	return referral, nil
+}
+
+// CreateCampaign creates a new campaign code
+func (s *Service) CreateCampaign(code, name string, createdBy int64) (*models.Campaign, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if !campaignCodePattern.MatchString(code) {
+		return nil, errors.New("campaign code must be 2-32 latin letters, digits or dashes")
+	}
+	if createdBy <= 0 {
+		return nil, errors.New("invalid creator ID")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = code
+	}
+
+	campaign := &models.Campaign{
+		Code:      code,
+		Name:      name,
+		CreatedBy: createdBy,
+		IsActive:  true,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.CreateCampaign(campaign); err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// ListCampaigns retrieves all campaigns
+func (s *Service) ListCampaigns() ([]models.Campaign, error) {
+	return s.repo.ListCampaigns()
 }
\ No newline at end of file
@@ -0,0 +1,190 @@
+package referral_test
+
+import (
+	"errors"
+	"testing"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/referral"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository is a mock implementation of referral.Repository
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateReferral(ref *models.Referral) error {
+	args := m.Called(ref)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetReferralByInvitee(inviteeID int64) (*models.Referral, error) {
+	args := m.Called(inviteeID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Referral), args.Error(1)
+}
+
+func (m *MockRepository) GetReferralsByInviter(inviterID int64) ([]models.Referral, error) {
+	args := m.Called(inviterID)
+	return args.Get(0).([]models.Referral), args.Error(1)
+}
+
+func (m *MockRepository) UpdateReferral(ref *models.Referral) error {
+	args := m.Called(ref)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CreateCampaign(campaign *models.Campaign) error {
+	args := m.Called(campaign)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetCampaignByCode(code string) (*models.Campaign, error) {
+	args := m.Called(code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Campaign), args.Error(1)
+}
+
+func (m *MockRepository) ListCampaigns() ([]models.Campaign, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Campaign), args.Error(1)
+}
+
+func (m *MockRepository) GetQRFileID(userID int64, fingerprint string) (string, error) {
+	args := m.Called(userID, fingerprint)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockRepository) SaveQRFileID(userID int64, fingerprint, fileID string) error {
+	args := m.Called(userID, fingerprint, fileID)
+	return args.Error(0)
+}
+
+func TestParseStartPayload(t *testing.T) {
+	t.Run("Referral", func(t *testing.T) {
+		inviterID, code, err := referral.ParseStartPayload("ref_12345")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(12345), inviterID)
+		assert.Empty(t, code)
+	})
+
+	t.Run("Campaign", func(t *testing.T) {
+		inviterID, code, err := referral.ParseStartPayload("camp_avito-2024")
+		assert.NoError(t, err)
+		assert.Zero(t, inviterID)
+		assert.Equal(t, "avito-2024", code)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, payload := range []string{"ref_abc", "ref_-1", "camp_Bad Code", "promo_1"} {
+			_, _, err := referral.ParseStartPayload(payload)
+			assert.Error(t, err, payload)
+		}
+	})
+}
+
+func TestService_RegisterStart(t *testing.T) {
+	t.Run("EmptyPayload", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := referral.NewService(mockRepo, referral.DefaultQRConfig())
+
+		ref, err := service.RegisterStart(100, "")
+		assert.NoError(t, err)
+		assert.Nil(t, ref)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Referral", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := referral.NewService(mockRepo, referral.DefaultQRConfig())
+
+		mockRepo.On("GetReferralByInvitee", int64(100)).Return(nil, referral.ErrReferralNotFound).Once()
+		mockRepo.On("CreateReferral", mock.MatchedBy(func(r *models.Referral) bool {
+			return r.InviterID == 42 && r.InviteeID == 100 && r.CampaignID == 0
+		})).Return(nil).Once()
+
+		ref, err := service.RegisterStart(100, "ref_42")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(42), ref.InviterID)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("SelfInvite", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := referral.NewService(mockRepo, referral.DefaultQRConfig())
+
+		mockRepo.On("GetReferralByInvitee", int64(100)).Return(nil, referral.ErrReferralNotFound).Once()
+
+		ref, err := service.RegisterStart(100, "ref_100")
+		assert.Error(t, err)
+		assert.Nil(t, ref)
+		mockRepo.AssertNotCalled(t, "CreateReferral", mock.Anything)
+	})
+
+	t.Run("Campaign", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := referral.NewService(mockRepo, referral.DefaultQRConfig())
+
+		mockRepo.On("GetReferralByInvitee", int64(100)).Return(nil, referral.ErrReferralNotFound).Once()
+		mockRepo.On("GetCampaignByCode", "flyers").Return(&models.Campaign{ID: 7, Code: "flyers", IsActive: true}, nil).Once()
+		mockRepo.On("CreateReferral", mock.MatchedBy(func(r *models.Referral) bool {
+			return r.InviterID == 0 && r.CampaignID == 7
+		})).Return(nil).Once()
+
+		ref, err := service.RegisterStart(100, "camp_flyers")
+		assert.NoError(t, err)
+		assert.Equal(t, 7, ref.CampaignID)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("DatabaseError", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := referral.NewService(mockRepo, referral.DefaultQRConfig())
+
+		// A failed lookup must not be mistaken for a user nobody invited
+		mockRepo.On("GetReferralByInvitee", int64(100)).Return(nil, errors.New("connection refused")).Once()
+
+		ref, err := service.RegisterStart(100, "ref_42")
+		assert.Error(t, err)
+		assert.Nil(t, ref)
+		mockRepo.AssertNotCalled(t, "CreateReferral", mock.Anything)
+	})
+
+	t.Run("AlreadyAttributed", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := referral.NewService(mockRepo, referral.DefaultQRConfig())
+
+		mockRepo.On("GetReferralByInvitee", int64(100)).Return(&models.Referral{ID: 1, InviterID: 5}, nil).Once()
+
+		ref, err := service.RegisterStart(100, "ref_42")
+		assert.NoError(t, err)
+		assert.Nil(t, ref)
+		mockRepo.AssertNotCalled(t, "CreateReferral", mock.Anything)
+	})
+}
+
+func TestService_CreateCampaign(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := referral.NewService(mockRepo, referral.DefaultQRConfig())
+
+	t.Run("Valid", func(t *testing.T) {
+		mockRepo.On("CreateCampaign", mock.AnythingOfType("*models.Campaign")).Return(nil).Once()
+
+		campaign, err := service.CreateCampaign("Avito", "Объявления Авито", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "avito", campaign.Code)
+		assert.True(t, campaign.IsActive)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("InvalidCode", func(t *testing.T) {
+		campaign, err := service.CreateCampaign("флаеры", "", 1)
+		assert.Error(t, err)
+		assert.Nil(t, campaign)
+	})
+}
\ No newline at end of file
@@ -0,0 +1,428 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+	"github.com/xuri/excelize/v2"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/expense"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// Sheet names of the financial workbook
+const (
+	SheetSummary  = "Сводка"
+	SheetOrders   = "Заказы"
+	SheetPayments = "Платежи"
+	SheetExpenses = "Расходы"
+	SheetSalaries = "Зарплата"
+	SheetDebts    = "Долги водителей"
+)
+
+// column describes a data sheet column
+type column struct {
+	title string
+	width float64
+}
+
+// table is a data sheet; its last column holds the amounts totalled below the rows
+type table struct {
+	sheet   string
+	name    string
+	columns []column
+	rows    [][]interface{}
+}
+
+// styles holds the cell style IDs shared by all sheets
+type styles struct {
+	title    int
+	header   int
+	money    int
+	datetime int
+	total    int
+	totalSum int
+}
+
+// WriteExcel renders a financial report as a workbook with a summary and one sheet per data set, entirely in memory
+func WriteExcel(report *models.FinancialReport) (*bytes.Buffer, error) {
+	f := excelize.NewFile()
+	defer func() {
+		if err := f.Close(); err != nil {
+			utils.LogError(err)
+		}
+	}()
+
+	st, err := newStyles(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Excel styles: %v", err)
+	}
+	if err := f.SetSheetName("Sheet1", SheetSummary); err != nil {
+		return nil, fmt.Errorf("failed to create Excel sheet: %v", err)
+	}
+
+	orders := ordersTable(report, st)
+	payments := paymentsTable(report, st)
+	expenses := expensesTable(report, st)
+	salaries := salariesTable(report, st)
+	debts := debtsTable(report, st)
+	for _, t := range []table{orders, payments, expenses, salaries, debts} {
+		if _, err := f.NewSheet(t.sheet); err != nil {
+			return nil, fmt.Errorf("failed to create Excel sheet: %v", err)
+		}
+		if err := writeTable(f, t, st); err != nil {
+			return nil, fmt.Errorf("failed to write %s sheet: %v", t.sheet, err)
+		}
+	}
+	if err := writeSummary(f, report, st, orders, payments, expenses, salaries, debts); err != nil {
+		return nil, fmt.Errorf("failed to write %s sheet: %v", SheetSummary, err)
+	}
+	f.SetActiveSheet(0)
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write Excel file: %v", err)
+	}
+	return buf, nil
+}
+
+// newStyles registers the workbook cell styles
+func newStyles(f *excelize.File) (styles, error) {
+	var st styles
+	var err error
+	moneyFormat := "#,##0.00"
+	border := []excelize.Border{{Type: "top", Color: "808080", Style: 1}}
+	definitions := []struct {
+		id    *int
+		style *excelize.Style
+	}{
+		{&st.title, &excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}}},
+		{&st.header, &excelize.Style{
+			Font:      &excelize.Font{Bold: true},
+			Fill:      excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"D9E1F2"}},
+			Alignment: &excelize.Alignment{Vertical: "center", WrapText: true},
+		}},
+		{&st.money, &excelize.Style{CustomNumFmt: &moneyFormat}},
+		{&st.datetime, &excelize.Style{NumFmt: 22}},
+		{&st.total, &excelize.Style{Font: &excelize.Font{Bold: true}, Border: border}},
+		{&st.totalSum, &excelize.Style{Font: &excelize.Font{Bold: true}, Border: border, CustomNumFmt: &moneyFormat}},
+	}
+	for _, d := range definitions {
+		if *d.id, err = f.NewStyle(d.style); err != nil {
+			return st, err
+		}
+	}
+	return st, nil
+}
+
+// writeTable streams a data sheet: a frozen header, an Excel table with filters and a filter-aware total row
+func writeTable(f *excelize.File, t table, st styles) error {
+	sw, err := f.NewStreamWriter(t.sheet)
+	if err != nil {
+		return err
+	}
+	for i, c := range t.columns {
+		if err := sw.SetColWidth(i+1, i+1, c.width); err != nil {
+			return err
+		}
+	}
+	if err := sw.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return err
+	}
+
+	header := make([]interface{}, len(t.columns))
+	for i, c := range t.columns {
+		header[i] = excelize.Cell{StyleID: st.header, Value: c.title}
+	}
+	if err := sw.SetRow("A1", header, excelize.RowOpts{Height: 30}); err != nil {
+		return err
+	}
+	for i, row := range t.rows {
+		if err := sw.SetRow(fmt.Sprintf("A%d", i+2), row); err != nil {
+			return err
+		}
+	}
+
+	lastCol, err := excelize.ColumnNumberToName(len(t.columns))
+	if err != nil {
+		return err
+	}
+	lastRow := len(t.rows) + 1
+	if len(t.rows) > 0 {
+		if err := sw.AddTable(&excelize.Table{
+			Range:     fmt.Sprintf("A1:%s%d", lastCol, lastRow),
+			Name:      t.name,
+			StyleName: "TableStyleLight9",
+		}); err != nil {
+			return err
+		}
+	}
+
+	total := make([]interface{}, len(t.columns))
+	total[0] = excelize.Cell{StyleID: st.total, Value: "Итого"}
+	for i := 1; i < len(t.columns)-1; i++ {
+		total[i] = excelize.Cell{StyleID: st.total}
+	}
+	total[len(t.columns)-1] = excelize.Cell{
+		StyleID: st.totalSum,
+		Formula: fmt.Sprintf("SUBTOTAL(9,%s)", amountRange(t, "")),
+		Value:   sumLastColumn(t.rows),
+	}
+	if err := sw.SetRow(fmt.Sprintf("A%d", lastRow+2), total); err != nil {
+		return err
+	}
+	return sw.Flush()
+}
+
+// amountRange refers to the amounts column of a data sheet, optionally from another sheet
+func amountRange(t table, sheet string) string {
+	col, _ := excelize.ColumnNumberToName(len(t.columns))
+	return dataRange(t, sheet, col)
+}
+
+// dataRange refers to a column of a data sheet's rows; an empty sheet still yields a valid one-row range
+func dataRange(t table, sheet, col string) string {
+	last := len(t.rows) + 1
+	if last < 2 {
+		last = 2
+	}
+	ref := fmt.Sprintf("$%s$2:$%s$%d", col, col, last)
+	if sheet == "" {
+		return ref
+	}
+	return fmt.Sprintf("'%s'!%s", sheet, ref)
+}
+
+// sumLastColumn totals the amounts of data rows for the cached value of a formula
+func sumLastColumn(rows [][]interface{}) float64 {
+	var sum float64
+	for _, row := range rows {
+		if cell, ok := row[len(row)-1].(excelize.Cell); ok {
+			if v, ok := cell.Value.(float64); ok {
+				sum += v
+			}
+		}
+	}
+	return sum
+}
+
+// moneyCell formats an amount as a number with two decimals
+func moneyCell(amount models.Money, st styles) excelize.Cell {
+	return excelize.Cell{StyleID: st.money, Value: amount.Float64()}
+}
+
+// timeCell formats a timestamp, leaving the cell empty for zero times
+func timeCell(t time.Time, style int) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return excelize.Cell{StyleID: style, Value: t}
+}
+
+// orderRef leaves the order cell empty for records without an order
+func orderRef(orderID int) interface{} {
+	if orderID == 0 {
+		return nil
+	}
+	return orderID
+}
+
+// ordersTable lists orders created or completed in the period
+func ordersTable(report *models.FinancialReport, st styles) table {
+	t := table{
+		sheet: SheetOrders,
+		name:  "Orders",
+		columns: []column{
+			{"№", 8}, {"Создан", 17}, {"Выполнен", 17}, {"Клиент", 22}, {"Категория", 18},
+			{"Подкатегория", 20}, {"Адрес", 36}, {"Статус", 12}, {"Оплата", 14}, {"Стоимость", 14},
+		},
+	}
+	for _, o := range report.Orders {
+		t.rows = append(t.rows, []interface{}{
+			o.ID, timeCell(o.CreatedAt, st.datetime), timeCell(o.CompletedAt, st.datetime), o.ClientName,
+			o.Category, o.Subcategory, o.Address, label(orderStatusLabels, o.Status),
+			label(paymentMethodLabels, o.PaymentMethod), moneyCell(o.Cost, st),
+		})
+	}
+	return t
+}
+
+// paymentsTable lists payments with refunds as negative amounts so the total is the net inflow
+func paymentsTable(report *models.FinancialReport, st styles) table {
+	t := table{
+		sheet: SheetPayments,
+		name:  "Payments",
+		columns: []column{
+			{"№", 8}, {"Заказ", 8}, {"Дата", 17}, {"Вид", 12}, {"Способ", 12},
+			{"Подтверждён", 13}, {"Дата подтверждения", 17}, {"Сумма", 14},
+		},
+	}
+	for _, p := range report.Payments {
+		amount := p.Amount
+		if p.Kind == "refund" {
+			amount = -amount.Abs()
+		}
+		confirmed := "нет"
+		if p.Confirmed {
+			confirmed = "да"
+		}
+		t.rows = append(t.rows, []interface{}{
+			p.ID, p.OrderID, timeCell(p.CreatedAt, st.datetime), label(paymentKindLabels, p.Kind),
+			label(paymentMethodLabels, p.Method), confirmed, timeCell(p.ConfirmedAt, st.datetime), moneyCell(amount, st),
+		})
+	}
+	return t
+}
+
+// expensesTable lists expenses submitted in the period
+func expensesTable(report *models.FinancialReport, st styles) table {
+	t := table{
+		sheet: SheetExpenses,
+		name:  "Expenses",
+		columns: []column{
+			{"№", 8}, {"Дата", 17}, {"Сотрудник", 22}, {"Категория", 16}, {"Заказ", 8},
+			{"Комментарий", 36}, {"Статус", 13}, {"Сумма", 14},
+		},
+	}
+	for _, e := range report.Expenses {
+		category := e.Category
+		if c, ok := expense.LookupCategory(e.Category); ok {
+			category = c.Name
+		}
+		t.rows = append(t.rows, []interface{}{
+			e.ID, timeCell(e.CreatedAt, st.datetime), e.UserName, category, orderRef(e.OrderID),
+			e.Comment, label(expenseStatusLabels, e.Status), moneyCell(e.Amount, st),
+		})
+	}
+	return t
+}
+
+// salariesTable lists payroll lines of runs approved in the period
+func salariesTable(report *models.FinancialReport, st styles) table {
+	t := table{
+		sheet: SheetSalaries,
+		name:  "Salaries",
+		columns: []column{
+			{"Ведомость", 11}, {"Период", 24}, {"Утверждена", 17}, {"Сотрудник", 22}, {"Роль", 12},
+			{"Заказов", 10}, {"Часов", 10}, {"Начислено", 14},
+		},
+	}
+	for _, s := range report.Salaries {
+		period := s.PeriodFrom.Format("02.01.2006") + " — " + s.PeriodTo.AddDate(0, 0, -1).Format("02.01.2006")
+		t.rows = append(t.rows, []interface{}{
+			s.RunID, period, timeCell(s.ApprovedAt, st.datetime), s.UserName, s.Role,
+			s.Orders, float64(s.Minutes) / 60, moneyCell(s.Total, st),
+		})
+	}
+	return t
+}
+
+// debtsTable lists the cash drivers held at the end of the period
+func debtsTable(report *models.FinancialReport, st styles) table {
+	t := table{
+		sheet:   SheetDebts,
+		name:    "DriverDebts",
+		columns: []column{{"ID", 14}, {"Водитель", 22}, {"Последнее движение", 19}, {"Долг", 14}},
+	}
+	for _, d := range report.DriverDebts {
+		t.rows = append(t.rows, []interface{}{
+			d.UserID, d.Name, timeCell(d.LastMovement, st.datetime), moneyCell(d.Balance, st),
+		})
+	}
+	return t
+}
+
+// summaryLine is a summary indicator computed by a formula over the data sheets
+type summaryLine struct {
+	title   string
+	formula string
+	value   float64
+	money   bool
+	total   bool
+}
+
+// writeSummary streams the summary sheet; formulas keep it live while cached values show without recalculation
+func writeSummary(f *excelize.File, report *models.FinancialReport, st styles, orders, payments, expenses, salaries, debts table) error {
+	var completed int
+	var revenue, received, refunded, approved, paid, owed float64
+	for _, o := range report.Orders {
+		if o.Status == "completed" {
+			completed++
+			revenue += o.Cost.Float64()
+		}
+	}
+	for _, p := range report.Payments {
+		if !p.Confirmed {
+			continue
+		}
+		if p.Kind == "refund" {
+			refunded += p.Amount.Abs().Float64()
+		} else {
+			received += p.Amount.Float64()
+		}
+	}
+	for _, e := range report.Expenses {
+		if e.Status == expense.StatusApproved {
+			approved += e.Amount.Float64()
+		}
+	}
+	paid = sumLastColumn(salaries.rows)
+	owed = sumLastColumn(debts.rows)
+
+	lines := []summaryLine{
+		{title: "Заказов за период", formula: fmt.Sprintf("COUNT(%s)", dataRange(orders, SheetOrders, "A")), value: float64(len(report.Orders))},
+		{title: "Выполнено заказов", formula: fmt.Sprintf(`COUNTIF(%s,"выполнен")`, dataRange(orders, SheetOrders, "H")), value: float64(completed)},
+		{title: "Выручка по выполненным заказам", money: true, value: revenue,
+			formula: fmt.Sprintf(`SUMIF(%s,"выполнен",%s)`, dataRange(orders, SheetOrders, "H"), amountRange(orders, SheetOrders))},
+		{title: "Поступило оплат", money: true, value: received,
+			formula: fmt.Sprintf(`SUMIFS(%s,%s,"<>возврат",%s,"да")`, amountRange(payments, SheetPayments), dataRange(payments, SheetPayments, "D"), dataRange(payments, SheetPayments, "F"))},
+		{title: "Возвраты", money: true, value: refunded,
+			formula: fmt.Sprintf(`-SUMIFS(%s,%s,"возврат",%s,"да")`, amountRange(payments, SheetPayments), dataRange(payments, SheetPayments, "D"), dataRange(payments, SheetPayments, "F"))},
+		{title: "Расходы (одобренные)", money: true, value: approved,
+			formula: fmt.Sprintf(`SUMIF(%s,"одобрен",%s)`, dataRange(expenses, SheetExpenses, "G"), amountRange(expenses, SheetExpenses))},
+		{title: "Зарплата", money: true, value: paid, formula: fmt.Sprintf("SUM(%s)", amountRange(salaries, SheetSalaries))},
+		{title: "Результат (поступления − возвраты − расходы − зарплата)", money: true, total: true,
+			value: received - refunded - approved - paid, formula: "B7-B8-B9-B10"},
+		{title: "Долги водителей на конец периода", money: true, value: owed, formula: fmt.Sprintf("SUM(%s)", amountRange(debts, SheetDebts))},
+	}
+
+	sw, err := f.NewStreamWriter(SheetSummary)
+	if err != nil {
+		return err
+	}
+	if err := sw.SetColWidth(1, 1, 58); err != nil {
+		return err
+	}
+	if err := sw.SetColWidth(2, 2, 18); err != nil {
+		return err
+	}
+	if err := sw.SetRow("A1", []interface{}{excelize.Cell{StyleID: st.title, Value: "Финансовый отчёт за " + periodLabel(report)}}); err != nil {
+		return err
+	}
+	if err := sw.SetRow("A2", []interface{}{"Сформирован", timeCell(report.GeneratedAt, st.datetime)}); err != nil {
+		return err
+	}
+	if err := sw.SetRow("A3", []interface{}{
+		excelize.Cell{StyleID: st.header, Value: "Показатель"},
+		excelize.Cell{StyleID: st.header, Value: "Значение"},
+	}); err != nil {
+		return err
+	}
+	for i, line := range lines {
+		titleStyle, valueStyle := 0, 0
+		if line.money {
+			valueStyle = st.money
+		}
+		if line.total {
+			titleStyle, valueStyle = st.total, st.totalSum
+		}
+		row := []interface{}{
+			excelize.Cell{StyleID: titleStyle, Value: line.title},
+			excelize.Cell{StyleID: valueStyle, Formula: line.formula, Value: line.value},
+		}
+		if err := sw.SetRow(fmt.Sprintf("A%d", i+4), row); err != nil {
+			return err
+		}
+	}
+	return sw.Flush()
+}
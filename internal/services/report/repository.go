@@ -0,0 +1,184 @@
+package report
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/db"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// PostgresRepository implements the Repository interface for PostgreSQL
+type PostgresRepository struct {
+	db *db.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL repository
+func NewPostgresRepository(db *db.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// GetReportOrders retrieves orders created or completed in [from, to)
+func (r *PostgresRepository) GetReportOrders(from, to time.Time) ([]models.ReportOrder, error) {
+	query := `
+		SELECT o.id, TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, '')), o.category, o.subcategory,
+		       o.address, o.status, COALESCE(o.payment_method, ''), COALESCE(o.cost, 0), o.created_at, o.completed_at
+		FROM orders o
+		LEFT JOIN users u ON u.chat_id = o.user_id
+		WHERE (o.created_at >= $1 AND o.created_at < $2) OR (o.completed_at >= $1 AND o.completed_at < $2)
+		ORDER BY o.created_at, o.id
+	`
+	rows, err := r.db.Conn().Query(query, from, to)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get report orders: %v", err)
+	}
+	defer rows.Close()
+
+	var orders []models.ReportOrder
+	for rows.Next() {
+		var order models.ReportOrder
+		var completedAt sql.NullTime
+		if err := rows.Scan(
+			&order.ID, &order.ClientName, &order.Category, &order.Subcategory, &order.Address, &order.Status,
+			&order.PaymentMethod, &order.Cost, &order.CreatedAt, &completedAt,
+		); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		if completedAt.Valid {
+			order.CompletedAt = completedAt.Time
+		}
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+// GetReportPayments retrieves payments, prepayments and refunds registered in [from, to)
+func (r *PostgresRepository) GetReportPayments(from, to time.Time) ([]models.ReportPayment, error) {
+	query := `
+		SELECT id, order_id, kind, method, amount, COALESCE(confirmed, FALSE), created_at, confirmed_at
+		FROM payments
+		WHERE created_at >= $1 AND created_at < $2
+		ORDER BY created_at, id
+	`
+	rows, err := r.db.Conn().Query(query, from, to)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get report payments: %v", err)
+	}
+	defer rows.Close()
+
+	var payments []models.ReportPayment
+	for rows.Next() {
+		var payment models.ReportPayment
+		var confirmedAt sql.NullTime
+		if err := rows.Scan(
+			&payment.ID, &payment.OrderID, &payment.Kind, &payment.Method, &payment.Amount, &payment.Confirmed,
+			&payment.CreatedAt, &confirmedAt,
+		); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		if confirmedAt.Valid {
+			payment.ConfirmedAt = confirmedAt.Time
+		}
+		payments = append(payments, payment)
+	}
+	return payments, nil
+}
+
+// GetReportExpenses retrieves expenses submitted in [from, to)
+func (r *PostgresRepository) GetReportExpenses(from, to time.Time) ([]models.ReportExpense, error) {
+	query := `
+		SELECT e.id, TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, '')), e.category,
+		       COALESCE(e.order_id, 0), COALESCE(e.comment, ''), e.status, e.amount, e.created_at
+		FROM expenses e
+		LEFT JOIN users u ON u.chat_id = e.user_id
+		WHERE e.created_at >= $1 AND e.created_at < $2
+		ORDER BY e.created_at, e.id
+	`
+	rows, err := r.db.Conn().Query(query, from, to)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get report expenses: %v", err)
+	}
+	defer rows.Close()
+
+	var expenses []models.ReportExpense
+	for rows.Next() {
+		var expense models.ReportExpense
+		if err := rows.Scan(
+			&expense.ID, &expense.UserName, &expense.Category, &expense.OrderID, &expense.Comment,
+			&expense.Status, &expense.Amount, &expense.CreatedAt,
+		); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		expenses = append(expenses, expense)
+	}
+	return expenses, nil
+}
+
+// GetReportSalaries retrieves payroll lines of runs approved in [from, to)
+func (r *PostgresRepository) GetReportSalaries(from, to time.Time) ([]models.ReportSalary, error) {
+	query := `
+		SELECT r.id, r.period_start, r.period_end, r.approved_at, l.name, l.role, l.orders, l.minutes, l.total
+		FROM payroll_lines l
+		JOIN payroll_runs r ON r.id = l.run_id
+		WHERE r.status = 'approved' AND r.approved_at >= $1 AND r.approved_at < $2 AND l.total > 0
+		ORDER BY r.approved_at, r.id, l.id
+	`
+	rows, err := r.db.Conn().Query(query, from, to)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get report salaries: %v", err)
+	}
+	defer rows.Close()
+
+	var salaries []models.ReportSalary
+	for rows.Next() {
+		var salary models.ReportSalary
+		if err := rows.Scan(
+			&salary.RunID, &salary.PeriodFrom, &salary.PeriodTo, &salary.ApprovedAt, &salary.UserName,
+			&salary.Role, &salary.Orders, &salary.Minutes, &salary.Total,
+		); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		salaries = append(salaries, salary)
+	}
+	return salaries, nil
+}
+
+// GetDriverDebts retrieves the cash every driver held and had not handed over before the given time
+func (r *PostgresRepository) GetDriverDebts(at time.Time) ([]models.DriverBalance, error) {
+	query := `
+		SELECT p.driver_id, COALESCE(u.first_name, ''), SUM(p.amount), MAX(e.created_at)
+		FROM ledger_postings p
+		JOIN ledger_entries e ON e.id = p.entry_id
+		JOIN users u ON u.chat_id = p.driver_id
+		WHERE p.account = 'driver_cash' AND e.created_at < $1
+		GROUP BY p.driver_id, u.first_name
+		HAVING SUM(p.amount) <> 0
+		ORDER BY 3 DESC
+	`
+	rows, err := r.db.Conn().Query(query, at)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get driver debts: %v", err)
+	}
+	defer rows.Close()
+
+	var balances []models.DriverBalance
+	for rows.Next() {
+		var balance models.DriverBalance
+		if err := rows.Scan(&balance.UserID, &balance.Name, &balance.Balance, &balance.LastMovement); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		balances = append(balances, balance)
+	}
+	return balances, nil
+}
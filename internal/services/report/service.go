@@ -0,0 +1,112 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+)
+
+// Service builds financial reports for export
+type Service struct {
+	repo Repository
+}
+
+// Repository defines the interface for report data access
+type Repository interface {
+	GetReportOrders(from, to time.Time) ([]models.ReportOrder, error)
+	GetReportPayments(from, to time.Time) ([]models.ReportPayment, error)
+	GetReportExpenses(from, to time.Time) ([]models.ReportExpense, error)
+	GetReportSalaries(from, to time.Time) ([]models.ReportSalary, error)
+	GetDriverDebts(at time.Time) ([]models.DriverBalance, error)
+}
+
+// NewService creates a new report service
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// GetFinancialReport collects orders, payments, expenses, salaries and driver debts for [from, to)
+func (s *Service) GetFinancialReport(from, to time.Time) (*models.FinancialReport, error) {
+	if !from.Before(to) {
+		return nil, errors.New("invalid report period")
+	}
+
+	// Timestamps are stored without a zone as server wall-clock time
+	start, end := from.In(time.Local), to.In(time.Local)
+
+	report := &models.FinancialReport{From: from, To: to, GeneratedAt: time.Now()}
+	var err error
+	if report.Orders, err = s.repo.GetReportOrders(start, end); err != nil {
+		return nil, err
+	}
+	if report.Payments, err = s.repo.GetReportPayments(start, end); err != nil {
+		return nil, err
+	}
+	if report.Expenses, err = s.repo.GetReportExpenses(start, end); err != nil {
+		return nil, err
+	}
+	if report.Salaries, err = s.repo.GetReportSalaries(start, end); err != nil {
+		return nil, err
+	}
+	if report.DriverDebts, err = s.repo.GetDriverDebts(end); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// FileName names an exported report file after its period, e.g. "finance_01.03.2024-31.03.2024.xlsx"
+func FileName(report *models.FinancialReport, ext string) string {
+	return fmt.Sprintf("finance_%s.%s", periodSlug(report), ext)
+}
+
+// periodSlug renders the inclusive report period for file names
+func periodSlug(report *models.FinancialReport) string {
+	return report.From.Format("02.01.2006") + "-" + report.To.AddDate(0, 0, -1).Format("02.01.2006")
+}
+
+// periodLabel renders the inclusive report period for people
+func periodLabel(report *models.FinancialReport) string {
+	return report.From.Format("02.01.2006") + " — " + report.To.AddDate(0, 0, -1).Format("02.01.2006")
+}
+
+// orderStatusLabels translates order statuses for exported reports
+var orderStatusLabels = map[string]string{
+	"new":         "новый",
+	"accepted":    "принят",
+	"assigned":    "назначен",
+	"on_the_way":  "в пути",
+	"in_progress": "в работе",
+	"completed":   "выполнен",
+	"canceled":    "отменён",
+}
+
+// paymentKindLabels translates payment kinds for exported reports
+var paymentKindLabels = map[string]string{
+	"payment":    "оплата",
+	"prepayment": "предоплата",
+	"refund":     "возврат",
+}
+
+// paymentMethodLabels translates payment methods for exported reports
+var paymentMethodLabels = map[string]string{
+	"cash":   "наличные",
+	"card":   "карта",
+	"link":   "ссылка",
+	"manual": "вручную",
+}
+
+// expenseStatusLabels translates expense statuses for exported reports
+var expenseStatusLabels = map[string]string{
+	"pending":  "на проверке",
+	"approved": "одобрен",
+	"rejected": "отклонён",
+}
+
+// label translates a code, falling back to the code itself
+func label(labels map[string]string, code string) string {
+	if text, ok := labels[code]; ok {
+		return text
+	}
+	return code
+}
@@ -0,0 +1,149 @@
+package report_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"github.com/xuri/excelize/v2"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository is a mock implementation of report.Repository
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) GetReportOrders(from, to time.Time) ([]models.ReportOrder, error) {
+	args := m.Called(from, to)
+	return args.Get(0).([]models.ReportOrder), args.Error(1)
+}
+
+func (m *MockRepository) GetReportPayments(from, to time.Time) ([]models.ReportPayment, error) {
+	args := m.Called(from, to)
+	return args.Get(0).([]models.ReportPayment), args.Error(1)
+}
+
+func (m *MockRepository) GetReportExpenses(from, to time.Time) ([]models.ReportExpense, error) {
+	args := m.Called(from, to)
+	return args.Get(0).([]models.ReportExpense), args.Error(1)
+}
+
+func (m *MockRepository) GetReportSalaries(from, to time.Time) ([]models.ReportSalary, error) {
+	args := m.Called(from, to)
+	return args.Get(0).([]models.ReportSalary), args.Error(1)
+}
+
+func (m *MockRepository) GetDriverDebts(at time.Time) ([]models.DriverBalance, error) {
+	args := m.Called(at)
+	return args.Get(0).([]models.DriverBalance), args.Error(1)
+}
+
+var (
+	reportFrom = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	reportTo   = time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func sampleReport() *models.FinancialReport {
+	completed := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	return &models.FinancialReport{
+		From: reportFrom,
+		To:   reportTo,
+		Orders: []models.ReportOrder{
+			{ID: 10, ClientName: "Иван", Category: "вывоз мусора", Status: "completed", PaymentMethod: "cash", Cost: 500000, CreatedAt: reportFrom, CompletedAt: completed},
+			{ID: 11, ClientName: "Пётр", Category: "демонтаж", Status: "canceled", Cost: 300000, CreatedAt: reportFrom},
+		},
+		Payments: []models.ReportPayment{
+			{ID: 1, OrderID: 10, Kind: "payment", Method: "cash", Amount: 500000, Confirmed: true, CreatedAt: completed, ConfirmedAt: completed},
+			{ID: 2, OrderID: 10, Kind: "refund", Method: "manual", Amount: 50000, Confirmed: true, CreatedAt: completed, ConfirmedAt: completed},
+			{ID: 3, OrderID: 10, Kind: "payment", Method: "cash", Amount: 100000, CreatedAt: completed},
+		},
+		Expenses: []models.ReportExpense{
+			{ID: 1, UserName: "Водитель", Category: "fuel", OrderID: 10, Status: "approved", Amount: 120000, CreatedAt: completed},
+			{ID: 2, UserName: "Водитель", Category: "parking", Status: "rejected", Amount: 20000, CreatedAt: completed},
+		},
+		Salaries: []models.ReportSalary{
+			{RunID: 1, PeriodFrom: reportFrom, PeriodTo: reportTo, ApprovedAt: completed, UserName: "Водитель", Role: "driver", Orders: 1, Minutes: 90, Total: 150000},
+		},
+		DriverDebts: []models.DriverBalance{
+			{UserID: 200, Name: "Водитель", Balance: 100000, LastMovement: completed},
+		},
+		GeneratedAt: completed,
+	}
+}
+
+func TestService_GetFinancialReport(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := report.NewService(mockRepo)
+	sample := sampleReport()
+
+	// The repository is queried in server time
+	start, end := reportFrom.In(time.Local), reportTo.In(time.Local)
+	mockRepo.On("GetReportOrders", start, end).Return(sample.Orders, nil).Once()
+	mockRepo.On("GetReportPayments", start, end).Return(sample.Payments, nil).Once()
+	mockRepo.On("GetReportExpenses", start, end).Return(sample.Expenses, nil).Once()
+	mockRepo.On("GetReportSalaries", start, end).Return(sample.Salaries, nil).Once()
+	mockRepo.On("GetDriverDebts", end).Return(sample.DriverDebts, nil).Once()
+
+	result, err := service.GetFinancialReport(reportFrom, reportTo)
+	assert.NoError(t, err)
+	assert.Len(t, result.Orders, 2)
+	assert.Len(t, result.DriverDebts, 1)
+	assert.Equal(t, "finance_01.03.2024-31.03.2024.xlsx", report.FileName(result, "xlsx"))
+	mockRepo.AssertExpectations(t)
+
+	t.Run("invalid period", func(t *testing.T) {
+		_, err := service.GetFinancialReport(reportTo, reportFrom)
+		assert.Error(t, err)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo.On("GetReportOrders", start, end).Return([]models.ReportOrder(nil), errors.New("db error")).Once()
+		_, err := service.GetFinancialReport(reportFrom, reportTo)
+		assert.Error(t, err)
+	})
+}
+
+func TestWriteExcel(t *testing.T) {
+	buf, err := report.WriteExcel(sampleReport())
+	assert.NoError(t, err)
+
+	f, err := excelize.OpenReader(buf)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	assert.Equal(t, []string{
+		report.SheetSummary, report.SheetOrders, report.SheetPayments,
+		report.SheetExpenses, report.SheetSalaries, report.SheetDebts,
+	}, f.GetSheetList())
+
+	header, _ := f.GetCellValue(report.SheetOrders, "J1")
+	assert.Equal(t, "Стоимость", header)
+	status, _ := f.GetCellValue(report.SheetOrders, "H2")
+	assert.Equal(t, "выполнен", status)
+
+	refund, _ := f.GetCellValue(report.SheetPayments, "H3")
+	assert.Equal(t, "-500.00", refund)
+	paymentsTotal, _ := f.GetCellFormula(report.SheetPayments, "H6")
+	assert.Equal(t, "SUBTOTAL(9,$H$2:$H$4)", paymentsTotal)
+
+	formula, _ := f.GetCellFormula(report.SheetSummary, "B6")
+	assert.Equal(t, `SUMIF('Заказы'!$H$2:$H$3,"выполнен",'Заказы'!$J$2:$J$3)`, formula)
+
+	for cell, want := range map[string]string{
+		"B4":  "2",         // orders
+		"B5":  "1",         // completed
+		"B6":  "5,000.00",  // revenue
+		"B7":  "5,000.00",  // confirmed payments
+		"B8":  "500.00",    // refunds
+		"B9":  "1,200.00",  // approved expenses
+		"B10": "1,500.00",  // salaries
+		"B11": "1,800.00",  // result
+		"B12": "1,000.00",  // driver debts
+	} {
+		value, _ := f.GetCellValue(report.SheetSummary, cell)
+		assert.Equal(t, want, value, cell)
+	}
+}
@@ -3,6 +3,8 @@ package review
 import (
 	"database/sql"
 	"fmt"
+	"time"
+	"github.com/lib/pq"
 	"github.com/skyzeper/telegram-bot/internal/db"
 	"github.com/skyzeper/telegram-bot/internal/models"
 	"github.com/skyzeper/telegram-bot/internal/utils"
@@ -18,16 +20,20 @@ func NewPostgresRepository(db *db.DB) *PostgresRepository {
 	return &PostgresRepository{db: db}
 }
 
+// reviewColumns lists the columns scanned by scanReview
+const reviewColumns = `id, order_id, user_id, rating, comment, photos, status, moderated_by, moderated_at, published_at, created_at`
+
 // CreateReview creates a new review
 func (r *PostgresRepository) CreateReview(review *models.Review) error {
 	query := `
-		INSERT INTO reviews (order_id, user_id, rating, comment, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO reviews (order_id, user_id, rating, comment, photos, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id
 	`
 	err := r.db.Conn().QueryRow(
 		query,
-		review.OrderID, review.UserID, review.Rating, review.Comment, review.CreatedAt,
+		review.OrderID, review.UserID, review.Rating, review.Comment, pq.Array(review.Photos),
+		review.Status, review.CreatedAt,
 	).Scan(&review.ID)
 	if err != nil {
 		utils.LogError(err)
@@ -38,15 +44,22 @@ func (r *PostgresRepository) CreateReview(review *models.Review) error {
 
 // GetReview retrieves a review by order ID
 func (r *PostgresRepository) GetReview(orderID int) (*models.Review, error) {
-	query := `
-		SELECT id, order_id, user_id, rating, comment, created_at
-		FROM reviews
-		WHERE order_id = $1
-	`
-	review := &models.Review{}
-	err := r.db.Conn().QueryRow(query, orderID).Scan(
-		&review.ID, &review.OrderID, &review.UserID, &review.Rating, &review.Comment, &review.CreatedAt,
-	)
+	query := `SELECT ` + reviewColumns + ` FROM reviews WHERE order_id = $1`
+	review, err := scanReview(r.db.Conn().QueryRow(query, orderID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("review not found")
+	}
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get review: %v", err)
+	}
+	return review, nil
+}
+
+// GetReviewByID retrieves a review by its ID
+func (r *PostgresRepository) GetReviewByID(id int) (*models.Review, error) {
+	query := `SELECT ` + reviewColumns + ` FROM reviews WHERE id = $1`
+	review, err := scanReview(r.db.Conn().QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("review not found")
 	}
@@ -59,28 +72,265 @@ func (r *PostgresRepository) GetReview(orderID int) (*models.Review, error) {
 
 // GetReviewsByUser retrieves all reviews by a user
 func (r *PostgresRepository) GetReviewsByUser(userID int64) ([]models.Review, error) {
+	query := `SELECT ` + reviewColumns + ` FROM reviews WHERE user_id = $1`
+	return r.queryReviews(query, userID)
+}
+
+// GetReviewsByStatus retrieves reviews with the given moderation status
+func (r *PostgresRepository) GetReviewsByStatus(status string) ([]models.Review, error) {
+	query := `SELECT ` + reviewColumns + ` FROM reviews WHERE status = $1 ORDER BY created_at`
+	return r.queryReviews(query, status)
+}
+
+// UpdateReview updates moderation and publication fields of a review
+func (r *PostgresRepository) UpdateReview(review *models.Review) error {
 	query := `
-		SELECT id, order_id, user_id, rating, comment, created_at
-		FROM reviews
-		WHERE user_id = $1
+		UPDATE reviews
+		SET comment = $1, photos = $2, status = $3, moderated_by = $4, moderated_at = $5, published_at = $6
+		WHERE id = $7
 	`
-	rows, err := r.db.Conn().Query(query, userID)
+	var moderatedBy sql.NullInt64
+	var moderatedAt, publishedAt sql.NullTime
+	if review.ModeratedBy != 0 {
+		moderatedBy.Valid = true
+		moderatedBy.Int64 = review.ModeratedBy
+	}
+	if !review.ModeratedAt.IsZero() {
+		moderatedAt.Valid = true
+		moderatedAt.Time = review.ModeratedAt
+	}
+	if !review.PublishedAt.IsZero() {
+		publishedAt.Valid = true
+		publishedAt.Time = review.PublishedAt
+	}
+	_, err := r.db.Conn().Exec(
+		query,
+		review.Comment, pq.Array(review.Photos), review.Status, moderatedBy, moderatedAt, publishedAt, review.ID,
+	)
 	if err != nil {
 		utils.LogError(err)
-		return nil, fmt.Errorf("failed to get reviews by user: %v", err)
+		return fmt.Errorf("failed to update review: %v", err)
+	}
+	return nil
+}
+
+// ModerateReview approves or rejects a pending review, reporting false if it was already moderated
+func (r *PostgresRepository) ModerateReview(id int, status string, moderatedBy int64, moderatedAt time.Time) (bool, error) {
+	query := `
+		UPDATE reviews
+		SET status = $1, moderated_by = $2, moderated_at = $3
+		WHERE id = $4 AND status = 'pending'
+	`
+	result, err := r.db.Conn().Exec(query, status, moderatedBy, moderatedAt, id)
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to moderate review: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to moderate review: %v", err)
+	}
+	return affected == 1, nil
+}
+
+// GetCompletedOrdersWithoutRequest retrieves orders completed in (completedAfter, completedBefore]
+// that have neither a review nor a rating prompt. Orders completed before completion times were kept
+// count as completed at their last update.
+func (r *PostgresRepository) GetCompletedOrdersWithoutRequest(completedAfter, completedBefore time.Time) ([]models.ReviewRequest, error) {
+	query := `
+		SELECT o.id, o.user_id, COALESCE(o.completed_at, o.updated_at) AS completed
+		FROM orders o
+		WHERE o.status = 'completed'
+		  AND COALESCE(o.completed_at, o.updated_at) > $1 AND COALESCE(o.completed_at, o.updated_at) <= $2
+		  AND NOT EXISTS (SELECT 1 FROM review_requests rr WHERE rr.order_id = o.id)
+		  AND NOT EXISTS (SELECT 1 FROM reviews rv WHERE rv.order_id = o.id)
+		ORDER BY completed
+	`
+	rows, err := r.db.Conn().Query(query, completedAfter, completedBefore)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get completed orders: %v", err)
 	}
 	defer rows.Close()
 
-	var reviews []models.Review
+	var requests []models.ReviewRequest
+	for rows.Next() {
+		var req models.ReviewRequest
+		if err := rows.Scan(&req.OrderID, &req.UserID, &req.CompletedAt); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		requests = append(requests, req)
+	}
+	return requests, nil
+}
+
+// CreateReviewRequest records a rating prompt, reporting false if one already exists for the order
+func (r *PostgresRepository) CreateReviewRequest(req *models.ReviewRequest) (bool, error) {
+	query := `
+		INSERT INTO review_requests (order_id, user_id, sent_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (order_id) DO NOTHING
+	`
+	result, err := r.db.Conn().Exec(query, req.OrderID, req.UserID, req.SentAt)
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to create review request: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to create review request: %v", err)
+	}
+	return affected == 1, nil
+}
+
+// DeleteReviewRequest removes the record of a rating prompt that could not be delivered
+func (r *PostgresRepository) DeleteReviewRequest(orderID int) error {
+	query := `DELETE FROM review_requests WHERE order_id = $1 AND reminded_at IS NULL`
+	if _, err := r.db.Conn().Exec(query, orderID); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to delete review request: %v", err)
+	}
+	return nil
+}
+
+// GetReviewRequestsForReminder retrieves unanswered rating prompts sent before the given time
+func (r *PostgresRepository) GetReviewRequestsForReminder(sentBefore time.Time) ([]models.ReviewRequest, error) {
+	query := `
+		SELECT rr.order_id, rr.user_id, rr.sent_at
+		FROM review_requests rr
+		WHERE rr.reminded_at IS NULL AND rr.sent_at <= $1
+		  AND NOT EXISTS (SELECT 1 FROM reviews rv WHERE rv.order_id = rr.order_id)
+		ORDER BY rr.sent_at
+	`
+	rows, err := r.db.Conn().Query(query, sentBefore)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get review requests: %v", err)
+	}
+	defer rows.Close()
+
+	var requests []models.ReviewRequest
 	for rows.Next() {
-		var review models.Review
+		var req models.ReviewRequest
+		if err := rows.Scan(&req.OrderID, &req.UserID, &req.SentAt); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		requests = append(requests, req)
+	}
+	return requests, nil
+}
+
+// MarkReviewRequestReminded records the reminder, reporting false if it was already sent
+func (r *PostgresRepository) MarkReviewRequestReminded(orderID int, remindedAt time.Time) (bool, error) {
+	query := `
+		UPDATE review_requests
+		SET reminded_at = $1
+		WHERE order_id = $2 AND reminded_at IS NULL
+	`
+	result, err := r.db.Conn().Exec(query, remindedAt, orderID)
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to mark review reminder: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to mark review reminder: %v", err)
+	}
+	return affected == 1, nil
+}
+
+// GetExecutorRatings aggregates ratings per executor (all executors when userID is 0),
+// with averages for the recent window and the window before it for the trend
+func (r *PostgresRepository) GetExecutorRatings(userID int64, recentSince, previousSince time.Time) ([]models.ExecutorRating, error) {
+	query := `
+		SELECT e.user_id, COALESCE(u.first_name, ''), u.role,
+		       AVG(rv.rating), COUNT(rv.id), COUNT(DISTINCT e.order_id),
+		       COALESCE(AVG(rv.rating) FILTER (WHERE rv.created_at >= $2), 0),
+		       COALESCE(AVG(rv.rating) FILTER (WHERE rv.created_at >= $3 AND rv.created_at < $2), 0)
+		FROM (SELECT DISTINCT order_id, user_id FROM executors) e
+		JOIN reviews rv ON rv.order_id = e.order_id
+		JOIN users u ON u.chat_id = e.user_id
+		WHERE $1::bigint = 0 OR e.user_id = $1::bigint
+		GROUP BY e.user_id, u.first_name, u.role
+		ORDER BY 4 DESC, 5 DESC
+	`
+	rows, err := r.db.Conn().Query(query, userID, recentSince, previousSince)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get executor ratings: %v", err)
+	}
+	defer rows.Close()
+
+	var ratings []models.ExecutorRating
+	for rows.Next() {
+		var rating models.ExecutorRating
 		if err := rows.Scan(
-			&review.ID, &review.OrderID, &review.UserID, &review.Rating, &review.Comment, &review.CreatedAt,
+			&rating.UserID, &rating.Name, &rating.Role, &rating.AvgRating, &rating.Reviews,
+			&rating.Orders, &rating.RecentAvg, &rating.PreviousAvg,
 		); err != nil {
 			utils.LogError(err)
 			continue
 		}
-		reviews = append(reviews, review)
+		ratings = append(ratings, rating)
+	}
+	return ratings, nil
+}
+
+// queryReviews runs a review query and scans all rows
+func (r *PostgresRepository) queryReviews(query string, args ...interface{}) ([]models.Review, error) {
+	rows, err := r.db.Conn().Query(query, args...)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get reviews: %v", err)
+	}
+	defer rows.Close()
+
+	var reviews []models.Review
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			utils.LogError(err)
+			continue
+		}
+		reviews = append(reviews, *review)
 	}
 	return reviews, nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanReview scans a review selected with reviewColumns
+func scanReview(row rowScanner) (*models.Review, error) {
+	review := &models.Review{}
+	var comment sql.NullString
+	var moderatedBy sql.NullInt64
+	var moderatedAt, publishedAt sql.NullTime
+	err := row.Scan(
+		&review.ID, &review.OrderID, &review.UserID, &review.Rating, &comment,
+		pq.Array(&review.Photos), &review.Status, &moderatedBy, &moderatedAt, &publishedAt, &review.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if comment.Valid {
+		review.Comment = comment.String
+	}
+	if moderatedBy.Valid {
+		review.ModeratedBy = moderatedBy.Int64
+	}
+	if moderatedAt.Valid {
+		review.ModeratedAt = moderatedAt.Time
+	}
+	if publishedAt.Valid {
+		review.PublishedAt = publishedAt.Time
+	}
+	return review, nil
 }
\ No newline at end of file
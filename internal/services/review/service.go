@@ -2,45 +2,336 @@ package review
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
+	"strings"
 	"time"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/utils"
 )
 
+// Review moderation statuses
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// MaxPhotos limits the number of photos attached to a review
+const MaxPhotos = 5
+
+// trendWindow is the period compared with the one before it to compute an executor's rating trend
+const trendWindow = 30 * 24 * time.Hour
+
+var (
+	linkPattern  = regexp.MustCompile(`(?i)(https?://|www\.|t\.me/|@[a-z0-9_]{4,})`)
+	phonePattern = regexp.MustCompile(`(\+?\d[\d\-\s()]{8,}\d)`)
+	flaggedWords = []string{"хуй", "хуе", "пизд", "ебан", "ебат", "бляд", "сука", "мудак", "говн"}
+)
+
+// Config holds review moderation and publication settings
+type Config struct {
+	// ChannelID is the Telegram channel approved reviews are published to (0 disables publishing)
+	ChannelID int64
+	// ModerationMaxRating is the highest rating that still requires moderation
+	ModerationMaxRating int
+	// RequestDelay is how long after completion the client is asked to rate the order
+	RequestDelay time.Duration
+	// ReminderDelay is how long after an unanswered prompt a single reminder is sent
+	ReminderDelay time.Duration
+	// RequestMaxAge is how long after completion the client is still asked; older orders are not prompted
+	RequestMaxAge time.Duration
+}
+
 // Service handles review-related business logic
 type Service struct {
+	bot  *tgbotapi.BotAPI
 	repo Repository
+	cfg  Config
 }
 
 // Repository defines the interface for review data access
 type Repository interface {
 	CreateReview(review *models.Review) error
 	GetReview(orderID int) (*models.Review, error)
+	GetReviewByID(id int) (*models.Review, error)
 	GetReviewsByUser(userID int64) ([]models.Review, error)
+	GetReviewsByStatus(status string) ([]models.Review, error)
+	UpdateReview(review *models.Review) error
+	ModerateReview(id int, status string, moderatedBy int64, moderatedAt time.Time) (bool, error)
+	GetCompletedOrdersWithoutRequest(completedAfter, completedBefore time.Time) ([]models.ReviewRequest, error)
+	CreateReviewRequest(req *models.ReviewRequest) (bool, error)
+	DeleteReviewRequest(orderID int) error
+	GetReviewRequestsForReminder(sentBefore time.Time) ([]models.ReviewRequest, error)
+	MarkReviewRequestReminded(orderID int, remindedAt time.Time) (bool, error)
+	GetExecutorRatings(userID int64, recentSince, previousSince time.Time) ([]models.ExecutorRating, error)
 }
 
 // NewService creates a new review service
-func NewService(repo Repository) *Service {
-	return &Service{repo: repo}
+func NewService(bot *tgbotapi.BotAPI, repo Repository, cfg Config) *Service {
+	if cfg.ModerationMaxRating <= 0 {
+		cfg.ModerationMaxRating = 3
+	}
+	if cfg.RequestDelay <= 0 {
+		cfg.RequestDelay = 2 * time.Hour
+	}
+	if cfg.ReminderDelay <= 0 {
+		cfg.ReminderDelay = 24 * time.Hour
+	}
+	if cfg.RequestMaxAge <= 0 {
+		cfg.RequestMaxAge = 7 * 24 * time.Hour
+	}
+	return &Service{
+		bot:  bot,
+		repo: repo,
+		cfg:  cfg,
+	}
 }
 
-// SubmitReview creates or updates a review for an order
-func (s *Service) SubmitReview(orderID int, userID int64, rating int, comment string) error {
+// SubmitReview creates a review for an order, holding it for moderation when needed
+func (s *Service) SubmitReview(orderID int, userID int64, rating int, comment string, photos []string) (*models.Review, error) {
 	if orderID <= 0 || userID <= 0 {
-		return errors.New("invalid order or user ID")
+		return nil, errors.New("invalid order or user ID")
 	}
 	if rating < 1 || rating > 5 {
-		return errors.New("rating must be between 1 and 5")
+		return nil, errors.New("rating must be between 1 and 5")
+	}
+	if len(photos) > MaxPhotos {
+		photos = photos[:MaxPhotos]
 	}
 
 	review := &models.Review{
 		OrderID:   orderID,
 		UserID:    userID,
 		Rating:    rating,
-		Comment:   comment,
+		Comment:   strings.TrimSpace(comment),
+		Photos:    photos,
+		Status:    StatusApproved,
 		CreatedAt: time.Now(),
 	}
+	if s.NeedsModeration(review.Rating, review.Comment) {
+		review.Status = StatusPending
+	}
+
+	if err := s.repo.CreateReview(review); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// NeedsModeration reports whether a review must be checked by the main operator
+func (s *Service) NeedsModeration(rating int, comment string) bool {
+	if rating <= s.cfg.ModerationMaxRating {
+		return true
+	}
+	return IsFlagged(comment)
+}
+
+// IsFlagged reports whether a comment contains links, phone numbers or obscene words
+func IsFlagged(comment string) bool {
+	if comment == "" {
+		return false
+	}
+	if linkPattern.MatchString(comment) || phonePattern.MatchString(comment) {
+		return true
+	}
+	lower := strings.ToLower(comment)
+	for _, word := range flaggedWords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// ModerateReview approves or rejects a pending review
+func (s *Service) ModerateReview(reviewID int, moderatorID int64, approve bool) (*models.Review, error) {
+	if reviewID <= 0 || moderatorID <= 0 {
+		return nil, errors.New("invalid review or moderator ID")
+	}
+
+	review, err := s.repo.GetReviewByID(reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.Status != StatusPending {
+		return nil, fmt.Errorf("review already moderated: %s", review.Status)
+	}
 
-	return s.repo.CreateReview(review)
+	status := StatusRejected
+	if approve {
+		status = StatusApproved
+	}
+	now := time.Now()
+	// Another moderator may have decided on the review since it was loaded
+	moderated, err := s.repo.ModerateReview(review.ID, status, moderatorID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !moderated {
+		return nil, errors.New("review already moderated")
+	}
+	review.Status = status
+	review.ModeratedBy = moderatorID
+	review.ModeratedAt = now
+	return review, nil
+}
+
+// PublishReview posts an approved review to the configured channel
+func (s *Service) PublishReview(review *models.Review) error {
+	if review == nil || review.Status != StatusApproved {
+		return errors.New("only approved reviews can be published")
+	}
+	if s.cfg.ChannelID == 0 || !review.PublishedAt.IsZero() {
+		return nil
+	}
+
+	text := FormatReview(review)
+	if len(review.Photos) > 0 {
+		media := make([]interface{}, 0, len(review.Photos))
+		for i, fileID := range review.Photos {
+			photo := tgbotapi.NewInputMediaPhoto(tgbotapi.FileID(fileID))
+			if i == 0 {
+				photo.Caption = text
+			}
+			media = append(media, photo)
+		}
+		if _, err := s.bot.SendMediaGroup(tgbotapi.NewMediaGroup(s.cfg.ChannelID, media)); err != nil {
+			return fmt.Errorf("failed to publish review: %v", err)
+		}
+	} else {
+		if _, err := s.bot.Send(tgbotapi.NewMessage(s.cfg.ChannelID, text)); err != nil {
+			return fmt.Errorf("failed to publish review: %v", err)
+		}
+	}
+
+	review.PublishedAt = time.Now()
+	return s.repo.UpdateReview(review)
+}
+
+// FormatReview renders a review for publication and moderation
+func FormatReview(review *models.Review) string {
+	text := fmt.Sprintf(
+		"%s Отзыв о заказе #%d\nОценка: %d/5",
+		strings.Repeat("⭐", review.Rating), review.OrderID, review.Rating,
+	)
+	if review.Comment != "" {
+		text += "\n\n" + review.Comment
+	}
+	return text
+}
+
+// ProcessReviewRequests asks clients to rate recently completed orders and reminds them once
+func (s *Service) ProcessReviewRequests() error {
+	now := time.Now()
+
+	completed, err := s.repo.GetCompletedOrdersWithoutRequest(now.Add(-s.cfg.RequestMaxAge), now.Add(-s.cfg.RequestDelay))
+	if err != nil {
+		return fmt.Errorf("failed to get completed orders: %v", err)
+	}
+	for _, req := range completed {
+		req.SentAt = now
+		created, err := s.repo.CreateReviewRequest(&req)
+		if err != nil || !created {
+			continue // Already asked or failed to record; never prompt without a record
+		}
+		text := fmt.Sprintf("✅ Заказ #%d выполнен!\n🌟 Пожалуйста, оцените нашу работу — это займёт пару секунд.", req.OrderID)
+		if err := s.sendRatingPrompt(req.UserID, req.OrderID, text); err != nil {
+			utils.LogError(err)
+			// The client never saw the prompt, so the record is dropped and the next run asks again
+			if err := s.repo.DeleteReviewRequest(req.OrderID); err != nil {
+				utils.LogError(err)
+			}
+		}
+	}
+
+	unanswered, err := s.repo.GetReviewRequestsForReminder(now.Add(-s.cfg.ReminderDelay))
+	if err != nil {
+		return fmt.Errorf("failed to get review requests: %v", err)
+	}
+	for _, req := range unanswered {
+		marked, err := s.repo.MarkReviewRequestReminded(req.OrderID, now)
+		if err != nil || !marked {
+			continue
+		}
+		text := fmt.Sprintf("⏰ Напоминаем: вы ещё не оценили заказ #%d.\nВаш отзыв помогает нам стать лучше!", req.OrderID)
+		if err := s.sendRatingPrompt(req.UserID, req.OrderID, text); err != nil {
+			utils.LogError(err)
+		}
+	}
+	return nil
+}
+
+// sendRatingPrompt sends the client a message with the rate button for an order
+func (s *Service) sendRatingPrompt(userID int64, orderID int, text string) error {
+	msg := tgbotapi.NewMessage(userID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🌟 Оценить заказ", fmt.Sprintf("review_rate_%d", orderID)),
+		),
+	)
+	if _, err := s.bot.Send(msg); err != nil {
+		return fmt.Errorf("failed to send rating prompt: %v", err)
+	}
+	return nil
+}
+
+// GetExecutorRating retrieves the aggregated rating of a single executor
+func (s *Service) GetExecutorRating(userID int64) (*models.ExecutorRating, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+	now := time.Now()
+	ratings, err := s.repo.GetExecutorRatings(userID, now.Add(-trendWindow), now.Add(-2*trendWindow))
+	if err != nil {
+		return nil, err
+	}
+	if len(ratings) == 0 {
+		return &models.ExecutorRating{UserID: userID}, nil
+	}
+	return &ratings[0], nil
+}
+
+// GetExecutorRatings retrieves aggregated ratings of all executors keyed by user ID
+func (s *Service) GetExecutorRatings() (map[int64]models.ExecutorRating, error) {
+	now := time.Now()
+	ratings, err := s.repo.GetExecutorRatings(0, now.Add(-trendWindow), now.Add(-2*trendWindow))
+	if err != nil {
+		return nil, err
+	}
+	byUser := make(map[int64]models.ExecutorRating, len(ratings))
+	for _, rating := range ratings {
+		byUser[rating.UserID] = rating
+	}
+	return byUser, nil
+}
+
+// FormatExecutorRating renders an executor rating like "⭐ 4.6 (12 отз.) 📈"
+func FormatExecutorRating(rating models.ExecutorRating) string {
+	if rating.Reviews == 0 {
+		return "⭐ нет отзывов"
+	}
+	text := fmt.Sprintf("⭐ %.1f (%d отз.)", rating.AvgRating, rating.Reviews)
+	if trend := Trend(rating); trend != "" {
+		text += " " + trend
+	}
+	return text
+}
+
+// Trend compares the recent average with the previous window: 📈 rising, 📉 falling, ➡️ stable
+func Trend(rating models.ExecutorRating) string {
+	if rating.RecentAvg == 0 || rating.PreviousAvg == 0 {
+		return ""
+	}
+	switch diff := rating.RecentAvg - rating.PreviousAvg; {
+	case diff >= 0.2:
+		return "📈"
+	case diff <= -0.2:
+		return "📉"
+	default:
+		return "➡️"
+	}
 }
 
 // GetReview retrieves a review by order ID
@@ -51,6 +342,19 @@ func (s *Service) GetReview(orderID int) (*models.Review, error) {
 	return s.repo.GetReview(orderID)
 }
 
+// GetReviewByID retrieves a review by its ID
+func (s *Service) GetReviewByID(reviewID int) (*models.Review, error) {
+	if reviewID <= 0 {
+		return nil, errors.New("invalid review ID")
+	}
+	return s.repo.GetReviewByID(reviewID)
+}
+
+// GetPendingReviews retrieves reviews waiting for moderation
+func (s *Service) GetPendingReviews() ([]models.Review, error) {
+	return s.repo.GetReviewsByStatus(StatusPending)
+}
+
 // GetReviewsByUser retrieves all reviews by a user
 func (s *Service) GetReviewsByUser(userID int64) ([]models.Review, error) {
 	if userID <= 0 {
@@ -0,0 +1,366 @@
+package review_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+	"time"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/review"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository is a mock implementation of review.Repository
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateReview(r *models.Review) error {
+	args := m.Called(r)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetReview(orderID int) (*models.Review, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Review), args.Error(1)
+}
+
+func (m *MockRepository) GetReviewByID(id int) (*models.Review, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Review), args.Error(1)
+}
+
+func (m *MockRepository) GetReviewsByUser(userID int64) ([]models.Review, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]models.Review), args.Error(1)
+}
+
+func (m *MockRepository) GetReviewsByStatus(status string) ([]models.Review, error) {
+	args := m.Called(status)
+	return args.Get(0).([]models.Review), args.Error(1)
+}
+
+func (m *MockRepository) UpdateReview(r *models.Review) error {
+	args := m.Called(r)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ModerateReview(id int, status string, moderatedBy int64, moderatedAt time.Time) (bool, error) {
+	args := m.Called(id, status, moderatedBy, moderatedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetCompletedOrdersWithoutRequest(completedAfter, completedBefore time.Time) ([]models.ReviewRequest, error) {
+	args := m.Called(completedAfter, completedBefore)
+	return args.Get(0).([]models.ReviewRequest), args.Error(1)
+}
+
+func (m *MockRepository) DeleteReviewRequest(orderID int) error {
+	args := m.Called(orderID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CreateReviewRequest(req *models.ReviewRequest) (bool, error) {
+	args := m.Called(req)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetReviewRequestsForReminder(sentBefore time.Time) ([]models.ReviewRequest, error) {
+	args := m.Called(sentBefore)
+	return args.Get(0).([]models.ReviewRequest), args.Error(1)
+}
+
+func (m *MockRepository) MarkReviewRequestReminded(orderID int, remindedAt time.Time) (bool, error) {
+	args := m.Called(orderID, remindedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetExecutorRatings(userID int64, recentSince, previousSince time.Time) ([]models.ExecutorRating, error) {
+	args := m.Called(userID, recentSince, previousSince)
+	return args.Get(0).([]models.ExecutorRating), args.Error(1)
+}
+
+// fakeBotAPI records the chat IDs of messages sent through a stub Bot API server
+type fakeBotAPI struct {
+	mu    sync.Mutex
+	chats []string
+}
+
+func newTestBot(t *testing.T) (*tgbotapi.BotAPI, *fakeBotAPI) {
+	fake := &fakeBotAPI{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err == nil && r.FormValue("chat_id") != "" {
+			fake.mu.Lock()
+			fake.chats = append(fake.chats, r.FormValue("chat_id"))
+			fake.mu.Unlock()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot","message_id":1,"date":0,"chat":{"id":1}}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint("test-token", server.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatalf("failed to create test bot: %v", err)
+	}
+	return bot, fake
+}
+
+func TestIsFlagged(t *testing.T) {
+	flagged := []string{
+		"Пишите мне в t.me/somebody",
+		"звоните +7 (978) 123-45-67",
+		"подробнее на https://example.com",
+		"полная сука, а не сервис",
+	}
+	for _, comment := range flagged {
+		assert.True(t, review.IsFlagged(comment), comment)
+	}
+
+	clean := []string{"", "Всё вывезли быстро, спасибо!", "Приехали к 10, за 2 часа управились"}
+	for _, comment := range clean {
+		assert.False(t, review.IsFlagged(comment), comment)
+	}
+}
+
+func TestService_SubmitReview(t *testing.T) {
+	t.Run("HighRatingApproved", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := review.NewService(nil, mockRepo, review.Config{})
+
+		mockRepo.On("CreateReview", mock.MatchedBy(func(r *models.Review) bool {
+			return r.Status == review.StatusApproved && len(r.Photos) == 1
+		})).Return(nil).Once()
+
+		r, err := service.SubmitReview(1, 100, 5, " Отлично ", []string{"photo-1"})
+		assert.NoError(t, err)
+		assert.Equal(t, "Отлично", r.Comment)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("LowRatingPending", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := review.NewService(nil, mockRepo, review.Config{})
+
+		mockRepo.On("CreateReview", mock.MatchedBy(func(r *models.Review) bool {
+			return r.Status == review.StatusPending
+		})).Return(nil).Once()
+
+		_, err := service.SubmitReview(1, 100, 3, "", nil)
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("FlaggedCommentPending", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := review.NewService(nil, mockRepo, review.Config{})
+
+		mockRepo.On("CreateReview", mock.MatchedBy(func(r *models.Review) bool {
+			return r.Status == review.StatusPending
+		})).Return(nil).Once()
+
+		_, err := service.SubmitReview(1, 100, 5, "Дешевле тут: www.example.com", nil)
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("PhotosTrimmed", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := review.NewService(nil, mockRepo, review.Config{})
+
+		mockRepo.On("CreateReview", mock.AnythingOfType("*models.Review")).Return(nil).Once()
+
+		r, err := service.SubmitReview(1, 100, 5, "", []string{"1", "2", "3", "4", "5", "6", "7"})
+		assert.NoError(t, err)
+		assert.Len(t, r.Photos, review.MaxPhotos)
+	})
+
+	t.Run("InvalidRating", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := review.NewService(nil, mockRepo, review.Config{})
+
+		_, err := service.SubmitReview(1, 100, 6, "", nil)
+		assert.Error(t, err)
+		mockRepo.AssertNotCalled(t, "CreateReview", mock.Anything)
+	})
+}
+
+func TestService_ModerateReview(t *testing.T) {
+	t.Run("Approve", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := review.NewService(nil, mockRepo, review.Config{})
+
+		mockRepo.On("GetReviewByID", 7).Return(&models.Review{ID: 7, Status: review.StatusPending}, nil).Once()
+		mockRepo.On("ModerateReview", 7, review.StatusApproved, int64(42), mock.AnythingOfType("time.Time")).Return(true, nil).Once()
+
+		r, err := service.ModerateReview(7, 42, true)
+		assert.NoError(t, err)
+		assert.Equal(t, review.StatusApproved, r.Status)
+		assert.Equal(t, int64(42), r.ModeratedBy)
+		assert.False(t, r.ModeratedAt.IsZero())
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("ModeratedConcurrently", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := review.NewService(nil, mockRepo, review.Config{})
+
+		// Another moderator rejected the review after it was loaded
+		mockRepo.On("GetReviewByID", 7).Return(&models.Review{ID: 7, Status: review.StatusPending}, nil).Once()
+		mockRepo.On("ModerateReview", 7, review.StatusApproved, int64(42), mock.AnythingOfType("time.Time")).Return(false, nil).Once()
+
+		_, err := service.ModerateReview(7, 42, true)
+		assert.Error(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("AlreadyModerated", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := review.NewService(nil, mockRepo, review.Config{})
+
+		mockRepo.On("GetReviewByID", 7).Return(&models.Review{ID: 7, Status: review.StatusRejected}, nil).Once()
+
+		_, err := service.ModerateReview(7, 42, true)
+		assert.Error(t, err)
+		mockRepo.AssertNotCalled(t, "ModerateReview", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := review.NewService(nil, mockRepo, review.Config{})
+
+		mockRepo.On("GetReviewByID", 7).Return(nil, errors.New("review not found")).Once()
+
+		_, err := service.ModerateReview(7, 42, false)
+		assert.Error(t, err)
+	})
+}
+
+func TestService_PublishReview(t *testing.T) {
+	t.Run("NoChannelConfigured", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := review.NewService(nil, mockRepo, review.Config{})
+
+		err := service.PublishReview(&models.Review{ID: 1, Rating: 5, Status: review.StatusApproved})
+		assert.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "UpdateReview", mock.Anything)
+	})
+
+	t.Run("NotApproved", func(t *testing.T) {
+		service := review.NewService(nil, new(MockRepository), review.Config{ChannelID: -100})
+
+		err := service.PublishReview(&models.Review{ID: 1, Rating: 2, Status: review.StatusPending})
+		assert.Error(t, err)
+	})
+}
+
+func TestService_ProcessReviewRequests(t *testing.T) {
+	t.Run("PromptAndReminder", func(t *testing.T) {
+		bot, fake := newTestBot(t)
+		mockRepo := new(MockRepository)
+		service := review.NewService(bot, mockRepo, review.Config{RequestDelay: time.Hour, ReminderDelay: 24 * time.Hour})
+
+		mockRepo.On("GetCompletedOrdersWithoutRequest", mock.MatchedBy(func(after time.Time) bool {
+			return time.Since(after) >= 7*24*time.Hour
+		}), mock.MatchedBy(func(before time.Time) bool {
+			return time.Since(before) >= time.Hour
+		})).Return([]models.ReviewRequest{{OrderID: 1, UserID: 100}}, nil).Once()
+		mockRepo.On("CreateReviewRequest", mock.MatchedBy(func(req *models.ReviewRequest) bool {
+			return req.OrderID == 1 && !req.SentAt.IsZero()
+		})).Return(true, nil).Once()
+		mockRepo.On("GetReviewRequestsForReminder", mock.AnythingOfType("time.Time")).
+			Return([]models.ReviewRequest{{OrderID: 2, UserID: 200}}, nil).Once()
+		mockRepo.On("MarkReviewRequestReminded", 2, mock.AnythingOfType("time.Time")).Return(true, nil).Once()
+
+		assert.NoError(t, service.ProcessReviewRequests())
+		assert.Equal(t, []string{"100", "200"}, fake.chats)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("NeverAskTwice", func(t *testing.T) {
+		bot, fake := newTestBot(t)
+		mockRepo := new(MockRepository)
+		service := review.NewService(bot, mockRepo, review.Config{})
+
+		mockRepo.On("GetCompletedOrdersWithoutRequest", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).
+			Return([]models.ReviewRequest{{OrderID: 1, UserID: 100}}, nil).Once()
+		mockRepo.On("CreateReviewRequest", mock.AnythingOfType("*models.ReviewRequest")).Return(false, nil).Once()
+		mockRepo.On("GetReviewRequestsForReminder", mock.AnythingOfType("time.Time")).
+			Return([]models.ReviewRequest{{OrderID: 2, UserID: 200}}, nil).Once()
+		mockRepo.On("MarkReviewRequestReminded", 2, mock.AnythingOfType("time.Time")).Return(false, nil).Once()
+
+		assert.NoError(t, service.ProcessReviewRequests())
+		assert.Empty(t, fake.chats)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("RetryAfterFailedSend", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if path.Base(r.URL.Path) == "sendMessage" {
+				w.Write([]byte(`{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`))
+				return
+			}
+			w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot"}}`))
+		}))
+		t.Cleanup(server.Close)
+		bot, err := tgbotapi.NewBotAPIWithAPIEndpoint("test-token", server.URL+"/bot%s/%s")
+		assert.NoError(t, err)
+		mockRepo := new(MockRepository)
+		service := review.NewService(bot, mockRepo, review.Config{})
+
+		mockRepo.On("GetCompletedOrdersWithoutRequest", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).
+			Return([]models.ReviewRequest{{OrderID: 1, UserID: 100}}, nil).Once()
+		mockRepo.On("CreateReviewRequest", mock.AnythingOfType("*models.ReviewRequest")).Return(true, nil).Once()
+		mockRepo.On("DeleteReviewRequest", 1).Return(nil).Once()
+		mockRepo.On("GetReviewRequestsForReminder", mock.AnythingOfType("time.Time")).Return([]models.ReviewRequest{}, nil).Once()
+
+		assert.NoError(t, service.ProcessReviewRequests())
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestService_GetExecutorRating(t *testing.T) {
+	t.Run("WithReviews", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := review.NewService(nil, mockRepo, review.Config{})
+
+		mockRepo.On("GetExecutorRatings", int64(7), mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).
+			Return([]models.ExecutorRating{{UserID: 7, AvgRating: 4.5, Reviews: 4, RecentAvg: 5, PreviousAvg: 4}}, nil).Once()
+
+		rating, err := service.GetExecutorRating(7)
+		assert.NoError(t, err)
+		assert.Equal(t, "⭐ 4.5 (4 отз.) 📈", review.FormatExecutorRating(*rating))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("NoReviews", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		service := review.NewService(nil, mockRepo, review.Config{})
+
+		mockRepo.On("GetExecutorRatings", int64(7), mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).
+			Return([]models.ExecutorRating{}, nil).Once()
+
+		rating, err := service.GetExecutorRating(7)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), rating.UserID)
+		assert.Equal(t, "⭐ нет отзывов", review.FormatExecutorRating(*rating))
+	})
+}
+
+func TestTrend(t *testing.T) {
+	assert.Equal(t, "📉", review.Trend(models.ExecutorRating{RecentAvg: 3.5, PreviousAvg: 4.8}))
+	assert.Equal(t, "➡️", review.Trend(models.ExecutorRating{RecentAvg: 4.6, PreviousAvg: 4.5}))
+	assert.Empty(t, review.Trend(models.ExecutorRating{RecentAvg: 4.6}))
+}
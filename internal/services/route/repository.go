@@ -0,0 +1,60 @@
+package route
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/db"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// PostgresRepository implements the Repository interface for PostgreSQL
+type PostgresRepository struct {
+	db *db.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL repository
+func NewPostgresRepository(db *db.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// GetExecutorDayOrders retrieves the open orders assigned to an executor for a day
+func (r *PostgresRepository) GetExecutorDayOrders(userID int64, day time.Time) ([]models.Order, error) {
+	query := `
+		SELECT o.id, o.address, o.date, o.time, o.latitude, o.longitude
+		FROM orders o
+		JOIN executors e ON o.id = e.order_id
+		WHERE e.user_id = $1 AND o.date = $2::date AND o.status NOT IN ('completed', 'canceled')
+		ORDER BY o.time, o.id
+	`
+	rows, err := r.db.Conn().Query(query, userID, day.Format("2006-01-02"))
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get executor day orders: %v", err)
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	for rows.Next() {
+		var order models.Order
+		var date, timeVal sql.NullTime
+		var lat, lon sql.NullFloat64
+		if err := rows.Scan(&order.ID, &order.Address, &date, &timeVal, &lat, &lon); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		if date.Valid {
+			order.Date = date.Time
+		}
+		if timeVal.Valid {
+			order.Time = timeVal.Time
+		}
+		if lat.Valid && lon.Valid {
+			order.Latitude = lat.Float64
+			order.Longitude = lon.Float64
+		}
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
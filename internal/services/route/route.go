@@ -0,0 +1,269 @@
+package route
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/services/geo"
+)
+
+// latePenalty weighs every minute of arriving after a time slot against minutes of driving
+const latePenalty = 10
+
+// maxImprovementRounds caps 2-opt passes over the route
+const maxImprovementRounds = 50
+
+// Stop is an order to visit
+type Stop struct {
+	OrderID int
+	Address string
+	Point   geo.Point
+	// SlotStart and SlotEnd bound the arrival time the client expects; zero values mean any time
+	SlotStart time.Time
+	SlotEnd   time.Time
+}
+
+// Leg is the travel between two points
+type Leg struct {
+	Meters   float64
+	Duration time.Duration
+}
+
+// DistanceMatrix returns travel legs between every pair of points; matrix[i][j] is the leg from i to j
+type DistanceMatrix interface {
+	Matrix(points []geo.Point) ([][]Leg, error)
+}
+
+// StraightLine estimates legs from great-circle distances, an average speed and a detour factor for the road network
+type StraightLine struct {
+	// Speed is the average speed in km/h
+	Speed float64
+	// Detour scales straight-line distances to road distances
+	Detour float64
+}
+
+// DefaultMatrix estimates city driving at 30 km/h with roads 30% longer than straight lines
+func DefaultMatrix() DistanceMatrix {
+	return StraightLine{Speed: 30, Detour: 1.3}
+}
+
+// Matrix implements DistanceMatrix
+func (s StraightLine) Matrix(points []geo.Point) ([][]Leg, error) {
+	if s.Speed <= 0 {
+		return nil, fmt.Errorf("invalid average speed: %.1f", s.Speed)
+	}
+	detour := s.Detour
+	if detour < 1 {
+		detour = 1
+	}
+	matrix := make([][]Leg, len(points))
+	for i := range points {
+		matrix[i] = make([]Leg, len(points))
+		for j := range points {
+			if i == j {
+				continue
+			}
+			meters := geo.Distance(points[i], points[j]) * detour
+			matrix[i][j] = Leg{
+				Meters:   meters,
+				Duration: time.Duration(meters / (s.Speed * 1000 / 3600) * float64(time.Second)),
+			}
+		}
+	}
+	return matrix, nil
+}
+
+// PlannedStop is a stop in visiting order with its estimated arrival
+type PlannedStop struct {
+	Stop
+	// Leg is the travel from the previous stop or the start point
+	Leg     Leg
+	Arrival time.Time
+	// Wait is the time spent waiting for the slot to open, Late the time past its end
+	Wait time.Duration
+	Late time.Duration
+}
+
+// Route is a planned visiting order
+type Route struct {
+	Departure time.Time
+	Start     *geo.Point
+	Stops     []PlannedStop
+	// Unrouted are stops without coordinates, listed in slot order
+	Unrouted []Stop
+	Meters   float64
+	// Finish is when the last stop is done
+	Finish time.Time
+}
+
+// Planner orders stops to minimise driving while honouring time slots
+type Planner struct {
+	Matrix DistanceMatrix
+	// ServiceTime is the time spent at every stop
+	ServiceTime time.Duration
+}
+
+// Plan builds a route departing at departure from start, or from the first stop when start is nil:
+// a time-aware nearest-neighbour tour improved with 2-opt
+func (p *Planner) Plan(start *geo.Point, departure time.Time, stops []Stop) (*Route, error) {
+	route := &Route{Departure: departure, Start: start}
+	var located []Stop
+	for _, stop := range stops {
+		if stop.Point.Valid() {
+			located = append(located, stop)
+		} else {
+			route.Unrouted = append(route.Unrouted, stop)
+		}
+	}
+	sortBySlot(route.Unrouted)
+	if len(located) == 0 {
+		route.Finish = departure
+		return route, nil
+	}
+
+	// Index 0 of the matrix is the start point when there is one
+	offset := 0
+	var points []geo.Point
+	if start != nil {
+		points = append(points, *start)
+		offset = 1
+	}
+	for _, stop := range located {
+		points = append(points, stop.Point)
+	}
+	matrix, err := p.Matrix.Matrix(points)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get distance matrix: %v", err)
+	}
+	t := &tour{stops: located, matrix: matrix, offset: offset, departure: departure, service: p.ServiceTime}
+
+	order := t.nearestNeighbour()
+	t.improve(order)
+	route.Stops, route.Meters, route.Finish = t.schedule(order)
+	return route, nil
+}
+
+// tour evaluates visiting orders of located stops
+type tour struct {
+	stops     []Stop
+	matrix    [][]Leg
+	offset    int
+	departure time.Time
+	service   time.Duration
+}
+
+// leg returns the travel from stop index from (-1 for the start) to stop index to
+func (t *tour) leg(from, to int) Leg {
+	if from < 0 {
+		if t.offset == 0 {
+			return Leg{}
+		}
+		return t.matrix[0][to+t.offset]
+	}
+	return t.matrix[from+t.offset][to+t.offset]
+}
+
+// arrive returns the arrival at a stop reached at reach, with the wait for its slot and the lateness past it
+func (t *tour) arrive(stop Stop, reach time.Time) (arrival time.Time, wait, late time.Duration) {
+	arrival = reach
+	if !stop.SlotStart.IsZero() && arrival.Before(stop.SlotStart) {
+		wait = stop.SlotStart.Sub(arrival)
+		arrival = stop.SlotStart
+	}
+	if !stop.SlotEnd.IsZero() && arrival.After(stop.SlotEnd) {
+		late = arrival.Sub(stop.SlotEnd)
+	}
+	return arrival, wait, late
+}
+
+// cost returns the time from departure to finishing the last stop plus the weighted lateness
+func (t *tour) cost(order []int) time.Duration {
+	now, prev := t.departure, -1
+	var late time.Duration
+	for _, i := range order {
+		arrival, _, l := t.arrive(t.stops[i], now.Add(t.leg(prev, i).Duration))
+		late += l
+		now, prev = arrival.Add(t.service), i
+	}
+	return now.Sub(t.departure) + late*latePenalty
+}
+
+// nearestNeighbour repeatedly visits the stop that can be served soonest, counting waits and lateness
+func (t *tour) nearestNeighbour() []int {
+	visited := make([]bool, len(t.stops))
+	order := make([]int, 0, len(t.stops))
+	now, prev := t.departure, -1
+	for len(order) < len(t.stops) {
+		best, bestScore, bestArrival := -1, time.Duration(0), time.Time{}
+		for i, stop := range t.stops {
+			if visited[i] {
+				continue
+			}
+			arrival, _, late := t.arrive(stop, now.Add(t.leg(prev, i).Duration))
+			score := arrival.Sub(now) + late*latePenalty
+			if best < 0 || score < bestScore || score == bestScore && slotBefore(stop, t.stops[best]) {
+				best, bestScore, bestArrival = i, score, arrival
+			}
+		}
+		visited[best] = true
+		order = append(order, best)
+		now, prev = bestArrival.Add(t.service), best
+	}
+	return order
+}
+
+// improve applies 2-opt segment reversals while they lower the cost
+func (t *tour) improve(order []int) {
+	best := t.cost(order)
+	for round := 0; round < maxImprovementRounds; round++ {
+		improved := false
+		for i := 0; i < len(order)-1; i++ {
+			for j := i + 1; j < len(order); j++ {
+				reverse(order[i : j+1])
+				if c := t.cost(order); c < best {
+					best, improved = c, true
+				} else {
+					reverse(order[i : j+1])
+				}
+			}
+		}
+		if !improved {
+			return
+		}
+	}
+}
+
+// schedule computes arrivals along the order
+func (t *tour) schedule(order []int) ([]PlannedStop, float64, time.Time) {
+	planned := make([]PlannedStop, 0, len(order))
+	now, prev := t.departure, -1
+	var meters float64
+	for _, i := range order {
+		leg := t.leg(prev, i)
+		arrival, wait, late := t.arrive(t.stops[i], now.Add(leg.Duration))
+		planned = append(planned, PlannedStop{Stop: t.stops[i], Leg: leg, Arrival: arrival, Wait: wait, Late: late})
+		meters += leg.Meters
+		now, prev = arrival.Add(t.service), i
+	}
+	return planned, meters, now
+}
+
+// reverse reverses a slice in place
+func reverse(order []int) {
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+}
+
+// slotBefore reports whether a's slot starts before b's; stops without a slot go last
+func slotBefore(a, b Stop) bool {
+	if a.SlotStart.IsZero() || b.SlotStart.IsZero() {
+		return !a.SlotStart.IsZero() && b.SlotStart.IsZero()
+	}
+	return a.SlotStart.Before(b.SlotStart)
+}
+
+// sortBySlot orders stops by slot start, keeping stops without a slot at the end
+func sortBySlot(stops []Stop) {
+	sort.SliceStable(stops, func(i, j int) bool { return slotBefore(stops[i], stops[j]) })
+}
@@ -0,0 +1,139 @@
+package route_test
+
+import (
+	"math"
+	"testing"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/geo"
+	"github.com/skyzeper/telegram-bot/internal/services/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository is a mock implementation of route.Repository
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) GetExecutorDayOrders(userID int64, day time.Time) ([]models.Order, error) {
+	args := m.Called(userID, day)
+	return args.Get(0).([]models.Order), args.Error(1)
+}
+
+// lineMatrix places points on a parallel: every 0.01° of longitude is one minute and one kilometre away
+type lineMatrix struct{}
+
+func (lineMatrix) Matrix(points []geo.Point) ([][]route.Leg, error) {
+	matrix := make([][]route.Leg, len(points))
+	for i := range points {
+		matrix[i] = make([]route.Leg, len(points))
+		for j := range points {
+			steps := math.Round(math.Abs(points[i].Lon-points[j].Lon) * 100)
+			matrix[i][j] = route.Leg{Meters: steps * 1000, Duration: time.Duration(steps) * time.Minute}
+		}
+	}
+	return matrix, nil
+}
+
+func at(lon float64) geo.Point {
+	return geo.Point{Lat: 55, Lon: lon}
+}
+
+func orderIDs(r *route.Route) []int {
+	var ids []int
+	for _, stop := range r.Stops {
+		ids = append(ids, stop.OrderID)
+	}
+	return ids
+}
+
+func TestPlanVisitsNearestFirst(t *testing.T) {
+	planner := &route.Planner{Matrix: lineMatrix{}, ServiceTime: 30 * time.Minute}
+	depot := at(0)
+	departure := time.Date(2024, 3, 13, 8, 0, 0, 0, time.UTC)
+
+	r, err := planner.Plan(&depot, departure, []route.Stop{
+		{OrderID: 3, Point: at(0.03)},
+		{OrderID: 1, Point: at(0.01)},
+		{OrderID: 2, Point: at(0.02)},
+		{OrderID: 4, Address: "ул. Ленина, 1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, orderIDs(r))
+	assert.Equal(t, 3000.0, r.Meters)
+	assert.Equal(t, time.Date(2024, 3, 13, 8, 1, 0, 0, time.UTC), r.Stops[0].Arrival)
+	assert.Equal(t, time.Date(2024, 3, 13, 8, 32, 0, 0, time.UTC), r.Stops[1].Arrival)
+	assert.Equal(t, time.Date(2024, 3, 13, 9, 33, 0, 0, time.UTC), r.Finish)
+	assert.Len(t, r.Unrouted, 1)
+	assert.Equal(t, 4, r.Unrouted[0].OrderID)
+}
+
+func TestPlanHonoursTimeSlots(t *testing.T) {
+	planner := &route.Planner{Matrix: lineMatrix{}, ServiceTime: 30 * time.Minute}
+	depot := at(0)
+	departure := time.Date(2024, 3, 13, 8, 0, 0, 0, time.UTC)
+
+	// The nearest stop first would make the client of order 2 wait past their slot
+	r, err := planner.Plan(&depot, departure, []route.Stop{
+		{OrderID: 1, Point: at(0.01)},
+		{OrderID: 2, Point: at(0.05), SlotStart: departure, SlotEnd: departure.Add(30 * time.Minute)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, orderIDs(r))
+	assert.Zero(t, r.Stops[0].Late)
+
+	// Early arrivals wait for the slot to open
+	r, err = planner.Plan(&depot, departure, []route.Stop{
+		{OrderID: 1, Point: at(0.01), SlotStart: departure.Add(2 * time.Hour), SlotEnd: departure.Add(4 * time.Hour)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, departure.Add(2*time.Hour), r.Stops[0].Arrival)
+	assert.Equal(t, 119*time.Minute, r.Stops[0].Wait)
+}
+
+func TestStraightLine(t *testing.T) {
+	matrix, err := route.StraightLine{Speed: 36, Detour: 1}.Matrix([]geo.Point{at(37.6), at(37.7)})
+	assert.NoError(t, err)
+	// 0.1° of longitude at 55° N is about 6.4 km, 10 m/s takes about 640 s
+	assert.InDelta(t, 6380, matrix[0][1].Meters, 50)
+	assert.InDelta(t, 638, matrix[0][1].Duration.Seconds(), 5)
+	assert.Equal(t, matrix[0][1], matrix[1][0])
+
+	_, err = route.StraightLine{}.Matrix([]geo.Point{at(37.6)})
+	assert.Error(t, err)
+}
+
+func TestPlanDay(t *testing.T) {
+	repo := new(MockRepository)
+	loc := time.FixedZone("MSK", 3*3600)
+	depot := at(0)
+	service := route.NewService(repo, lineMatrix{}, route.Config{Depot: &depot, Location: loc})
+	day := time.Date(2024, 3, 13, 0, 0, 0, 0, loc)
+
+	repo.On("GetExecutorDayOrders", int64(7), day).Return([]models.Order{
+		{ID: 10, Address: "ул. Мира, 5", Latitude: 55, Longitude: 0.02, Time: time.Date(0, 1, 1, 10, 0, 0, 0, time.UTC)},
+		{ID: 11, Address: "пр. Победы, 1", Latitude: 55, Longitude: 0.01},
+	}, nil)
+
+	// Before the working day starts the route departs at 08:00
+	r, err := service.PlanDay(7, day, day.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, day.Add(8*time.Hour), r.Departure)
+	assert.Equal(t, []int{11, 10}, orderIDs(r))
+	assert.Equal(t, day.Add(10*time.Hour), r.Stops[1].SlotStart)
+	assert.Equal(t, day.Add(12*time.Hour), r.Stops[1].SlotEnd)
+	assert.Equal(t, []geo.Point{depot, at(0.01), at(0.02)}, r.Points())
+
+	text := route.FormatRoute(day, r)
+	assert.Contains(t, text, "🧭 Маршрут на 13.03.2024")
+	assert.Contains(t, text, "1. ~08:01 — заказ #11")
+	assert.Contains(t, text, "2. ~10:00 — заказ #10")
+	assert.Contains(t, text, "Время клиента: 10:00–12:00")
+
+	// Later in the day the route departs now
+	r, err = service.PlanDay(7, day, day.Add(9*time.Hour+30*time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, day.Add(9*time.Hour), r.Departure)
+	repo.AssertExpectations(t)
+}
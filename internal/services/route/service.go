@@ -0,0 +1,152 @@
+package route
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/geo"
+)
+
+// Config holds route planning settings
+type Config struct {
+	// Depot is where drivers start the day; without it routes start at the first stop
+	Depot *geo.Point
+	// DayStart is the earliest departure as the offset from midnight
+	DayStart time.Duration
+	// ServiceTime is the time spent at every order
+	ServiceTime time.Duration
+	// SlotWindow is how long after the ordered time the client still expects the driver
+	SlotWindow time.Duration
+	// Location is the time zone of order dates and times
+	Location *time.Location
+}
+
+// Service plans drivers' daily routes
+type Service struct {
+	repo    Repository
+	planner *Planner
+	cfg     Config
+}
+
+// Repository defines the interface for route data access
+type Repository interface {
+	GetExecutorDayOrders(userID int64, day time.Time) ([]models.Order, error)
+}
+
+// NewService creates a new route service; a nil matrix estimates legs from straight-line distances
+func NewService(repo Repository, matrix DistanceMatrix, cfg Config) *Service {
+	if matrix == nil {
+		matrix = DefaultMatrix()
+	}
+	if cfg.DayStart <= 0 {
+		cfg.DayStart = 8 * time.Hour
+	}
+	if cfg.ServiceTime <= 0 {
+		cfg.ServiceTime = 45 * time.Minute
+	}
+	if cfg.SlotWindow <= 0 {
+		cfg.SlotWindow = 2 * time.Hour
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+	return &Service{
+		repo:    repo,
+		planner: &Planner{Matrix: matrix, ServiceTime: cfg.ServiceTime},
+		cfg:     cfg,
+	}
+}
+
+// Location returns the time zone routes are planned in
+func (s *Service) Location() *time.Location {
+	return s.cfg.Location
+}
+
+// PlanDay plans the route through an executor's open orders of a day, departing no earlier than now
+func (s *Service) PlanDay(userID int64, day, now time.Time) (*Route, error) {
+	day = day.In(s.cfg.Location)
+	orders, err := s.repo.GetExecutorDayOrders(userID, day)
+	if err != nil {
+		return nil, err
+	}
+
+	stops := make([]Stop, 0, len(orders))
+	for _, o := range orders {
+		stops = append(stops, s.stopOf(o, day))
+	}
+	departure := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, s.cfg.Location).Add(s.cfg.DayStart)
+	if now.After(departure) {
+		departure = now.In(s.cfg.Location).Truncate(time.Minute)
+	}
+	return s.planner.Plan(s.cfg.Depot, departure, stops)
+}
+
+// stopOf converts an order to a stop with a slot starting at its ordered time
+func (s *Service) stopOf(o models.Order, day time.Time) Stop {
+	stop := Stop{
+		OrderID: o.ID,
+		Address: o.Address,
+		Point:   geo.Point{Lat: o.Latitude, Lon: o.Longitude},
+	}
+	if !o.Time.IsZero() {
+		stop.SlotStart = time.Date(day.Year(), day.Month(), day.Day(), o.Time.Hour(), o.Time.Minute(), 0, 0, s.cfg.Location)
+		stop.SlotEnd = stop.SlotStart.Add(s.cfg.SlotWindow)
+	}
+	return stop
+}
+
+// Points returns the start point, when known, and the stops in visiting order
+func (r *Route) Points() []geo.Point {
+	var points []geo.Point
+	if r.Start != nil {
+		points = append(points, *r.Start)
+	}
+	for _, stop := range r.Stops {
+		points = append(points, stop.Point)
+	}
+	return points
+}
+
+// FormatRoute renders a route as a numbered list with arrival estimates
+func FormatRoute(day time.Time, r *Route) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🧭 Маршрут на %s\n", day.Format("02.01.2006")))
+	total := len(r.Stops) + len(r.Unrouted)
+	if total == 0 {
+		sb.WriteString("Заказов на этот день нет.")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("Заказов: %d", total))
+	if len(r.Stops) > 0 {
+		sb.WriteString(fmt.Sprintf(", выезд в %s, ~%.1f км, окончание ~%s",
+			r.Departure.Format("15:04"), r.Meters/1000, r.Finish.Format("15:04")))
+	}
+	sb.WriteString("\n")
+
+	for i, stop := range r.Stops {
+		sb.WriteString(fmt.Sprintf("\n%d. ~%s — заказ #%d\n%s", i+1, stop.Arrival.Format("15:04"), stop.OrderID, stop.Address))
+		if !stop.SlotStart.IsZero() {
+			sb.WriteString(fmt.Sprintf("\nВремя клиента: %s–%s", stop.SlotStart.Format("15:04"), stop.SlotEnd.Format("15:04")))
+		}
+		if stop.Wait >= time.Minute {
+			sb.WriteString(fmt.Sprintf("\n⏳ Ожидание %d мин", int(stop.Wait.Minutes())))
+		}
+		if stop.Late >= time.Minute {
+			sb.WriteString(fmt.Sprintf("\n⚠️ Опоздание %d мин — предупредите клиента", int(stop.Late.Minutes())))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(r.Unrouted) > 0 {
+		sb.WriteString("\n📍 Без геолокации, порядок не рассчитан:")
+		for _, stop := range r.Unrouted {
+			sb.WriteString(fmt.Sprintf("\n- заказ #%d", stop.OrderID))
+			if !stop.SlotStart.IsZero() {
+				sb.WriteString(" в " + stop.SlotStart.Format("15:04"))
+			}
+			sb.WriteString(": " + stop.Address)
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
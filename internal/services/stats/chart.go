@@ -0,0 +1,361 @@
+package stats
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sync"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// Chart image size and plot area margins in pixels
+const (
+	chartWidth  = 960
+	chartHeight = 540
+	plotLeft    = 100
+	plotRight   = chartWidth - 40
+	plotTop     = 90
+	plotBottom  = chartHeight - 70
+)
+
+// maxPieSlices caps the number of pie slices; the rest are merged into "другие"
+const maxPieSlices = 6
+
+var (
+	chartBackground = color.RGBA{255, 255, 255, 255}
+	chartText       = color.RGBA{33, 37, 41, 255}
+	chartMuted      = color.RGBA{108, 117, 125, 255}
+	chartGrid       = color.RGBA{222, 226, 230, 255}
+	chartPalette    = []color.RGBA{
+		{13, 110, 253, 255}, {25, 135, 84, 255}, {253, 126, 20, 255}, {220, 53, 69, 255},
+		{111, 66, 193, 255}, {32, 201, 151, 255}, {255, 193, 7, 255}, {108, 117, 125, 255},
+	}
+)
+
+// ChartPoint is a labelled value plotted on a chart
+type ChartPoint struct {
+	Label string
+	Value float64
+}
+
+// Chart is a rendered PNG chart
+type Chart struct {
+	Name  string
+	Title string
+	PNG   []byte
+}
+
+// chartFonts holds the parsed fonts shared by all charts
+var chartFonts struct {
+	once    sync.Once
+	regular *opentype.Font
+	bold    *opentype.Font
+	err     error
+}
+
+// loadChartFonts parses the embedded Go fonts once
+func loadChartFonts() error {
+	chartFonts.once.Do(func() {
+		if chartFonts.regular, chartFonts.err = opentype.Parse(goregular.TTF); chartFonts.err != nil {
+			return
+		}
+		chartFonts.bold, chartFonts.err = opentype.Parse(gobold.TTF)
+	})
+	if chartFonts.err != nil {
+		return fmt.Errorf("failed to parse chart font: %v", chartFonts.err)
+	}
+	return nil
+}
+
+// canvas is a chart image with its fonts
+type canvas struct {
+	img   *image.RGBA
+	title font.Face
+	label font.Face
+}
+
+// newCanvas creates a white chart image with a title
+func newCanvas(title string) (*canvas, error) {
+	if err := loadChartFonts(); err != nil {
+		return nil, err
+	}
+	titleFace, err := opentype.NewFace(chartFonts.bold, &opentype.FaceOptions{Size: 26, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chart font: %v", err)
+	}
+	labelFace, err := opentype.NewFace(chartFonts.regular, &opentype.FaceOptions{Size: 15, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		titleFace.Close()
+		return nil, fmt.Errorf("failed to create chart font: %v", err)
+	}
+
+	c := &canvas{img: image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight)), title: titleFace, label: labelFace}
+	draw.Draw(c.img, c.img.Bounds(), image.NewUniform(chartBackground), image.Point{}, draw.Src)
+	c.text(title, c.title, chartText, chartWidth/2, 50, 0)
+	return c, nil
+}
+
+// text draws s with its baseline at y; align is -1 for left, 0 for center and 1 for right of x
+func (c *canvas) text(s string, face font.Face, col color.Color, x, y, align int) {
+	d := &font.Drawer{Dst: c.img, Src: image.NewUniform(col), Face: face}
+	width := d.MeasureString(s).Ceil()
+	switch align {
+	case 0:
+		x -= width / 2
+	case 1:
+		x -= width
+	}
+	d.Dot = fixed.P(x, y)
+	d.DrawString(s)
+}
+
+// polygon fills a closed polygon given as x, y pairs
+func (c *canvas) polygon(col color.Color, points ...float32) {
+	if len(points) < 6 {
+		return
+	}
+	r := vector.NewRasterizer(chartWidth, chartHeight)
+	r.MoveTo(points[0], points[1])
+	for i := 2; i+1 < len(points); i += 2 {
+		r.LineTo(points[i], points[i+1])
+	}
+	r.ClosePath()
+	r.Draw(c.img, c.img.Bounds(), image.NewUniform(col), image.Point{})
+}
+
+// line draws a straight line of the given width
+func (c *canvas) line(x0, y0, x1, y1, width float32, col color.Color) {
+	dx, dy := x1-x0, y1-y0
+	length := float32(math.Hypot(float64(dx), float64(dy)))
+	if length == 0 {
+		return
+	}
+	nx, ny := -dy/length*width/2, dx/length*width/2
+	c.polygon(col, x0+nx, y0+ny, x1+nx, y1+ny, x1-nx, y1-ny, x0-nx, y0-ny)
+}
+
+// disc draws a filled circle
+func (c *canvas) disc(cx, cy, radius float32, col color.Color) {
+	c.sector(cx, cy, radius, 0, 2*math.Pi, col)
+}
+
+// sector draws a pie slice between two angles in radians, clockwise from 3 o'clock
+func (c *canvas) sector(cx, cy, radius float32, from, to float64, col color.Color) {
+	steps := int(math.Ceil((to-from)/(math.Pi/90))) + 1
+	points := []float32{cx, cy}
+	for i := 0; i <= steps; i++ {
+		angle := from + (to-from)*float64(i)/float64(steps)
+		points = append(points, cx+radius*float32(math.Cos(angle)), cy+radius*float32(math.Sin(angle)))
+	}
+	c.polygon(col, points...)
+}
+
+// rect fills an axis-aligned rectangle
+func (c *canvas) rect(r image.Rectangle, col color.Color) {
+	draw.Draw(c.img, r, image.NewUniform(col), image.Point{}, draw.Over)
+}
+
+// grid draws horizontal grid lines with value labels and returns the axis maximum
+func (c *canvas) grid(maxValue float64) float64 {
+	top := niceMax(maxValue)
+	const lines = 5
+	for i := 0; i <= lines; i++ {
+		value := top * float64(i) / lines
+		y := plotBottom - (plotBottom-plotTop)*i/lines
+		c.rect(image.Rect(plotLeft, y, plotRight, y+1), chartGrid)
+		c.text(compactNumber(value), c.label, chartMuted, plotLeft-10, y+5, 1)
+	}
+	return top
+}
+
+// encode closes the fonts and returns the PNG bytes
+func (c *canvas) encode() ([]byte, error) {
+	c.title.Close()
+	c.label.Close()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.img); err != nil {
+		return nil, fmt.Errorf("failed to encode chart: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderLineChart draws values over time as a line with a shaded area
+func RenderLineChart(title string, points []ChartPoint) ([]byte, error) {
+	c, err := newCanvas(title)
+	if err != nil {
+		return nil, err
+	}
+	top := c.grid(maxValue(points))
+
+	n := len(points)
+	x := func(i int) float32 {
+		if n == 1 {
+			return float32(plotLeft+plotRight) / 2
+		}
+		return float32(plotLeft) + float32(plotRight-plotLeft)*float32(i)/float32(n-1)
+	}
+	y := func(v float64) float32 {
+		return float32(plotBottom) - float32(plotBottom-plotTop)*float32(v/top)
+	}
+
+	if n > 1 {
+		area := []float32{x(0), plotBottom}
+		for i, p := range points {
+			area = append(area, x(i), y(p.Value))
+		}
+		area = append(area, x(n-1), plotBottom)
+		line := chartPalette[0]
+		c.polygon(color.NRGBA{line.R, line.G, line.B, 48}, area...)
+		for i := 1; i < n; i++ {
+			c.line(x(i-1), y(points[i-1].Value), x(i), y(points[i].Value), 3, line)
+		}
+	}
+	step := labelStep(n, 10)
+	for i, p := range points {
+		if n <= 31 {
+			c.disc(x(i), y(p.Value), 4, chartPalette[0])
+		}
+		if i%step == 0 {
+			c.text(p.Label, c.label, chartMuted, int(x(i)), plotBottom+25, 0)
+		}
+	}
+	return c.encode()
+}
+
+// RenderBarChart draws one vertical bar per point with its value above
+func RenderBarChart(title string, points []ChartPoint) ([]byte, error) {
+	c, err := newCanvas(title)
+	if err != nil {
+		return nil, err
+	}
+	top := c.grid(maxValue(points))
+
+	if n := len(points); n > 0 {
+		slot := (plotRight - plotLeft) / n
+		width := slot * 3 / 5
+		for i, p := range points {
+			left := plotLeft + slot*i + (slot-width)/2
+			height := int(float64(plotBottom-plotTop) * p.Value / top)
+			c.rect(image.Rect(left, plotBottom-height, left+width, plotBottom), chartPalette[i%len(chartPalette)])
+			c.text(compactNumber(p.Value), c.label, chartText, left+width/2, plotBottom-height-8, 0)
+			c.text(truncateLabel(p.Label, slot/9), c.label, chartMuted, left+width/2, plotBottom+25, 0)
+		}
+	}
+	return c.encode()
+}
+
+// RenderPieChart draws shares of a whole with a legend
+func RenderPieChart(title string, points []ChartPoint) ([]byte, error) {
+	c, err := newCanvas(title)
+	if err != nil {
+		return nil, err
+	}
+	points = mergeTail(points, maxPieSlices)
+
+	var total float64
+	for _, p := range points {
+		total += p.Value
+	}
+	if total <= 0 {
+		c.text("нет данных", c.label, chartMuted, chartWidth/2, chartHeight/2, 0)
+		return c.encode()
+	}
+
+	const cx, cy, radius = 270, 305, 190
+	angle := -math.Pi / 2
+	for i, p := range points {
+		share := p.Value / total
+		col := chartPalette[i%len(chartPalette)]
+		c.sector(cx, cy, radius, angle, angle+share*2*math.Pi, col)
+		angle += share * 2 * math.Pi
+
+		y := 150 + i*40
+		c.rect(image.Rect(520, y-14, 538, y+4), col)
+		c.text(fmt.Sprintf("%s — %s (%.0f%%)", truncateLabel(p.Label, 24), compactNumber(p.Value), share*100), c.label, chartText, 550, y, -1)
+	}
+	return c.encode()
+}
+
+// maxValue returns the largest value among points
+func maxValue(points []ChartPoint) float64 {
+	var max float64
+	for _, p := range points {
+		if p.Value > max {
+			max = p.Value
+		}
+	}
+	return max
+}
+
+// niceMax rounds a value up to 1, 2 or 5 times a power of ten for the axis
+func niceMax(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if v <= step*magnitude {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// compactNumber renders axis values like "950", "12.5 тыс" or "1.2 млн"
+func compactNumber(v float64) string {
+	switch abs := math.Abs(v); {
+	case abs >= 1e6:
+		return trimZero(fmt.Sprintf("%.1f", v/1e6)) + " млн"
+	case abs >= 1e3:
+		return trimZero(fmt.Sprintf("%.1f", v/1e3)) + " тыс"
+	default:
+		return trimZero(fmt.Sprintf("%.1f", v))
+	}
+}
+
+// trimZero drops a trailing ".0"
+func trimZero(s string) string {
+	if len(s) > 2 && s[len(s)-2:] == ".0" {
+		return s[:len(s)-2]
+	}
+	return s
+}
+
+// labelStep returns how many points to skip between axis labels to show at most max labels
+func labelStep(n, max int) int {
+	if n <= max {
+		return 1
+	}
+	return (n + max - 1) / max
+}
+
+// truncateLabel shortens a label to at most max characters
+func truncateLabel(label string, max int) string {
+	runes := []rune(label)
+	if max < 2 || len(runes) <= max {
+		return label
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+// mergeTail keeps the first limit-1 points and sums the rest into "другие"
+func mergeTail(points []ChartPoint, limit int) []ChartPoint {
+	if len(points) <= limit {
+		return points
+	}
+	merged := append([]ChartPoint{}, points[:limit-1]...)
+	other := ChartPoint{Label: "другие"}
+	for _, p := range points[limit-1:] {
+		other.Value += p.Value
+	}
+	return append(merged, other)
+}
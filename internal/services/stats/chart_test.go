@@ -0,0 +1,91 @@
+package stats_test
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func assertPNG(t *testing.T, data []byte) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 960, img.Bounds().Dx())
+		assert.Equal(t, 540, img.Bounds().Dy())
+	}
+}
+
+func TestRenderCharts(t *testing.T) {
+	points := []stats.ChartPoint{{Label: "01.03", Value: 1500}, {Label: "02.03", Value: 0}, {Label: "03.03", Value: 4200.5}}
+
+	line, err := stats.RenderLineChart("Выручка", points)
+	assert.NoError(t, err)
+	assertPNG(t, line)
+
+	bar, err := stats.RenderBarChart("Заказы", points)
+	assert.NoError(t, err)
+	assertPNG(t, bar)
+
+	pie, err := stats.RenderPieChart("Причины отмен", []stats.ChartPoint{
+		{Label: "дорого", Value: 3}, {Label: "передумал", Value: 2}, {Label: "a", Value: 1},
+		{Label: "b", Value: 1}, {Label: "c", Value: 1}, {Label: "d", Value: 1}, {Label: "e", Value: 1},
+	})
+	assert.NoError(t, err)
+	assertPNG(t, pie)
+
+	empty, err := stats.RenderPieChart("Причины отмен", nil)
+	assert.NoError(t, err)
+	assertPNG(t, empty)
+}
+
+func TestGetRevenueSeries(t *testing.T) {
+	loc := mustLoad(t, "Asia/Vladivostok")
+	repo := new(MockRepository)
+	service := stats.NewService(repo, loc)
+	week, err := stats.WeekPeriod(2024, 11, loc) // 11.03 — 17.03.2024
+	assert.NoError(t, err)
+	start, end := week.Start.In(time.Local), week.End.In(time.Local)
+
+	// Stored timestamps are server wall-clock; 23:00 server time on 11.03 may already be 12.03 in Vladivostok
+	late := time.Date(2024, 3, 11, 23, 0, 0, 0, time.Local)
+	repo.On("GetHourlyRevenue", start, end).Return([]models.RevenuePoint{
+		{Time: time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC).In(time.Local), Orders: 1, Revenue: 1000},
+		{Time: late, Orders: 1, Revenue: 500},
+	}, nil)
+
+	series, err := service.GetRevenueSeries(week)
+	assert.NoError(t, err)
+	assert.Len(t, series, 7)
+	assert.Equal(t, "11.03", series[0].Label)
+	assert.Equal(t, "17.03", series[6].Label)
+
+	var total float64
+	for _, point := range series {
+		total += point.Value
+	}
+	assert.Equal(t, 1500.0, total)
+	lateDay := late.In(loc).Format("02.01")
+	for _, point := range series {
+		if point.Label == lateDay {
+			assert.GreaterOrEqual(t, point.Value, 500.0)
+		}
+	}
+	repo.AssertExpectations(t)
+}
+
+func TestGetRevenueSeriesByMonth(t *testing.T) {
+	repo := new(MockRepository)
+	service := stats.NewService(repo, time.UTC)
+	year := stats.YearPeriod(2024, time.UTC)
+
+	repo.On("GetHourlyRevenue", year.Start.In(time.Local), year.End.In(time.Local)).Return([]models.RevenuePoint{}, nil)
+
+	series, err := service.GetRevenueSeries(year)
+	assert.NoError(t, err)
+	assert.Len(t, series, 12)
+	assert.Equal(t, "01.2024", series[0].Label)
+	assert.Equal(t, "12.2024", series[11].Label)
+}
@@ -0,0 +1,151 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Period kinds
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+	PeriodYear  = "year"
+	PeriodRange = "range"
+	PeriodAll   = "all"
+)
+
+// monthNames are Russian month names in the nominative case
+var monthNames = [...]string{
+	"январь", "февраль", "март", "апрель", "май", "июнь",
+	"июль", "август", "сентябрь", "октябрь", "ноябрь", "декабрь",
+}
+
+// MonthName returns the Russian name of a month
+func MonthName(month time.Month) string {
+	return monthNames[month-1]
+}
+
+// Period is a half-open time range [Start, End) of calendar days in the business time zone
+type Period struct {
+	Kind  string
+	Start time.Time
+	End   time.Time
+}
+
+// midnight returns the start of the day of t in loc
+func midnight(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// DayPeriod returns the calendar day containing t
+func DayPeriod(t time.Time, loc *time.Location) Period {
+	start := midnight(t, loc)
+	return Period{Kind: PeriodDay, Start: start, End: start.AddDate(0, 0, 1)}
+}
+
+// WeekPeriod returns ISO week number week of ISO year year, Monday to Sunday
+func WeekPeriod(year, week int, loc *time.Location) (Period, error) {
+	if week < 1 || week > ISOWeeksInYear(year) {
+		return Period{}, fmt.Errorf("year %d has no ISO week %d", year, week)
+	}
+	// January 4th always falls in ISO week 1
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+	offset := (int(jan4.Weekday()) + 6) % 7
+	start := jan4.AddDate(0, 0, -offset+7*(week-1))
+	return Period{Kind: PeriodWeek, Start: start, End: start.AddDate(0, 0, 7)}, nil
+}
+
+// WeekPeriodOf returns the ISO week containing t
+func WeekPeriodOf(t time.Time, loc *time.Location) Period {
+	start := midnight(t, loc)
+	start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+	return Period{Kind: PeriodWeek, Start: start, End: start.AddDate(0, 0, 7)}
+}
+
+// ISOWeeksInYear returns 52 or 53, the number of ISO weeks in a year
+func ISOWeeksInYear(year int) int {
+	// December 28th always falls in the last ISO week
+	_, week := time.Date(year, time.December, 28, 0, 0, 0, 0, time.UTC).ISOWeek()
+	return week
+}
+
+// MonthPeriod returns a calendar month
+func MonthPeriod(year int, month time.Month, loc *time.Location) Period {
+	start := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	return Period{Kind: PeriodMonth, Start: start, End: start.AddDate(0, 1, 0)}
+}
+
+// YearPeriod returns a calendar year
+func YearPeriod(year int, loc *time.Location) Period {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	return Period{Kind: PeriodYear, Start: start, End: start.AddDate(1, 0, 0)}
+}
+
+// RangePeriod returns the days from first to last inclusive
+func RangePeriod(first, last time.Time, loc *time.Location) (Period, error) {
+	start, end := midnight(first, loc), midnight(last, loc)
+	if end.Before(start) {
+		return Period{}, errors.New("period ends before it starts")
+	}
+	return Period{Kind: PeriodRange, Start: start, End: end.AddDate(0, 0, 1)}, nil
+}
+
+// Days returns the number of calendar days in the period, independent of DST shifts
+func (p Period) Days() int {
+	start := time.Date(p.Start.Year(), p.Start.Month(), p.Start.Day(), 0, 0, 0, 0, time.UTC)
+	end := time.Date(p.End.Year(), p.End.Month(), p.End.Day(), 0, 0, 0, 0, time.UTC)
+	return int(end.Sub(start).Hours() / 24)
+}
+
+// Previous returns the period of equal length right before p: the previous day, ISO week, month or year,
+// or as many days before a custom range; all time has no previous period
+func (p Period) Previous() (Period, bool) {
+	prev := Period{Kind: p.Kind, End: p.Start}
+	switch p.Kind {
+	case PeriodMonth:
+		prev.Start = p.Start.AddDate(0, -1, 0)
+	case PeriodYear:
+		prev.Start = p.Start.AddDate(-1, 0, 0)
+	case PeriodDay, PeriodWeek, PeriodRange:
+		prev.Start = p.Start.AddDate(0, 0, -p.Days())
+	default:
+		return Period{}, false
+	}
+	return prev, true
+}
+
+// Label describes the period for people, e.g. "неделя 12 (18.03 — 24.03.2024)"
+func (p Period) Label() string {
+	last := p.End.AddDate(0, 0, -1)
+	switch p.Kind {
+	case PeriodDay:
+		return p.Start.Format("02.01.2006")
+	case PeriodWeek:
+		year, week := p.Start.ISOWeek()
+		if year != p.Start.Year() || last.Year() != p.Start.Year() {
+			return fmt.Sprintf("неделя %d/%d (%s — %s)", week, year, p.Start.Format("02.01.2006"), last.Format("02.01.2006"))
+		}
+		return fmt.Sprintf("неделя %d (%s — %s)", week, p.Start.Format("02.01"), last.Format("02.01.2006"))
+	case PeriodMonth:
+		return fmt.Sprintf("%s %d", MonthName(p.Start.Month()), p.Start.Year())
+	case PeriodYear:
+		return fmt.Sprintf("%d год", p.Start.Year())
+	case PeriodAll:
+		return "всё время"
+	default:
+		return p.Start.Format("02.01.2006") + " — " + last.Format("02.01.2006")
+	}
+}
+
+// WeeksOfMonth returns the ISO weeks that have at least one day in a month
+func WeeksOfMonth(year int, month time.Month, loc *time.Location) []Period {
+	monthPeriod := MonthPeriod(year, month, loc)
+	var weeks []Period
+	for week := WeekPeriodOf(monthPeriod.Start, loc); week.Start.Before(monthPeriod.End); week = WeekPeriodOf(week.End, loc) {
+		weeks = append(weeks, week)
+	}
+	return weeks
+}
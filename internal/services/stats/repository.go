@@ -1,7 +1,6 @@
 package stats
 
 import (
-	"database/sql"
 	"fmt"
 	"time"
 	"github.com/skyzeper/telegram-bot/internal/db"
@@ -19,97 +18,302 @@ func NewPostgresRepository(db *db.DB) *PostgresRepository {
 	return &PostgresRepository{db: db}
 }
 
-// GetOrderStats retrieves orders within a time range
-func (r *PostgresRepository) GetOrderStats(start, end time.Time) ([]models.Order, error) {
+// GetOrderKPIs aggregates orders created within a time range
+func (r *PostgresRepository) GetOrderKPIs(start, end time.Time) (models.OrderKPIs, error) {
 	query := `
-		SELECT id, user_id, category, subcategory, photos, video, date, time, phone, 
-		       address, description, status, reason, cost, payment_method, payment_confirmed, 
-		       created_at, updated_at, confirmed
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE o.status = 'completed'),
+		       COUNT(*) FILTER (WHERE o.status = 'canceled'),
+		       COALESCE(SUM(o.cost) FILTER (WHERE o.status = 'completed'), 0),
+		       COALESCE(AVG(EXTRACT(EPOCH FROM o.completed_at - o.created_at) / 3600)
+		                FILTER (WHERE o.status = 'completed' AND o.completed_at IS NOT NULL), 0),
+		       COUNT(DISTINCT o.user_id) FILTER (WHERE o.status = 'completed'),
+		       COUNT(DISTINCT o.user_id) FILTER (WHERE o.status = 'completed' AND EXISTS (
+		           SELECT 1 FROM orders p
+		           WHERE p.user_id = o.user_id AND p.status = 'completed' AND p.created_at < o.created_at
+		       ))
+		FROM orders o
+		WHERE o.created_at >= $1 AND o.created_at < $2
+	`
+	var kpis models.OrderKPIs
+	err := r.db.Conn().QueryRow(query, start, end).Scan(
+		&kpis.Created, &kpis.Completed, &kpis.Canceled, &kpis.Revenue,
+		&kpis.AvgCompletionHours, &kpis.Clients, &kpis.RepeatClients,
+	)
+	if err != nil {
+		utils.LogError(err)
+		return kpis, fmt.Errorf("failed to get order kpis: %v", err)
+	}
+	return kpis, nil
+}
+
+// GetHourlyRevenue sums completed orders created within a time range per hour, leaving out empty hours
+func (r *PostgresRepository) GetHourlyRevenue(start, end time.Time) ([]models.RevenuePoint, error) {
+	query := `
+		SELECT date_trunc('hour', created_at), COUNT(*), COALESCE(SUM(cost), 0)
 		FROM orders
+		WHERE status = 'completed' AND created_at >= $1 AND created_at < $2
+		GROUP BY 1
+		ORDER BY 1
 	`
-	args := []interface{}{}
-	if !start.IsZero() && !end.IsZero() {
-		query += ` WHERE created_at >= $1 AND created_at < $2`
-		args = append(args, start, end)
-	} else if !start.IsZero() {
-		query += ` WHERE created_at >= $1`
-		args = append(args, start)
-	} else if !end.IsZero() {
-		query += ` WHERE created_at < $1`
-		args = append(args, end)
+	rows, err := r.db.Conn().Query(query, start, end)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get hourly revenue: %v", err)
 	}
+	defer rows.Close()
 
-	rows, err := r.db.Conn().Query(query, args...)
+	var points []models.RevenuePoint
+	for rows.Next() {
+		var point models.RevenuePoint
+		if err := rows.Scan(&point.Time, &point.Orders, &point.Revenue); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// GetCancellationReasons counts orders created within a time range and canceled, grouped by reason
+func (r *PostgresRepository) GetCancellationReasons(start, end time.Time) ([]models.CancellationReason, error) {
+	query := `
+		SELECT COALESCE(NULLIF(TRIM(reason), ''), ''), COUNT(*)
+		FROM orders
+		WHERE status = 'canceled' AND created_at >= $1 AND created_at < $2
+		GROUP BY 1
+		ORDER BY 2 DESC, 1
+	`
+	rows, err := r.db.Conn().Query(query, start, end)
 	if err != nil {
 		utils.LogError(err)
-		return nil, fmt.Errorf("failed to get order stats: %v", err)
+		return nil, fmt.Errorf("failed to get cancellation reasons: %v", err)
 	}
 	defer rows.Close()
 
-	var orders []models.Order
+	var reasons []models.CancellationReason
 	for rows.Next() {
-		var order models.Order
-		var date, time sql.NullTime
-		if err := rows.Scan(
-			&order.ID, &order.UserID, &order.Category, &order.Subcategory, &order.Photos,
-			&order.Video, &date, &time, &order.Phone, &order.Address,
-			&order.Description, &order.Status, &order.Reason, &order.Cost, &order.PaymentMethod,
-			&order.PaymentConfirmed, &order.CreatedAt, &order.UpdatedAt, &order.Confirmed,
-		); err != nil {
+		var reason models.CancellationReason
+		if err := rows.Scan(&reason.Reason, &reason.Orders); err != nil {
 			utils.LogError(err)
 			continue
 		}
-		if date.Valid {
-			order.Date = date.Time
+		reasons = append(reasons, reason)
+	}
+	return reasons, nil
+}
+
+// GetExecutorRevenue sums the cost of completed orders each executor worked on, for orders created within a time range
+func (r *PostgresRepository) GetExecutorRevenue(start, end time.Time) ([]models.ExecutorRevenue, error) {
+	query := `
+		SELECT e.user_id, COALESCE(u.first_name, ''), u.role, COUNT(o.id), COALESCE(SUM(o.cost), 0)
+		FROM (SELECT DISTINCT order_id, user_id FROM executors) e
+		JOIN orders o ON o.id = e.order_id
+		JOIN users u ON u.chat_id = e.user_id
+		WHERE o.status = 'completed' AND o.created_at >= $1 AND o.created_at < $2
+		GROUP BY e.user_id, u.first_name, u.role
+		ORDER BY 5 DESC, 4 DESC
+	`
+	rows, err := r.db.Conn().Query(query, start, end)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get executor revenue: %v", err)
+	}
+	defer rows.Close()
+
+	var executors []models.ExecutorRevenue
+	for rows.Next() {
+		var executor models.ExecutorRevenue
+		if err := rows.Scan(&executor.UserID, &executor.Name, &executor.Role, &executor.Orders, &executor.Revenue); err != nil {
+			utils.LogError(err)
+			continue
 		}
-		if time.Valid {
-			order.Time = time.Time
+		executors = append(executors, executor)
+	}
+	return executors, nil
+}
+
+// GetSubcategoryStats counts completed orders created within a time range per category and subcategory
+func (r *PostgresRepository) GetSubcategoryStats(start, end time.Time) ([]models.SubcategoryStats, error) {
+	query := `
+		SELECT category, subcategory, COUNT(*), COALESCE(SUM(cost), 0)
+		FROM orders
+		WHERE status = 'completed' AND created_at >= $1 AND created_at < $2
+		GROUP BY category, subcategory
+		ORDER BY 4 DESC, 3 DESC
+	`
+	rows, err := r.db.Conn().Query(query, start, end)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get subcategory stats: %v", err)
+	}
+	defer rows.Close()
+
+	var subcategories []models.SubcategoryStats
+	for rows.Next() {
+		var sub models.SubcategoryStats
+		if err := rows.Scan(&sub.Category, &sub.Subcategory, &sub.Orders, &sub.Revenue); err != nil {
+			utils.LogError(err)
+			continue
 		}
-		orders = append(orders, order)
+		subcategories = append(subcategories, sub)
 	}
-	return orders, nil
+	return subcategories, nil
 }
 
-// GetAccountingStats retrieves accounting records within a time range
-func (r *PostgresRepository) GetAccountingStats(start, end time.Time) ([]models.AccountingRecord, error) {
+// GetDriverCashChange sums driver cash ledger postings within a time range
+func (r *PostgresRepository) GetDriverCashChange(start, end time.Time) (models.Money, error) {
 	query := `
-		SELECT id, order_id, user_id, type, amount, description, created_at
-		FROM accounting_records
+		SELECT COALESCE(SUM(p.amount), 0)
+		FROM ledger_postings p
+		JOIN ledger_entries e ON e.id = p.entry_id
+		WHERE p.account = 'driver_cash'
 	`
 	args := []interface{}{}
-	if !start.IsZero() && !end.IsZero() {
-		query += ` WHERE created_at >= $1 AND created_at < $2`
-		args = append(args, start, end)
-	} else if !start.IsZero() {
-		query += ` WHERE created_at >= $1`
+	if !start.IsZero() {
 		args = append(args, start)
-	} else if !end.IsZero() {
-		query += ` WHERE created_at < $1`
+		query += fmt.Sprintf(` AND e.created_at >= $%d`, len(args))
+	}
+	if !end.IsZero() {
 		args = append(args, end)
+		query += fmt.Sprintf(` AND e.created_at < $%d`, len(args))
+	}
+
+	var change models.Money
+	if err := r.db.Conn().QueryRow(query, args...).Scan(&change); err != nil {
+		utils.LogError(err)
+		return 0, fmt.Errorf("failed to get driver cash change: %v", err)
+	}
+	return change, nil
+}
+
+// GetTopReferrers ranks inviters by revenue from their invitees within a time range
+func (r *PostgresRepository) GetTopReferrers(start, end time.Time, limit int) ([]models.ReferrerStats, error) {
+	query := `
+		SELECT r.inviter_id, COALESCE(u.first_name, ''),
+		       COUNT(DISTINCT r.invitee_id) FILTER (WHERE r.created_at >= $1 AND r.created_at < $2),
+		       COUNT(o.id),
+		       COALESCE(SUM(o.cost) FILTER (WHERE o.status = 'completed'), 0)
+		FROM referrals r
+		JOIN users u ON u.chat_id = r.inviter_id
+		LEFT JOIN orders o ON o.user_id = r.invitee_id AND o.created_at >= $1 AND o.created_at < $2
+		WHERE r.inviter_id IS NOT NULL
+		GROUP BY r.inviter_id, u.first_name
+		HAVING COUNT(o.id) > 0 OR COUNT(DISTINCT r.invitee_id) FILTER (WHERE r.created_at >= $1 AND r.created_at < $2) > 0
+		ORDER BY 5 DESC, 3 DESC
+		LIMIT $3
+	`
+	rows, err := r.db.Conn().Query(query, start, end, limit)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get top referrers: %v", err)
+	}
+	defer rows.Close()
+
+	var referrers []models.ReferrerStats
+	for rows.Next() {
+		var referrer models.ReferrerStats
+		if err := rows.Scan(
+			&referrer.InviterID, &referrer.Name, &referrer.NewUsers, &referrer.Orders, &referrer.Revenue,
+		); err != nil {
+			utils.LogError(err)
+			continue
+		}
+		referrers = append(referrers, referrer)
 	}
+	return referrers, nil
+}
 
-	rows, err := r.db.Conn().Query(query, args...)
+// GetCampaignStats retrieves new users, orders and revenue per campaign within a time range
+func (r *PostgresRepository) GetCampaignStats(start, end time.Time) ([]models.CampaignStats, error) {
+	query := `
+		SELECT c.id, c.code, c.name,
+		       COUNT(DISTINCT r.invitee_id) FILTER (WHERE r.created_at >= $1 AND r.created_at < $2),
+		       COUNT(o.id),
+		       COALESCE(SUM(o.cost) FILTER (WHERE o.status = 'completed'), 0)
+		FROM referral_campaigns c
+		LEFT JOIN referrals r ON r.campaign_id = c.id
+		LEFT JOIN orders o ON o.user_id = r.invitee_id AND o.created_at >= $1 AND o.created_at < $2
+		GROUP BY c.id, c.code, c.name
+		ORDER BY 6 DESC, 4 DESC
+	`
+	rows, err := r.db.Conn().Query(query, start, end)
 	if err != nil {
 		utils.LogError(err)
-		return nil, fmt.Errorf("failed to get accounting stats: %v", err)
+		return nil, fmt.Errorf("failed to get campaign stats: %v", err)
 	}
 	defer rows.Close()
 
-	var records []models.AccountingRecord
+	var campaigns []models.CampaignStats
 	for rows.Next() {
-		var record models.AccountingRecord
-		var orderID sql.NullInt64
+		var campaign models.CampaignStats
 		if err := rows.Scan(
-			&record.ID, &orderID, &record.UserID, &record.Type,
-			&record.Amount, &record.Description, &record.CreatedAt,
+			&campaign.CampaignID, &campaign.Code, &campaign.Name,
+			&campaign.NewUsers, &campaign.Orders, &campaign.Revenue,
 		); err != nil {
 			utils.LogError(err)
 			continue
 		}
-		if orderID.Valid {
-			record.OrderID = int(orderID.Int64)
+		campaigns = append(campaigns, campaign)
+	}
+	return campaigns, nil
+}
+
+// GetEscalationStats counts escalations opened within a time range and their average resolution time
+func (r *PostgresRepository) GetEscalationStats(start, end time.Time) (models.EscalationStats, error) {
+	query := `
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE status = 'resolved'),
+		       COALESCE(AVG(EXTRACT(EPOCH FROM resolved_at - created_at) / 3600) FILTER (WHERE status = 'resolved'), 0)
+		FROM escalations
+		WHERE created_at >= $1 AND created_at < $2
+	`
+	var stats models.EscalationStats
+	err := r.db.Conn().QueryRow(query, start, end).Scan(&stats.Opened, &stats.Resolved, &stats.AvgResolutionHours)
+	if err != nil {
+		utils.LogError(err)
+		return stats, fmt.Errorf("failed to get escalation stats: %v", err)
+	}
+	return stats, nil
+}
+
+// GetExecutorRanking ranks executors by average rating of reviews left within a time range,
+// including their average for the previous range starting at prevStart
+func (r *PostgresRepository) GetExecutorRanking(start, end, prevStart time.Time, limit int) ([]models.ExecutorRating, error) {
+	query := `
+		SELECT e.user_id, COALESCE(u.first_name, ''), u.role,
+		       AVG(rv.rating) FILTER (WHERE rv.created_at >= $1),
+		       COUNT(rv.id) FILTER (WHERE rv.created_at >= $1),
+		       COUNT(DISTINCT e.order_id) FILTER (WHERE rv.created_at >= $1),
+		       COALESCE(AVG(rv.rating) FILTER (WHERE rv.created_at < $1), 0)
+		FROM (SELECT DISTINCT order_id, user_id FROM executors) e
+		JOIN reviews rv ON rv.order_id = e.order_id
+		JOIN users u ON u.chat_id = e.user_id
+		WHERE rv.created_at >= $3 AND rv.created_at < $2
+		GROUP BY e.user_id, u.first_name, u.role
+		HAVING COUNT(rv.id) FILTER (WHERE rv.created_at >= $1) > 0
+		ORDER BY 4 DESC, 5 DESC
+		LIMIT $4
+	`
+	rows, err := r.db.Conn().Query(query, start, end, prevStart, limit)
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get executor ranking: %v", err)
+	}
+	defer rows.Close()
+
+	var ranking []models.ExecutorRating
+	for rows.Next() {
+		var rating models.ExecutorRating
+		if err := rows.Scan(
+			&rating.UserID, &rating.Name, &rating.Role, &rating.AvgRating,
+			&rating.Reviews, &rating.Orders, &rating.PreviousAvg,
+		); err != nil {
+			utils.LogError(err)
+			continue
 		}
-		records = append(records, record)
+		rating.RecentAvg = rating.AvgRating
+		ranking = append(ranking, rating)
 	}
-	return records, nil
+	return ranking, nil
 }
\ No newline at end of file
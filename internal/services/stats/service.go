@@ -2,6 +2,8 @@ package stats
 
 import (
 	"fmt"
+	"math"
+	"strings"
 	"time"
 	"github.com/skyzeper/telegram-bot/internal/models"
 )
@@ -9,92 +11,444 @@ import (
 // Service handles statistics-related business logic
 type Service struct {
 	repo Repository
+	loc  *time.Location
 }
 
 // Repository defines the interface for stats data access
 type Repository interface {
-	GetOrderStats(start, end time.Time) ([]models.Order, error)
-	GetAccountingStats(start, end time.Time) ([]models.AccountingRecord, error)
+	GetOrderKPIs(start, end time.Time) (models.OrderKPIs, error)
+	GetCancellationReasons(start, end time.Time) ([]models.CancellationReason, error)
+	GetExecutorRevenue(start, end time.Time) ([]models.ExecutorRevenue, error)
+	GetSubcategoryStats(start, end time.Time) ([]models.SubcategoryStats, error)
+	GetHourlyRevenue(start, end time.Time) ([]models.RevenuePoint, error)
+	GetDriverCashChange(start, end time.Time) (models.Money, error)
+	GetTopReferrers(start, end time.Time, limit int) ([]models.ReferrerStats, error)
+	GetCampaignStats(start, end time.Time) ([]models.CampaignStats, error)
+	GetEscalationStats(start, end time.Time) (models.EscalationStats, error)
+	GetExecutorRanking(start, end, prevStart time.Time, limit int) ([]models.ExecutorRating, error)
 }
 
-// NewService creates a new stats service
-func NewService(repo Repository) *Service {
-	return &Service{repo: repo}
+// breakdownLimit caps the number of rows in each report breakdown
+const breakdownLimit = 5
+
+// NewService creates a new stats service; periods are cut at midnight in loc, the business time zone
+func NewService(repo Repository, loc *time.Location) *Service {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &Service{repo: repo, loc: loc}
+}
+
+// Location returns the business time zone
+func (s *Service) Location() *time.Location {
+	return s.loc
 }
 
 // GetStatsForDay retrieves statistics for the current day
 func (s *Service) GetStatsForDay() (models.Stats, error) {
-	start := time.Now().Truncate(24 * time.Hour)
-	end := start.Add(24 * time.Hour)
-	return s.getStats(start, end)
+	return s.getStatsForPeriod(PeriodDay)
 }
 
-// GetStatsForWeek retrieves statistics for the current week
+// GetStatsForWeek retrieves statistics for the current ISO week
 func (s *Service) GetStatsForWeek() (models.Stats, error) {
-	start := time.Now().Truncate(24 * time.Hour).AddDate(0, 0, -int(time.Now().Weekday()))
-	end := start.AddDate(0, 0, 7)
-	return s.getStats(start, end)
+	return s.getStatsForPeriod(PeriodWeek)
 }
 
 // GetStatsForMonth retrieves statistics for the current month
 func (s *Service) GetStatsForMonth() (models.Stats, error) {
-	start := time.Now().Truncate(24 * time.Hour).AddDate(0, 0, -time.Now().Day()+1)
-	end := start.AddDate(0, 1, 0)
-	return s.getStats(start, end)
+	return s.getStatsForPeriod(PeriodMonth)
 }
 
 // GetStatsForYear retrieves statistics for the current year
 func (s *Service) GetStatsForYear() (models.Stats, error) {
-	start := time.Now().Truncate(24 * time.Hour).AddDate(0, 0, -time.Now().Day()+1).AddDate(0, -int(time.Now().Month())+1, 0)
-	end := start.AddDate(1, 0, 0)
-	return s.getStats(start, end)
+	return s.getStatsForPeriod(PeriodYear)
 }
 
 // GetStatsForAllTime retrieves statistics for all time
 func (s *Service) GetStatsForAllTime() (models.Stats, error) {
-	start := time.Time{} // Zero time for all history
-	end := time.Now().Add(24 * time.Hour)
-	return s.getStats(start, end)
+	return s.getStatsForPeriod(PeriodAll)
+}
+
+// getStatsForPeriod calculates statistics for a named period
+func (s *Service) getStatsForPeriod(kind string) (models.Stats, error) {
+	period, err := s.CurrentPeriod(kind)
+	if err != nil {
+		return models.Stats{}, err
+	}
+	return s.GetStats(period)
+}
+
+// CurrentPeriod returns the day, ISO week, month or year containing now, or all time
+func (s *Service) CurrentPeriod(kind string) (Period, error) {
+	now := time.Now().In(s.loc)
+	switch kind {
+	case PeriodDay:
+		return DayPeriod(now, s.loc), nil
+	case PeriodWeek:
+		return WeekPeriodOf(now, s.loc), nil
+	case PeriodMonth:
+		return MonthPeriod(now.Year(), now.Month(), s.loc), nil
+	case PeriodYear:
+		return YearPeriod(now.Year(), s.loc), nil
+	case PeriodAll:
+		return Period{Kind: PeriodAll, End: DayPeriod(now, s.loc).End}, nil
+	default:
+		return Period{}, fmt.Errorf("unknown period: %s", kind)
+	}
+}
+
+// GetStatsReport calculates statistics for a period and the previous period of equal length
+func (s *Service) GetStatsReport(period Period) (models.StatsReport, error) {
+	report := models.StatsReport{Title: period.Label()}
+	var err error
+	if report.Current, err = s.GetStats(period); err != nil {
+		return report, err
+	}
+	if prev, ok := period.Previous(); ok {
+		previous, err := s.GetStats(prev)
+		if err != nil {
+			return report, err
+		}
+		report.Previous = &previous
+		report.PreviousTitle = prev.Label()
+	}
+	return report, nil
 }
 
-// getStats calculates statistics for a given time range
-func (s *Service) getStats(start, end time.Time) (models.Stats, error) {
+// GetStats calculates statistics for a period
+func (s *Service) GetStats(period Period) (models.Stats, error) {
 	var stats models.Stats
+	start, end := storageBounds(period)
 
-	// Get orders
-	orders, err := s.repo.GetOrderStats(start, end)
+	kpis, err := s.repo.GetOrderKPIs(start, end)
 	if err != nil {
 		return stats, fmt.Errorf("failed to get order stats: %v", err)
 	}
+	stats.CreatedOrders = kpis.Created
+	stats.TotalOrders = kpis.Completed
+	stats.CanceledOrders = kpis.Canceled
+	stats.TotalAmount = kpis.Revenue
+	stats.AvgCompletionHours = kpis.AvgCompletionHours
+	stats.ConversionRate = percent(kpis.Completed, kpis.Created)
+	stats.CancellationRate = percent(kpis.Canceled, kpis.Created)
+	stats.RepeatClientShare = percent(kpis.RepeatClients, kpis.Clients)
+	if kpis.Completed > 0 {
+		stats.AverageCheck = kpis.Revenue / float64(kpis.Completed)
+	}
 
-	// Process orders
-	for _, order := range orders {
-		if order.Status == "completed" {
-			stats.TotalOrders++
-			switch order.Category {
-			case "waste_removal":
-				stats.WasteRemovalOrders++
-			case "demolition":
-				stats.DemolitionOrders++
-			case "construction_materials":
-				stats.ConstructionOrders++
-			}
-			stats.TotalAmount += order.Cost
+	stats.Subcategories, err = s.repo.GetSubcategoryStats(start, end)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get subcategory stats: %v", err)
+	}
+	for _, sub := range stats.Subcategories {
+		switch sub.Category {
+		case "waste_removal", "вывоз мусора":
+			stats.WasteRemovalOrders += sub.Orders
+		case "demolition", "демонтаж":
+			stats.DemolitionOrders += sub.Orders
+		case "construction_materials", "стройматериалы":
+			stats.ConstructionOrders += sub.Orders
 		}
 	}
 
-	// Get accounting records for driver debts
-	records, err := s.repo.GetAccountingStats(start, end)
+	stats.CancellationReasons, err = s.repo.GetCancellationReasons(start, end)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get cancellation reasons: %v", err)
+	}
+
+	stats.Executors, err = s.repo.GetExecutorRevenue(start, end)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get executor revenue: %v", err)
+	}
+	if len(stats.Executors) > 0 {
+		stats.RevenuePerExecutor = stats.TotalAmount / float64(len(stats.Executors))
+	}
+
+	// Driver debts: cash collected in the period minus cash handed over
+	debts, err := s.repo.GetDriverCashChange(start, end)
 	if err != nil {
 		return stats, fmt.Errorf("failed to get accounting stats: %v", err)
 	}
+	stats.DriverDebts = debts.Float64()
 
-	// Process driver debts
-	for _, record := range records {
-		if (record.Type == "expense" || record.Type == "salary") && record.UserID != 0 {
-			stats.DriverDebts += record.Amount // Negative amounts increase debt
-		}
+	stats.Escalations, err = s.repo.GetEscalationStats(start, end)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get escalation stats: %v", err)
 	}
 
 	return stats, nil
-}
\ No newline at end of file
+}
+
+// GetRevenueSeries returns the revenue of completed orders in a period by day,
+// or by month for periods longer than two months and for all time
+func (s *Service) GetRevenueSeries(period Period) ([]ChartPoint, error) {
+	start, end := storageBounds(period)
+	hourly, err := s.repo.GetHourlyRevenue(start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revenue series: %v", err)
+	}
+
+	monthly := period.Kind == PeriodAll || period.Days() > 62
+	bucket := func(t time.Time) time.Time {
+		if monthly {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, s.loc)
+		}
+		return midnight(t, s.loc)
+	}
+	next := func(t time.Time) time.Time {
+		if monthly {
+			return t.AddDate(0, 1, 0)
+		}
+		return t.AddDate(0, 0, 1)
+	}
+	label := "02.01"
+	if monthly {
+		label = "01.2006"
+	}
+
+	revenue := make(map[time.Time]float64)
+	for _, point := range hourly {
+		revenue[bucket(s.fromStorage(point.Time))] += point.Revenue
+	}
+
+	first := period.Start
+	if first.IsZero() {
+		if len(hourly) == 0 {
+			return nil, nil
+		}
+		first = s.fromStorage(hourly[0].Time)
+	}
+	var series []ChartPoint
+	for t := bucket(first); t.Before(period.End); t = next(t) {
+		series = append(series, ChartPoint{Label: t.Format(label), Value: revenue[t]})
+	}
+	return series, nil
+}
+
+// RenderCharts draws the revenue line, orders per category bars and cancellation reasons pie of a period,
+// leaving out charts without data
+func (s *Service) RenderCharts(period Period, current models.Stats) ([]Chart, error) {
+	var charts []Chart
+
+	series, err := s.GetRevenueSeries(period)
+	if err != nil {
+		return nil, err
+	}
+	if maxValue(series) > 0 {
+		image, err := RenderLineChart("Выручка, руб. — "+period.Label(), series)
+		if err != nil {
+			return nil, err
+		}
+		charts = append(charts, Chart{Name: "revenue.png", Title: "Выручка", PNG: image})
+	}
+
+	var categories []ChartPoint
+	index := make(map[string]int)
+	for _, sub := range current.Subcategories {
+		i, ok := index[sub.Category]
+		if !ok {
+			i = len(categories)
+			index[sub.Category] = i
+			categories = append(categories, ChartPoint{Label: sub.Category})
+		}
+		categories[i].Value += float64(sub.Orders)
+	}
+	if len(categories) > 0 {
+		image, err := RenderBarChart("Выполненные заказы по категориям", categories)
+		if err != nil {
+			return nil, err
+		}
+		charts = append(charts, Chart{Name: "categories.png", Title: "Заказы по категориям", PNG: image})
+	}
+
+	var reasons []ChartPoint
+	for _, reason := range current.CancellationReasons {
+		label := reason.Reason
+		if label == "" {
+			label = "без причины"
+		}
+		reasons = append(reasons, ChartPoint{Label: label, Value: float64(reason.Orders)})
+	}
+	if len(reasons) > 0 {
+		image, err := RenderPieChart("Причины отмен", reasons)
+		if err != nil {
+			return nil, err
+		}
+		charts = append(charts, Chart{Name: "cancellations.png", Title: "Причины отмен", PNG: image})
+	}
+	return charts, nil
+}
+
+// GetReferralReport ranks referrers and campaigns for a period (day, week, month, year or all)
+func (s *Service) GetReferralReport(period string, limit int) (models.ReferralReport, error) {
+	var report models.ReferralReport
+	start, end, err := s.periodBounds(period)
+	if err != nil {
+		return report, err
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	report.TopReferrers, err = s.repo.GetTopReferrers(start, end, limit)
+	if err != nil {
+		return report, fmt.Errorf("failed to get top referrers: %v", err)
+	}
+	report.Campaigns, err = s.repo.GetCampaignStats(start, end)
+	if err != nil {
+		return report, fmt.Errorf("failed to get campaign stats: %v", err)
+	}
+	return report, nil
+}
+
+// GetExecutorRanking ranks executors by client rating for a period (day, week, month, year or all),
+// comparing with the previous period of the same length
+func (s *Service) GetExecutorRanking(period string, limit int) ([]models.ExecutorRating, error) {
+	current, err := s.CurrentPeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	start, end := storageBounds(current)
+	prevStart := start
+	if prev, ok := current.Previous(); ok {
+		prevStart, _ = storageBounds(prev)
+	}
+
+	ranking, err := s.repo.GetExecutorRanking(start, end, prevStart, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get executor ranking: %v", err)
+	}
+	return ranking, nil
+}
+
+// percent returns part as a percentage of total, or zero for an empty total
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+// periodBounds returns the storage time range of a named period
+func (s *Service) periodBounds(kind string) (time.Time, time.Time, error) {
+	period, err := s.CurrentPeriod(kind)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	start, end := storageBounds(period)
+	return start, end, nil
+}
+
+// fromStorage reads a stored server wall-clock timestamp as a time in the business time zone
+func (s *Service) fromStorage(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local).In(s.loc)
+}
+
+// storageBounds converts a period to server time: timestamps are stored without a zone as server wall-clock time
+func storageBounds(period Period) (time.Time, time.Time) {
+	return period.Start.In(time.Local), period.End.In(time.Local)
+}
+
+// FormatStatsReport renders period statistics with the change against the previous period
+func FormatStatsReport(report models.StatsReport) string {
+	cur, prev := report.Current, report.Previous
+	var p models.Stats
+	if prev != nil {
+		p = *prev
+	}
+	has := prev != nil
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📊 Статистика за %s", report.Title))
+	if has {
+		sb.WriteString(fmt.Sprintf("\n↔️ Сравнение с: %s", report.PreviousTitle))
+	}
+	sb.WriteString("\n\n")
+	sb.WriteString("- Всего заказов: " + WithChange(float64(cur.TotalOrders), float64(p.TotalOrders), has, "%.0f") + "\n")
+	sb.WriteString("- Вывоз мусора: " + WithChange(float64(cur.WasteRemovalOrders), float64(p.WasteRemovalOrders), has, "%.0f") + "\n")
+	sb.WriteString("- Демонтаж: " + WithChange(float64(cur.DemolitionOrders), float64(p.DemolitionOrders), has, "%.0f") + "\n")
+	sb.WriteString("- Стройматериалы: " + WithChange(float64(cur.ConstructionOrders), float64(p.ConstructionOrders), has, "%.0f") + "\n")
+	sb.WriteString("- Сумма: " + WithChange(cur.TotalAmount, p.TotalAmount, has, "%.2f руб.") + "\n")
+	sb.WriteString("- Долги водителей: " + WithChange(cur.DriverDebts, p.DriverDebts, has, "%.2f руб.") + "\n")
+	sb.WriteString("- Жалобы: " + WithChange(float64(cur.Escalations.Opened), float64(p.Escalations.Opened), has, "%.0f"))
+	sb.WriteString(fmt.Sprintf(
+		"\n  решено: %d, среднее время решения: %.1f ч.",
+		cur.Escalations.Resolved, cur.Escalations.AvgResolutionHours,
+	))
+	sb.WriteString("\n\n🎯 Показатели\n")
+	sb.WriteString("- Средний чек: " + WithChange(cur.AverageCheck, p.AverageCheck, has, "%.2f руб.") + "\n")
+	sb.WriteString(fmt.Sprintf("- Конверсия: %s (%d из %d)\n",
+		WithChange(cur.ConversionRate, p.ConversionRate, has, "%.1f%%"), cur.TotalOrders, cur.CreatedOrders))
+	sb.WriteString(fmt.Sprintf("- Отмены: %s (%d)\n",
+		WithChange(cur.CancellationRate, p.CancellationRate, has, "%.1f%%"), cur.CanceledOrders))
+	sb.WriteString("- Время выполнения: " + WithChange(cur.AvgCompletionHours, p.AvgCompletionHours, has, "%.1f ч.") + "\n")
+	sb.WriteString("- Повторные клиенты: " + WithChange(cur.RepeatClientShare, p.RepeatClientShare, has, "%.1f%%") + "\n")
+	sb.WriteString("- Выручка на исполнителя: " + WithChange(cur.RevenuePerExecutor, p.RevenuePerExecutor, has, "%.2f руб."))
+
+	if len(cur.CancellationReasons) > 0 {
+		sb.WriteString("\n\n❌ Причины отмен")
+		for i, reason := range cur.CancellationReasons {
+			if i == breakdownLimit {
+				break
+			}
+			text := reason.Reason
+			if text == "" {
+				text = "без причины"
+			}
+			sb.WriteString(fmt.Sprintf("\n- %s: %d", text, reason.Orders))
+		}
+	}
+	if len(cur.Subcategories) > 0 {
+		sb.WriteString("\n\n🧾 По услугам")
+		for i, sub := range cur.Subcategories {
+			if i == breakdownLimit {
+				break
+			}
+			sb.WriteString(fmt.Sprintf("\n- %s / %s: %d, %.2f руб.", sub.Category, sub.Subcategory, sub.Orders, sub.Revenue))
+		}
+	}
+	if len(cur.Executors) > 0 {
+		sb.WriteString("\n\n👷 Выручка по исполнителям")
+		for i, executor := range cur.Executors {
+			if i == breakdownLimit {
+				break
+			}
+			sb.WriteString(fmt.Sprintf("\n- %s: %d зак., %.2f руб.", executorName(executor), executor.Orders, executor.Revenue))
+		}
+	}
+
+	sb.WriteString("\n\n📈 Держите руку на пульсе бизнеса!")
+	return sb.String()
+}
+
+// executorName returns the executor's name, falling back to the chat ID
+func executorName(executor models.ExecutorRevenue) string {
+	if executor.Name != "" {
+		return executor.Name
+	}
+	return fmt.Sprintf("ID %d", executor.UserID)
+}
+
+// WithChange renders a value followed by the previous period's value and the relative change
+func WithChange(current, previous float64, hasPrevious bool, format string) string {
+	text := fmt.Sprintf(format, current)
+	if !hasPrevious {
+		return text
+	}
+	was := fmt.Sprintf(format, previous)
+	switch {
+	case math.Abs(current-previous) < 0.005:
+		return fmt.Sprintf("%s (было %s, без изменений)", text, was)
+	case math.Abs(previous) < 0.005:
+		return fmt.Sprintf("%s (было %s, ▲)", text, was)
+	}
+	change := (current - previous) / math.Abs(previous) * 100
+	arrow := "▲"
+	if change < 0 {
+		arrow = "▼"
+	}
+	return fmt.Sprintf("%s (было %s, %s %+.0f%%)", text, was, arrow, change)
+}
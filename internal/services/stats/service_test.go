@@ -0,0 +1,236 @@
+package stats_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository is a mock implementation of stats.Repository
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) GetOrderKPIs(start, end time.Time) (models.OrderKPIs, error) {
+	args := m.Called(start, end)
+	return args.Get(0).(models.OrderKPIs), args.Error(1)
+}
+
+func (m *MockRepository) GetCancellationReasons(start, end time.Time) ([]models.CancellationReason, error) {
+	args := m.Called(start, end)
+	return args.Get(0).([]models.CancellationReason), args.Error(1)
+}
+
+func (m *MockRepository) GetExecutorRevenue(start, end time.Time) ([]models.ExecutorRevenue, error) {
+	args := m.Called(start, end)
+	return args.Get(0).([]models.ExecutorRevenue), args.Error(1)
+}
+
+func (m *MockRepository) GetSubcategoryStats(start, end time.Time) ([]models.SubcategoryStats, error) {
+	args := m.Called(start, end)
+	return args.Get(0).([]models.SubcategoryStats), args.Error(1)
+}
+
+func (m *MockRepository) GetHourlyRevenue(start, end time.Time) ([]models.RevenuePoint, error) {
+	args := m.Called(start, end)
+	return args.Get(0).([]models.RevenuePoint), args.Error(1)
+}
+
+func (m *MockRepository) GetDriverCashChange(start, end time.Time) (models.Money, error) {
+	args := m.Called(start, end)
+	return args.Get(0).(models.Money), args.Error(1)
+}
+
+func (m *MockRepository) GetTopReferrers(start, end time.Time, limit int) ([]models.ReferrerStats, error) {
+	args := m.Called(start, end, limit)
+	return args.Get(0).([]models.ReferrerStats), args.Error(1)
+}
+
+func (m *MockRepository) GetCampaignStats(start, end time.Time) ([]models.CampaignStats, error) {
+	args := m.Called(start, end)
+	return args.Get(0).([]models.CampaignStats), args.Error(1)
+}
+
+func (m *MockRepository) GetEscalationStats(start, end time.Time) (models.EscalationStats, error) {
+	args := m.Called(start, end)
+	return args.Get(0).(models.EscalationStats), args.Error(1)
+}
+
+func (m *MockRepository) GetExecutorRanking(start, end, prevStart time.Time, limit int) ([]models.ExecutorRating, error) {
+	args := m.Called(start, end, prevStart, limit)
+	return args.Get(0).([]models.ExecutorRating), args.Error(1)
+}
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s is not available: %v", name, err)
+	}
+	return loc
+}
+
+func TestWeekPeriod(t *testing.T) {
+	// Week 1 of 2025 starts in the previous calendar year
+	week, err := stats.WeekPeriod(2025, 1, time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC), week.Start)
+	assert.Equal(t, time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC), week.End)
+
+	// 2020 has 53 ISO weeks, 2021 has 52
+	assert.Equal(t, 53, stats.ISOWeeksInYear(2020))
+	assert.Equal(t, 52, stats.ISOWeeksInYear(2021))
+	week, err = stats.WeekPeriod(2020, 53, time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 12, 28, 0, 0, 0, 0, time.UTC), week.Start)
+
+	_, err = stats.WeekPeriod(2021, 53, time.UTC)
+	assert.Error(t, err)
+	_, err = stats.WeekPeriod(2021, 0, time.UTC)
+	assert.Error(t, err)
+
+	// 1 January 2021 belongs to the last week of 2020
+	week = stats.WeekPeriodOf(time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC), time.UTC)
+	assert.Equal(t, time.Date(2020, 12, 28, 0, 0, 0, 0, time.UTC), week.Start)
+}
+
+func TestDayPeriodInBusinessZone(t *testing.T) {
+	loc := mustLoad(t, "Asia/Vladivostok")
+
+	// 20:00 UTC is already the next day in Vladivostok (UTC+10)
+	day := stats.DayPeriod(time.Date(2024, 3, 10, 20, 0, 0, 0, time.UTC), loc)
+	assert.Equal(t, time.Date(2024, 3, 11, 0, 0, 0, 0, loc), day.Start)
+	assert.Equal(t, time.Date(2024, 3, 12, 0, 0, 0, 0, loc), day.End)
+	assert.Equal(t, "11.03.2024", day.Label())
+}
+
+func TestPeriodPrevious(t *testing.T) {
+	march := stats.MonthPeriod(2024, time.March, time.UTC)
+	prev, ok := march.Previous()
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), prev.Start)
+	assert.Equal(t, march.Start, prev.End)
+	assert.Equal(t, "февраль 2024", prev.Label())
+
+	// A custom range is compared with as many days right before it
+	rng, err := stats.RangePeriod(time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC), time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, rng.Days())
+	prev, ok = rng.Previous()
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), prev.Start)
+	assert.Equal(t, "05.03.2024 — 09.03.2024", prev.Label())
+
+	_, err = stats.RangePeriod(rng.End, rng.Start, time.UTC)
+	assert.Error(t, err)
+
+	_, ok = stats.Period{Kind: stats.PeriodAll}.Previous()
+	assert.False(t, ok)
+}
+
+func TestWeeksOfMonth(t *testing.T) {
+	// March 2024 starts on a Friday and ends on a Sunday
+	weeks := stats.WeeksOfMonth(2024, time.March, time.UTC)
+	assert.Len(t, weeks, 5)
+	assert.Equal(t, time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC), weeks[0].Start)
+	assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), weeks[4].End)
+}
+
+func TestGetStatsReport(t *testing.T) {
+	repo := new(MockRepository)
+	service := stats.NewService(repo, time.UTC)
+	march := stats.MonthPeriod(2024, time.March, time.UTC)
+	february, _ := march.Previous()
+	start, end := march.Start.In(time.Local), march.End.In(time.Local)
+	prevStart, prevEnd := february.Start.In(time.Local), february.End.In(time.Local)
+
+	repo.On("GetOrderKPIs", start, end).Return(models.OrderKPIs{
+		Created: 4, Completed: 2, Canceled: 1, Revenue: 8000, AvgCompletionHours: 5.5, Clients: 2, RepeatClients: 1,
+	}, nil)
+	repo.On("GetSubcategoryStats", start, end).Return([]models.SubcategoryStats{
+		{Category: "демонтаж", Subcategory: "стены", Orders: 1, Revenue: 5000},
+		{Category: "waste_removal", Subcategory: "строительный мусор", Orders: 1, Revenue: 3000},
+	}, nil)
+	repo.On("GetCancellationReasons", start, end).Return([]models.CancellationReason{{Reason: "", Orders: 1}}, nil)
+	repo.On("GetExecutorRevenue", start, end).Return([]models.ExecutorRevenue{
+		{UserID: 7, Name: "Олег", Orders: 2, Revenue: 8000},
+		{UserID: 8, Orders: 1, Revenue: 5000},
+	}, nil)
+	repo.On("GetDriverCashChange", start, end).Return(models.NewMoney(1500), nil)
+	repo.On("GetEscalationStats", start, end).Return(models.EscalationStats{Opened: 1}, nil)
+
+	repo.On("GetOrderKPIs", prevStart, prevEnd).Return(models.OrderKPIs{Created: 1, Completed: 1, Revenue: 4000, Clients: 1}, nil)
+	repo.On("GetSubcategoryStats", prevStart, prevEnd).Return([]models.SubcategoryStats{}, nil)
+	repo.On("GetCancellationReasons", prevStart, prevEnd).Return([]models.CancellationReason{}, nil)
+	repo.On("GetExecutorRevenue", prevStart, prevEnd).Return([]models.ExecutorRevenue{}, nil)
+	repo.On("GetDriverCashChange", prevStart, prevEnd).Return(models.Money(0), nil)
+	repo.On("GetEscalationStats", prevStart, prevEnd).Return(models.EscalationStats{}, nil)
+
+	report, err := service.GetStatsReport(march)
+	assert.NoError(t, err)
+	assert.Equal(t, "март 2024", report.Title)
+	assert.Equal(t, "февраль 2024", report.PreviousTitle)
+
+	cur := report.Current
+	assert.Equal(t, 2, cur.TotalOrders)
+	assert.Equal(t, 1, cur.WasteRemovalOrders)
+	assert.Equal(t, 1, cur.DemolitionOrders)
+	assert.Equal(t, 8000.0, cur.TotalAmount)
+	assert.Equal(t, 1500.0, cur.DriverDebts)
+	assert.Equal(t, 4000.0, cur.AverageCheck)
+	assert.Equal(t, 50.0, cur.ConversionRate)
+	assert.Equal(t, 25.0, cur.CancellationRate)
+	assert.Equal(t, 50.0, cur.RepeatClientShare)
+	assert.Equal(t, 4000.0, cur.RevenuePerExecutor)
+	if assert.NotNil(t, report.Previous) {
+		assert.Equal(t, 1, report.Previous.TotalOrders)
+		assert.Equal(t, 100.0, report.Previous.ConversionRate)
+	}
+
+	text := stats.FormatStatsReport(report)
+	assert.Contains(t, text, "Всего заказов: 2 (было 1, ▲ +100%)")
+	assert.Contains(t, text, "Сумма: 8000.00 руб. (было 4000.00 руб., ▲ +100%)")
+	assert.Contains(t, text, "Стройматериалы: 0 (было 0, без изменений)")
+	assert.Contains(t, text, "Средний чек: 4000.00 руб. (было 4000.00 руб., без изменений)")
+	assert.Contains(t, text, "Конверсия: 50.0% (было 100.0%, ▼ -50%) (2 из 4)")
+	assert.Contains(t, text, "- без причины: 1")
+	assert.Contains(t, text, "- демонтаж / стены: 1, 5000.00 руб.")
+	assert.Contains(t, text, "- ID 8: 1 зак., 5000.00 руб.")
+	repo.AssertExpectations(t)
+}
+
+func TestGetStatsReportAllTimeHasNoComparison(t *testing.T) {
+	repo := new(MockRepository)
+	service := stats.NewService(repo, time.UTC)
+	period, err := service.CurrentPeriod(stats.PeriodAll)
+	assert.NoError(t, err)
+
+	repo.On("GetOrderKPIs", mock.Anything, mock.Anything).Return(models.OrderKPIs{}, nil)
+	repo.On("GetSubcategoryStats", mock.Anything, mock.Anything).Return([]models.SubcategoryStats{}, nil)
+	repo.On("GetCancellationReasons", mock.Anything, mock.Anything).Return([]models.CancellationReason{}, nil)
+	repo.On("GetExecutorRevenue", mock.Anything, mock.Anything).Return([]models.ExecutorRevenue{}, nil)
+	repo.On("GetDriverCashChange", mock.Anything, mock.Anything).Return(models.Money(0), nil)
+	repo.On("GetEscalationStats", mock.Anything, mock.Anything).Return(models.EscalationStats{}, nil)
+
+	report, err := service.GetStatsReport(period)
+	assert.NoError(t, err)
+	assert.Nil(t, report.Previous)
+	assert.Zero(t, report.Current.AverageCheck)
+	assert.Zero(t, report.Current.ConversionRate)
+	assert.False(t, strings.Contains(stats.FormatStatsReport(report), "было"))
+	repo.AssertNumberOfCalls(t, "GetOrderKPIs", 1)
+}
+
+func TestGetStatsReportError(t *testing.T) {
+	repo := new(MockRepository)
+	service := stats.NewService(repo, time.UTC)
+
+	repo.On("GetOrderKPIs", mock.Anything, mock.Anything).Return(models.OrderKPIs{}, errors.New("db down"))
+
+	_, err := service.GetStatsReport(stats.MonthPeriod(2024, time.March, time.UTC))
+	assert.Error(t, err)
+}
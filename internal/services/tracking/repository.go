@@ -0,0 +1,199 @@
+package tracking
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/db"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/geo"
+	"github.com/skyzeper/telegram-bot/internal/utils"
+)
+
+// PostgresRepository implements the Repository interface for PostgreSQL
+type PostgresRepository struct {
+	db *db.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL repository
+func NewPostgresRepository(db *db.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// IsAssigned reports whether a user is an executor of an order
+func (r *PostgresRepository) IsAssigned(orderID int, userID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM executors WHERE order_id = $1 AND user_id = $2)
+	`
+	var assigned bool
+	if err := r.db.Conn().QueryRow(query, orderID, userID).Scan(&assigned); err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to check executor: %v", err)
+	}
+	return assigned, nil
+}
+
+// StartTrip starts or restarts the trip to an order and marks the order as on the way,
+// reporting false when work on the order has started or it is closed
+func (r *PostgresRepository) StartTrip(orderID int, userID int64, at time.Time) (bool, error) {
+	tx, err := r.db.Conn().Begin()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	updated, err := setTripStatus(tx, orderID, StatusOnTheWay, at)
+	if err != nil || !updated {
+		return false, err
+	}
+	query := `
+		INSERT INTO trips (order_id, user_id, started_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (order_id) DO UPDATE
+		SET user_id = EXCLUDED.user_id, started_at = EXCLUDED.started_at, ended_at = NULL,
+		    latitude = NULL, longitude = NULL, located_at = NULL, live_until = NULL
+	`
+	if _, err := tx.Exec(query, orderID, userID, at); err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to start trip: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to commit trip: %v", err)
+	}
+	return true, nil
+}
+
+// FinishTrip ends the trip to an order, if any, and marks the order as in progress,
+// reporting false when work on the order has already started or it is closed
+func (r *PostgresRepository) FinishTrip(orderID int, at time.Time) (bool, error) {
+	tx, err := r.db.Conn().Begin()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	updated, err := setTripStatus(tx, orderID, StatusInProgress, at)
+	if err != nil || !updated {
+		return false, err
+	}
+	query := `UPDATE trips SET ended_at = $1 WHERE order_id = $2 AND ended_at IS NULL`
+	if _, err := tx.Exec(query, at, orderID); err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to finish trip: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to commit trip: %v", err)
+	}
+	return true, nil
+}
+
+// setTripStatus moves an order to a trip status unless work on it has started or it is closed
+func setTripStatus(tx *sql.Tx, orderID int, status string, at time.Time) (bool, error) {
+	query := `
+		UPDATE orders SET status = $1, updated_at = $2
+		WHERE id = $3 AND status NOT IN ('in_progress', 'completed', 'canceled')
+	`
+	result, err := tx.Exec(query, status, at, orderID)
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to update order status: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(err)
+		return false, fmt.Errorf("failed to update order status: %v", err)
+	}
+	return affected == 1, nil
+}
+
+// GetActiveTrip retrieves the latest unfinished trip of an executor to an order still on the way
+func (r *PostgresRepository) GetActiveTrip(userID int64) (*models.Trip, error) {
+	query := `
+		SELECT t.order_id, t.user_id, t.started_at, t.ended_at, t.latitude, t.longitude, t.located_at, t.live_until
+		FROM trips t
+		JOIN orders o ON o.id = t.order_id
+		WHERE t.user_id = $1 AND t.ended_at IS NULL AND o.status = $2
+		ORDER BY t.started_at DESC
+		LIMIT 1
+	`
+	return r.scanTrip(r.db.Conn().QueryRow(query, userID, StatusOnTheWay))
+}
+
+// GetTrip retrieves the trip to an order
+func (r *PostgresRepository) GetTrip(orderID int) (*models.Trip, error) {
+	query := `
+		SELECT order_id, user_id, started_at, ended_at, latitude, longitude, located_at, live_until
+		FROM trips
+		WHERE order_id = $1
+	`
+	return r.scanTrip(r.db.Conn().QueryRow(query, orderID))
+}
+
+// scanTrip reads a trip row, returning nil when there is none
+func (r *PostgresRepository) scanTrip(row *sql.Row) (*models.Trip, error) {
+	trip := &models.Trip{}
+	var endedAt, locatedAt, liveUntil sql.NullTime
+	var lat, lon sql.NullFloat64
+	err := row.Scan(&trip.OrderID, &trip.UserID, &trip.StartedAt, &endedAt, &lat, &lon, &locatedAt, &liveUntil)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		utils.LogError(err)
+		return nil, fmt.Errorf("failed to get trip: %v", err)
+	}
+	if endedAt.Valid {
+		trip.EndedAt = &endedAt.Time
+	}
+	if lat.Valid && lon.Valid && locatedAt.Valid {
+		trip.Latitude = lat.Float64
+		trip.Longitude = lon.Float64
+		trip.LocatedAt = &locatedAt.Time
+	}
+	if liveUntil.Valid {
+		trip.LiveUntil = &liveUntil.Time
+	}
+	return trip, nil
+}
+
+// RecordLocation stores a location in the trip history and as the trip's last position;
+// a nil liveUntil keeps the live period of an earlier update
+func (r *PostgresRepository) RecordLocation(orderID int, userID int64, point geo.Point, at time.Time, liveUntil *time.Time) error {
+	tx, err := r.db.Conn().Begin()
+	if err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO trip_locations (order_id, user_id, latitude, longitude, recorded_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	if _, err := tx.Exec(query, orderID, userID, point.Lat, point.Lon, at); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to record location: %v", err)
+	}
+	var until sql.NullTime
+	if liveUntil != nil {
+		until = sql.NullTime{Time: *liveUntil, Valid: true}
+	}
+	query = `
+		UPDATE trips
+		SET latitude = $1, longitude = $2, located_at = $3, live_until = COALESCE($4, live_until)
+		WHERE order_id = $5
+	`
+	if _, err := tx.Exec(query, point.Lat, point.Lon, at, until, orderID); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to update trip location: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		utils.LogError(err)
+		return fmt.Errorf("failed to commit location: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package tracking
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/geo"
+	"github.com/skyzeper/telegram-bot/internal/services/route"
+)
+
+// Order statuses set by executors
+const (
+	StatusOnTheWay   = "on_the_way"  // the executor is driving to the client
+	StatusInProgress = "in_progress" // the executor has started the job
+)
+
+var (
+	// ErrNotAssigned is returned when a user who is not an executor of the order updates its trip
+	ErrNotAssigned = errors.New("executor is not assigned to the order")
+	// ErrNoActiveTrip is returned when nobody is on the way to the order
+	ErrNoActiveTrip = errors.New("no active trip")
+	// ErrOrderClosed is returned for completed or canceled orders
+	ErrOrderClosed = errors.New("order is closed")
+	// ErrWorkStarted is returned when the order moved past the trip, possibly by another executor at the same time
+	ErrWorkStarted = errors.New("work on the order has already started")
+)
+
+// Config holds live tracking settings
+type Config struct {
+	// StaleAfter is how old a position may get before the client is warned it is outdated
+	StaleAfter time.Duration
+}
+
+// Service tracks executors driving to orders by their shared live location
+type Service struct {
+	repo   Repository
+	matrix route.DistanceMatrix
+	cfg    Config
+}
+
+// Repository defines the interface for tracking data access
+type Repository interface {
+	IsAssigned(orderID int, userID int64) (bool, error)
+	StartTrip(orderID int, userID int64, at time.Time) (bool, error)
+	FinishTrip(orderID int, at time.Time) (bool, error)
+	GetActiveTrip(userID int64) (*models.Trip, error)
+	GetTrip(orderID int) (*models.Trip, error)
+	RecordLocation(orderID int, userID int64, point geo.Point, at time.Time, liveUntil *time.Time) error
+}
+
+// NewService creates a new tracking service; a nil matrix estimates the ETA from straight-line distances
+func NewService(repo Repository, matrix route.DistanceMatrix, cfg Config) *Service {
+	if matrix == nil {
+		matrix = route.DefaultMatrix()
+	}
+	if cfg.StaleAfter <= 0 {
+		cfg.StaleAfter = 10 * time.Minute
+	}
+	return &Service{
+		repo:   repo,
+		matrix: matrix,
+		cfg:    cfg,
+	}
+}
+
+// StartTrip marks the order as on the way and starts accepting the executor's locations
+func (s *Service) StartTrip(order *models.Order, userID int64) error {
+	if err := s.checkExecutor(order, userID); err != nil {
+		return err
+	}
+	started, err := s.repo.StartTrip(order.ID, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !started {
+		return ErrWorkStarted
+	}
+	return nil
+}
+
+// FinishTrip marks the order as in progress and stops tracking when the executor starts the job
+func (s *Service) FinishTrip(order *models.Order, userID int64) error {
+	if err := s.checkExecutor(order, userID); err != nil {
+		return err
+	}
+	finished, err := s.repo.FinishTrip(order.ID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !finished {
+		return ErrWorkStarted
+	}
+	return nil
+}
+
+// checkExecutor ensures the order is open and the user is one of its executors
+func (s *Service) checkExecutor(order *models.Order, userID int64) error {
+	if order.Status == "completed" || order.Status == "canceled" {
+		return ErrOrderClosed
+	}
+	assigned, err := s.repo.IsAssigned(order.ID, userID)
+	if err != nil {
+		return err
+	}
+	if !assigned {
+		return ErrNotAssigned
+	}
+	return nil
+}
+
+// RecordLocation stores an executor's location for the trip in progress and returns its order ID;
+// livePeriod is the live location duration in seconds, 0 for a single location or an update
+func (s *Service) RecordLocation(userID int64, point geo.Point, livePeriod int, at time.Time) (int, error) {
+	if !point.Valid() {
+		return 0, errors.New("invalid coordinates")
+	}
+	trip, err := s.repo.GetActiveTrip(userID)
+	if err != nil {
+		return 0, err
+	}
+	if trip == nil {
+		return 0, ErrNoActiveTrip
+	}
+	var liveUntil *time.Time
+	if livePeriod > 0 {
+		until := at.Add(time.Duration(livePeriod) * time.Second)
+		liveUntil = &until
+	}
+	if err := s.repo.RecordLocation(trip.OrderID, userID, point, at, liveUntil); err != nil {
+		return 0, err
+	}
+	return trip.OrderID, nil
+}
+
+// Position is the executor's last known location on the way to an order
+type Position struct {
+	Trip *models.Trip
+	// Point and At are the last location and when it was received; zero until the executor shares it
+	Point geo.Point
+	At    time.Time
+	// Stale is set when the location is outdated or the live sharing has ended
+	Stale bool
+	// Leg is the remaining drive to the order, set when both points are known
+	Leg *route.Leg
+}
+
+// Locate returns where the executor driving to the order is and how far they are
+func (s *Service) Locate(order *models.Order, now time.Time) (*Position, error) {
+	if order.Status != StatusOnTheWay {
+		return nil, ErrNoActiveTrip
+	}
+	trip, err := s.repo.GetTrip(order.ID)
+	if err != nil {
+		return nil, err
+	}
+	if trip == nil || trip.EndedAt != nil {
+		return nil, ErrNoActiveTrip
+	}
+
+	pos := &Position{Trip: trip}
+	if !trip.HasLocation() {
+		return pos, nil
+	}
+	pos.Point = geo.Point{Lat: trip.Latitude, Lon: trip.Longitude}
+	pos.At = *trip.LocatedAt
+	// A parked executor sends no updates while live sharing is on, so only old positions without it are stale
+	live := trip.LiveUntil != nil && now.Before(*trip.LiveUntil)
+	pos.Stale = !live && now.Sub(pos.At) > s.cfg.StaleAfter
+
+	if order.HasLocation() {
+		matrix, err := s.matrix.Matrix([]geo.Point{pos.Point, {Lat: order.Latitude, Lon: order.Longitude}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to estimate arrival: %v", err)
+		}
+		pos.Leg = &matrix[0][1]
+	}
+	return pos, nil
+}
+
+// FormatPosition renders the executor's position and a rough ETA for the client
+func FormatPosition(order *models.Order, pos *Position, now time.Time) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🚚 Заказ #%d: исполнитель выехал в %s.", order.ID, pos.Trip.StartedAt.Format("15:04")))
+	if pos.At.IsZero() {
+		sb.WriteString("\n📍 Исполнитель ещё не поделился геопозицией, попробуйте чуть позже.")
+		return sb.String()
+	}
+
+	sb.WriteString(fmt.Sprintf("\n📍 Геопозиция обновлена %s.", ago(now.Sub(pos.At))))
+	if pos.Leg != nil {
+		arrival := pos.At.Add(pos.Leg.Duration)
+		if minutes := int(arrival.Sub(now).Round(time.Minute).Minutes()); minutes >= 1 {
+			sb.WriteString(fmt.Sprintf("\n🛣 До вас ~%.1f км, прибытие примерно через %d мин (~%s).",
+				pos.Leg.Meters/1000, minutes, arrival.Format("15:04")))
+		} else {
+			sb.WriteString(fmt.Sprintf("\n🛣 До вас ~%.1f км, исполнитель вот-вот будет на месте.", pos.Leg.Meters/1000))
+		}
+	}
+	if pos.Stale {
+		sb.WriteString("\n⚠️ Геопозиция давно не обновлялась, оценка может быть неточной.")
+	}
+	return sb.String()
+}
+
+// ago renders how long ago something happened
+func ago(d time.Duration) string {
+	if d < time.Minute {
+		return "только что"
+	}
+	if d < time.Hour {
+		return fmt.Sprintf("%d мин назад", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%d ч %d мин назад", int(d.Hours()), int(d.Minutes())%60)
+}
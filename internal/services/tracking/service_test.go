@@ -0,0 +1,145 @@
+package tracking_test
+
+import (
+	"testing"
+	"time"
+	"github.com/skyzeper/telegram-bot/internal/models"
+	"github.com/skyzeper/telegram-bot/internal/services/geo"
+	"github.com/skyzeper/telegram-bot/internal/services/route"
+	"github.com/skyzeper/telegram-bot/internal/services/tracking"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRepository is a mock implementation of tracking.Repository
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) IsAssigned(orderID int, userID int64) (bool, error) {
+	args := m.Called(orderID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) StartTrip(orderID int, userID int64, at time.Time) (bool, error) {
+	args := m.Called(orderID, userID, at)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) FinishTrip(orderID int, at time.Time) (bool, error) {
+	args := m.Called(orderID, at)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetActiveTrip(userID int64) (*models.Trip, error) {
+	args := m.Called(userID)
+	trip, _ := args.Get(0).(*models.Trip)
+	return trip, args.Error(1)
+}
+
+func (m *MockRepository) GetTrip(orderID int) (*models.Trip, error) {
+	args := m.Called(orderID)
+	trip, _ := args.Get(0).(*models.Trip)
+	return trip, args.Error(1)
+}
+
+func (m *MockRepository) RecordLocation(orderID int, userID int64, point geo.Point, at time.Time, liveUntil *time.Time) error {
+	args := m.Called(orderID, userID, point, at, liveUntil)
+	return args.Error(0)
+}
+
+func TestStartTripRequiresExecutor(t *testing.T) {
+	repo := new(MockRepository)
+	service := tracking.NewService(repo, nil, tracking.Config{})
+	order := &models.Order{ID: 5, Status: "assigned"}
+
+	repo.On("IsAssigned", 5, int64(2)).Return(false, nil)
+	assert.ErrorIs(t, service.StartTrip(order, 2), tracking.ErrNotAssigned)
+
+	repo.On("IsAssigned", 5, int64(1)).Return(true, nil)
+	repo.On("StartTrip", 5, int64(1), mock.AnythingOfType("time.Time")).Return(true, nil).Once()
+	assert.NoError(t, service.StartTrip(order, 1))
+
+	assert.ErrorIs(t, service.StartTrip(&models.Order{ID: 6, Status: "completed"}, 1), tracking.ErrOrderClosed)
+	repo.AssertExpectations(t)
+}
+
+func TestTripRejectedAfterWorkStarted(t *testing.T) {
+	repo := new(MockRepository)
+	service := tracking.NewService(repo, nil, tracking.Config{})
+	// The order was loaded before another executor started the job
+	order := &models.Order{ID: 5, Status: tracking.StatusOnTheWay}
+
+	repo.On("IsAssigned", 5, int64(1)).Return(true, nil)
+	repo.On("StartTrip", 5, int64(1), mock.AnythingOfType("time.Time")).Return(false, nil).Once()
+	repo.On("FinishTrip", 5, mock.AnythingOfType("time.Time")).Return(false, nil).Once()
+
+	assert.ErrorIs(t, service.StartTrip(order, 1), tracking.ErrWorkStarted)
+	assert.ErrorIs(t, service.FinishTrip(order, 1), tracking.ErrWorkStarted)
+	repo.AssertExpectations(t)
+}
+
+func TestRecordLocation(t *testing.T) {
+	repo := new(MockRepository)
+	service := tracking.NewService(repo, nil, tracking.Config{})
+	at := time.Date(2024, 3, 13, 10, 0, 0, 0, time.UTC)
+	point := geo.Point{Lat: 55.75, Lon: 37.61}
+
+	repo.On("GetActiveTrip", int64(2)).Return(nil, nil)
+	_, err := service.RecordLocation(2, point, 900, at)
+	assert.ErrorIs(t, err, tracking.ErrNoActiveTrip)
+
+	repo.On("GetActiveTrip", int64(1)).Return(&models.Trip{OrderID: 5, UserID: 1}, nil)
+	until := at.Add(15 * time.Minute)
+	repo.On("RecordLocation", 5, int64(1), point, at, &until).Return(nil).Once()
+	orderID, err := service.RecordLocation(1, point, 900, at)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, orderID)
+
+	// Live location updates keep the period of the first message
+	repo.On("RecordLocation", 5, int64(1), point, at, (*time.Time)(nil)).Return(nil).Once()
+	_, err = service.RecordLocation(1, point, 0, at)
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestLocate(t *testing.T) {
+	repo := new(MockRepository)
+	service := tracking.NewService(repo, route.StraightLine{Speed: 36, Detour: 1}, tracking.Config{StaleAfter: 10 * time.Minute})
+	now := time.Date(2024, 3, 13, 10, 0, 0, 0, time.UTC)
+	order := &models.Order{ID: 5, Status: tracking.StatusOnTheWay, Latitude: 55.75, Longitude: 37.70}
+
+	// No location shared yet
+	repo.On("GetTrip", 5).Return(&models.Trip{OrderID: 5, StartedAt: now.Add(-5 * time.Minute)}, nil).Once()
+	pos, err := service.Locate(order, now)
+	assert.NoError(t, err)
+	assert.Nil(t, pos.Leg)
+	assert.Contains(t, tracking.FormatPosition(order, pos, now), "ещё не поделился геопозицией")
+
+	// About 6.3 km away at 36 km/h is about 10 minutes
+	located := now.Add(-time.Minute)
+	repo.On("GetTrip", 5).Return(&models.Trip{
+		OrderID: 5, StartedAt: now.Add(-5 * time.Minute), Latitude: 55.75, Longitude: 37.60, LocatedAt: &located,
+	}, nil).Once()
+	pos, err = service.Locate(order, now)
+	assert.NoError(t, err)
+	assert.False(t, pos.Stale)
+	assert.InDelta(t, 6270, pos.Leg.Meters, 50)
+	text := tracking.FormatPosition(order, pos, now)
+	assert.Contains(t, text, "выехал в 09:55")
+	assert.Contains(t, text, "обновлена 1 мин назад")
+	assert.Contains(t, text, "До вас ~6.3 км, прибытие примерно через 9 мин (~10:09)")
+
+	// Old positions are stale once live sharing has ended
+	located = now.Add(-20 * time.Minute)
+	repo.On("GetTrip", 5).Return(&models.Trip{
+		OrderID: 5, StartedAt: now.Add(-30 * time.Minute), Latitude: 55.75, Longitude: 37.60, LocatedAt: &located, LiveUntil: &located,
+	}, nil).Once()
+	pos, err = service.Locate(order, now)
+	assert.NoError(t, err)
+	assert.True(t, pos.Stale)
+
+	// Tracking stops when the job has started
+	_, err = service.Locate(&models.Order{ID: 5, Status: tracking.StatusInProgress}, now)
+	assert.ErrorIs(t, err, tracking.ErrNoActiveTrip)
+}
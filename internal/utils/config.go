@@ -3,6 +3,8 @@ package utils
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 )
 
 // Config holds application configuration
@@ -14,10 +16,69 @@ type Config struct {
 	DBUser     string
 	DBPassword string
 	DBName     string
+
+	ErrorLogFile string
+
+	QRSize          int
+	QRRecoveryLevel string
+	QRLogoPath      string
+	QRCaption       string
+
+	ReviewChannelID           int64
+	ReviewModerationMaxRating int
+	ReviewRequestDelay        time.Duration
+	ReviewReminderDelay       time.Duration
+
+	PaymentProviderToken string
+	PaymentCurrency      string
+	PaymentLinkProvider  string
+	PaymentWebhookAddr   string
+	PaymentWebhookSecret string
+	PaymentPublicURL     string
+
+	FiscalProvider string
+	FiscalVATMode  string
+
+	AccountingCompanyName string
+	AccountingCompanyINN  string
+	AccountingBankAccount string
+
+	BusinessTimezone string
+	ServiceAreasFile string
+
+	RouteDepot        string
+	RouteAverageSpeed float64
+	RouteDayStart     time.Duration
+	RouteServiceTime  time.Duration
+	RouteSlotWindow   time.Duration
+
+	TrackingStaleAfter time.Duration
+
+	FleetReminderLead    time.Duration
+	FleetReminderMileage int
+
+	Digests         string
+	DigestDailyAt   string
+	DigestWeeklyAt  string
+	DigestMonthlyAt string
 }
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() (*Config, error) {
+	cfg := loadEnv()
+	if cfg.BotToken == "" {
+		return nil, fmt.Errorf("BOT_TOKEN is required")
+	}
+	return cfg, nil
+}
+
+// LoadToolConfig loads configuration for command-line tools, which use the database but not the bot
+func LoadToolConfig() *Config {
+	return loadEnv()
+}
+
+// loadEnv reads every setting from environment variables and applies the database and log defaults
+func loadEnv() *Config {
 	cfg := &Config{
 		BotToken:   os.Getenv("BOT_TOKEN"),
 		BotDebug:   os.Getenv("BOT_DEBUG") == "true",
@@ -26,11 +87,53 @@ func LoadConfig() (*Config, error) {
 		DBUser:     os.Getenv("DB_USER"),
 		DBPassword: os.Getenv("DB_PASSWORD"),
 		DBName:     os.Getenv("DB_NAME"),
-	}
 
-	if cfg.BotToken == "" {
-		return nil, fmt.Errorf("BOT_TOKEN is required")
+		ErrorLogFile: os.Getenv("ERROR_LOG_FILE"),
+
+		QRSize:          parseInt(os.Getenv("QR_SIZE")),
+		QRRecoveryLevel: os.Getenv("QR_RECOVERY_LEVEL"),
+		QRLogoPath:      os.Getenv("QR_LOGO_PATH"),
+		QRCaption:       os.Getenv("QR_CAPTION"),
+
+		ReviewChannelID:           parseInt64(os.Getenv("REVIEW_CHANNEL_ID")),
+		ReviewModerationMaxRating: parseInt(os.Getenv("REVIEW_MODERATION_MAX_RATING")),
+		ReviewRequestDelay:        parseDuration(os.Getenv("REVIEW_REQUEST_DELAY")),
+		ReviewReminderDelay:       parseDuration(os.Getenv("REVIEW_REMINDER_DELAY")),
+
+		PaymentProviderToken: os.Getenv("PAYMENT_PROVIDER_TOKEN"),
+		PaymentCurrency:      os.Getenv("PAYMENT_CURRENCY"),
+		PaymentLinkProvider:  os.Getenv("PAYMENT_LINK_PROVIDER"),
+		PaymentWebhookAddr:   os.Getenv("PAYMENT_WEBHOOK_ADDR"),
+		PaymentWebhookSecret: os.Getenv("PAYMENT_WEBHOOK_SECRET"),
+		PaymentPublicURL:     os.Getenv("PAYMENT_PUBLIC_URL"),
+
+		FiscalProvider: os.Getenv("FISCAL_PROVIDER"),
+		FiscalVATMode:  os.Getenv("FISCAL_VAT_MODE"),
+
+		AccountingCompanyName: os.Getenv("ACCOUNTING_COMPANY_NAME"),
+		AccountingCompanyINN:  os.Getenv("ACCOUNTING_COMPANY_INN"),
+		AccountingBankAccount: os.Getenv("ACCOUNTING_BANK_ACCOUNT"),
+
+		BusinessTimezone: os.Getenv("BUSINESS_TIMEZONE"),
+		ServiceAreasFile: os.Getenv("SERVICE_AREAS_FILE"),
+
+		RouteDepot:        os.Getenv("ROUTE_DEPOT"),
+		RouteAverageSpeed: parseFloat(os.Getenv("ROUTE_AVERAGE_SPEED")),
+		RouteDayStart:     parseDuration(os.Getenv("ROUTE_DAY_START")),
+		RouteServiceTime:  parseDuration(os.Getenv("ROUTE_SERVICE_TIME")),
+		RouteSlotWindow:   parseDuration(os.Getenv("ROUTE_SLOT_WINDOW")),
+
+		TrackingStaleAfter: parseDuration(os.Getenv("TRACKING_STALE_AFTER")),
+
+		FleetReminderLead:    parseDuration(os.Getenv("FLEET_REMINDER_LEAD")),
+		FleetReminderMileage: parseInt(os.Getenv("FLEET_REMINDER_MILEAGE")),
+
+		Digests:         os.Getenv("DIGESTS"),
+		DigestDailyAt:   os.Getenv("DIGEST_DAILY_AT"),
+		DigestWeeklyAt:  os.Getenv("DIGEST_WEEKLY_AT"),
+		DigestMonthlyAt: os.Getenv("DIGEST_MONTHLY_AT"),
 	}
+
 	if cfg.DBHost == "" {
 		cfg.DBHost = "localhost"
 	}
@@ -46,6 +149,33 @@ func LoadConfig() (*Config, error) {
 	if cfg.DBName == "" {
 		cfg.DBName = "telegram_bot"
 	}
+	if cfg.ErrorLogFile == "" {
+		cfg.ErrorLogFile = "error.log"
+	}
 
-	return cfg, nil
+	return cfg
+}
+
+// parseInt parses an optional integer setting, returning 0 when unset or invalid
+func parseInt(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// parseInt64 parses an optional 64-bit integer setting such as a chat ID, returning 0 when unset or invalid
+func parseInt64(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+// parseFloat parses an optional decimal setting such as a speed, returning 0 when unset or invalid
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// parseDuration parses an optional duration setting such as "2h" or "30m", returning 0 when unset or invalid
+func parseDuration(s string) time.Duration {
+	d, _ := time.ParseDuration(s)
+	return d
 }
\ No newline at end of file
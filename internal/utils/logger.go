@@ -3,13 +3,34 @@ package utils
 import (
 	"log"
 	"os"
+	"sync"
 )
 
+var (
+	logMu   sync.Mutex
+	logPath = "error.log"
+)
+
+// SetLogFile sets the file errors are appended to; an empty path logs to the console only
+func SetLogFile(path string) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	logPath = path
+}
+
 // LogError logs an error to console and file
 func LogError(err error) {
 	log.Println("ERROR:", err)
-	f, _ := os.OpenFile("error.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	logMu.Lock()
+	defer logMu.Unlock()
+	if logPath == "" {
+		return
+	}
+	f, openErr := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if openErr != nil {
+		log.Println("ERROR: failed to open error log:", openErr)
+		return
+	}
 	defer f.Close()
-	log.SetOutput(f)
-	log.Println("ERROR:", err)
+	log.New(f, "", log.LstdFlags).Println("ERROR:", err)
 }
\ No newline at end of file